@@ -29,6 +29,12 @@
 #define ETH_P_IP	0x0800		/* Internet Protocol packet	*/
 // ETH_P_IPV6 value as defined in IEEE 802: https://www.iana.org/assignments/ieee-802-numbers/ieee-802-numbers.xhtml
 #define ETH_P_IPV6	0x86DD		/* IPv6 over bluebook		*/
+#ifndef AF_INET
+#define AF_INET		2	/* Internet IP Protocol 	*/
+#endif
+#ifndef AF_INET6
+#define AF_INET6	10	/* IP version 6			    */
+#endif
 typedef __u8 u8;
 typedef __u16 u16;
 typedef __u32 u32;
@@ -44,6 +50,10 @@ typedef struct flow_metrics_t {
     u64 end_mono_time_ns;
     // TCP Flags from https://www.ietf.org/rfc/rfc793.txt
     u16 flags;
+    // number of packets with the RST flag
+    u32 resets;
+    // number of connection attempts: packets with the SYN flag but not the ACK flag
+    u32 syns;
     // The positive errno of a failed map insertion that caused a flow
     // to be sent via ringbuffer.
     // 0 otherwise
@@ -78,4 +88,55 @@ typedef struct flow_record_t {
     flow_metrics metrics;
 } __attribute__((packed)) flow_record;
 
+// Identifies a TCP connection from the point of view of the packets that are sent
+// from src to dst. It matches the addresses and ports of the flow_id of the flows
+// that carry the packets of the connection in that direction.
+// Contents in this struct must match byte-by-byte with Go's ebpf.TCPConnID struct
+typedef struct tcp_conn_id_t {
+    struct in6_addr src_ip;
+    struct in6_addr dst_ip;
+    u16 src_port;
+    u16 dst_port;
+} tcp_conn_id;
+
+// TCP health statistics of a connection, accumulated since the last eviction.
+// The fields are not packed, as they are atomically updated from different CPUs.
+// Contents in this struct must match byte-by-byte with Go's ebpf.TCPStats struct
+typedef struct tcp_stats_t {
+    // last sampled smoothed round-trip time of the connection, in microseconds
+    u32 srtt_us;
+    // number of retransmitted segments
+    u32 retransmits;
+} tcp_stats;
+
+// maximum number of filter rules that can be evaluated in the kernel space
+#define MAX_FILTER_RULES 16
+#define FILTER_ACCEPT 0
+#define FILTER_DROP 1
+#define FILTER_ANY_DIRECTION 0xff
+
+// Rule to accept or drop the packets before they are aggregated into flows.
+// The addresses are stored already masked by their prefix length, which is counted over the
+// 128 bits of the address (IPv4 rules are defined over IPv4-mapped IPv6 addresses).
+// A zero prefix length or protocol matches any value, and the port ranges are inclusive.
+// Contents in this struct must match byte-by-byte with Go's ebpf.FilterRule struct
+typedef struct filter_rule_t {
+    struct in6_addr src_ip;
+    struct in6_addr dst_ip;
+    u8 src_prefix_len;
+    u8 dst_prefix_len;
+    u8 protocol;
+    // INGRESS, EGRESS or FILTER_ANY_DIRECTION
+    u8 direction;
+    // FILTER_ACCEPT or FILTER_DROP
+    u8 action;
+    u8 __pad;
+    u16 src_port_start;
+    u16 src_port_end;
+    u16 dst_port_start;
+    u16 dst_port_end;
+    // explicit padding up to the 4-byte alignment of the in6_addr fields
+    u8 __pad_end[2];
+} filter_rule;
+
 #endif
@@ -21,6 +21,8 @@
 
 #include "bpf_helpers.h"
 #include "bpf_endian.h"
+#include "bpf_core_read.h"
+#include "bpf_tracing.h"
 
 #include "flow.h"
 
@@ -59,9 +61,30 @@ struct {
     __type(value, flow_metrics);
 } aggregated_flows SEC(".maps");
 
+// Key: the TCP connection, in the direction of its packets. Value: the TCP statistics
+// of the connection since the last eviction.
+// The userspace will join them with the flows that have the same addresses and ports.
+struct {
+    __uint(type, BPF_MAP_TYPE_LRU_HASH);
+    __type(key, tcp_conn_id);
+    __type(value, tcp_stats);
+} tcp_flow_stats SEC(".maps");
+
+// Key: the position of the rule. Value: the filter rule.
+// The rules are evaluated in order, and the first rule that matches a packet decides
+// whether it is accepted or dropped.
+struct {
+    __uint(type, BPF_MAP_TYPE_ARRAY);
+    __uint(max_entries, MAX_FILTER_RULES);
+    __type(key, u32);
+    __type(value, filter_rule);
+} flow_filters SEC(".maps");
+
 // Constant definitions, to be overridden by the invoker
 volatile const u32 sampling = 0;
 volatile const u8 trace_messages = 0;
+// number of rules in the flow_filters map
+volatile const u32 filter_rules = 0;
 
 const u8 ip4in6[] = {0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff};
 
@@ -92,6 +115,19 @@ static inline void set_flags(struct tcphdr *th, u16 *flags) {
         *flags |= CWR_FLAG;
     }
 }
+// returns the TCP statistics entry for the given connection, creating it if it does not exist.
+// It might return NULL if the entry couldn't be created (e.g. the map is full and busy)
+static inline tcp_stats *tcp_stats_for(tcp_conn_id *conn) {
+    tcp_stats *stats = (tcp_stats *)bpf_map_lookup_elem(&tcp_flow_stats, conn);
+    if (stats != NULL) {
+        return stats;
+    }
+    tcp_stats empty = {};
+    // BPF_NOEXIST, as another CPU might have concurrently created the entry
+    bpf_map_update_elem(&tcp_flow_stats, conn, &empty, BPF_NOEXIST);
+    return (tcp_stats *)bpf_map_lookup_elem(&tcp_flow_stats, conn);
+}
+
 // sets flow fields from IPv4 header information
 static inline int fill_iphdr(struct iphdr *ip, void *data_end, flow_id *id, u16 *flags) {
     if ((void *)ip + sizeof(*ip) > data_end) {
@@ -185,6 +221,56 @@ static inline int fill_ethhdr(struct ethhdr *eth, void *data_end, flow_id *id, u
     return SUBMIT;
 }
 
+// returns whether the address belongs to the network of the given prefix length.
+// The network address is already masked.
+static inline bool ip_matches(struct in6_addr *ip, struct in6_addr *network, u8 prefix_len) {
+#pragma unroll
+    for (int i = 0; i < 4; i++) {
+        int bits = prefix_len - i * 32;
+        if (bits <= 0) {
+            return true;
+        }
+        u32 mask = bits >= 32 ? 0xffffffff : bpf_htonl(~((1U << (32 - bits)) - 1));
+        if ((ip->in6_u.u6_addr32[i] & mask) != network->in6_u.u6_addr32[i]) {
+            return false;
+        }
+    }
+    return true;
+}
+
+// src_ip and dst_ip are the addresses of the flow, as the flow_id members might not be aligned
+static inline bool rule_matches(filter_rule *rule, flow_id *id,
+                                struct in6_addr *src_ip, struct in6_addr *dst_ip) {
+    return (rule->direction == FILTER_ANY_DIRECTION || rule->direction == id->direction)
+        && (rule->protocol == 0 || rule->protocol == id->transport_protocol)
+        && id->src_port >= rule->src_port_start && id->src_port <= rule->src_port_end
+        && id->dst_port >= rule->dst_port_start && id->dst_port <= rule->dst_port_end
+        && ip_matches(src_ip, &rule->src_ip, rule->src_prefix_len)
+        && ip_matches(dst_ip, &rule->dst_ip, rule->dst_prefix_len);
+}
+
+// returns whether the packet must be dropped, according to the first filter rule that it matches.
+// Packets that do not match any rule are accepted.
+static inline bool filtered_out(flow_id *id) {
+    struct in6_addr src_ip = id->src_ip;
+    struct in6_addr dst_ip = id->dst_ip;
+#pragma unroll
+    for (u32 i = 0; i < MAX_FILTER_RULES; i++) {
+        if (i >= filter_rules) {
+            return false;
+        }
+        u32 key = i;
+        filter_rule *rule = (filter_rule *)bpf_map_lookup_elem(&flow_filters, &key);
+        if (rule == NULL) {
+            return false;
+        }
+        if (rule_matches(rule, id, &src_ip, &dst_ip)) {
+            return rule->action == FILTER_DROP;
+        }
+    }
+    return false;
+}
+
 static inline int flow_monitor(struct __sk_buff *skb, u8 direction) {
     // If sampling is defined, will only parse 1 out of "sampling" flows
     if (sampling != 0 && (bpf_get_prandom_u32() % sampling) != 0) {
@@ -206,6 +292,17 @@ static inline int flow_monitor(struct __sk_buff *skb, u8 direction) {
     id.if_index = skb->ifindex;
     id.direction = direction;
 
+    // the filters are evaluated before the packet is accounted in the flows, so the dropped
+    // traffic isn't reported, and it doesn't count in the TCP resets and connection attempts
+    if (filter_rules > 0 && filtered_out(&id)) {
+        return TC_ACT_OK;
+    }
+
+    // RST packets and connection attempts are accounted in the flow, so they are deduplicated
+    // as the rest of flow metrics when the packet is seen from different interfaces
+    u32 resets = (flags & (RST_FLAG | RST_ACK_FLAG)) ? 1 : 0;
+    u32 syns = (flags & SYN_FLAG) ? 1 : 0;
+
     // TODO: we need to add spinlock here when we deprecate versions prior to 5.1, or provide
     // a spinlocked alternative version and use it selectively https://lwn.net/Articles/779120/
     flow_metrics *aggregate_flow = (flow_metrics *)bpf_map_lookup_elem(&aggregated_flows, &id);
@@ -219,6 +316,8 @@ static inline int flow_monitor(struct __sk_buff *skb, u8 direction) {
             aggregate_flow->start_mono_time_ns = current_time;
         }
         aggregate_flow->flags |= flags;
+        aggregate_flow->resets += resets;
+        aggregate_flow->syns += syns;
 
         long ret = bpf_map_update_elem(&aggregated_flows, &id, aggregate_flow, BPF_ANY);
         if (trace_messages && ret != 0) {
@@ -237,6 +336,8 @@ static inline int flow_monitor(struct __sk_buff *skb, u8 direction) {
             .start_mono_time_ns = current_time,
             .end_mono_time_ns = current_time,
             .flags = flags, 
+            .resets = resets,
+            .syns = syns,
         };
 
         // even if we know that the entry is new, another CPU might be concurrently inserting a flow
@@ -278,6 +379,63 @@ int egress_flow_parse(struct __sk_buff *skb) {
     return flow_monitor(skb, EGRESS);
 }
 
+// reads the addresses and ports of a socket, from the point of view of the packets that it sends
+static inline bool tcp_conn_from_sock(struct sock *sk, tcp_conn_id *conn) {
+    u16 family = BPF_CORE_READ(sk, __sk_common.skc_family);
+    if (family == AF_INET) {
+        u32 saddr = BPF_CORE_READ(sk, __sk_common.skc_rcv_saddr);
+        u32 daddr = BPF_CORE_READ(sk, __sk_common.skc_daddr);
+        __builtin_memcpy(conn->src_ip.s6_addr, ip4in6, sizeof(ip4in6));
+        __builtin_memcpy(conn->dst_ip.s6_addr, ip4in6, sizeof(ip4in6));
+        __builtin_memcpy(conn->src_ip.s6_addr + sizeof(ip4in6), &saddr, sizeof(saddr));
+        __builtin_memcpy(conn->dst_ip.s6_addr + sizeof(ip4in6), &daddr, sizeof(daddr));
+    } else if (family == AF_INET6) {
+        BPF_CORE_READ_INTO(&conn->src_ip, sk, __sk_common.skc_v6_rcv_saddr);
+        BPF_CORE_READ_INTO(&conn->dst_ip, sk, __sk_common.skc_v6_daddr);
+    } else {
+        return false;
+    }
+    // skc_num is in host byte order, while skc_dport is in network byte order
+    conn->src_port = BPF_CORE_READ(sk, __sk_common.skc_num);
+    conn->dst_port = __bpf_ntohs(BPF_CORE_READ(sk, __sk_common.skc_dport));
+    return true;
+}
+
+// stores the smoothed RTT of the socket, and returns its statistics entry
+static inline tcp_stats *sample_srtt(struct sock *sk) {
+    tcp_conn_id conn;
+    __builtin_memset(&conn, 0, sizeof(conn));
+    if (!tcp_conn_from_sock(sk, &conn)) {
+        return NULL;
+    }
+    tcp_stats *stats = tcp_stats_for(&conn);
+    if (stats != NULL) {
+        // srtt_us is stored as 8 times the smoothed RTT, in microseconds
+        stats->srtt_us = BPF_CORE_READ((struct tcp_sock *)sk, srtt_us) >> 3;
+    }
+    return stats;
+}
+
+// samples the smoothed RTT of the TCP connections, as they receive packets
+SEC("kprobe/tcp_rcv_established")
+int BPF_KPROBE(flow_tcp_rcv_established, struct sock *sk) {
+    if (sampling != 0 && (bpf_get_prandom_u32() % sampling) != 0) {
+        return 0;
+    }
+    sample_srtt(sk);
+    return 0;
+}
+
+// counts the retransmitted segments of the TCP connections
+SEC("kprobe/tcp_retransmit_skb")
+int BPF_KPROBE(flow_tcp_retransmit_skb, struct sock *sk, struct sk_buff *skb, int segs) {
+    tcp_stats *stats = sample_srtt(sk);
+    if (stats != NULL) {
+        __sync_fetch_and_add(&stats->retransmits, segs > 0 ? segs : 1);
+    }
+    return 0;
+}
+
 // Force emitting structs into the ELF for automatic creation of Golang struct
 const flow_metrics *unused_flow_metrics __attribute__((unused));
 const flow_id *unused_flow_id __attribute__((unused));
@@ -29,6 +29,12 @@ func main() {
 
 	slog.Info("Grafana Beyla", "Version", buildinfo.Version, "Revision", buildinfo.Revision, "OpenTelemetry SDK Version", otelsdk.Version())
 
+	// replaying a capture of raw eBPF events does not require any eBPF support from the OS
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replay(&lvl, os.Args[2:])
+		return
+	}
+
 	if err := beyla.CheckOSSupport(); err != nil {
 		slog.Error("can't start Beyla", "error", err)
 		os.Exit(-1)
@@ -73,6 +79,51 @@ func main() {
 	}
 }
 
+// replay the raw eBPF events of a capture file, generated with the capture.path configuration
+// option, through the processing pipelines of Beyla
+func replay(lvl *slog.LevelVar, args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: beyla replay [options] <capture file>")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String("config", "", "path to the configuration file")
+	fast := flags.Bool("fast", false, "replay the events as fast as possible, instead of keeping the captured pacing")
+	linger := flags.Duration("linger", 15*time.Second, "time to wait for the exporters to flush the data after the replay")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(-1)
+	}
+
+	if cfg := os.Getenv("BEYLA_CONFIG_PATH"); cfg != "" {
+		configPath = &cfg
+	}
+	config := loadConfig(configPath)
+	if err := config.Validate(); err != nil {
+		slog.Error("wrong Beyla configuration", "error", err)
+		os.Exit(-1)
+	}
+	if err := lvl.UnmarshalText([]byte(config.LogLevel)); err != nil {
+		slog.Error("unknown log level specified, choices are [DEBUG, INFO, WARN, ERROR]", "error", err)
+		os.Exit(-1)
+	}
+
+	in, err := os.Open(flags.Arg(0))
+	if err != nil {
+		slog.Error("can't open capture file", "error", err)
+		os.Exit(-1)
+	}
+
+	ctx, _ := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	err = components.ReplayBeyla(ctx, config, in, !*fast, *linger)
+	in.Close()
+	if err != nil {
+		slog.Error("can't replay capture", "error", err)
+		os.Exit(-1)
+	}
+}
+
 func loadConfig(configPath *string) *beyla.Config {
 	var configReader io.ReadCloser
 	if configPath != nil && *configPath != "" {
@@ -9,11 +9,18 @@ import (
 	otelconsumer "go.opentelemetry.io/collector/consumer"
 	"gopkg.in/yaml.v3"
 
+	"github.com/grafana/beyla/pkg/internal/capture"
 	ebpfcommon "github.com/grafana/beyla/pkg/internal/ebpf/common"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/export/debug"
+	"github.com/grafana/beyla/pkg/internal/export/file"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/export/statsd"
+	"github.com/grafana/beyla/pkg/internal/export/zipkin"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+	netprom "github.com/grafana/beyla/pkg/internal/netolly/export/prom"
 	"github.com/grafana/beyla/pkg/internal/traces"
 	"github.com/grafana/beyla/pkg/services"
 	"github.com/grafana/beyla/pkg/transform"
@@ -59,12 +66,41 @@ var DefaultConfig = Config{
 		MaxExportBatchSize: 4096,
 		ReportersCacheLen:  ReporterLRUSize,
 	},
+	Logs: otel.LogsConfig{
+		Protocol:     otel.ProtocolUnset,
+		LogsProtocol: otel.ProtocolUnset,
+	},
+	Zipkin: zipkin.Config{
+		Timeout:           10 * time.Second,
+		ReportersCacheLen: ReporterLRUSize,
+	},
+	FileExport: file.TracesConfig{
+		File:              file.RotationConfig{MaxSizeMB: 100, MaxFiles: 10},
+		BatchTimeout:      5 * time.Second,
+		ReportersCacheLen: ReporterLRUSize,
+	},
 	Prometheus: prom.PrometheusConfig{
-		Path:    "/metrics",
-		Buckets: otel.DefaultBuckets,
+		Path:     "/metrics",
+		Buckets:  otel.DefaultBuckets,
+		Features: []string{otel.FeatureNetwork, otel.FeatureApplication},
+	},
+	PrometheusRemoteWrite: prom.RemoteWriteConfig{
+		Interval:     15 * time.Second,
+		Timeout:      10 * time.Second,
+		MaxRetries:   3,
+		RetryBackoff: time.Second,
+		Buckets:      otel.DefaultBuckets,
 	},
-	Printer: false,
-	Noop:    false,
+	StatsD: statsd.Config{
+		Prefix:           "beyla.",
+		FlushInterval:    10 * time.Second,
+		MaxPacketSize:    1432,
+		MaxTimingSamples: 100,
+	},
+	Printer:       false,
+	PrinterFormat: debug.PrintFormatText,
+	PrinterOutput: debug.OutputStdout,
+	Noop:          false,
 	InternalMetrics: imetrics.Config{
 		Prometheus: imetrics.PrometheusConfig{
 			Port: 0, // disabled by default
@@ -79,6 +115,7 @@ var DefaultConfig = Config{
 			Enable:               transform.EnabledDefault,
 			InformersSyncTimeout: 30 * time.Second,
 		},
+		SemConvVersion: attributes.DefaultSemConvVersion,
 	},
 	Routes:       &transform.RoutesConfig{},
 	NetworkFlows: defaultNetworkConfig,
@@ -99,8 +136,18 @@ type Config struct {
 	Routes     *transform.RoutesConfig `yaml:"routes"`
 	Metrics    otel.MetricsConfig      `yaml:"otel_metrics_export"`
 	Traces     otel.TracesConfig       `yaml:"otel_traces_export"`
+	Logs       otel.LogsConfig         `yaml:"otel_logs_export"`
+	Zipkin     zipkin.Config           `yaml:"zipkin_export"`
+	FileExport file.TracesConfig       `yaml:"file_export"`
+	StatsD     statsd.Config           `yaml:"statsd_export"`
 	Prometheus prom.PrometheusConfig   `yaml:"prometheus_export"`
 	Printer    debug.PrintEnabled      `yaml:"print_traces" env:"BEYLA_PRINT_TRACES"`
+	// PrinterFormat of the traces printer: text or json
+	PrinterFormat debug.PrintFormat `yaml:"print_traces_format" env:"BEYLA_PRINT_TRACES_FORMAT"`
+	// PrinterOutput of the traces printer: stdout or the path of a file
+	PrinterOutput string `yaml:"print_traces_output" env:"BEYLA_PRINT_TRACES_OUTPUT"`
+
+	PrometheusRemoteWrite prom.RemoteWriteConfig `yaml:"prometheus_remote_write"`
 
 	// Exec allows selecting the instrumented executable whose complete path contains the Exec value.
 	Exec services.RegexpAttr `yaml:"executable_name" env:"BEYLA_EXECUTABLE_NAME"`
@@ -128,6 +175,9 @@ type Config struct {
 	ProfilePort      int               `yaml:"profile_port" env:"BEYLA_PROFILE_PORT"`
 	InternalMetrics  imetrics.Config   `yaml:"internal_metrics"`
 
+	// Capture the raw eBPF events into a file, for offline debugging with the "beyla replay" command
+	Capture capture.Config `yaml:"capture"`
+
 	// Grafana Agent specific configuration
 	TracesReceiver TracesReceiverConfig `yaml:"-"`
 }
@@ -138,6 +188,9 @@ type Consumer interface {
 
 type TracesReceiverConfig struct {
 	Traces []Consumer
+	// SemConv version that defines the names of the span attributes.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
 }
 
 func (t TracesReceiverConfig) Enabled() bool {
@@ -149,6 +202,11 @@ func (t TracesReceiverConfig) Enabled() bool {
 type Attributes struct {
 	Kubernetes transform.KubernetesDecorator `yaml:"kubernetes"`
 	InstanceID traces.InstanceIDConfig       `yaml:"instance_id"`
+	// Select specifies which optional attributes are reported by the application metrics exporters
+	Select attributes.Selection `yaml:"select"`
+	// SemConvVersion of the OpenTelemetry semantic conventions that define the names of the
+	// metrics and attributes reported by the application exporters
+	SemConvVersion attributes.SemConvVersion `yaml:"semconv_version" env:"BEYLA_SEMCONV_VERSION"`
 }
 
 type ConfigError string
@@ -168,23 +226,58 @@ func (c *Config) Validate() error {
 	if (c.Port.Len() > 0 || c.Exec.IsSet() || len(c.Discovery.Services) > 0) && c.Discovery.SystemWide {
 		return ConfigError("you can't use BEYLA_SYSTEM_WIDE if any of BEYLA_EXECUTABLE_NAME, BEYLA_OPEN_PORT or services (YAML) are set")
 	}
+	if err := c.Attributes.Select.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in attributes.select YAML property: %s", err.Error()))
+	}
+	if err := c.Attributes.SemConvVersion.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in attributes.semconv_version YAML property: %s", err.Error()))
+	}
+	if err := c.Prometheus.Server.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in prometheus_export YAML property: %s", err.Error()))
+	}
+	if err := c.PrinterFormat.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in print_traces_format YAML property: %s", err.Error()))
+	}
+	if err := c.NetworkFlows.PrintFormat.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in network.print_flows_format YAML property: %s", err.Error()))
+	}
+	if err := c.NetworkFlows.IPFIX.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in network.ipfix_export YAML property: %s", err.Error()))
+	}
+	if err := c.NetworkFlows.Filters.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in network.filters YAML property: %s", err.Error()))
+	}
+	if err := export.ValidateBuckets(c.NetworkFlows.RTTBuckets); err != nil {
+		return ConfigError(fmt.Sprintf("error in network.rtt_buckets YAML property: %s", err.Error()))
+	}
+	if err := c.NetworkFlows.Histograms.Validate(); err != nil {
+		return ConfigError(fmt.Sprintf("error in network.histograms YAML property: %s", err.Error()))
+	}
 	if c.EBPF.BatchLength == 0 {
 		return ConfigError("BEYLA_BPF_BATCH_LENGTH must be at least 1")
 	}
 
-	if c.Enabled(FeatureNetO11y) && !c.Grafana.OTLP.MetricsEnabled() && !c.Metrics.Enabled() && !c.NetworkFlows.Print {
+	if c.Enabled(FeatureNetO11y) && !c.Grafana.OTLP.MetricsEnabled() && !c.Metrics.Enabled() &&
+		!(netprom.PrometheusConfig{Config: &c.Prometheus}).Enabled() && !c.NetworkFlows.Print &&
+		!c.NetworkFlows.FileExport.Enabled() && !c.NetworkFlows.IPFIX.Enabled() {
 		return ConfigError("enabling network metrics requires to enable at least the OpenTelemetry" +
 			" metrics exporter: grafana or otel_metrics_export sections in the YAML configuration file; or the" +
-			" OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_METRICS_ENDPOINT environment variables. For debugging" +
-			" purposes, you can also set BEYLA_NETWORK_PRINT_FLOWS=true")
+			" OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_METRICS_ENDPOINT environment variables; or the" +
+			" Prometheus exporter: prometheus_export section in the YAML configuration file or the" +
+			" BEYLA_PROMETHEUS_PORT environment variable; or the file exporter: network.file_export section" +
+			" in the YAML configuration file or the BEYLA_NETWORK_FILE_EXPORT_PATH environment variable; or the" +
+			" IPFIX exporter: network.ipfix_export section in the YAML configuration file or the" +
+			" BEYLA_NETWORK_IPFIX_ENDPOINT environment variable." +
+			" For debugging purposes, you can also set BEYLA_NETWORK_PRINT_FLOWS=true")
 	}
 
 	if c.Enabled(FeatureAppO11y) && !c.Noop.Enabled() && !c.Printer.Enabled() &&
 		!c.Grafana.OTLP.MetricsEnabled() && !c.Grafana.OTLP.TracesEnabled() &&
-		!c.Metrics.Enabled() && !c.Traces.Enabled() &&
-		!c.Prometheus.Enabled() {
+		!c.Metrics.Enabled() && !c.Traces.Enabled() && !c.Logs.Enabled() && !c.Zipkin.Enabled() && !c.FileExport.Enabled() &&
+		!c.Prometheus.Enabled() && !c.PrometheusRemoteWrite.Enabled() && !c.StatsD.Enabled() {
 		return ConfigError("you need to define at least one exporter: print_traces," +
-			" grafana, otel_metrics_export, otel_traces_export or prometheus_export")
+			" grafana, otel_metrics_export, otel_traces_export, otel_logs_export, zipkin_export, file_export," +
+			" prometheus_export, prometheus_remote_write or statsd_export")
 	}
 
 	if c.Enabled(FeatureNetO11y) {
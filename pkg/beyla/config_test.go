@@ -11,10 +11,17 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"github.com/grafana/beyla/pkg/internal/connector"
 	ebpfcommon "github.com/grafana/beyla/pkg/internal/ebpf/common"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/debug"
+	"github.com/grafana/beyla/pkg/internal/export/file"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/export/statsd"
+	"github.com/grafana/beyla/pkg/internal/export/zipkin"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
 	"github.com/grafana/beyla/pkg/internal/netolly/transform/cidr"
 	"github.com/grafana/beyla/pkg/internal/traces"
 	"github.com/grafana/beyla/pkg/transform"
@@ -87,6 +94,8 @@ network:
 		ChannelBufferLen: 33,
 		LogLevel:         "INFO",
 		Printer:          false,
+		PrinterFormat:    debug.PrintFormatText,
+		PrinterOutput:    debug.OutputStdout,
 		Noop:             true,
 		EBPF: ebpfcommon.TracerConfig{
 			BatchLength:  100,
@@ -120,12 +129,39 @@ network:
 			MaxExportBatchSize: 4096,
 			ReportersCacheLen:  ReporterLRUSize,
 		},
+		Logs: otel.LogsConfig{
+			Protocol:     otel.ProtocolUnset,
+			LogsProtocol: otel.ProtocolUnset,
+		},
+		Zipkin: zipkin.Config{
+			Timeout:           10 * time.Second,
+			ReportersCacheLen: ReporterLRUSize,
+		},
+		FileExport: file.TracesConfig{
+			File:              file.RotationConfig{MaxSizeMB: 100, MaxFiles: 10},
+			BatchTimeout:      5 * time.Second,
+			ReportersCacheLen: ReporterLRUSize,
+		},
 		Prometheus: prom.PrometheusConfig{
-			Path: "/metrics",
+			Path:     "/metrics",
+			Features: []string{"network", "application"},
 			Buckets: otel.Buckets{
 				DurationHistogram:    otel.DefaultBuckets.DurationHistogram,
 				RequestSizeHistogram: []float64{0, 10, 20, 22},
 			}},
+		PrometheusRemoteWrite: prom.RemoteWriteConfig{
+			Interval:     15 * time.Second,
+			Timeout:      10 * time.Second,
+			MaxRetries:   3,
+			RetryBackoff: time.Second,
+			Buckets:      otel.DefaultBuckets,
+		},
+		StatsD: statsd.Config{
+			Prefix:           "beyla.",
+			FlushInterval:    10 * time.Second,
+			MaxPacketSize:    1432,
+			MaxTimingSamples: 100,
+		},
 		InternalMetrics: imetrics.Config{
 			Prometheus: imetrics.PrometheusConfig{
 				Port: 3210,
@@ -141,6 +177,7 @@ network:
 				Enable:               transform.EnabledTrue,
 				InformersSyncTimeout: 30 * time.Second,
 			},
+			SemConvVersion: attributes.SemConv123,
 		},
 		Routes: &transform.RoutesConfig{},
 	}, cfg)
@@ -161,7 +198,10 @@ func TestConfigValidate(t *testing.T) {
 		{"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "localhost:1234", "BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar"},
 		{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "localhost:1234", "BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar"},
 		{"BEYLA_PRINT_TRACES": "true", "BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar"},
+		{"BEYLA_PRINT_TRACES": "true", "BEYLA_PRINT_TRACES_FORMAT": "json", "BEYLA_EXECUTABLE_NAME": "foo"},
 		{"BEYLA_PROMETHEUS_PORT": "8080", "BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar"},
+		{"BEYLA_FILE_EXPORT_PATH": "/var/log/beyla/traces.jsonl", "BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar"},
+		{"BEYLA_STATSD_ENDPOINT": "localhost:8125", "BEYLA_EXECUTABLE_NAME": "foo"},
 	}
 	for n, tc := range testCases {
 		t.Run(fmt.Sprint("case", n), func(t *testing.T) {
@@ -175,6 +215,8 @@ func TestConfigValidate_error(t *testing.T) {
 	testCases := []map[string]string{
 		{"OTEL_EXPORTER_OTLP_ENDPOINT": "localhost:1234", "INSTRUMENT_FUNC_NAME": "bar"},
 		{"BEYLA_EXECUTABLE_NAME": "foo", "INSTRUMENT_FUNC_NAME": "bar", "BEYLA_PRINT_TRACES": "false"},
+		{"BEYLA_EXECUTABLE_NAME": "foo", "BEYLA_PRINT_TRACES": "true", "BEYLA_PRINT_TRACES_FORMAT": "xml"},
+		{"BEYLA_NETWORK_METRICS": "true", "BEYLA_NETWORK_PRINT_FLOWS": "true", "BEYLA_NETWORK_PRINT_FLOWS_FORMAT": "yaml"},
 	}
 	for n, tc := range testCases {
 		t.Run(fmt.Sprint("case", n), func(t *testing.T) {
@@ -237,6 +279,125 @@ network:
 	require.NoError(t, cfg.Validate())
 }
 
+func TestConfigValidate_Network_Prometheus(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+prometheus_export:
+  port: 9090
+network:
+  enable: true
+  allowed_attributes:
+    - src.name
+    - dst.name
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfigValidate_Network_Prometheus_NoNetworkFeature(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+prometheus_export:
+  port: 9090
+  features: [application]
+network:
+  enable: true
+  allowed_attributes:
+    - src.name
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigValidate_Network_IPFIX(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+network:
+  enable: true
+  allowed_attributes:
+    - src.name
+  ipfix_export:
+    endpoint: collector:4739
+    transport: tcp
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, "collector:4739", cfg.NetworkFlows.IPFIX.Endpoint)
+	assert.Equal(t, "tcp", cfg.NetworkFlows.IPFIX.Transport)
+	assert.Equal(t, 10*time.Minute, cfg.NetworkFlows.IPFIX.TemplateRefresh)
+
+	cfg.NetworkFlows.IPFIX.Transport = "sctp"
+	require.Error(t, cfg.Validate())
+
+	cfg.NetworkFlows.IPFIX.Transport = "udp"
+	cfg.NetworkFlows.IPFIX.Endpoint = "collector"
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfig_RemoteWriteEnvVars(t *testing.T) {
+	t.Setenv("BEYLA_PROMETHEUS_TTL", "1m")
+	t.Setenv("BEYLA_PROMETHEUS_DISABLE_BUILD_INFO", "true")
+	t.Setenv("BEYLA_PROMETHEUS_REMOTE_WRITE_TTL", "2m")
+	cfg, err := LoadConfig(bytes.NewBufferString(""))
+	require.NoError(t, err)
+
+	// the Prometheus endpoint variables do not configure the remote write exporter
+	assert.Equal(t, time.Minute, cfg.Prometheus.TTL)
+	assert.True(t, cfg.Prometheus.DisableBuildInfo)
+	assert.Equal(t, 2*time.Minute, cfg.PrometheusRemoteWrite.TTL)
+	assert.False(t, cfg.PrometheusRemoteWrite.DisableBuildInfo)
+}
+
+func TestConfigValidate_Network_Filters(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+network:
+  enable: true
+  print_flows: true
+  allowed_attributes:
+    - src.name
+  filters:
+    - protocol: udp
+      dst_port: 53
+    - action: accept
+      src_cidr: 10.0.0.0/8
+      direction: ingress
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	require.Len(t, cfg.NetworkFlows.Filters, 2)
+	assert.Equal(t, "53", cfg.NetworkFlows.Filters[0].DstPort)
+	assert.Equal(t, "10.0.0.0/8", cfg.NetworkFlows.Filters[1].SrcCIDR)
+
+	cfg.NetworkFlows.Filters[1].SrcCIDR = "10.0.0.0"
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfigValidate_Network_Histograms(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+network:
+  enable: true
+  print_flows: true
+  allowed_attributes:
+    - src.name
+  histograms:
+    enable: true
+    bytes_buckets: [0, 1024, 1048576]
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.True(t, cfg.NetworkFlows.Histograms.Enable)
+	assert.Equal(t, []float64{0, 1024, 1048576}, cfg.NetworkFlows.Histograms.BytesBuckets)
+	// unset buckets keep their default values
+	assert.Equal(t, export.DefaultFlowHistograms.DurationBuckets, cfg.NetworkFlows.Histograms.DurationBuckets)
+
+	cfg.NetworkFlows.Histograms.PacketsBuckets = []float64{10, 1}
+	require.Error(t, cfg.Validate())
+	cfg.NetworkFlows.Histograms.PacketsBuckets = nil
+	require.Error(t, cfg.Validate())
+}
+
 func TestConfigValidate_Network_Empty_Attrs(t *testing.T) {
 	userConfig := bytes.NewBufferString(`
 otel_metrics_export:
@@ -279,3 +440,170 @@ func unsetEnv(t *testing.T, env map[string]string) {
 		require.NoError(t, os.Unsetenv(k))
 	}
 }
+
+func TestConfigValidate_AttributesSelection(t *testing.T) {
+	userConfig := bytes.NewBufferString(`
+print_traces: true
+executable_name: foo
+attributes:
+  select:
+    http_server:
+      include: [url_path, k8s.pod.name]
+      exclude: [client_address]
+    sql_client:
+      exclude: [k8s_pod_uid]
+`)
+	cfg, err := LoadConfig(userConfig)
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfigValidate_AttributesSelection_Errors(t *testing.T) {
+	for _, tc := range []string{`
+print_traces: true
+executable_name: foo
+attributes:
+  select:
+    unknown_section:
+      include: [url_path]
+`, `
+print_traces: true
+executable_name: foo
+attributes:
+  select:
+    rpc_server:
+      include: [url_path]
+`,
+	} {
+		cfg, err := LoadConfig(bytes.NewBufferString(tc))
+		require.NoError(t, err)
+		require.Error(t, cfg.Validate())
+	}
+}
+
+func TestConfigValidate_SemConvVersion(t *testing.T) {
+	cfg, err := LoadConfig(bytes.NewBufferString(`
+print_traces: true
+executable_name: foo
+attributes:
+  semconv_version: "1.19"
+`))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, attributes.SemConv119, cfg.Attributes.SemConvVersion)
+
+	cfg, err = LoadConfig(bytes.NewBufferString(`
+print_traces: true
+executable_name: foo
+attributes:
+  semconv_version: "1.7"
+`))
+	require.NoError(t, err)
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfig_PrometheusServer(t *testing.T) {
+	cfg, err := LoadConfig(bytes.NewBufferString(`
+executable_name: foo
+prometheus_export:
+  port: 8999
+  tls:
+    cert_file: /certs/tls.crt
+    key_file: /certs/tls.key
+  basic_auth:
+    username: user
+    password: pass
+`))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+	assert.Equal(t, connector.ServerConfig{
+		TLS:       connector.TLSConfig{CertFile: "/certs/tls.crt", KeyFile: "/certs/tls.key"},
+		BasicAuth: connector.BasicAuth{Username: "user", Password: "pass"},
+	}, cfg.Prometheus.Server)
+
+	cfg, err = LoadConfig(bytes.NewBufferString(`
+executable_name: foo
+prometheus_export:
+  port: 8999
+  tls:
+    cert_file: /certs/tls.crt
+`))
+	require.NoError(t, err)
+	require.Error(t, cfg.Validate())
+}
+
+func TestConfig_OTLPClient(t *testing.T) {
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_HEADERS":             "X-Scope-OrgID=tenant-1,Authorization=Basic%20Zm9v",
+		"OTEL_EXPORTER_OTLP_TRACES_HEADERS":      "X-Scope-OrgID=tenant-2",
+		"OTEL_EXPORTER_OTLP_COMPRESSION":         "gzip",
+		"OTEL_EXPORTER_OTLP_METRICS_CERTIFICATE": "/certs/ca.pem",
+		"BEYLA_OTEL_TRACES_RETRY_MAX_INTERVAL":   "10s",
+	}
+	defer unsetEnv(t, env)
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+	}
+	cfg, err := LoadConfig(bytes.NewBufferString(`
+otel_metrics_export:
+  endpoint: https://otelcol:4318
+  headers:
+    Foo: bar
+  cert_file: /certs/client.pem
+  key_file: /certs/client-key.pem
+  retry:
+    initial_interval: 2s
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, otel.ClientConfig{
+		Headers:  otel.Headers{"Foo": "bar"},
+		CAFile:   "/certs/ca.pem",
+		CertFile: "/certs/client.pem",
+		KeyFile:  "/certs/client-key.pem",
+	}, cfg.Metrics.Client)
+	assert.Equal(t, otel.RetryConfig{InitialInterval: 2 * time.Second}, cfg.Metrics.Retry)
+	assert.Equal(t, otel.ClientConfig{
+		Compression: "gzip",
+		Headers:     otel.Headers{"X-Scope-OrgID": "tenant-1", "Authorization": "Basic Zm9v"},
+	}, cfg.Metrics.CommonClient)
+
+	assert.Equal(t, otel.ClientConfig{Headers: otel.Headers{"X-Scope-OrgID": "tenant-2"}}, cfg.Traces.Client)
+	assert.Equal(t, cfg.Metrics.CommonClient, cfg.Traces.CommonClient)
+	assert.Equal(t, otel.RetryConfig{MaxInterval: 10 * time.Second}, cfg.Traces.Retry)
+}
+
+func TestConfig_FileExport(t *testing.T) {
+	env := map[string]string{
+		"BEYLA_FILE_EXPORT_COMPRESS":                  "true",
+		"BEYLA_NETWORK_FILE_EXPORT_PATH":              "/var/log/beyla/flows.jsonl",
+		"BEYLA_NETWORK_FILE_EXPORT_MAX_SIZE_MB":       "20",
+		"BEYLA_NETWORK_FILE_EXPORT_ROTATION_INTERVAL": "1h",
+	}
+	defer unsetEnv(t, env)
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+	}
+	cfg, err := LoadConfig(bytes.NewBufferString(`
+file_export:
+  path: /var/log/beyla/traces.jsonl
+  max_files: 3
+network:
+  file_export:
+    max_files: 5
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, file.RotationConfig{
+		Path:      "/var/log/beyla/traces.jsonl",
+		MaxSizeMB: 100,
+		Compress:  true,
+		MaxFiles:  3,
+	}, cfg.FileExport.File)
+	assert.Equal(t, file.RotationConfig{
+		Path:             "/var/log/beyla/flows.jsonl",
+		MaxSizeMB:        20,
+		RotationInterval: time.Hour,
+		MaxFiles:         5,
+	}, cfg.NetworkFlows.FileExport)
+}
@@ -24,6 +24,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/grafana/beyla/pkg/internal/export/debug"
+	"github.com/grafana/beyla/pkg/internal/export/file"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+	"github.com/grafana/beyla/pkg/internal/netolly/export/ipfix"
 	"github.com/grafana/beyla/pkg/internal/netolly/flow"
 	"github.com/grafana/beyla/pkg/internal/netolly/transform/cidr"
 )
@@ -90,14 +94,43 @@ type NetworkConfig struct {
 	// ListenInterfaces value is set to "poll".
 	ListenPollPeriod time.Duration `yaml:"listen_poll_period" env:"BEYLA_NETWORK_LISTEN_POLL_PERIOD"`
 
+	// Filters drop or accept the flows that match the source/destination CIDRs, ports, protocol or direction
+	// of each rule. The first 16 rules (ebpf.MaxKernelFilterRules) are evaluated in the kernel, so the traffic
+	// that they drop is neither accounted nor copied to the user space. The rest of rules are evaluated
+	// in the user space.
+	Filters flow.FilterRules `yaml:"filters"`
+
+	// ConntrackNAT replaces the endpoints of the flows that are translated by the host (e.g. by
+	// kube-proxy, from a Service ClusterIP to a Pod IP) by the endpoints of the actual client and
+	// server, as registered in the conntrack table. It requires the CAP_NET_ADMIN capability.
+	ConntrackNAT bool `yaml:"conntrack_nat" env:"BEYLA_NETWORK_CONNTRACK_NAT"`
+
 	// ReverseDNS allows flows that haven't been previously decorated with any source/destination name
 	// to override the name with the network hostname of the source and destination IPs.
 	// This is an experimental feature and it is not guaranteed to work on most virtualized environments
 	// for external traffic.
 	ReverseDNS flow.ReverseDNS `yaml:"reverse_dns"`
 
+	// RTTBuckets of the histogram of the smoothed round-trip time of the TCP connections, in seconds
+	RTTBuckets []float64 `yaml:"rtt_buckets" env:"BEYLA_NETWORK_RTT_BUCKETS" envSeparator:","`
+
+	// Histograms of the duration, bytes and packets of each flow record, exported as OTEL
+	// and Prometheus metrics. They are disabled by default, as they can considerably
+	// increase the cardinality of the exported metrics.
+	Histograms export.FlowHistograms `yaml:"histograms"`
+
 	// Print the network flows in the Standard Output, if true
 	Print bool `yaml:"print_flows" env:"BEYLA_NETWORK_PRINT_FLOWS"`
+	// PrintFormat of the network flows printer: text or json
+	PrintFormat debug.PrintFormat `yaml:"print_flows_format" env:"BEYLA_NETWORK_PRINT_FLOWS_FORMAT"`
+	// PrintOutput of the network flows printer: stdout or the path of a file
+	PrintOutput string `yaml:"print_flows_output" env:"BEYLA_NETWORK_PRINT_FLOWS_OUTPUT"`
+
+	// FileExport writes the network flows into a local file, as OTLP-JSON lines
+	FileExport file.RotationConfig `yaml:"file_export" envPrefix:"BEYLA_NETWORK_FILE_EXPORT_"`
+
+	// IPFIX submits the network flows to an IPFIX collector
+	IPFIX ipfix.Config `yaml:"ipfix_export"`
 
 	// AllowedAttributes is a hidden/unstable/incomplete/epxerimental feature. This configuration API
 	// could change and be moved to other part, if we decide to extend this functionality also
@@ -125,6 +158,14 @@ var defaultNetworkConfig = NetworkConfig{
 	Direction:          "both",
 	ListenInterfaces:   "watch",
 	ListenPollPeriod:   10 * time.Second,
+	PrintFormat:        debug.PrintFormatText,
+	PrintOutput:        debug.OutputStdout,
+	FileExport:         file.RotationConfig{MaxSizeMB: 100, MaxFiles: 10},
+	IPFIX: ipfix.Config{
+		Transport:       ipfix.TransportUDP,
+		TemplateRefresh: 10 * time.Minute,
+		EnterpriseID:    ipfix.DefaultEnterpriseID,
+	},
 	AllowedAttributes: []string{
 		"k8s.src.owner.name",
 		"k8s.src.namespace",
@@ -137,6 +178,8 @@ var defaultNetworkConfig = NetworkConfig{
 		CacheLen: 256,
 		CacheTTL: time.Hour,
 	},
+	RTTBuckets: export.DefaultRTTBuckets,
+	Histograms: export.DefaultFlowHistograms,
 }
 
 func (nc *NetworkConfig) Validate(isKubeEnabled bool) error {
@@ -8,7 +8,13 @@ import (
 
 	"github.com/grafana/beyla/pkg/beyla"
 	"github.com/grafana/beyla/pkg/internal/appolly"
+	"github.com/grafana/beyla/pkg/internal/capture"
+	"github.com/grafana/beyla/pkg/internal/connector"
+	ebpfcommon "github.com/grafana/beyla/pkg/internal/ebpf/common"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/netolly/agent"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 // RunBeyla in the foreground process. This is a blocking function and won't exit
@@ -24,29 +30,48 @@ func RunBeyla(ctx context.Context, cfg *beyla.Config) {
 		wg.Add(1)
 	}
 
+	// Beyla components share the Prometheus manager, so they can expose their
+	// metrics through the same port and path
+	ctxInfo := buildCommonContextInfo(cfg)
+	if cfg.Capture.Enabled() {
+		rec, err := capture.NewRecorder(&cfg.Capture)
+		if err != nil {
+			slog.Error("can't start capture of eBPF events", "error", err)
+			os.Exit(-1)
+		}
+		slog.Info("capturing raw eBPF events", "path", cfg.Capture.Path)
+		ctxInfo.Capture = rec
+		defer func() {
+			if err := rec.Close(); err != nil {
+				slog.Warn("error closing capture file", "error", err)
+			}
+		}()
+	}
+
 	if app {
 		go func() {
 			defer wg.Done()
-			setupAppO11y(ctx, cfg)
+			setupAppO11y(ctx, ctxInfo, cfg)
 		}()
 	}
 	if net {
 		go func() {
 			defer wg.Done()
-			setupNetO11y(ctx, cfg)
+			setupNetO11y(ctx, ctxInfo, cfg)
 		}()
 	}
 	wg.Wait()
 }
 
-func setupAppO11y(ctx context.Context, config *beyla.Config) {
+func setupAppO11y(ctx context.Context, ctxInfo *global.ContextInfo, config *beyla.Config) {
 	slog.Info("starting Beyla in Application Observability mode")
 	// TODO: when we split Beyla in two processes with different permissions, this code can be split:
 	// in two parts:
 	// 1st process (privileged) - Invoke FindTarget, which also mounts the BPF maps
 	// 2nd executable (unprivileged) - Invoke ReadAndForward, receiving the BPF map mountpoint as argument
 
-	instr := appolly.New(config)
+	ebpfcommon.CaptureWith(ctxInfo.Capture)
+	instr := appolly.New(config, ctxInfo)
 	if err := instr.FindAndInstrument(ctx); err != nil {
 		slog.Error("Beyla couldn't find target process", "error", err)
 		os.Exit(-1)
@@ -57,9 +82,9 @@ func setupAppO11y(ctx context.Context, config *beyla.Config) {
 	}
 }
 
-func setupNetO11y(ctx context.Context, cfg *beyla.Config) {
+func setupNetO11y(ctx context.Context, ctxInfo *global.ContextInfo, cfg *beyla.Config) {
 	slog.Info("starting Beyla in Network metrics mode")
-	flowsAgent, err := agent.FlowsAgent(cfg)
+	flowsAgent, err := agent.FlowsAgent(ctxInfo, cfg)
 	if err != nil {
 		slog.Error("can't start network metrics capture", "error", err)
 		os.Exit(-1)
@@ -69,3 +94,25 @@ func setupNetO11y(ctx context.Context, cfg *beyla.Config) {
 		os.Exit(-1)
 	}
 }
+
+// buildCommonContextInfo populates some globally shared components and properties
+// from the user-provided configuration
+func buildCommonContextInfo(config *beyla.Config) *global.ContextInfo {
+	promMgr := &connector.PrometheusManager{}
+	promMgr.SecureWith(&config.Prometheus.Server)
+	ctxInfo := &global.ContextInfo{
+		Prometheus:           promMgr,
+		InstrumentedServices: svc.NewPIDRegistry(),
+	}
+	if config.InternalMetrics.Prometheus.Port != 0 {
+		slog.Debug("reporting internal metrics as Prometheus")
+		ctxInfo.Metrics = imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, promMgr)
+		// Prometheus manager also has its own internal metrics, so we need to pass the imetrics reporter
+		// TODO: remove this dependency cycle and let prommgr to create and return the PrometheusReporter
+		promMgr.InstrumentWith(ctxInfo.Metrics)
+	} else {
+		slog.Debug("not reporting internal metrics")
+		ctxInfo.Metrics = imetrics.NoopReporter{}
+	}
+	return ctxInfo
+}
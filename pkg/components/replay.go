@@ -0,0 +1,81 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/appolly"
+	"github.com/grafana/beyla/pkg/internal/capture"
+	ebpfcommon "github.com/grafana/beyla/pkg/internal/ebpf/common"
+	"github.com/grafana/beyla/pkg/internal/netolly/agent"
+)
+
+// ReplayBeyla feeds the raw eBPF events from a capture through the same decoding functions
+// and processing pipelines as RunBeyla, without loading any eBPF program. After all the events
+// are replayed, it waits for the linger duration, so the exporters can flush their pending
+// data, and then stops the pipelines. This is a blocking function.
+func ReplayBeyla(ctx context.Context, cfg *beyla.Config, in io.Reader, realtime bool, linger time.Duration) error {
+	reader, err := capture.NewReader(in)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ctxInfo := buildCommonContextInfo(cfg)
+	feeders := map[string]capture.Feeder{}
+	wg := sync.WaitGroup{}
+
+	if cfg.Enabled(beyla.FeatureAppO11y) {
+		slog.Info("replaying Application Observability events")
+		replay := ebpfcommon.NewAppReplay()
+		feeders[capture.TracerAppRingbuf] = replay
+		feeders[capture.TracerAppPIDs] = replay
+		instr := appolly.New(cfg, ctxInfo)
+		instr.Replay(ctx, replay)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := instr.ReadAndForward(ctx); err != nil {
+				slog.Error("can't replay Application Observability events", "error", err)
+				cancel()
+			}
+		}()
+	}
+	if cfg.Enabled(beyla.FeatureNetO11y) {
+		slog.Info("replaying Network metrics events")
+		flows, replay, err := agent.FlowsReplay(ctxInfo, cfg)
+		if err != nil {
+			return fmt.Errorf("can't start network metrics replay: %w", err)
+		}
+		feeders[capture.TracerNetIface] = replay
+		feeders[capture.TracerNetRingbuf] = replay
+		feeders[capture.TracerNetMap] = replay
+		feeders[capture.TracerNetTCP] = replay
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := flows.Run(ctx); err != nil {
+				slog.Error("can't replay Network metrics events", "error", err)
+				cancel()
+			}
+		}()
+	}
+
+	err = capture.Replay(ctx, reader, feeders, realtime)
+	if err == nil {
+		slog.Info("waiting for the pending data to be exported", "linger", linger)
+		select {
+		case <-ctx.Done():
+		case <-time.After(linger):
+		}
+	}
+	cancel()
+	wg.Wait()
+	return err
+}
@@ -10,9 +10,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/grafana/beyla/pkg/beyla"
-	"github.com/grafana/beyla/pkg/internal/connector"
 	"github.com/grafana/beyla/pkg/internal/discover"
-	"github.com/grafana/beyla/pkg/internal/imetrics"
+	ebpfcommon "github.com/grafana/beyla/pkg/internal/ebpf/common"
 	kube2 "github.com/grafana/beyla/pkg/internal/kube"
 	"github.com/grafana/beyla/pkg/internal/pipe"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
@@ -38,11 +37,12 @@ type Instrumenter struct {
 	tracesInput chan []request.Span
 }
 
-// New Instrumenter, given a Config
-func New(config *beyla.Config) *Instrumenter {
+// New Instrumenter, given a Config and the context information that is shared
+// with other Beyla components (e.g. the Prometheus manager)
+func New(config *beyla.Config, ctxInfo *global.ContextInfo) *Instrumenter {
 	return &Instrumenter{
 		config:      config,
-		ctxInfo:     buildContextInfo(config),
+		ctxInfo:     buildContextInfo(config, ctxInfo),
 		tracesInput: make(chan []request.Span, config.ChannelBufferLen),
 	}
 }
@@ -92,6 +92,12 @@ func (i *Instrumenter) FindAndInstrument(ctx context.Context) error {
 	return nil
 }
 
+// Replay forwards in background the spans from the captured raw eBPF events that are fed into the
+// provided AppReplay, instead of searching and instrumenting the executables.
+func (i *Instrumenter) Replay(ctx context.Context, replay *ebpfcommon.AppReplay) {
+	go replay.Forwarder(&i.config.EBPF, i.ctxInfo.Metrics)(ctx, i.tracesInput)
+}
+
 // ReadAndForward keeps listening for traces in the BPF map, then reads,
 // processes and forwards them
 func (i *Instrumenter) ReadAndForward(ctx context.Context) error {
@@ -114,30 +120,17 @@ func (i *Instrumenter) ReadAndForward(ctx context.Context) error {
 	return nil
 }
 
-// buildContextInfo populates some globally shared components and properties
-// from the user-provided configuration
-func buildContextInfo(config *beyla.Config) *global.ContextInfo {
-	promMgr := &connector.PrometheusManager{}
+// buildContextInfo populates some application-specific components and properties
+// from the user-provided configuration, on top of the shared context information
+func buildContextInfo(config *beyla.Config, shared *global.ContextInfo) *global.ContextInfo {
 	k8sCfg := &config.Attributes.Kubernetes
-	ctxInfo := &global.ContextInfo{
-		ReportRoutes: config.Routes != nil,
-		Prometheus:   promMgr,
-		K8sEnabled:   k8sCfg.Enabled(),
-	}
+	ctxInfo := *shared
+	ctxInfo.ReportRoutes = config.Routes != nil
+	ctxInfo.K8sEnabled = k8sCfg.Enabled()
 	if ctxInfo.K8sEnabled {
-		setupKubernetes(k8sCfg, ctxInfo)
-	}
-	if config.InternalMetrics.Prometheus.Port != 0 {
-		slog.Debug("reporting internal metrics as Prometheus")
-		ctxInfo.Metrics = imetrics.NewPrometheusReporter(&config.InternalMetrics.Prometheus, promMgr)
-		// Prometheus manager also has its own internal metrics, so we need to pass the imetrics reporter
-		// TODO: remove this dependency cycle and let prommgr to create and return the PrometheusReporter
-		promMgr.InstrumentWith(ctxInfo.Metrics)
-	} else {
-		slog.Debug("not reporting internal metrics")
-		ctxInfo.Metrics = imetrics.NoopReporter{}
+		setupKubernetes(k8sCfg, &ctxInfo)
 	}
-	return ctxInfo
+	return &ctxInfo
 }
 
 // setupKubernetes sets up common Kubernetes database and API clients that need to be accessed
@@ -0,0 +1,270 @@
+// Package capture records the raw events that Beyla reads from the eBPF programs, so
+// they can be replayed later through the same decoding functions and processing pipelines,
+// without requiring any eBPF.
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gavv/monotime"
+)
+
+func clog() *slog.Logger {
+	return slog.With("component", "capture.Recorder")
+}
+
+// Tracers of origin of the captured records
+const (
+	// TracerAppRingbuf records are the raw samples of the application ring buffer
+	TracerAppRingbuf = "app.ringbuf"
+	// TracerAppPIDs records are the changes in the PIDs that are allowed by the service discovery,
+	// which are required to decorate the spans with the service information
+	TracerAppPIDs = "app.pids"
+	// TracerNetRingbuf records are the raw samples of the network flows ring buffer
+	TracerNetRingbuf = "net.ringbuf"
+	// TracerNetMap records are the flows evicted from the network flows eBPF map
+	TracerNetMap = "net.map"
+	// TracerNetTCP records are the TCP statistics evicted from the network flows eBPF map
+	TracerNetTCP = "net.tcp"
+	// TracerNetIface records are the network interfaces where the flows are captured
+	TracerNetIface = "net.iface"
+)
+
+// flushPeriod of the buffered records into the capture file
+const flushPeriod = time.Second
+
+// FormatVersion of the capture files. It must be increased whenever the layout of the
+// records or the raw eBPF events changes, so the replay rejects the incompatible captures.
+const FormatVersion = 2
+
+// Header is written in the first line of the capture file
+type Header struct {
+	Version int `json:"capture_version"`
+}
+
+// Config of the capture mode
+type Config struct {
+	// Path of the file where the raw eBPF events are recorded. If empty, capture mode is disabled.
+	Path string `yaml:"path" env:"BEYLA_CAPTURE_PATH"`
+}
+
+// nolint:gocritic
+func (c Config) Enabled() bool {
+	return c.Path != ""
+}
+
+// Record of a raw eBPF event
+type Record struct {
+	// Time when the record was read
+	Time time.Time `json:"time"`
+	// Mono is the value of the monotonic clock, in nanoseconds, when the record was read. It allows
+	// converting the kernel monotonic timestamps of the events to wall time during the replay.
+	Mono int64 `json:"mono"`
+	// Tracer of origin of the record
+	Tracer string `json:"tracer"`
+	// Raw binary data of the record, as read from eBPF
+	Raw []byte `json:"raw,omitempty"`
+	// Event contains the information of non-binary records, in JSON format
+	Event json.RawMessage `json:"event,omitempty"`
+}
+
+// Recorder writes the captured records into a file, one JSON object per line.
+// It is safe for concurrent use. A nil Recorder does not record anything, so it can
+// be safely invoked when the capture mode is disabled.
+type Recorder struct {
+	mt      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	enc     *json.Encoder
+	clock   func() time.Time
+	mono    func() time.Duration
+	stopped chan struct{}
+}
+
+// NewRecorder creates the capture file, overwriting any previous content. As the
+// captured events may contain sensitive information, the file is only readable by its owner.
+func NewRecorder(cfg *Config) (*Recorder, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("creating capture file: %w", err)
+	}
+	buf := bufio.NewWriter(file)
+	if err := json.NewEncoder(buf).Encode(Header{Version: FormatVersion}); err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("writing capture header: %w", err)
+	}
+	r := &Recorder{
+		file:    file,
+		buf:     buf,
+		enc:     json.NewEncoder(buf),
+		clock:   time.Now,
+		mono:    monotime.Now,
+		stopped: make(chan struct{}),
+	}
+	go r.flushPeriodically()
+	return r, nil
+}
+
+// Record a raw binary sample from the given tracer
+func (r *Recorder) Record(tracer string, raw []byte) {
+	if r == nil {
+		return
+	}
+	r.write(&Record{Tracer: tracer, Raw: raw})
+}
+
+// RecordEvent records a non-binary event from the given tracer, encoded as JSON
+func (r *Recorder) RecordEvent(tracer string, event any) {
+	if r == nil {
+		return
+	}
+	ev, err := json.Marshal(event)
+	if err != nil {
+		clog().Warn("can't encode captured event", "tracer", tracer, "error", err)
+		return
+	}
+	r.write(&Record{Tracer: tracer, Event: ev})
+}
+
+func (r *Recorder) write(rec *Record) {
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	if r.file == nil {
+		return
+	}
+	rec.Time = r.clock()
+	rec.Mono = int64(r.mono())
+	if err := r.enc.Encode(rec); err != nil {
+		clog().Warn("can't write captured record", "tracer", rec.Tracer, "error", err)
+	}
+}
+
+func (r *Recorder) flushPeriodically() {
+	ticker := time.NewTicker(flushPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopped:
+			return
+		case <-ticker.C:
+			r.mt.Lock()
+			if r.file != nil {
+				if err := r.buf.Flush(); err != nil {
+					clog().Warn("can't flush capture file", "error", err)
+				}
+			}
+			r.mt.Unlock()
+		}
+	}
+}
+
+// Close flushes the pending records and closes the capture file
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	close(r.stopped)
+	flushErr := r.buf.Flush()
+	closeErr := r.file.Close()
+	r.file = nil
+	return errors.Join(flushErr, closeErr)
+}
+
+// Reader reads the records from a capture file
+type Reader struct {
+	dec *json.Decoder
+}
+
+// NewReader reads the header of the capture and returns an error if the capture
+// has been recorded with an incompatible format version.
+func NewReader(in io.Reader) (*Reader, error) {
+	dec := json.NewDecoder(bufio.NewReader(in))
+	var header Header
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("reading capture header: %w", err)
+	}
+	if header.Version != FormatVersion {
+		return nil, fmt.Errorf("unsupported capture format version %d. Expected version: %d",
+			header.Version, FormatVersion)
+	}
+	return &Reader{dec: dec}, nil
+}
+
+// Next record in the capture. It returns io.EOF when there are no more records.
+func (r *Reader) Next() (Record, error) {
+	var rec Record
+	if err := r.dec.Decode(&rec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return rec, io.EOF
+		}
+		return rec, fmt.Errorf("reading captured record: %w", err)
+	}
+	return rec, nil
+}
+
+// Feeder forwards the replayed records of a tracer into the processing pipeline
+type Feeder interface {
+	// Feed a record. The offset is the difference between the monotonic clock of the replay and
+	// the monotonic clock of the capture, which must be added to the kernel timestamps of the record.
+	Feed(rec *Record, offset time.Duration) error
+}
+
+// Replay reads all the records from the capture and feeds each of them to the Feeder of its
+// tracer. All the records are shifted the same offset, so they keep their relative times.
+// If realtime is true, the records are fed with the same pacing as they were captured.
+// Otherwise, they are fed as fast as they can be processed.
+func Replay(ctx context.Context, in *Reader, feeders map[string]Feeder, realtime bool) error {
+	log := slog.With("component", "capture.Replay")
+	var offset time.Duration
+	replayed, skipped := 0, 0
+	for first := true; ; first = false {
+		rec, err := in.Next()
+		if errors.Is(err, io.EOF) {
+			log.Info("replay finished", "replayed", replayed, "skipped", skipped)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			offset = monotime.Now() - time.Duration(rec.Mono)
+		}
+		if realtime {
+			wait := time.Duration(rec.Mono) + offset - monotime.Now()
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-time.After(wait):
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		feeder, ok := feeders[rec.Tracer]
+		if !ok {
+			log.Debug("no feeder for record. Skipping", "tracer", rec.Tracer)
+			skipped++
+			continue
+		}
+		if err := feeder.Feed(&rec, offset); err != nil {
+			return fmt.Errorf("replaying %s record from %s: %w", rec.Tracer, rec.Time, err)
+		}
+		replayed++
+	}
+}
@@ -0,0 +1,124 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder_NilIsNoop(t *testing.T) {
+	var r *Recorder
+	r.Record(TracerAppRingbuf, []byte{1, 2, 3})
+	r.RecordEvent(TracerAppPIDs, map[string]string{"foo": "bar"})
+	assert.NoError(t, r.Close())
+}
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.jsonl")
+	r, err := NewRecorder(&Config{Path: path})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.clock = func() time.Time { return now }
+	mono := time.Second
+	r.mono = func() time.Duration { mono += time.Second; return mono }
+
+	r.Record(TracerNetRingbuf, []byte{1, 2, 3})
+	r.RecordEvent(TracerNetIface, map[string]any{"Name": "eth0", "Index": 2})
+	require.NoError(t, r.Close())
+	// records after closing are ignored
+	r.Record(TracerNetRingbuf, []byte{4, 5, 6})
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte(`{"capture_version":2}`+"\n")))
+	reader, err := NewReader(bytes.NewReader(content))
+	require.NoError(t, err)
+
+	rec, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, Record{
+		Time: now, Mono: int64(2 * time.Second), Tracer: TracerNetRingbuf, Raw: []byte{1, 2, 3},
+	}, rec)
+
+	rec, err = reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*time.Second), rec.Mono)
+	assert.Equal(t, TracerNetIface, rec.Tracer)
+	assert.JSONEq(t, `{"Name":"eth0","Index":2}`, string(rec.Event))
+
+	_, err = reader.Next()
+	require.ErrorIs(t, err, io.EOF)
+}
+
+type fakeFeeder struct {
+	records []Record
+	offsets []time.Duration
+}
+
+func (f *fakeFeeder) Feed(rec *Record, offset time.Duration) error {
+	f.records = append(f.records, *rec)
+	f.offsets = append(f.offsets, offset)
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	capture := bytes.Buffer{}
+	for _, rec := range []string{
+		`{"capture_version":2}`,
+		`{"mono":1000,"tracer":"app.pids","event":{"op":"allow"}}`,
+		`{"mono":2000,"tracer":"app.ringbuf","raw":"AQI="}`,
+		`{"mono":3000,"tracer":"unknown","raw":"AQI="}`,
+		`{"mono":4000,"tracer":"app.ringbuf","raw":"AwQ="}`,
+	} {
+		capture.WriteString(rec + "\n")
+	}
+	reader, err := NewReader(&capture)
+	require.NoError(t, err)
+	app := &fakeFeeder{}
+	require.NoError(t, Replay(context.Background(), reader,
+		map[string]Feeder{TracerAppRingbuf: app, TracerAppPIDs: app}, true))
+
+	require.Len(t, app.records, 3)
+	assert.Equal(t, TracerAppPIDs, app.records[0].Tracer)
+	assert.Equal(t, []byte{1, 2}, app.records[1].Raw)
+	assert.Equal(t, []byte{3, 4}, app.records[2].Raw)
+	// all the records are shifted by the same offset
+	assert.Equal(t, app.offsets[0], app.offsets[1])
+	assert.Equal(t, app.offsets[0], app.offsets[2])
+}
+
+func TestReplay_Error(t *testing.T) {
+	capture := bytes.NewBufferString(`{"capture_version":2}` + "\n" +
+		`{"mono":1000,"tracer":"app.ringbuf","raw":"AQI="}` + "\n{malformed")
+	reader, err := NewReader(capture)
+	require.NoError(t, err)
+	app := &fakeFeeder{}
+	require.Error(t, Replay(context.Background(), reader,
+		map[string]Feeder{TracerAppRingbuf: app}, false))
+	assert.Len(t, app.records, 1)
+}
+
+func TestNewReader_IncompatibleVersion(t *testing.T) {
+	for _, header := range []string{
+		`{"capture_version":3}`,
+		// captures without header, recorded before the format was versioned
+		`{"mono":1000,"tracer":"app.ringbuf","raw":"AQI="}`,
+		``,
+	} {
+		t.Run(header, func(t *testing.T) {
+			_, err := NewReader(bytes.NewBufferString(header + "\n"))
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package connector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// minimum time between two checks for changes in the certificate files
+const certsCheckPeriod = 10 * time.Second
+
+var timeNow = time.Now
+
+// certsLoader provides the TLS configuration of the HTTPS servers, reloading the
+// certificate, key and client CA files when their modification time changes.
+type certsLoader struct {
+	cfg *TLSConfig
+
+	mt        sync.Mutex
+	lastCheck time.Time
+	modTimes  []time.Time
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// newCertsLoader returns a certsLoader after loading the files for the first time
+func newCertsLoader(cfg *TLSConfig) (*certsLoader, error) {
+	cl := &certsLoader{cfg: cfg}
+	modTimes, err := cl.statFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := cl.load(modTimes); err != nil {
+		return nil, err
+	}
+	return cl, nil
+}
+
+func (cl *certsLoader) files() []string {
+	files := []string{cl.cfg.CertFile, cl.cfg.KeyFile}
+	if cl.cfg.ClientCAFile != "" {
+		files = append(files, cl.cfg.ClientCAFile)
+	}
+	return files
+}
+
+func (cl *certsLoader) statFiles() ([]time.Time, error) {
+	files := cl.files()
+	modTimes := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("checking TLS file: %w", err)
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+	return modTimes, nil
+}
+
+func (cl *certsLoader) load(modTimes []time.Time) error {
+	cert, err := tls.LoadX509KeyPair(cl.cfg.CertFile, cl.cfg.KeyFile)
+	if err != nil {
+		return fmt.Errorf("loading TLS certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if cl.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cl.cfg.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("reading client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("client CA file does not contain any valid PEM certificate")
+		}
+	}
+	cl.cert = &cert
+	cl.clientCAs = clientCAs
+	cl.modTimes = modTimes
+	return nil
+}
+
+// current returns the last loaded certificates, reloading them if the files
+// have changed since the last check
+func (cl *certsLoader) current() (*tls.Certificate, *x509.CertPool) {
+	cl.mt.Lock()
+	defer cl.mt.Unlock()
+	now := timeNow()
+	if now.Sub(cl.lastCheck) < certsCheckPeriod {
+		return cl.cert, cl.clientCAs
+	}
+	cl.lastCheck = now
+	llog := log().With("certFile", cl.cfg.CertFile)
+	modTimes, err := cl.statFiles()
+	if err != nil {
+		llog.Warn("can't check TLS files. Keeping the previous certificates", "error", err)
+		return cl.cert, cl.clientCAs
+	}
+	if !cl.changed(modTimes) {
+		return cl.cert, cl.clientCAs
+	}
+	llog.Info("TLS files changed. Reloading certificates")
+	if err := cl.load(modTimes); err != nil {
+		llog.Warn("can't reload TLS files. Keeping the previous certificates", "error", err)
+	}
+	return cl.cert, cl.clientCAs
+}
+
+func (cl *certsLoader) changed(modTimes []time.Time) bool {
+	for i := range modTimes {
+		if !modTimes[i].Equal(cl.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsConfig returns a TLS configuration that always provides the current certificates
+func (cl *certsLoader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _ := cl.current()
+			return cert, nil
+		},
+		GetConfigForClient: func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := cl.current()
+			cfg := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+			if clientCAs != nil {
+				cfg.ClientCAs = clientCAs
+				cfg.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return cfg, nil
+		},
+	}
+}
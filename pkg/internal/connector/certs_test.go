@@ -0,0 +1,133 @@
+package connector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const timeout = 5 * time.Second
+
+func TestCertsLoader_Reload(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	dir := t.TempDir()
+	cfg := TLSConfig{CertFile: path.Join(dir, "cert.pem"), KeyFile: path.Join(dir, "key.pem")}
+	writeCert(t, "first", cfg.CertFile, cfg.KeyFile)
+
+	cl, err := newCertsLoader(&cfg)
+	require.NoError(t, err)
+	assert.Equal(t, "first", commonName(t, cl))
+
+	writeCert(t, "second", cfg.CertFile, cfg.KeyFile)
+	// force a different modification time, as file systems might have a low time resolution
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(cfg.CertFile, later, later))
+
+	// files are not checked again until the check period elapses
+	assert.Equal(t, "first", commonName(t, cl))
+	now = now.Add(certsCheckPeriod)
+	assert.Equal(t, "second", commonName(t, cl))
+
+	// wrong files keep the previous certificate
+	require.NoError(t, os.WriteFile(cfg.CertFile, []byte("wrong"), 0o600))
+	later = later.Add(time.Minute)
+	require.NoError(t, os.Chtimes(cfg.CertFile, later, later))
+	now = now.Add(certsCheckPeriod)
+	assert.Equal(t, "second", commonName(t, cl))
+}
+
+func TestPrometheusManager_TLSAndAuth(t *testing.T) {
+	dir := t.TempDir()
+	cfg := ServerConfig{
+		TLS:         TLSConfig{CertFile: path.Join(dir, "cert.pem"), KeyFile: path.Join(dir, "key.pem")},
+		BearerToken: "tok3n",
+	}
+	writeCert(t, "localhost", cfg.TLS.CertFile, cfg.TLS.KeyFile)
+
+	port := freePort(t)
+	pm := PrometheusManager{}
+	pm.SecureWith(&cfg)
+	pm.Register(port, "/metrics", prometheus.NewCounter(prometheus.CounterOpts{Name: "test_counter"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pm.StartHTTP(ctx)
+
+	client := http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+	}}
+	url := fmt.Sprintf("https://localhost:%d/metrics", port)
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		resp, err := client.Get(url)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer tok3n")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// plain HTTP is not accepted
+	resp, err = http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+	if err == nil {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func commonName(t *testing.T, cl *certsLoader) string {
+	cert, _ := cl.current()
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return parsed.Subject.CommonName
+}
+
+func writeCert(t *testing.T, cn, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     []string{cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
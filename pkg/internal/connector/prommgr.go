@@ -9,7 +9,7 @@ import (
 	"log/slog"
 	"net/http"
 	"strconv"
-	"sync/atomic"
+	"sync"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,12 +21,18 @@ func log() *slog.Logger {
 
 // PrometheusManager allows exporting metrics from different sources (instrumented metrics, internal metrics...)
 // sharing the same port and path, or using different ones, depending on the configuration provided by the registrars.
+// It can be shared by different pipelines (e.g. application and network metrics).
 type PrometheusManager struct {
-	started atomic.Bool
+	mt sync.Mutex
+	// startCtx is set when StartHTTP is invoked for the first time
+	startCtx context.Context
 	// key 1: port. Key 2: path
 	registries map[int]map[string]*prometheus.Registry
+	// key: port
+	muxes map[int]*http.ServeMux
 
 	metrics internalIntrumenter
+	server  *ServerConfig
 }
 
 type internalIntrumenter interface {
@@ -37,11 +43,19 @@ func (pm *PrometheusManager) InstrumentWith(ii internalIntrumenter) {
 	pm.metrics = ii
 }
 
+// SecureWith sets the TLS and authentication configuration of all the HTTP servers
+// opened by the manager. It must be invoked before StartHTTP.
+func (pm *PrometheusManager) SecureWith(cfg *ServerConfig) {
+	pm.server = cfg
+}
+
 // Register a set of prometheus metrics to be accessible through an HTTP port/path.
-// This method is not thread-safe
+// If the HTTP servers have been already started, the port/path is served immediately.
 func (pm *PrometheusManager) Register(port int, path string, collectors ...prometheus.Collector) {
 	log().Debug("registering Prometheus metrics collectors",
 		"len", len(collectors), "port", port, "path", path)
+	pm.mt.Lock()
+	defer pm.mt.Unlock()
 	if pm.registries == nil {
 		pm.registries = map[int]map[string]*prometheus.Registry{}
 	}
@@ -54,31 +68,56 @@ func (pm *PrometheusManager) Register(port int, path string, collectors ...prome
 	if !ok {
 		reg = prometheus.NewRegistry()
 		paths[path] = reg
+		if pm.startCtx != nil {
+			pm.serve(port, path, reg)
+		}
 	}
 	reg.MustRegister(collectors...)
 }
 
-// StartHTTP serves metrics in background. Its invocation won't have effect if it has been invoked previously,
-// so invoke it only after you are sure that all the collectors have been registered via the Register method.
+// StartHTTP serves metrics in background. Its invocation won't have effect if it has been invoked previously.
+// Collectors that are registered after its invocation are also served.
 func (pm *PrometheusManager) StartHTTP(ctx context.Context) {
-	if pm.started.Swap(true) {
+	pm.mt.Lock()
+	defer pm.mt.Unlock()
+	if pm.startCtx != nil {
 		return
 	}
-	log := log()
-	// Creating a serve mux for each port
+	pm.startCtx = ctx
 	for port, paths := range pm.registries {
-		mux := http.NewServeMux()
 		for path, registry := range paths {
-			log.With("port", port, "path", path).Info("opening prometheus scrape endpoint")
-			promHandler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
-			promHandler = wrapDebugHandler(log, promHandler)
-			promHandler = wrapInstrumentedHandler(pm.metrics, port, path, promHandler)
-			mux.Handle(path, promHandler)
+			pm.serve(port, path, registry)
 		}
-		pm.listenAndServe(ctx, port, mux)
 	}
 }
 
+// serve the registry in the given port/path, opening a new HTTP server if the port
+// wasn't served yet. It must be invoked with the mutex locked.
+func (pm *PrometheusManager) serve(port int, path string, registry *prometheus.Registry) {
+	log := log()
+	log.With("port", port, "path", path).Info("opening prometheus scrape endpoint")
+	promHandler := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		Registry: registry,
+		// OpenMetrics is only served when the scraper explicitly asks for it in the Accept header.
+		// It is required to expose the histogram exemplars.
+		EnableOpenMetrics: true,
+	})
+	promHandler = wrapDebugHandler(log, promHandler)
+	promHandler = wrapInstrumentedHandler(pm.metrics, port, path, promHandler)
+	promHandler = wrapAuthHandler(pm.server, promHandler)
+	if mux, ok := pm.muxes[port]; ok {
+		mux.Handle(path, promHandler)
+		return
+	}
+	if pm.muxes == nil {
+		pm.muxes = map[int]*http.ServeMux{}
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, promHandler)
+	pm.muxes[port] = mux
+	pm.listenAndServe(pm.startCtx, port, mux)
+}
+
 func wrapInstrumentedHandler(metrics internalIntrumenter, port int, path string, promHandler http.Handler) http.HandlerFunc {
 	// we don't wrap anything if the reporter is nil
 	if metrics == nil {
@@ -103,11 +142,25 @@ func wrapDebugHandler(log *slog.Logger, promHandler http.Handler) http.HandlerFu
 }
 
 func (pm *PrometheusManager) listenAndServe(ctx context.Context, port int, handler http.Handler) {
-	// TODO: support TLS configuration
 	server := http.Server{Addr: fmt.Sprintf(":%d", port), Handler: handler}
 	log := log().With("port", port)
+	secure := pm.server != nil && pm.server.TLS.Enabled()
+	if secure {
+		certs, err := newCertsLoader(&pm.server.TLS)
+		if err != nil {
+			log.Error("can't load TLS certificates. Not opening the Prometheus endpoint", "error", err)
+			return
+		}
+		server.TLSConfig = certs.tlsConfig()
+	}
 	go func() {
-		err := server.ListenAndServe()
+		var err error
+		if secure {
+			// certificates are provided by the server TLS configuration
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
 		if errors.Is(err, http.ErrServerClosed) {
 			log.Debug("HTTP server was closed", "err", err)
 		} else {
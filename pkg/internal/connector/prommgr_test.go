@@ -0,0 +1,49 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrometheusManager_RegisterAfterStart(t *testing.T) {
+	port1, port2 := freePort(t), freePort(t)
+	pm := PrometheusManager{}
+	pm.Register(port1, "/metrics", prometheus.NewCounter(prometheus.CounterOpts{Name: "first_counter"}))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pm.StartHTTP(ctx)
+
+	// collectors registered after the start are served in the existing and new ports and paths
+	pm.Register(port1, "/metrics", prometheus.NewCounter(prometheus.CounterOpts{Name: "second_counter"}))
+	pm.Register(port1, "/other", prometheus.NewCounter(prometheus.CounterOpts{Name: "third_counter"}))
+	pm.Register(port2, "/metrics", prometheus.NewCounter(prometheus.CounterOpts{Name: "fourth_counter"}))
+
+	for _, tc := range []struct {
+		url      string
+		contains []string
+	}{
+		{url: fmt.Sprintf("http://localhost:%d/metrics", port1), contains: []string{"first_counter", "second_counter"}},
+		{url: fmt.Sprintf("http://localhost:%d/other", port1), contains: []string{"third_counter"}},
+		{url: fmt.Sprintf("http://localhost:%d/metrics", port2), contains: []string{"fourth_counter"}},
+	} {
+		test.Eventually(t, timeout, func(t require.TestingT) {
+			resp, err := http.Get(tc.url)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			for _, c := range tc.contains {
+				assert.Contains(t, string(body), c)
+			}
+		})
+	}
+}
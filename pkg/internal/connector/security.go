@@ -0,0 +1,98 @@
+package connector
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ServerConfig specifies the security settings of the HTTP servers opened by the PrometheusManager
+type ServerConfig struct {
+	TLS       TLSConfig `yaml:"tls"`
+	BasicAuth BasicAuth `yaml:"basic_auth"`
+	// BearerToken, if set, is accepted as a valid "Authorization: Bearer" header
+	BearerToken string `yaml:"bearer_token" env:"BEYLA_PROMETHEUS_BEARER_TOKEN"`
+}
+
+// TLSConfig enables HTTPS in the Prometheus endpoints. The certificate files are reloaded
+// when they change, so they can be rotated without restarting Beyla.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" env:"BEYLA_PROMETHEUS_TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"BEYLA_PROMETHEUS_TLS_KEY_FILE"`
+	// ClientCAFile, if set, requires the scrapers to present a client certificate signed
+	// by any of the authorities in the file
+	ClientCAFile string `yaml:"client_ca_file" env:"BEYLA_PROMETHEUS_TLS_CLIENT_CA_FILE"`
+}
+
+// BasicAuth credentials that are accepted by the Prometheus endpoints
+type BasicAuth struct {
+	Username string `yaml:"username" env:"BEYLA_PROMETHEUS_BASIC_AUTH_USERNAME"`
+	Password string `yaml:"password" env:"BEYLA_PROMETHEUS_BASIC_AUTH_PASSWORD"`
+}
+
+func (t *TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+func (b *BasicAuth) Enabled() bool {
+	return b.Username != "" || b.Password != ""
+}
+
+func (s *ServerConfig) authEnabled() bool {
+	return s.BasicAuth.Enabled() || s.BearerToken != ""
+}
+
+func (s *ServerConfig) Validate() error {
+	if s.TLS.Enabled() && (s.TLS.CertFile == "" || s.TLS.KeyFile == "") {
+		return errors.New("TLS requires both cert_file and key_file")
+	}
+	if s.TLS.ClientCAFile != "" && !s.TLS.Enabled() {
+		return errors.New("client_ca_file requires setting cert_file and key_file")
+	}
+	if s.BasicAuth.Enabled() && (s.BasicAuth.Username == "" || s.BasicAuth.Password == "") {
+		return errors.New("basic_auth requires both username and password")
+	}
+	return nil
+}
+
+// wrapAuthHandler rejects the requests that do not provide any of the configured
+// basic auth or bearer token credentials
+func wrapAuthHandler(cfg *ServerConfig, promHandler http.Handler) http.HandlerFunc {
+	// we don't wrap anything if authentication is not configured
+	if cfg == nil || !cfg.authEnabled() {
+		return promHandler.ServeHTTP
+	}
+	return func(rw http.ResponseWriter, req *http.Request) {
+		if authorized(cfg, req) {
+			promHandler.ServeHTTP(rw, req)
+			return
+		}
+		if cfg.BasicAuth.Enabled() {
+			rw.Header().Set("WWW-Authenticate", `Basic realm="beyla"`)
+		} else {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	}
+}
+
+func authorized(cfg *ServerConfig, req *http.Request) bool {
+	if cfg.BasicAuth.Enabled() {
+		if user, pass, ok := req.BasicAuth(); ok &&
+			secureEquals(user, cfg.BasicAuth.Username) && secureEquals(pass, cfg.BasicAuth.Password) {
+			return true
+		}
+	}
+	if cfg.BearerToken != "" {
+		auth := req.Header.Get("Authorization")
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok && secureEquals(token, cfg.BearerToken) {
+			return true
+		}
+	}
+	return false
+}
+
+func secureEquals(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
@@ -0,0 +1,52 @@
+package connector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	type testCase struct {
+		name     string
+		cfg      *ServerConfig
+		setup    func(req *http.Request)
+		expected int
+	}
+	basic := &ServerConfig{BasicAuth: BasicAuth{Username: "user", Password: "pass"}}
+	bearer := &ServerConfig{BearerToken: "tok3n"}
+	both := &ServerConfig{BasicAuth: BasicAuth{Username: "user", Password: "pass"}, BearerToken: "tok3n"}
+	for _, tc := range []testCase{
+		{name: "no auth", cfg: &ServerConfig{}, setup: func(_ *http.Request) {}, expected: http.StatusOK},
+		{name: "nil config", cfg: nil, setup: func(_ *http.Request) {}, expected: http.StatusOK},
+		{name: "basic ok", cfg: basic, setup: func(r *http.Request) { r.SetBasicAuth("user", "pass") }, expected: http.StatusOK},
+		{name: "basic wrong", cfg: basic, setup: func(r *http.Request) { r.SetBasicAuth("user", "wrong") }, expected: http.StatusUnauthorized},
+		{name: "basic missing", cfg: basic, setup: func(_ *http.Request) {}, expected: http.StatusUnauthorized},
+		{name: "bearer ok", cfg: bearer, setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok3n") }, expected: http.StatusOK},
+		{name: "bearer wrong", cfg: bearer, setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer foo") }, expected: http.StatusUnauthorized},
+		{name: "bearer as basic", cfg: bearer, setup: func(r *http.Request) { r.SetBasicAuth("tok3n", "tok3n") }, expected: http.StatusUnauthorized},
+		{name: "both, using basic", cfg: both, setup: func(r *http.Request) { r.SetBasicAuth("user", "pass") }, expected: http.StatusOK},
+		{name: "both, using bearer", cfg: both, setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer tok3n") }, expected: http.StatusOK},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			tc.setup(req)
+			rec := httptest.NewRecorder()
+			wrapAuthHandler(tc.cfg, ok).ServeHTTP(rec, req)
+			assert.Equal(t, tc.expected, rec.Code)
+		})
+	}
+}
+
+func TestServerConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&ServerConfig{}).Validate())
+	assert.NoError(t, (&ServerConfig{TLS: TLSConfig{CertFile: "a", KeyFile: "b", ClientCAFile: "c"}}).Validate())
+	assert.Error(t, (&ServerConfig{TLS: TLSConfig{CertFile: "a"}}).Validate())
+	assert.Error(t, (&ServerConfig{TLS: TLSConfig{ClientCAFile: "c"}}).Validate())
+	assert.Error(t, (&ServerConfig{BasicAuth: BasicAuth{Username: "user"}}).Validate())
+}
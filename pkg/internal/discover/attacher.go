@@ -28,7 +28,9 @@ type TraceAttacher struct {
 	DiscoveredTracers chan *ebpf.ProcessTracer
 	DeleteTracers     chan *Instrumentable
 	Metrics           imetrics.Reporter
-	pinPath           string
+	// Services registry is notified about the PIDs of the instrumented processes. It can be nil.
+	Services *svc.PIDRegistry
+	pinPath  string
 
 	// processInstances keeps track of the instances of each process. This will help making sure
 	// that we don't remove the BPF resources of an executable until all their instances are removed
@@ -38,12 +40,17 @@ type TraceAttacher struct {
 	// keeps a copy of all the tracers for a given executable path
 	existingTracers map[uint64]*ebpf.ProcessTracer
 	reusableTracer  *ebpf.ProcessTracer
+
+	// childPIDs keeps the children of each instrumented PID, to remove them from the
+	// Services registry when the parent process is deleted
+	childPIDs map[int32][]uint32
 }
 
 //nolint:gocritic
 func TraceAttacherProvider(ta TraceAttacher) (node.TerminalFunc[[]Event[Instrumentable]], error) {
 	ta.log = slog.With("component", "discover.TraceAttacher")
 	ta.existingTracers = map[uint64]*ebpf.ProcessTracer{}
+	ta.childPIDs = map[int32][]uint32{}
 	ta.processInstances = helpers.MultiCounter[uint64]{}
 	ta.pinPath = BuildPinPath(ta.Cfg)
 
@@ -87,9 +94,9 @@ func (ta *TraceAttacher) getTracer(ie *Instrumentable) (*ebpf.ProcessTracer, boo
 			"exec", ie.FileInfo.CmdExePath)
 		ie.FileInfo.Service.SDKLanguage = ie.Type
 		// allowing the tracer to forward traces from the new PID and its children processes
-		monitorPIDs(tracer, ie)
+		ta.monitorPIDs(tracer, ie)
 		if tracer.Type == ebpf.Generic {
-			monitorPIDs(ta.reusableTracer, ie)
+			ta.monitorPIDs(ta.reusableTracer, ie)
 		}
 		ta.log.Debug(".done")
 		return nil, false
@@ -157,11 +164,11 @@ func (ta *TraceAttacher) getTracer(ie *Instrumentable) (*ebpf.ProcessTracer, boo
 		"child", ie.ChildPids,
 		"exec", ie.FileInfo.CmdExePath)
 	// allowing the tracer to forward traces from the discovered PID and its children processes
-	monitorPIDs(tracer, ie)
+	ta.monitorPIDs(tracer, ie)
 	ta.existingTracers[ie.FileInfo.Ino] = tracer
 	if tracer.Type == ebpf.Generic {
 		if ta.reusableTracer != nil {
-			monitorPIDs(ta.reusableTracer, ie)
+			ta.monitorPIDs(ta.reusableTracer, ie)
 		} else {
 			ta.reusableTracer = tracer
 		}
@@ -170,7 +177,7 @@ func (ta *TraceAttacher) getTracer(ie *Instrumentable) (*ebpf.ProcessTracer, boo
 	return tracer, true
 }
 
-func monitorPIDs(tracer *ebpf.ProcessTracer, ie *Instrumentable) {
+func (ta *TraceAttacher) monitorPIDs(tracer *ebpf.ProcessTracer, ie *Instrumentable) {
 	// If the user does not override the service name via configuration
 	// the service name is the name of the found executable
 	// Unless the case of system-wide tracing, where the name of the
@@ -184,8 +191,13 @@ func monitorPIDs(tracer *ebpf.ProcessTracer, ie *Instrumentable) {
 
 	// allowing the tracer to forward traces from the discovered PID and its children processes
 	tracer.AllowPID(uint32(ie.FileInfo.Pid), ie.FileInfo.Service)
+	ta.Services.Add(ie.FileInfo.Pid, &ie.FileInfo.Service)
 	for _, pid := range ie.ChildPids {
 		tracer.AllowPID(pid, ie.FileInfo.Service)
+		ta.Services.Add(int32(pid), &ie.FileInfo.Service)
+	}
+	if len(ie.ChildPids) > 0 {
+		ta.childPIDs[ie.FileInfo.Pid] = ie.ChildPids
 	}
 }
 
@@ -205,6 +217,11 @@ func (ta *TraceAttacher) notifyProcessDeletion(ie *Instrumentable) {
 		// to avoid that a new process reusing this PID could send traces
 		// unless explicitly allowed
 		tracer.BlockPID(uint32(ie.FileInfo.Pid))
+		ta.Services.Remove(ie.FileInfo.Pid)
+		for _, pid := range ta.childPIDs[ie.FileInfo.Pid] {
+			ta.Services.Remove(int32(pid))
+		}
+		delete(ta.childPIDs, ie.FileInfo.Pid)
 
 		// if there are no more trace instances for a Go program, we need to notify that
 		// the tracer needs to be stopped and deleted.
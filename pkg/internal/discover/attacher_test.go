@@ -0,0 +1,43 @@
+package discover
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/ebpf"
+	"github.com/grafana/beyla/pkg/internal/exec"
+	"github.com/grafana/beyla/pkg/internal/helpers"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestTraceAttacher_ServicesRegistry(t *testing.T) {
+	services := svc.NewPIDRegistry()
+	tracer := &ebpf.ProcessTracer{Type: ebpf.Generic}
+	ta := TraceAttacher{
+		log:              slog.Default(),
+		Services:         services,
+		existingTracers:  map[uint64]*ebpf.ProcessTracer{123: tracer},
+		processInstances: helpers.MultiCounter[uint64]{},
+		childPIDs:        map[int32][]uint32{},
+	}
+
+	ie := &Instrumentable{
+		FileInfo:  &exec.FileInfo{Pid: 10, Ino: 123, Service: svc.ID{Name: "foo"}},
+		ChildPids: []uint32{11, 12},
+	}
+	ta.monitorPIDs(tracer, ie)
+	for _, pid := range []int32{10, 11, 12} {
+		id, ok := services.Get(pid)
+		assert.Truef(t, ok, "pid %d", pid)
+		assert.Equal(t, "foo", id.Name)
+	}
+
+	// the children are removed with their parent process
+	ta.notifyProcessDeletion(&Instrumentable{FileInfo: &exec.FileInfo{Pid: 10, Ino: 123}})
+	for _, pid := range []int32{10, 11, 12} {
+		_, ok := services.Get(pid)
+		assert.Falsef(t, ok, "pid %d", pid)
+	}
+}
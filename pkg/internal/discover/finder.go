@@ -43,6 +43,7 @@ func NewProcessFinder(ctx context.Context, cfg *beyla.Config, ctxInfo *global.Co
 			DiscoveredTracers: make(chan *ebpf.ProcessTracer),
 			DeleteTracers:     make(chan *Instrumentable),
 			Metrics:           ctxInfo.Metrics,
+			Services:          ctxInfo.InstrumentedServices,
 		},
 	}
 	if ctxInfo.K8sEnabled {
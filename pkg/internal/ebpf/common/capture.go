@@ -0,0 +1,202 @@
+package ebpfcommon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/ringbuf"
+
+	"github.com/grafana/beyla/pkg/internal/capture"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// recorder of the raw ring buffer records and the PIDs filter changes. If nil, nothing is recorded.
+var recorder *capture.Recorder
+
+// CaptureWith sets the recorder of the raw eBPF events. It must be invoked before
+// instantiating any tracer.
+func CaptureWith(r *capture.Recorder) {
+	recorder = r
+}
+
+const (
+	pidsEventAllow = "allow"
+	pidsEventBlock = "block"
+	// pidsEventHost records the service information of a host PID, when Beyla runs system-wide
+	pidsEventHost = "host"
+)
+
+// pidsEvent records a change in the PIDs filters, after the namespaced PIDs have been resolved, so
+// the spans can be decorated with the service information during the replay without accessing
+// the /proc filesystem of the original host.
+type pidsEvent struct {
+	Op        string   `json:"op"`
+	Namespace uint32   `json:"ns,omitempty"`
+	PIDs      []uint32 `json:"pids"`
+	Type      PIDType  `json:"type,omitempty"`
+	Service   *svc.ID  `json:"service,omitempty"`
+}
+
+// AppReplay forwards the captured application ring buffer records through the same
+// decoding, batching and filtering functions as the ring buffer of a live eBPF tracer.
+type AppReplay struct {
+	records chan replayRecord
+	done    chan struct{}
+	closed  sync.Once
+	filter  *replayFilter
+	// offset between the monotonic clocks of the replay and the capture
+	offset time.Duration
+}
+
+func NewAppReplay() *AppReplay {
+	return &AppReplay{
+		records: make(chan replayRecord),
+		done:    make(chan struct{}),
+		filter: &replayFilter{
+			pids:  NewPIDsFilter(slog.With("component", "ebpfCommon.ReplayPIDsFilter")),
+			hosts: map[uint32]svc.ID{},
+		},
+	}
+}
+
+// Feed a captured record into the replay. It blocks until the record is read by the
+// forwarder, or the replay is closed.
+func (ar *AppReplay) Feed(rec *capture.Record, offset time.Duration) error {
+	switch rec.Tracer {
+	case capture.TracerAppRingbuf:
+		select {
+		case ar.records <- replayRecord{raw: rec.Raw, offset: offset}:
+		case <-ar.done:
+		}
+		return nil
+	case capture.TracerAppPIDs:
+		ev := pidsEvent{}
+		if err := json.Unmarshal(rec.Event, &ev); err != nil {
+			return fmt.Errorf("decoding captured PIDs event: %w", err)
+		}
+		ar.filter.apply(&ev)
+		return nil
+	default:
+		return fmt.Errorf("unexpected tracer for application replay: %q", rec.Tracer)
+	}
+}
+
+// Forwarder returns a function that reads the fed records and forwards them as spans,
+// as SharedRingbuf does in a live tracer.
+func (ar *AppReplay) Forwarder(cfg *TracerConfig, metrics imetrics.Reporter) func(context.Context, chan<- []request.Span) {
+	rbf := ringBufForwarder{
+		cfg:     cfg,
+		logger:  slog.With("component", "ringbuf.Replay"),
+		reader:  ar.readSpan,
+		filter:  ar.filter.Filter,
+		metrics: metrics,
+	}
+	rbf.newReader = func() (ringBufReader, error) {
+		return ar, nil
+	}
+	return rbf.readAndForward
+}
+
+// Read implements the ringBufReader interface
+func (ar *AppReplay) Read() (ringbuf.Record, error) {
+	select {
+	case rec := <-ar.records:
+		ar.offset = rec.offset
+		return ringbuf.Record{RawSample: rec.raw}, nil
+	case <-ar.done:
+		return ringbuf.Record{}, ringbuf.ErrClosed
+	}
+}
+
+// Close implements the ringBufReader interface
+func (ar *AppReplay) Close() error {
+	ar.closed.Do(func() {
+		close(ar.done)
+	})
+	return nil
+}
+
+func (ar *AppReplay) readSpan(record *ringbuf.Record) (request.Span, bool, error) {
+	span, ignore, err := ReadHTTPRequestTraceAsSpan(record)
+	if err != nil || ignore {
+		return span, ignore, err
+	}
+	// the kernel monotonic times of the span are shifted to the monotonic clock of the replay
+	for _, t := range []*int64{&span.RequestStart, &span.Start, &span.End} {
+		if *t != 0 {
+			*t += int64(ar.offset)
+		}
+	}
+	return span, false, nil
+}
+
+type replayRecord struct {
+	raw    []byte
+	offset time.Duration
+}
+
+// replayFilter decorates the spans with the captured service information of their PIDs
+type replayFilter struct {
+	pids *PIDsFilter
+	// hosts contains the services of each host PID, when the capture was system-wide
+	mt    sync.RWMutex
+	hosts map[uint32]svc.ID
+}
+
+func (rf *replayFilter) apply(ev *pidsEvent) {
+	switch ev.Op {
+	case pidsEventAllow:
+		if ev.Service == nil {
+			return
+		}
+		rf.pids.mux.Lock()
+		ns, ok := rf.pids.current[ev.Namespace]
+		if !ok {
+			ns = map[uint32]PIDInfo{}
+			rf.pids.current[ev.Namespace] = ns
+		}
+		for _, pid := range ev.PIDs {
+			ns[pid] = PIDInfo{service: *ev.Service, pidType: ev.Type}
+		}
+		rf.pids.mux.Unlock()
+	case pidsEventBlock:
+		rf.pids.mux.Lock()
+		if ns, ok := rf.pids.current[ev.Namespace]; ok {
+			for _, pid := range ev.PIDs {
+				delete(ns, pid)
+			}
+			if len(ns) == 0 {
+				delete(rf.pids.current, ev.Namespace)
+			}
+		}
+		rf.pids.mux.Unlock()
+	case pidsEventHost:
+		if ev.Service == nil {
+			return
+		}
+		rf.mt.Lock()
+		for _, pid := range ev.PIDs {
+			rf.hosts[pid] = *ev.Service
+		}
+		rf.mt.Unlock()
+	}
+}
+
+func (rf *replayFilter) Filter(spans []request.Span) []request.Span {
+	rf.mt.RLock()
+	defer rf.mt.RUnlock()
+	if len(rf.hosts) == 0 {
+		return rf.pids.Filter(spans)
+	}
+	// system-wide capture: as the IdentityPidsFilter, any span is forwarded
+	for i := range spans {
+		spans[i].ServiceID = rf.hosts[spans[i].Pid.HostPID]
+	}
+	return spans
+}
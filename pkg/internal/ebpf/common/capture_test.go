@@ -0,0 +1,82 @@
+package ebpfcommon
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/capture"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+func TestAppReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	replay := NewAppReplay()
+	spans := make(chan []request.Span, 10)
+	go replay.Forwarder(&TracerConfig{BatchLength: 2}, imetrics.NoopReporter{})(ctx, spans)
+
+	// GIVEN a PIDs event that allows a service
+	require.NoError(t, replay.Feed(pidsRecord(t, &pidsEvent{
+		Op: pidsEventAllow, Namespace: 33, PIDs: []uint32{12},
+		Type: PIDTypeGo, Service: &svc.ID{Name: "my-service"},
+	}), 0))
+
+	// WHEN the captured ring buffer records are fed
+	offset := time.Hour
+	require.NoError(t, replay.Feed(traceRecord(t, 12, 33, 10), offset))
+	// spans from non-allowed PIDs are filtered
+	require.NoError(t, replay.Feed(traceRecord(t, 13, 33, 20), offset))
+	require.NoError(t, replay.Feed(traceRecord(t, 12, 33, 30), offset))
+
+	// THEN the spans are decorated with the service, and their times are shifted by the offset
+	batch := testutil.ReadChannel(t, spans, testTimeout)
+	require.Len(t, batch, 1)
+	assert.Equal(t, "my-service", batch[0].ServiceID.Name)
+	assert.Equal(t, int64(10)+int64(offset), batch[0].Start)
+	assert.Equal(t, int64(11)+int64(offset), batch[0].End)
+
+	// AND blocked PIDs are not forwarded anymore
+	require.NoError(t, replay.Feed(pidsRecord(t, &pidsEvent{
+		Op: pidsEventBlock, Namespace: 33, PIDs: []uint32{12},
+	}), 0))
+	require.NoError(t, replay.Feed(traceRecord(t, 12, 33, 40), offset))
+	require.NoError(t, replay.Feed(traceRecord(t, 12, 33, 50), offset))
+	batch = testutil.ReadChannel(t, spans, testTimeout)
+	assert.Empty(t, batch)
+}
+
+func TestReplayFilter_SystemWide(t *testing.T) {
+	replay := NewAppReplay()
+	replay.filter.apply(&pidsEvent{Op: pidsEventHost, PIDs: []uint32{1}, Service: &svc.ID{Name: "foo"}})
+	spans := replay.filter.Filter([]request.Span{
+		{Pid: request.PidInfo{HostPID: 1}},
+		{Pid: request.PidInfo{HostPID: 2}},
+	})
+	require.Len(t, spans, 2)
+	assert.Equal(t, "foo", spans[0].ServiceID.Name)
+	assert.Empty(t, spans[1].ServiceID.Name)
+}
+
+func pidsRecord(t *testing.T, ev *pidsEvent) *capture.Record {
+	raw, err := json.Marshal(ev)
+	require.NoError(t, err)
+	return &capture.Record{Tracer: capture.TracerAppPIDs, Event: raw}
+}
+
+func traceRecord(t *testing.T, pid, ns uint32, start uint64) *capture.Record {
+	trace := HTTPRequestTrace{Type: 1, StartMonotimeNs: start, EndMonotimeNs: start + 1}
+	trace.Pid.HostPid, trace.Pid.UserPid, trace.Pid.Ns = pid, pid, ns
+	raw := bytes.Buffer{}
+	require.NoError(t, binary.Write(&raw, binary.LittleEndian, &trace))
+	return &capture.Record{Tracer: capture.TracerAppRingbuf, Raw: raw.Bytes()}
+}
@@ -6,6 +6,7 @@ import (
 
 	lru "github.com/hashicorp/golang-lru/v2"
 
+	"github.com/grafana/beyla/pkg/internal/capture"
 	"github.com/grafana/beyla/pkg/internal/exec"
 	"github.com/grafana/beyla/pkg/internal/request"
 	"github.com/grafana/beyla/pkg/internal/svc"
@@ -166,6 +167,9 @@ func (pf *PIDsFilter) addPID(pid uint32, s svc.ID, t PIDType) {
 	for _, p := range allPids {
 		ns[p] = PIDInfo{service: s, pidType: t}
 	}
+	recorder.RecordEvent(capture.TracerAppPIDs, &pidsEvent{
+		Op: pidsEventAllow, Namespace: nsid, PIDs: allPids, Type: t, Service: &s,
+	})
 }
 
 func (pf *PIDsFilter) removePID(pid uint32) {
@@ -189,6 +193,9 @@ func (pf *PIDsFilter) removePID(pid uint32) {
 	if len(ns) == 0 {
 		delete(pf.current, nsid)
 	}
+	recorder.RecordEvent(capture.TracerAppPIDs, &pidsEvent{
+		Op: pidsEventBlock, Namespace: nsid, PIDs: []uint32{pid},
+	})
 }
 
 // IdentityPidsFilter is a PIDsFilter that does not filter anything. It is feasible
@@ -222,6 +229,9 @@ func serviceInfo(pid uint32) svc.ID {
 	result := svc.ID{Name: name, SDKLanguage: lang}
 
 	activePids.Add(pid, result)
+	recorder.RecordEvent(capture.TracerAppPIDs, &pidsEvent{
+		Op: pidsEventHost, PIDs: []uint32{pid}, Service: &result,
+	})
 
 	return result
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/ringbuf"
 
+	"github.com/grafana/beyla/pkg/internal/capture"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/request"
 )
@@ -38,6 +39,10 @@ type ringBufForwarder struct {
 	access     sync.Mutex
 	ticker     *time.Ticker
 	reader     func(*ringbuf.Record) (request.Span, bool, error)
+	// newReader instantiates the reader of the raw ring buffer records
+	newReader func() (ringBufReader, error)
+	// capture the raw ring buffer records, if not nil
+	capture *capture.Recorder
 	// filter the input spans, eliminating these from processes whose PID
 	// belong to a process that does not match the discovery policies
 	filter  func([]request.Span) []request.Span
@@ -70,7 +75,9 @@ func SharedRingbuf(
 		cfg: cfg, logger: log, ringbuffer: ringbuffer,
 		closers: closers, reader: ReadHTTPRequestTraceAsSpan,
 		filter: filter.Filter, metrics: metrics,
+		capture: recorder,
 	}
+	rbf.newReader = rbf.readerForMap
 	singleRbf = &rbf
 	return singleRbf.readAndForward
 }
@@ -89,14 +96,19 @@ func ForwardRingbuf(
 		closers: closers, reader: reader,
 		filter: filter.Filter, metrics: metrics,
 	}
+	rbf.newReader = rbf.readerForMap
 	return rbf.readAndForward
 }
 
+func (rbf *ringBufForwarder) readerForMap() (ringBufReader, error) {
+	return readerFactory(rbf.ringbuffer)
+}
+
 func (rbf *ringBufForwarder) readAndForward(ctx context.Context, spansChan chan<- []request.Span) {
 	rbf.logger.Debug("start reading and forwarding")
 	// BPF will send each measured trace via Ring Buffer, so we listen for them from the
 	// user space.
-	eventsReader, err := readerFactory(rbf.ringbuffer)
+	eventsReader, err := rbf.newReader()
 	if err != nil {
 		rbf.logger.Error("creating perf reader. Exiting", err)
 		return
@@ -152,6 +164,7 @@ func (rbf *ringBufForwarder) alreadyForwarded(ctx context.Context, _ chan<- []re
 func (rbf *ringBufForwarder) processAndForward(record ringbuf.Record, spansChan chan<- []request.Span) {
 	rbf.access.Lock()
 	defer rbf.access.Unlock()
+	rbf.capture.Record(capture.TracerAppRingbuf, record.RawSample)
 	s, ignore, err := rbf.reader(&record)
 	if err != nil {
 		rbf.logger.Error("error parsing perf event", err)
@@ -0,0 +1,145 @@
+// Package attributes allows selecting which optional attributes are reported
+// by the application metrics exporters (OpenTelemetry and Prometheus).
+package attributes
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Section of the application metrics whose attributes can be selected.
+// Each section groups the metrics sharing the same attributes set (e.g. the
+// duration and the request body size of the HTTP server requests).
+type Section string
+
+const (
+	SectionHTTPServer = Section("http_server")
+	SectionHTTPClient = Section("http_client")
+	SectionRPCServer  = Section("rpc_server")
+	SectionRPCClient  = Section("rpc_client")
+	SectionSQLClient  = Section("sql_client")
+)
+
+// Name of an optional metric attribute. Its value is the name of the attribute
+// as exposed by Prometheus.
+type Name string
+
+const (
+	URLPath    = Name("url_path")
+	ClientAddr = Name("client_address")
+	ServerAddr = Name("server_address")
+	ServerPort = Name("server_port")
+	HTTPRoute  = Name("http_route")
+
+	K8sNamespaceName   = Name("k8s_namespace_name")
+	K8sPodName         = Name("k8s_pod_name")
+	K8sNodeName        = Name("k8s_node_name")
+	K8sPodUID          = Name("k8s_pod_uid")
+	K8sPodStartTime    = Name("k8s_pod_start_time")
+	K8sDeploymentName  = Name("k8s_deployment_name")
+	K8sReplicaSetName  = Name("k8s_replicaset_name")
+	K8sStatefulSetName = Name("k8s_statefulset_name")
+	K8sDaemonSetName   = Name("k8s_daemonset_name")
+)
+
+// K8sNames lists all the Kubernetes attributes, in the same order as they are reported.
+var K8sNames = []Name{
+	K8sNamespaceName, K8sPodName, K8sNodeName, K8sPodUID, K8sPodStartTime,
+	K8sDeploymentName, K8sReplicaSetName, K8sStatefulSetName, K8sDaemonSetName,
+}
+
+// sectionAttributes lists, for each section, the optional attributes (besides
+// the Kubernetes attributes) that can be reported.
+var sectionAttributes = map[Section][]Name{
+	SectionHTTPServer: {URLPath, ClientAddr, HTTPRoute},
+	SectionHTTPClient: {ServerAddr, ServerPort, HTTPRoute},
+	SectionRPCServer:  {ClientAddr},
+	SectionRPCClient:  {ServerAddr},
+	SectionSQLClient:  {},
+}
+
+// Prom returns the name of the attribute as exposed by Prometheus.
+func (n Name) Prom() string {
+	return string(n)
+}
+
+// OTEL returns the name of the attribute as exposed by OpenTelemetry.
+func (n Name) OTEL() string {
+	// the start_time suffix keeps the underscore, as in the Kubernetes metadata
+	if n == K8sPodStartTime {
+		return "k8s.pod.start_time"
+	}
+	return strings.ReplaceAll(string(n), "_", ".")
+}
+
+// IsK8s returns true if the attribute is part of the Kubernetes metadata.
+func (n Name) IsK8s() bool {
+	return strings.HasPrefix(string(n), "k8s_")
+}
+
+// InclusionLists specifies which optional attributes must be reported for a section
+type InclusionLists struct {
+	// Include the listed attributes. If empty, the attributes that are reported
+	// by default (according to other configuration options) are included.
+	Include []string `yaml:"include"`
+	// Exclude the listed attributes, even if they are included by default or
+	// explicitly included in the Include list.
+	Exclude []string `yaml:"exclude"`
+}
+
+// Selection of the optional attributes that are reported for each application
+// metrics section.
+// Attribute names can be provided either in Prometheus format (e.g. url_path)
+// or in OpenTelemetry format (e.g. url.path).
+type Selection map[Section]InclusionLists
+
+// Validate that the selection only refers to existing sections and attributes.
+func (s Selection) Validate() error {
+	for section, lists := range s {
+		candidates, ok := sectionAttributes[section]
+		if !ok {
+			return fmt.Errorf("unknown metrics section %q in attributes selection", section)
+		}
+		candidates = append(slices.Clone(candidates), K8sNames...)
+		for _, name := range append(slices.Clone(lists.Include), lists.Exclude...) {
+			if !slices.Contains(candidates, normalize(name)) {
+				return fmt.Errorf("attribute %q can't be selected for metrics section %q. Accepted values are: %v",
+					name, section, candidates)
+			}
+		}
+	}
+	return nil
+}
+
+// For returns the optional attributes that must be reported for the given section.
+// If the user did not define an inclusion list for the section, the attributes in
+// the defaults list are selected. Excluded attributes are never selected.
+// The returned attributes always follow the same order, no matter the order of
+// the user-provided lists.
+func (s Selection) For(section Section, defaults []Name) []Name {
+	lists := s[section]
+	include := make([]Name, 0, len(lists.Include))
+	for _, n := range lists.Include {
+		include = append(include, normalize(n))
+	}
+	if len(include) == 0 {
+		include = defaults
+	}
+	exclude := make([]Name, 0, len(lists.Exclude))
+	for _, n := range lists.Exclude {
+		exclude = append(exclude, normalize(n))
+	}
+	var selected []Name
+	for _, c := range append(slices.Clone(sectionAttributes[section]), K8sNames...) {
+		if slices.Contains(include, c) && !slices.Contains(exclude, c) {
+			selected = append(selected, c)
+		}
+	}
+	return selected
+}
+
+// normalize a user-provided attribute name to its Prometheus format
+func normalize(name string) Name {
+	return Name(strings.ReplaceAll(name, ".", "_"))
+}
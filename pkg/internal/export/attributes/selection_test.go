@@ -0,0 +1,63 @@
+package attributes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelection_Defaults(t *testing.T) {
+	var sel Selection
+	assert.Equal(t, []Name{URLPath, HTTPRoute},
+		sel.For(SectionHTTPServer, []Name{HTTPRoute, ServerAddr, URLPath}))
+	assert.Equal(t, []Name{ServerAddr},
+		sel.For(SectionHTTPClient, []Name{ServerAddr, URLPath}))
+	assert.Empty(t, sel.For(SectionSQLClient, []Name{ServerAddr, URLPath}))
+	assert.Equal(t, []Name{K8sPodName},
+		sel.For(SectionSQLClient, []Name{K8sPodName}))
+}
+
+func TestSelection_IncludeExclude(t *testing.T) {
+	sel := Selection{
+		SectionHTTPServer: {
+			Include: []string{"k8s.pod.name", "http_route", "url.path", "server_address"},
+			Exclude: []string{"url_path"},
+		},
+		SectionRPCServer: {
+			Exclude: []string{"k8s.pod.start_time"},
+		},
+	}
+	// inclusion list overrides the default values
+	assert.Equal(t, []Name{HTTPRoute, K8sPodName},
+		sel.For(SectionHTTPServer, []Name{ClientAddr, K8sNamespaceName}))
+	// only exclusion list removes attributes from the default values
+	assert.Equal(t, []Name{ClientAddr, K8sPodName},
+		sel.For(SectionRPCServer, []Name{ClientAddr, K8sPodName, K8sPodStartTime}))
+	// other sections are not affected
+	assert.Equal(t, []Name{ServerAddr, K8sPodStartTime},
+		sel.For(SectionHTTPClient, []Name{ServerAddr, K8sPodStartTime}))
+}
+
+func TestSelection_Validate(t *testing.T) {
+	assert.NoError(t, Selection{
+		SectionHTTPServer: {Include: []string{"url.path", "k8s_pod_name"}},
+		SectionSQLClient:  {Exclude: []string{"k8s.pod.start_time"}},
+	}.Validate())
+	assert.Error(t, Selection{
+		"foo": {Include: []string{"url.path"}},
+	}.Validate())
+	assert.Error(t, Selection{
+		SectionSQLClient: {Include: []string{"url.path"}},
+	}.Validate())
+	assert.Error(t, Selection{
+		SectionHTTPClient: {Exclude: []string{"k8s_pod_foo"}},
+	}.Validate())
+}
+
+func TestName_Formats(t *testing.T) {
+	assert.Equal(t, "url_path", URLPath.Prom())
+	assert.Equal(t, "url.path", URLPath.OTEL())
+	assert.Equal(t, "k8s_pod_start_time", K8sPodStartTime.Prom())
+	assert.Equal(t, "k8s.pod.start_time", K8sPodStartTime.OTEL())
+	assert.Equal(t, "k8s.replicaset.name", K8sReplicaSetName.OTEL())
+}
@@ -0,0 +1,141 @@
+package attributes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SemConvVersion of the OpenTelemetry semantic conventions that define the names of the
+// exported metrics and attributes.
+type SemConvVersion string
+
+const (
+	// SemConv119 follows the naming of the OpenTelemetry semantic conventions v1.19.0, before
+	// the HTTP conventions were stabilized (e.g. http.method or http.server.duration).
+	SemConv119 = SemConvVersion("1.19")
+	// SemConv123 follows the naming of the stable HTTP semantic conventions, as defined by the
+	// OpenTelemetry semantic conventions v1.23.0 (e.g. http.request.method or http.server.request.duration).
+	SemConv123 = SemConvVersion("1.23")
+
+	DefaultSemConvVersion = SemConv123
+)
+
+// Names of the metrics and attributes whose name depends on the semantic conventions version.
+// All the names are provided in OpenTelemetry format. The Prometheus exporters derive
+// their names from them by means of the PromName function.
+// The unit of the metrics does not change between versions: durations are always
+// reported in seconds and sizes in bytes.
+type Names struct {
+	HTTPServerDuration    string
+	HTTPClientDuration    string
+	RPCServerDuration     string
+	RPCClientDuration     string
+	SQLClientDuration     string
+	HTTPServerRequestSize string
+	HTTPClientRequestSize string
+
+	HTTPRequestMethod      string
+	HTTPResponseStatusCode string
+	HTTPRequestBodySize    string
+	// URLPath is the path of the requests received by a server
+	URLPath string
+	// URLFull is the URL of the requests submitted by a client
+	URLFull string
+	// ClientAddr and ClientPort are the peer address of a server span
+	ClientAddr string
+	ClientPort string
+	// ServerAddr and ServerPort are the peer address of a client span
+	ServerAddr string
+	ServerPort string
+	// HostAddr and HostPort are the local address of a server span
+	HostAddr string
+	HostPort string
+}
+
+var semConvNames = map[SemConvVersion]*Names{
+	SemConv119: {
+		HTTPServerDuration:    "http.server.duration",
+		HTTPClientDuration:    "http.client.duration",
+		RPCServerDuration:     "rpc.server.duration",
+		RPCClientDuration:     "rpc.client.duration",
+		SQLClientDuration:     "sql.client.duration",
+		HTTPServerRequestSize: "http.server.request.size",
+		HTTPClientRequestSize: "http.client.request.size",
+
+		HTTPRequestMethod:      "http.method",
+		HTTPResponseStatusCode: "http.status_code",
+		HTTPRequestBodySize:    "http.request_content_length",
+		URLPath:                "http.target",
+		URLFull:                "http.url",
+		ClientAddr:             "net.sock.peer.addr",
+		ClientPort:             "net.sock.peer.port",
+		ServerAddr:             "net.peer.name",
+		ServerPort:             "net.peer.port",
+		HostAddr:               "net.host.name",
+		HostPort:               "net.host.port",
+	},
+	SemConv123: {
+		HTTPServerDuration:    "http.server.request.duration",
+		HTTPClientDuration:    "http.client.request.duration",
+		RPCServerDuration:     "rpc.server.duration",
+		RPCClientDuration:     "rpc.client.duration",
+		SQLClientDuration:     "sql.client.duration",
+		HTTPServerRequestSize: "http.server.request.body.size",
+		HTTPClientRequestSize: "http.client.request.body.size",
+
+		HTTPRequestMethod:      "http.request.method",
+		HTTPResponseStatusCode: "http.response.status_code",
+		HTTPRequestBodySize:    "http.request.body.size",
+		URLPath:                "url.path",
+		URLFull:                "url.full",
+		ClientAddr:             "client.address",
+		ClientPort:             "client.port",
+		ServerAddr:             "server.address",
+		ServerPort:             "server.port",
+		HostAddr:               "server.address",
+		HostPort:               "server.port",
+	},
+}
+
+// Validate that the version is supported. An empty version is accepted, as it
+// means the default version.
+func (v SemConvVersion) Validate() error {
+	if v == "" {
+		return nil
+	}
+	if _, ok := semConvNames[v]; !ok {
+		return fmt.Errorf("unsupported semantic conventions version %q. Accepted values: %s, %s",
+			v, SemConv119, SemConv123)
+	}
+	return nil
+}
+
+// Names returns the naming table of the version. If the version is empty or
+// unsupported, it returns the naming table of the default version.
+func (v SemConvVersion) Names() *Names {
+	if names, ok := semConvNames[v]; ok {
+		return names
+	}
+	return semConvNames[DefaultSemConvVersion]
+}
+
+// Attribute returns the name, in OpenTelemetry format, of the given optional attribute.
+func (n *Names) Attribute(name Name) string {
+	switch name {
+	case URLPath:
+		return n.URLPath
+	case ClientAddr:
+		return n.ClientAddr
+	case ServerAddr:
+		return n.ServerAddr
+	case ServerPort:
+		return n.ServerPort
+	}
+	return name.OTEL()
+}
+
+// PromName converts the name of a metric or attribute from the OpenTelemetry format
+// to the Prometheus format.
+func PromName(otelName string) string {
+	return strings.ReplaceAll(otelName, ".", "_")
+}
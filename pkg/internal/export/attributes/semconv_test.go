@@ -0,0 +1,48 @@
+package attributes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSemConvVersion_Validate(t *testing.T) {
+	require.NoError(t, SemConvVersion("").Validate())
+	require.NoError(t, SemConv119.Validate())
+	require.NoError(t, SemConv123.Validate())
+	require.Error(t, SemConvVersion("1.7").Validate())
+}
+
+func TestSemConvVersion_Names(t *testing.T) {
+	// empty version defaults to the current conventions
+	assert.Same(t, SemConv123.Names(), SemConvVersion("").Names())
+
+	legacy := SemConv119.Names()
+	assert.Equal(t, "http.server.duration", legacy.HTTPServerDuration)
+	assert.Equal(t, "http.method", legacy.HTTPRequestMethod)
+	assert.Equal(t, "http.target", legacy.Attribute(URLPath))
+	assert.Equal(t, "net.peer.name", legacy.Attribute(ServerAddr))
+	assert.Equal(t, "k8s.pod.start_time", legacy.Attribute(K8sPodStartTime))
+
+	current := SemConv123.Names()
+	assert.Equal(t, "http.server.request.duration", current.HTTPServerDuration)
+	assert.Equal(t, "http.request.method", current.HTTPRequestMethod)
+	assert.Equal(t, "url.path", current.Attribute(URLPath))
+	assert.Equal(t, "server.address", current.Attribute(ServerAddr))
+	assert.Equal(t, "http_route", PromName(current.Attribute(HTTPRoute)))
+}
+
+func TestSemConvVersion_PromNamesMatchSelectionNames(t *testing.T) {
+	// the Prometheus names of the default version must match the names of the
+	// selectable attributes, so existing dashboards are not broken
+	names := DefaultSemConvVersion.Names()
+	for _, section := range sectionAttributes {
+		for _, name := range section {
+			assert.Equal(t, name.Prom(), PromName(names.Attribute(name)))
+		}
+	}
+	for _, name := range K8sNames {
+		assert.Equal(t, name.Prom(), PromName(names.Attribute(name)))
+	}
+}
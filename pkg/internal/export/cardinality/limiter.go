@@ -0,0 +1,109 @@
+// Package cardinality provides tools to limit the number of series that
+// are generated by the metrics exporters.
+package cardinality
+
+import (
+	"sync"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+// OverflowValue is the value of the attributes of the series that aggregates
+// all the observations exceeding the cardinality limits.
+const OverflowValue = "__overflow__"
+
+// Limits of distinct series that can be generated by a metrics exporter.
+// A zero value means that the limit is disabled.
+type Limits struct {
+	// MaxSeriesPerMetric is the maximum number of distinct label sets of each metric.
+	MaxSeriesPerMetric int `yaml:"max_series_per_metric" env:"BEYLA_METRICS_MAX_SERIES_PER_METRIC"`
+	// MaxSeriesPerService is the maximum number of distinct label sets, across all the metrics,
+	// that can be generated by a single service.
+	MaxSeriesPerService int `yaml:"max_series_per_service" env:"BEYLA_METRICS_MAX_SERIES_PER_SERVICE"`
+}
+
+func (l *Limits) Enabled() bool {
+	return l.MaxSeriesPerMetric > 0 || l.MaxSeriesPerService > 0
+}
+
+// Limiter keeps track of the distinct series that are recorded for each metric and service,
+// and decides whether a new series can be recorded or its observations must go to the
+// overflow series.
+// The type of the series key K depends on the exporter, but it must uniquely identify a set of
+// attributes (including the service attributes) within a metric.
+type Limiter[K comparable] struct {
+	mt     sync.Mutex
+	limits Limits
+	// for each metric, it stores the service owning each series
+	metrics  map[string]map[K]svc.UID
+	services map[svc.UID]int
+
+	onLimit func(metric string, service *svc.ID)
+}
+
+// NewLimiter creates a Limiter for the provided limits. The onLimit function is invoked
+// every time an observation is redirected to the overflow series.
+func NewLimiter[K comparable](limits Limits, onLimit func(metric string, service *svc.ID)) *Limiter[K] {
+	return &Limiter[K]{
+		limits:   limits,
+		metrics:  map[string]map[K]svc.UID{},
+		services: map[svc.UID]int{},
+		onLimit:  onLimit,
+	}
+}
+
+// Allow returns true if the series identified by the provided key can be recorded for the metric.
+// If it returns false, the observation must be recorded in the overflow series.
+func (l *Limiter[K]) Allow(metric string, service *svc.ID, key K) bool {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+	series, ok := l.metrics[metric]
+	if !ok {
+		series = map[K]svc.UID{}
+		l.metrics[metric] = series
+	}
+	if _, ok := series[key]; ok {
+		return true
+	}
+	if (l.limits.MaxSeriesPerMetric > 0 && len(series) >= l.limits.MaxSeriesPerMetric) ||
+		(l.limits.MaxSeriesPerService > 0 && l.services[service.UID] >= l.limits.MaxSeriesPerService) {
+		if l.onLimit != nil {
+			l.onLimit(metric, service)
+		}
+		return false
+	}
+	series[key] = service.UID
+	l.services[service.UID]++
+	return true
+}
+
+// Forget removes a series from the limiter accounting, e.g. because it has expired.
+func (l *Limiter[K]) Forget(metric string, key K) {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+	series := l.metrics[metric]
+	uid, ok := series[key]
+	if !ok {
+		return
+	}
+	delete(series, key)
+	if l.services[uid] <= 1 {
+		delete(l.services, uid)
+	} else {
+		l.services[uid]--
+	}
+}
+
+// ForgetService removes all the series of a service from the limiter accounting.
+func (l *Limiter[K]) ForgetService(uid svc.UID) {
+	l.mt.Lock()
+	defer l.mt.Unlock()
+	for _, series := range l.metrics {
+		for key, owner := range series {
+			if owner == uid {
+				delete(series, key)
+			}
+		}
+	}
+	delete(l.services, uid)
+}
@@ -0,0 +1,75 @@
+package cardinality
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestLimiter_PerMetric(t *testing.T) {
+	hits := map[string]int{}
+	l := NewLimiter[string](Limits{MaxSeriesPerMetric: 2}, func(metric string, service *svc.ID) {
+		hits[metric+"/"+service.Name]++
+	})
+	foo := &svc.ID{UID: "foo", Name: "foo"}
+	bar := &svc.ID{UID: "bar", Name: "bar"}
+
+	assert.True(t, l.Allow("m1", foo, "a"))
+	assert.True(t, l.Allow("m1", bar, "b"))
+	// already existing series are always allowed
+	assert.True(t, l.Allow("m1", foo, "a"))
+	assert.True(t, l.Allow("m1", bar, "b"))
+	// new series exceeding the limit are rejected
+	assert.False(t, l.Allow("m1", foo, "c"))
+	assert.False(t, l.Allow("m1", bar, "d"))
+	assert.False(t, l.Allow("m1", bar, "d"))
+	// other metrics have their own limit
+	assert.True(t, l.Allow("m2", bar, "a"))
+
+	assert.Equal(t, map[string]int{"m1/foo": 1, "m1/bar": 2}, hits)
+}
+
+func TestLimiter_PerService(t *testing.T) {
+	hits := map[string]int{}
+	l := NewLimiter[string](Limits{MaxSeriesPerService: 2}, func(metric string, service *svc.ID) {
+		hits[metric+"/"+service.Name]++
+	})
+	foo := &svc.ID{UID: "foo", Name: "foo"}
+	bar := &svc.ID{UID: "bar", Name: "bar"}
+
+	assert.True(t, l.Allow("m1", foo, "a"))
+	assert.True(t, l.Allow("m2", foo, "a"))
+	assert.False(t, l.Allow("m1", foo, "b"))
+	assert.False(t, l.Allow("m3", foo, "a"))
+	// existing series are still allowed
+	assert.True(t, l.Allow("m2", foo, "a"))
+	// other services have their own limit
+	assert.True(t, l.Allow("m1", bar, "b"))
+	assert.True(t, l.Allow("m1", bar, "c"))
+	assert.False(t, l.Allow("m1", bar, "d"))
+
+	assert.Equal(t, map[string]int{"m1/foo": 1, "m3/foo": 1, "m1/bar": 1}, hits)
+}
+
+func TestLimiter_Forget(t *testing.T) {
+	l := NewLimiter[string](Limits{MaxSeriesPerMetric: 2, MaxSeriesPerService: 2}, nil)
+	foo := &svc.ID{UID: "foo", Name: "foo"}
+	bar := &svc.ID{UID: "bar", Name: "bar"}
+
+	assert.True(t, l.Allow("m1", foo, "a"))
+	assert.True(t, l.Allow("m1", foo, "b"))
+	assert.False(t, l.Allow("m1", foo, "c"))
+	assert.False(t, l.Allow("m2", foo, "a"))
+
+	// forgetting a series leaves room for a new one
+	l.Forget("m1", "a")
+	assert.True(t, l.Allow("m1", foo, "c"))
+	assert.False(t, l.Allow("m1", bar, "d"))
+
+	// forgetting a service leaves room for the series of other services
+	l.ForgetService("foo")
+	assert.True(t, l.Allow("m1", bar, "d"))
+	assert.True(t, l.Allow("m2", foo, "a"))
+}
@@ -2,7 +2,12 @@
 package debug
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
+	"time"
 
 	"github.com/mariomac/pipes/pkg/node"
 	"go.opentelemetry.io/otel/trace"
@@ -16,32 +21,150 @@ func (p PrintEnabled) Enabled() bool {
 	return bool(p)
 }
 
-func PrinterNode(_ PrintEnabled) (node.TerminalFunc[[]request.Span], error) {
+// PrinterConfig of the traces printer
+type PrinterConfig struct {
+	PrintEnabled
+	Format PrintFormat
+	// Output is either "stdout" or the path of the file where the traces are printed
+	Output string
+}
+
+func PrinterNode(cfg PrinterConfig) (node.TerminalFunc[[]request.Span], error) {
+	out, err := OpenOutput(cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	printSpan := printText
+	if cfg.Format == PrintFormatJSON {
+		printSpan = printJSON
+	}
 	return func(input <-chan []request.Span) {
+		defer out.Close()
+		buf := bufio.NewWriter(out)
 		for spans := range input {
 			for i := range spans {
-				t := spans[i].Timings()
-				fmt.Printf("%s (%s[%s]) %s %v %s %s [%s]->[%s:%d] size:%dB svc=[%s %s] traceparent=[%s]\n",
-					t.Start.Format("2006-01-02 15:04:05.12345"),
-					t.End.Sub(t.RequestStart),
-					t.End.Sub(t.Start),
-					spanType(&spans[i]),
-					spans[i].Status,
-					spans[i].Method,
-					spans[i].Path,
-					spans[i].Peer,
-					spans[i].Host,
-					spans[i].HostPort,
-					spans[i].ContentLength,
-					&spans[i].ServiceID,
-					spans[i].ServiceID.SDKLanguage.String(),
-					traceparent(&spans[i]),
-				)
+				if err := printSpan(buf, &spans[i]); err != nil {
+					slog.Warn("can't print span", "component", "debug.PrinterNode", "error", err)
+				}
 			}
+			_ = buf.Flush()
 		}
 	}, nil
 }
 
+func printText(out io.Writer, span *request.Span) error {
+	t := span.Timings()
+	_, err := fmt.Fprintf(out, "%s (%s[%s]) %s %v %s %s [%s]->[%s:%d] size:%dB svc=[%s %s] traceparent=[%s]\n",
+		t.Start.Format("2006-01-02 15:04:05.12345"),
+		t.End.Sub(t.RequestStart),
+		t.End.Sub(t.Start),
+		spanType(span),
+		span.Status,
+		span.Method,
+		span.Path,
+		span.Peer,
+		span.Host,
+		span.HostPort,
+		span.ContentLength,
+		&span.ServiceID,
+		span.ServiceID.SDKLanguage.String(),
+		traceparent(span),
+	)
+	return err
+}
+
+// jsonSpan contains all the fields of a request.Span, with the timings and identifiers
+// already decoded into human-readable values
+type jsonSpan struct {
+	Type            string             `json:"type"`
+	IgnoreSpan      request.IgnoreMode `json:"ignore_span,omitempty"`
+	ID              uint64             `json:"id"`
+	Method          string             `json:"method"`
+	Path            string             `json:"path"`
+	Route           string             `json:"route"`
+	Peer            string             `json:"peer"`
+	Host            string             `json:"host"`
+	HostPort        int                `json:"host_port"`
+	Status          int                `json:"status"`
+	ContentLength   int64              `json:"content_length"`
+	RequestStart    time.Time          `json:"request_start"`
+	Start           time.Time          `json:"start"`
+	End             time.Time          `json:"end"`
+	DurationNs      int64              `json:"duration_ns"`
+	HandlerDuration int64              `json:"handler_duration_ns"`
+	Service         jsonService        `json:"service"`
+	TraceID         string             `json:"trace_id,omitempty"`
+	SpanID          string             `json:"span_id,omitempty"`
+	ParentSpanID    string             `json:"parent_span_id,omitempty"`
+	Flags           uint8              `json:"flags"`
+	Traceparent     string             `json:"traceparent,omitempty"`
+	Pid             jsonPid            `json:"pid"`
+}
+
+type jsonService struct {
+	UID         string            `json:"uid"`
+	Name        string            `json:"name"`
+	AutoName    bool              `json:"auto_name"`
+	Namespace   string            `json:"namespace"`
+	SDKLanguage string            `json:"sdk_language"`
+	Instance    string            `json:"instance"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+}
+
+type jsonPid struct {
+	HostPID   uint32 `json:"host"`
+	UserPID   uint32 `json:"user"`
+	Namespace uint32 `json:"namespace"`
+}
+
+func printJSON(out io.Writer, span *request.Span) error {
+	t := span.Timings()
+	js := jsonSpan{
+		Type:            spanType(span),
+		IgnoreSpan:      span.IgnoreSpan,
+		ID:              span.ID,
+		Method:          span.Method,
+		Path:            span.Path,
+		Route:           span.Route,
+		Peer:            span.Peer,
+		Host:            span.Host,
+		HostPort:        span.HostPort,
+		Status:          span.Status,
+		ContentLength:   span.ContentLength,
+		RequestStart:    t.RequestStart,
+		Start:           t.Start,
+		End:             t.End,
+		DurationNs:      t.End.Sub(t.RequestStart).Nanoseconds(),
+		HandlerDuration: t.End.Sub(t.Start).Nanoseconds(),
+		Service: jsonService{
+			UID:         string(span.ServiceID.UID),
+			Name:        span.ServiceID.Name,
+			AutoName:    span.ServiceID.AutoName,
+			Namespace:   span.ServiceID.Namespace,
+			SDKLanguage: span.ServiceID.SDKLanguage.String(),
+			Instance:    span.ServiceID.Instance,
+			Metadata:    span.ServiceID.Metadata,
+		},
+		Flags:       span.Flags,
+		Traceparent: traceparent(span),
+		Pid: jsonPid{
+			HostPID:   span.Pid.HostPID,
+			UserPID:   span.Pid.UserPID,
+			Namespace: span.Pid.Namespace,
+		},
+	}
+	if span.TraceID.IsValid() {
+		js.TraceID = span.TraceID.String()
+	}
+	if span.SpanID.IsValid() {
+		js.SpanID = span.SpanID.String()
+	}
+	if span.ParentSpanID.IsValid() {
+		js.ParentSpanID = span.ParentSpanID.String()
+	}
+	return json.NewEncoder(out).Encode(&js)
+}
+
 func traceparent(span *request.Span) string {
 	if !trace.TraceID(span.TraceID).IsValid() {
 		return ""
@@ -0,0 +1,111 @@
+package debug
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestPrintFormat_Validate(t *testing.T) {
+	assert.NoError(t, PrintFormat("").Validate())
+	assert.NoError(t, PrintFormatText.Validate())
+	assert.NoError(t, PrintFormatJSON.Validate())
+	assert.Error(t, PrintFormat("yaml").Validate())
+}
+
+func TestPrintJSON(t *testing.T) {
+	span := request.Span{
+		Type:          request.EventTypeHTTP,
+		ID:            33,
+		Method:        "GET",
+		Path:          "/users/123",
+		Route:         "/users/{id}",
+		Peer:          "1.1.1.1",
+		Host:          "2.2.2.2",
+		HostPort:      8080,
+		Status:        200,
+		ContentLength: 1024,
+		RequestStart:  int64(10 * time.Second),
+		Start:         int64(11 * time.Second),
+		End:           int64(13 * time.Second),
+		ServiceID: svc.ID{
+			UID: "uid-1", Name: "users", Namespace: "shop", SDKLanguage: svc.InstrumentableJava,
+			Instance: "users-1", Metadata: map[string]string{"k8s.pod.name": "users-abcde"},
+		},
+		TraceID:      [16]byte{0x01, 0x02, 0x03},
+		SpanID:       [8]byte{0x04},
+		ParentSpanID: [8]byte{0x05},
+		Flags:        1,
+		Pid:          request.PidInfo{HostPID: 1234, UserPID: 1, Namespace: 5678},
+	}
+	out := bytes.Buffer{}
+	require.NoError(t, printJSON(&out, &span))
+
+	printed := map[string]any{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	// timings depend on the current time, so we just check that they are present
+	for _, field := range []string{"request_start", "start", "end"} {
+		require.Contains(t, printed, field)
+		delete(printed, field)
+	}
+	assert.Equal(t, map[string]any{
+		"type":                "SRV",
+		"id":                  float64(33),
+		"method":              "GET",
+		"path":                "/users/123",
+		"route":               "/users/{id}",
+		"peer":                "1.1.1.1",
+		"host":                "2.2.2.2",
+		"host_port":           float64(8080),
+		"status":              float64(200),
+		"content_length":      float64(1024),
+		"duration_ns":         float64(3 * time.Second),
+		"handler_duration_ns": float64(2 * time.Second),
+		"service": map[string]any{
+			"uid":          "uid-1",
+			"name":         "users",
+			"auto_name":    false,
+			"namespace":    "shop",
+			"sdk_language": "java",
+			"instance":     "users-1",
+			"metadata":     map[string]any{"k8s.pod.name": "users-abcde"},
+		},
+		"trace_id":       "01020300000000000000000000000000",
+		"span_id":        "0400000000000000",
+		"parent_span_id": "0500000000000000",
+		"flags":          float64(1),
+		"traceparent":    "00-01020300000000000000000000000000-0500000000000000-01",
+		"pid":            map[string]any{"host": float64(1234), "user": float64(1), "namespace": float64(5678)},
+	}, printed)
+}
+
+func TestPrinterNode_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out", "traces.jsonl")
+	printer, err := PrinterNode(PrinterConfig{PrintEnabled: true, Format: PrintFormatJSON, Output: path})
+	require.NoError(t, err)
+
+	in := make(chan []request.Span, 2)
+	in <- []request.Span{{Type: request.EventTypeHTTP, Path: "/foo"}, {Type: request.EventTypeGRPC, Path: "/bar"}}
+	in <- []request.Span{{Type: request.EventTypeSQLClient, Path: "SELECT"}}
+	close(in)
+	printer(in)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	require.Len(t, lines, 3)
+	for i, expected := range []string{"/foo", "/bar", "SELECT"} {
+		span := jsonSpan{}
+		require.NoError(t, json.Unmarshal(lines[i], &span))
+		assert.Equal(t, expected, span.Path)
+	}
+}
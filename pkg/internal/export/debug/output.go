@@ -0,0 +1,55 @@
+package debug
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PrintFormat of the debug printers
+type PrintFormat string
+
+const (
+	// PrintFormatText prints each record as a human-readable line
+	PrintFormatText PrintFormat = "text"
+	// PrintFormatJSON prints each record as a JSON object in a single line, which can be
+	// piped into tools such as jq or log shippers
+	PrintFormatJSON PrintFormat = "json"
+)
+
+// OutputStdout is the value of the printer output that prints into the standard output
+const OutputStdout = "stdout"
+
+func (pf PrintFormat) Validate() error {
+	switch pf {
+	case "", PrintFormatText, PrintFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown print format %q. Accepted values: %s, %s", pf, PrintFormatText, PrintFormatJSON)
+}
+
+// OpenOutput returns the writer of the debug printers. If the path is empty or "stdout",
+// it returns the standard output. Otherwise, it opens the file in the path, appending
+// to any existing content.
+func OpenOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == OutputStdout {
+		return nopCloser{Writer: os.Stdout}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating printer output directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening printer output file: %w", err)
+	}
+	return file, nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
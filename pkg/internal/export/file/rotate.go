@@ -0,0 +1,271 @@
+// Package file provides exporters that write the telemetry into local files, as
+// OTLP-JSON objects (one per line), with size- and time-based rotation.
+package file
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+func rlog() *slog.Logger {
+	return slog.With("component", "file.RotatingWriter")
+}
+
+// rotatedTimeFormat is the suffix format of the rotated files. It is sortable, so the
+// lexicographic order of the file names is the same as their rotation order.
+const rotatedTimeFormat = "20060102T150405.000000000"
+
+const compressedSuffix = ".gz"
+
+// RotationConfig specifies the file where the telemetry is written, and how it is rotated
+type RotationConfig struct {
+	// Path of the file where the telemetry is written. If empty, the file exporter is disabled.
+	Path string `yaml:"path" env:"PATH"`
+	// MaxSizeMB is the maximum size, in megabytes, that the file can reach before being rotated.
+	// Zero means that the file is not rotated by size.
+	MaxSizeMB int `yaml:"max_size_mb" env:"MAX_SIZE_MB"`
+	// RotationInterval is the maximum time a file is written before being rotated.
+	// Zero means that the file is not rotated by time.
+	RotationInterval time.Duration `yaml:"rotation_interval" env:"ROTATION_INTERVAL"`
+	// Compress the rotated files with gzip
+	Compress bool `yaml:"compress" env:"COMPRESS"`
+	// MaxFiles is the maximum number of rotated files that are retained. The oldest rotated
+	// files are removed. Zero means that all the rotated files are retained.
+	MaxFiles int `yaml:"max_files" env:"MAX_FILES"`
+}
+
+// nolint:gocritic
+func (rc RotationConfig) Enabled() bool {
+	return rc.Path != ""
+}
+
+// RotatingWriter writes lines into a file, which is renamed with a timestamp suffix
+// and replaced by a new file when its maximum size or age are exceeded.
+// It is safe for concurrent use.
+type RotatingWriter struct {
+	cfg     *RotationConfig
+	maxSize int64
+	clock   func() time.Time
+
+	mt       sync.Mutex
+	file     *os.File
+	buf      *bufio.Writer
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingWriter opens (or creates) the file specified in the configuration, appending
+// the new content to the existing file, if any.
+func NewRotatingWriter(cfg *RotationConfig) (*RotatingWriter, error) {
+	return newRotatingWriter(cfg, time.Now)
+}
+
+func newRotatingWriter(cfg *RotationConfig, clock func() time.Time) (*RotatingWriter, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("missing file path")
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating directory for %s: %w", cfg.Path, err)
+	}
+	rw := &RotatingWriter{
+		cfg:     cfg,
+		maxSize: int64(cfg.MaxSizeMB) * 1024 * 1024,
+		clock:   clock,
+	}
+	if err := rw.open(); err != nil {
+		return nil, err
+	}
+	return rw, nil
+}
+
+// WriteLine writes the provided content as a single line, rotating the
+// file before if the content would exceed its maximum size, or if the file is older
+// than the rotation interval.
+func (rw *RotatingWriter) WriteLine(line []byte) error {
+	rw.mt.Lock()
+	defer rw.mt.Unlock()
+	if rw.file == nil {
+		return fmt.Errorf("writing into closed file %s", rw.cfg.Path)
+	}
+	if rw.mustRotate(int64(len(line) + 1)) {
+		if err := rw.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := rw.buf.Write(line)
+	rw.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing into %s: %w", rw.cfg.Path, err)
+	}
+	if err := rw.buf.WriteByte('\n'); err != nil {
+		return fmt.Errorf("writing into %s: %w", rw.cfg.Path, err)
+	}
+	rw.size++
+	// the content is flushed after each line, so the file can be tailed by other tools
+	if err := rw.buf.Flush(); err != nil {
+		return fmt.Errorf("flushing %s: %w", rw.cfg.Path, err)
+	}
+	return nil
+}
+
+// Close the current file. Further writes will fail.
+func (rw *RotatingWriter) Close() error {
+	rw.mt.Lock()
+	defer rw.mt.Unlock()
+	return rw.close()
+}
+
+func (rw *RotatingWriter) mustRotate(writeLen int64) bool {
+	// an empty file is never rotated, even if the line is larger than the maximum size
+	if rw.size == 0 {
+		return false
+	}
+	if rw.maxSize > 0 && rw.size+writeLen > rw.maxSize {
+		return true
+	}
+	return rw.cfg.RotationInterval > 0 && rw.clock().Sub(rw.openedAt) >= rw.cfg.RotationInterval
+}
+
+func (rw *RotatingWriter) open() error {
+	file, err := os.OpenFile(rw.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", rw.cfg.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("reading info of %s: %w", rw.cfg.Path, err)
+	}
+	rw.file = file
+	rw.buf = bufio.NewWriter(file)
+	rw.size = info.Size()
+	rw.openedAt = rw.clock()
+	return nil
+}
+
+func (rw *RotatingWriter) close() error {
+	if rw.file == nil {
+		return nil
+	}
+	flushErr := rw.buf.Flush()
+	closeErr := rw.file.Close()
+	rw.file, rw.buf = nil, nil
+	if flushErr != nil {
+		return fmt.Errorf("flushing %s: %w", rw.cfg.Path, flushErr)
+	}
+	if closeErr != nil {
+		return fmt.Errorf("closing %s: %w", rw.cfg.Path, closeErr)
+	}
+	return nil
+}
+
+func (rw *RotatingWriter) rotate() error {
+	if err := rw.close(); err != nil {
+		return err
+	}
+	rotated := rw.rotatedName(rw.clock())
+	if err := os.Rename(rw.cfg.Path, rotated); err != nil {
+		return fmt.Errorf("rotating %s: %w", rw.cfg.Path, err)
+	}
+	if err := rw.open(); err != nil {
+		return err
+	}
+	// errors in the post-processing of the rotated files are logged but do not prevent
+	// writing into the new file
+	log := rlog().With("path", rw.cfg.Path)
+	if rw.cfg.Compress {
+		if err := compress(rotated); err != nil {
+			log.Warn("can't compress rotated file", "file", rotated, "error", err)
+		}
+	}
+	if rw.cfg.MaxFiles > 0 {
+		if err := rw.removeOldest(); err != nil {
+			log.Warn("can't remove old rotated files", "error", err)
+		}
+	}
+	return nil
+}
+
+// rotatedName returns the name of a rotated file: the original name with a timestamp
+// suffix before the extension (e.g. traces.jsonl --> traces-20240102T150405.000000000.jsonl)
+func (rw *RotatingWriter) rotatedName(now time.Time) string {
+	ext := filepath.Ext(rw.cfg.Path)
+	return strings.TrimSuffix(rw.cfg.Path, ext) + "-" + now.UTC().Format(rotatedTimeFormat) + ext
+}
+
+// rotatedFiles returns the names of the rotated files, sorted from the oldest to the newest
+func (rw *RotatingWriter) rotatedFiles() ([]string, error) {
+	ext := filepath.Ext(rw.cfg.Path)
+	prefix := filepath.Base(strings.TrimSuffix(rw.cfg.Path, ext)) + "-"
+	dir := filepath.Dir(rw.cfg.Path)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimSuffix(name, compressedSuffix), ext)
+		if _, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(stamp, prefix)); err != nil {
+			// not a file rotated by us
+			continue
+		}
+		files = append(files, filepath.Join(dir, name))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (rw *RotatingWriter) removeOldest() error {
+	files, err := rw.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(files) > rw.cfg.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return err
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// compress the provided file into a new file with the .gz suffix, and removes the original
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+compressedSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(dst.Name())
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
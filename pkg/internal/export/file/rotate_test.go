@@ -0,0 +1,141 @@
+package file
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func TestRotatingWriter_Size(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	rw, err := newRotatingWriter(&RotationConfig{Path: filepath.Join(dir, "traces.jsonl")}, clock.Now)
+	require.NoError(t, err)
+	// forcing a small size to test rotation
+	rw.maxSize = 10
+
+	require.NoError(t, rw.WriteLine([]byte("1234")))
+	require.NoError(t, rw.WriteLine([]byte("5678")))
+	clock.Advance(time.Second)
+	// would exceed the max size
+	require.NoError(t, rw.WriteLine([]byte("abc")))
+	clock.Advance(time.Second)
+	// a line larger than the max size is written in a new file, and rotated in the next write
+	require.NoError(t, rw.WriteLine([]byte("larger than max size")))
+	require.NoError(t, rw.Close())
+
+	assert.Equal(t, map[string]string{
+		"traces.jsonl":                           "larger than max size\n",
+		"traces-20240102T030406.000000000.jsonl": "1234\n5678\n",
+		"traces-20240102T030407.000000000.jsonl": "abc\n",
+	}, readDir(t, dir))
+
+	require.Error(t, rw.WriteLine([]byte("closed")))
+}
+
+func TestRotatingWriter_Interval(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	cfg := RotationConfig{Path: filepath.Join(dir, "flows"), RotationInterval: time.Minute}
+	rw, err := newRotatingWriter(&cfg, clock.Now)
+	require.NoError(t, err)
+
+	require.NoError(t, rw.WriteLine([]byte("foo")))
+	clock.Advance(30 * time.Second)
+	require.NoError(t, rw.WriteLine([]byte("bar")))
+	clock.Advance(30 * time.Second)
+	require.NoError(t, rw.WriteLine([]byte("baz")))
+	require.NoError(t, rw.Close())
+
+	assert.Equal(t, map[string]string{
+		"flows":                           "baz\n",
+		"flows-20240102T030505.000000000": "foo\nbar\n",
+	}, readDir(t, dir))
+}
+
+func TestRotatingWriter_AppendsToExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "traces.jsonl")
+	rw, err := NewRotatingWriter(&RotationConfig{Path: path})
+	require.NoError(t, err)
+	require.NoError(t, rw.WriteLine([]byte("foo")))
+	require.NoError(t, rw.Close())
+
+	rw, err = NewRotatingWriter(&RotationConfig{Path: path})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, rw.size)
+	require.NoError(t, rw.WriteLine([]byte("bar")))
+	require.NoError(t, rw.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(content))
+}
+
+func TestRotatingWriter_CompressAndMaxFiles(t *testing.T) {
+	dir := t.TempDir()
+	// a file that has not been rotated by the writer must be kept
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "traces-other.jsonl"), []byte("other\n"), 0o600))
+
+	clock := &fakeClock{now: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}
+	cfg := RotationConfig{
+		Path:             filepath.Join(dir, "traces.jsonl"),
+		RotationInterval: time.Second,
+		Compress:         true,
+		MaxFiles:         2,
+	}
+	rw, err := newRotatingWriter(&cfg, clock.Now)
+	require.NoError(t, err)
+	for _, line := range []string{"one", "two", "three", "four"} {
+		require.NoError(t, rw.WriteLine([]byte(line)))
+		clock.Advance(time.Second)
+	}
+	require.NoError(t, rw.Close())
+
+	assert.Equal(t, map[string]string{
+		"traces-other.jsonl": "other\n",
+		"traces.jsonl":       "four\n",
+		"traces-20240102T030407.000000000.jsonl.gz": "two\n",
+		"traces-20240102T030408.000000000.jsonl.gz": "three\n",
+	}, readDir(t, dir))
+}
+
+// readDir returns the contents of the files in the directory, decompressing the gzipped ones
+func readDir(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, entry := range entries {
+		f, err := os.Open(filepath.Join(dir, entry.Name()))
+		require.NoError(t, err)
+		var reader io.Reader = f
+		if strings.HasSuffix(entry.Name(), ".gz") {
+			reader, err = gzip.NewReader(f)
+			require.NoError(t, err)
+		}
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+		files[entry.Name()] = string(content)
+	}
+	return files
+}
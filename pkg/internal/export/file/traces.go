@@ -0,0 +1,151 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/otel"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func tlog() *slog.Logger {
+	return slog.With("component", "file.TracesExporter")
+}
+
+// TracesConfig of the exporter that writes the application spans into a local file
+type TracesConfig struct {
+	File RotationConfig `yaml:",inline" envPrefix:"BEYLA_FILE_EXPORT_"`
+
+	// BatchTimeout is the maximum time the spans are buffered before they are written
+	BatchTimeout time.Duration `yaml:"batch_timeout" env:"BEYLA_FILE_EXPORT_BATCH_TIMEOUT"`
+
+	Sampler otel.Sampler `yaml:"sampler"`
+
+	ReportersCacheLen int `yaml:"reporters_cache_len" env:"BEYLA_TRACES_REPORT_CACHE_LEN"`
+
+	// SemConv version that defines the names of the span attributes.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+}
+
+// nolint:gocritic
+func (c TracesConfig) Enabled() bool {
+	return c.File.Enabled()
+}
+
+// TracesFile builds the spans with the same logic as the OTEL traces reporter, so
+// they have the same structure and attributes, and writes them as OTLP-JSON lines.
+func TracesFile(ctx context.Context, cfg *TracesConfig) (node.TerminalFunc[[]request.Span], error) {
+	writer, err := NewRotatingWriter(&cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("instantiating traces file exporter: %w", err)
+	}
+	return otel.ReportTracesTo(ctx, &otel.TracesConfig{
+		Sampler:           cfg.Sampler,
+		BatchTimeout:      cfg.BatchTimeout,
+		ReportersCacheLen: cfg.ReportersCacheLen,
+		SemConv:           cfg.SemConv,
+	}, &TracesExporter{writer: writer}), nil
+}
+
+// TracesExporter writes the OpenTelemetry SDK spans as OTLP-JSON lines. Each line
+// contains the spans of a batch, grouped by resource.
+type TracesExporter struct {
+	writer    *RotatingWriter
+	marshaler ptrace.JSONMarshaler
+}
+
+func (e *TracesExporter) ExportSpans(_ context.Context, spans []trace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	line, err := e.marshaler.MarshalTraces(toTraces(spans))
+	if err != nil {
+		return fmt.Errorf("encoding spans: %w", err)
+	}
+	if err := e.writer.WriteLine(line); err != nil {
+		tlog().Debug("error writing spans", "error", err, "len", len(spans))
+		return err
+	}
+	return nil
+}
+
+func (e *TracesExporter) Shutdown(_ context.Context) error {
+	return e.writer.Close()
+}
+
+// toTraces converts the SDK spans into the OTLP traces model
+func toTraces(spans []trace.ReadOnlySpan) ptrace.Traces {
+	traces := ptrace.NewTraces()
+	byResource := map[*resource.Resource]ptrace.SpanSlice{}
+	for _, s := range spans {
+		dst, ok := byResource[s.Resource()]
+		if !ok {
+			rs := traces.ResourceSpans().AppendEmpty()
+			if res := s.Resource(); res != nil {
+				rs.SetSchemaUrl(res.SchemaURL())
+				putAttributes(rs.Resource().Attributes(), res.Attributes())
+			}
+			ss := rs.ScopeSpans().AppendEmpty()
+			ss.Scope().SetName(s.InstrumentationScope().Name)
+			ss.Scope().SetVersion(s.InstrumentationScope().Version)
+			dst = ss.Spans()
+			byResource[s.Resource()] = dst
+		}
+		toSpan(s, dst.AppendEmpty())
+	}
+	return traces
+}
+
+func toSpan(s trace.ReadOnlySpan, dst ptrace.Span) {
+	dst.SetTraceID(pcommon.TraceID(s.SpanContext().TraceID()))
+	dst.SetSpanID(pcommon.SpanID(s.SpanContext().SpanID()))
+	dst.TraceState().FromRaw(s.SpanContext().TraceState().String())
+	if s.Parent().SpanID().IsValid() {
+		dst.SetParentSpanID(pcommon.SpanID(s.Parent().SpanID()))
+	}
+	dst.SetName(s.Name())
+	// the OTEL SDK and the OTLP span kinds have the same numeric values
+	dst.SetKind(ptrace.SpanKind(s.SpanKind()))
+	dst.SetStartTimestamp(pcommon.NewTimestampFromTime(s.StartTime()))
+	dst.SetEndTimestamp(pcommon.NewTimestampFromTime(s.EndTime()))
+	putAttributes(dst.Attributes(), s.Attributes())
+	dst.SetDroppedAttributesCount(uint32(s.DroppedAttributes()))
+	switch s.Status().Code {
+	case codes.Ok:
+		dst.Status().SetCode(ptrace.StatusCodeOk)
+	case codes.Error:
+		dst.Status().SetCode(ptrace.StatusCodeError)
+	}
+	dst.Status().SetMessage(s.Status().Description)
+}
+
+func putAttributes(dst pcommon.Map, attrs []attribute.KeyValue) {
+	dst.EnsureCapacity(len(attrs))
+	for _, kv := range attrs {
+		key := string(kv.Key)
+		switch kv.Value.Type() {
+		case attribute.BOOL:
+			dst.PutBool(key, kv.Value.AsBool())
+		case attribute.INT64:
+			dst.PutInt(key, kv.Value.AsInt64())
+		case attribute.FLOAT64:
+			dst.PutDouble(key, kv.Value.AsFloat64())
+		case attribute.STRING:
+			dst.PutStr(key, kv.Value.AsString())
+		default:
+			dst.PutStr(key, kv.Value.Emit())
+		}
+	}
+}
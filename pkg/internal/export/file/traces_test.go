@@ -0,0 +1,89 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+const timeout = 5 * time.Second
+
+func TestTracesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node, err := TracesFile(ctx, &TracesConfig{
+		File:              RotationConfig{Path: path},
+		BatchTimeout:      10 * time.Millisecond,
+		ReportersCacheLen: 16,
+	})
+	require.NoError(t, err)
+
+	traceID := trace2.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	start := time.Now().UnixNano()
+	input := make(chan []request.Span, 1)
+	go node(input)
+	input <- []request.Span{{
+		Type: request.EventTypeHTTP, Method: "GET", Path: "/users/1234", Route: "/users/{id}", Status: 500,
+		Peer: "10.0.0.1", Host: "10.0.0.2", HostPort: 8080,
+		RequestStart: start, Start: start + int64(time.Millisecond), End: start + int64(3*time.Millisecond),
+		TraceID: traceID, SpanID: trace2.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		ServiceID: svc.ID{Name: "users", Namespace: "shop", UID: "users"},
+	}}
+
+	// the spans of the same batch are written in a single line
+	var lines [][]byte
+	require.Eventually(t, func() bool {
+		content, _ := os.ReadFile(path)
+		lines = bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+		return len(content) > 0
+	}, timeout, 10*time.Millisecond)
+	close(input)
+	require.Len(t, lines, 1)
+
+	traces, err := (&ptrace.JSONUnmarshaler{}).UnmarshalTraces(lines[0])
+	require.NoError(t, err)
+	require.Equal(t, 1, traces.ResourceSpans().Len())
+	rs := traces.ResourceSpans().At(0)
+	svcName, _ := rs.Resource().Attributes().Get("service.name")
+	assert.Equal(t, "users", svcName.Str())
+	svcNamespace, _ := rs.Resource().Attributes().Get("service.namespace")
+	assert.Equal(t, "shop", svcNamespace.Str())
+
+	// same span structure as the OTEL traces exporter
+	spans := map[string]ptrace.Span{}
+	ss := rs.ScopeSpans().At(0).Spans()
+	for i := 0; i < ss.Len(); i++ {
+		spans[ss.At(i).Name()] = ss.At(i)
+	}
+	require.Len(t, spans, 3)
+	server := spans["GET /users/{id}"]
+	assert.Equal(t, traceID.String(), server.TraceID().String())
+	assert.Equal(t, ptrace.SpanKindServer, server.Kind())
+	assert.True(t, server.ParentSpanID().IsEmpty())
+	assert.Equal(t, 3*time.Millisecond, server.EndTimestamp().AsTime().Sub(server.StartTimestamp().AsTime()))
+	assert.Equal(t, ptrace.StatusCodeError, server.Status().Code())
+	route, _ := server.Attributes().Get("http.route")
+	assert.Equal(t, "/users/{id}", route.Str())
+	status, _ := server.Attributes().Get("http.response.status_code")
+	assert.EqualValues(t, 500, status.Int())
+
+	for _, name := range []string{"in queue", "processing"} {
+		inner := spans[name]
+		assert.Equal(t, traceID.String(), inner.TraceID().String())
+		assert.Equal(t, server.SpanID(), inner.ParentSpanID())
+		assert.Equal(t, ptrace.SpanKindInternal, inner.Kind())
+	}
+	assert.Equal(t, "0102030405060708", spans["processing"].SpanID().String())
+}
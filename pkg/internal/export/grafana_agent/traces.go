@@ -11,6 +11,7 @@ import (
 	"go.opentelemetry.io/otel/codes"
 
 	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/request"
 )
@@ -26,7 +27,7 @@ func TracesReceiver(ctx context.Context, cfg beyla.TracesReceiverConfig) (node.T
 				}
 
 				for _, tc := range cfg.Traces {
-					traces := generateTraces(span)
+					traces := generateTraces(span, cfg.SemConv.Names())
 					err := tc.ConsumeTraces(ctx, traces)
 					if err != nil {
 						slog.Error("error sending trace to consumer", "error", err)
@@ -38,7 +39,7 @@ func TracesReceiver(ctx context.Context, cfg beyla.TracesReceiverConfig) (node.T
 }
 
 // generateTraces creates a ptrace.Traces from a request.Span
-func generateTraces(span *request.Span) ptrace.Traces {
+func generateTraces(span *request.Span, names *attributes.Names) ptrace.Traces {
 	t := span.Timings()
 	start := otel.SpanStartTime(t)
 	hasSubSpans := t.Start.After(start)
@@ -75,7 +76,7 @@ func generateTraces(span *request.Span) ptrace.Traces {
 	}
 
 	// Set span attributes
-	attrs := otel.TraceAttributes(span)
+	attrs := otel.TraceAttributes(span, names)
 	m := attrsToMap(attrs)
 	m.CopyTo(s.Attributes())
 
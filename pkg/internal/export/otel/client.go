@@ -0,0 +1,186 @@
+package otel
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Accepted values for the compression of the OTLP exporters
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+)
+
+// ClientConfig of the OTLP exporters, following the naming of the OTEL_EXPORTER_OTLP_* standard
+// configuration values. Each signal (metrics, traces...) has its own ClientConfig, which takes
+// precedence over the common OTEL_EXPORTER_OTLP_* values.
+// More info: https://opentelemetry.io/docs/specs/otel/protocol/exporter/
+type ClientConfig struct {
+	// Compression of the submitted data. Accepted values are "none" (default) and "gzip"
+	Compression string `yaml:"compression" env:"COMPRESSION"`
+	// Headers to be sent in each export request (for example, authentication or tenant headers)
+	Headers Headers `yaml:"headers" env:"HEADERS"`
+	// CAFile to verify the certificate of the OTLP endpoint, instead of the system's root CAs
+	CAFile string `yaml:"ca_file" env:"CERTIFICATE"`
+	// CertFile and KeyFile provide a client certificate for mutual TLS authentication
+	CertFile string `yaml:"cert_file" env:"CLIENT_CERTIFICATE"`
+	KeyFile  string `yaml:"key_file" env:"CLIENT_KEY"`
+}
+
+// RetryConfig of the OTLP exporters when an export request fails. If unset, the
+// default retry configuration of the OpenTelemetry SDK is used. If only some of the
+// values are set, the rest take the default values.
+type RetryConfig struct {
+	// InitialInterval to wait after the first failure before retrying
+	InitialInterval time.Duration `yaml:"initial_interval" env:"INITIAL_INTERVAL"`
+	// MaxInterval is the upper bound of the backoff between two retries
+	MaxInterval time.Duration `yaml:"max_interval" env:"MAX_INTERVAL"`
+	// MaxElapsedTime spent trying to send a request, after which the data is dropped
+	MaxElapsedTime time.Duration `yaml:"max_elapsed_time" env:"MAX_ELAPSED_TIME"`
+}
+
+// defaultRetry matches the default retry configuration of the OpenTelemetry SDK exporters
+var defaultRetry = RetryConfig{
+	InitialInterval: 5 * time.Second,
+	MaxInterval:     30 * time.Second,
+	MaxElapsedTime:  time.Minute,
+}
+
+func (r *RetryConfig) isSet() bool {
+	return r.InitialInterval != 0 || r.MaxInterval != 0 || r.MaxElapsedTime != 0
+}
+
+// withDefaults returns the retry configuration, taking the unset values from the default
+// configuration. Otherwise, the exporters would retry without any delay, or forever.
+func (r *RetryConfig) withDefaults() RetryConfig {
+	merged := *r
+	if merged.InitialInterval == 0 {
+		merged.InitialInterval = defaultRetry.InitialInterval
+	}
+	if merged.MaxInterval == 0 {
+		merged.MaxInterval = defaultRetry.MaxInterval
+	}
+	if merged.MaxElapsedTime == 0 {
+		merged.MaxElapsedTime = defaultRetry.MaxElapsedTime
+	}
+	return merged
+}
+
+// Headers of the OTLP requests. When they are provided as an environment variable,
+// they follow the OTEL_EXPORTER_OTLP_HEADERS format: a comma-separated list of key=value
+// pairs, whose values can be percent-encoded.
+type Headers map[string]string
+
+func (h *Headers) UnmarshalText(text []byte) error {
+	headers := Headers{}
+	for _, pair := range strings.Split(string(text), ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return fmt.Errorf("header %q should be in key=value format", pair)
+		}
+		value, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("decoding header %q: %w", key, err)
+		}
+		headers[key] = value
+	}
+	*h = headers
+	return nil
+}
+
+// withDefaults returns the client configuration, taking the unset values from the
+// common configuration. Headers are merged, overriding the common ones.
+func (c *ClientConfig) withDefaults(common *ClientConfig) ClientConfig {
+	merged := *c
+	if merged.Compression == "" {
+		merged.Compression = common.Compression
+	}
+	if merged.CAFile == "" {
+		merged.CAFile = common.CAFile
+	}
+	if merged.CertFile == "" && merged.KeyFile == "" {
+		merged.CertFile, merged.KeyFile = common.CertFile, common.KeyFile
+	}
+	if len(common.Headers) > 0 {
+		merged.Headers = make(Headers, len(common.Headers)+len(c.Headers))
+		for k, v := range common.Headers {
+			merged.Headers[k] = v
+		}
+		for k, v := range c.Headers {
+			merged.Headers[k] = v
+		}
+	}
+	return merged
+}
+
+// tlsConfig returns the TLS configuration for a custom CA and/or client certificate,
+// or nil if none of them is set.
+func (c *ClientConfig) tlsConfig(skipVerify bool) (*tls.Config, error) {
+	if c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: skipVerify, //nolint:gosec
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA file does not contain any valid PEM certificate")
+		}
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		if c.CertFile == "" || c.KeyFile == "" {
+			return nil, errors.New("client TLS authentication requires both the certificate and key files")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// setupClient completes the OTLP options with the compression, headers, TLS and retry configuration
+func (o *otlpOptions) setupClient(client *ClientConfig, retry *RetryConfig) error {
+	switch client.Compression {
+	case "", CompressionNone:
+	case CompressionGzip:
+		o.Gzip = true
+	default:
+		return fmt.Errorf("invalid compression value: %q. Accepted values are: %s, %s",
+			client.Compression, CompressionNone, CompressionGzip)
+	}
+	if len(client.Headers) > 0 {
+		if o.HTTPHeaders == nil {
+			o.HTTPHeaders = map[string]string{}
+		}
+		for k, v := range client.Headers {
+			o.HTTPHeaders[k] = v
+		}
+	}
+	tlsCfg, err := client.tlsConfig(o.SkipTLSVerify)
+	if err != nil {
+		return err
+	}
+	o.TLSConfig = tlsCfg
+	if retry != nil && retry.isSet() {
+		o.Retry = retry.withDefaults()
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package otel
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestHeaders_UnmarshalText(t *testing.T) {
+	h := Headers{}
+	require.NoError(t, h.UnmarshalText([]byte("X-Scope-OrgID=tenant-1, Authorization=Basic%20Zm9vOmJhcg==,")))
+	assert.Equal(t, Headers{
+		"X-Scope-OrgID": "tenant-1",
+		"Authorization": "Basic Zm9vOmJhcg==",
+	}, h)
+
+	require.Error(t, h.UnmarshalText([]byte("foo:bar")))
+	require.Error(t, h.UnmarshalText([]byte("=bar")))
+}
+
+func TestClientConfig_WithDefaults(t *testing.T) {
+	common := ClientConfig{
+		Compression: CompressionGzip,
+		Headers:     Headers{"a": "common", "b": "common"},
+		CAFile:      "common-ca.pem",
+		CertFile:    "common-cert.pem",
+		KeyFile:     "common-key.pem",
+	}
+	assert.Equal(t, common, (&ClientConfig{}).withDefaults(&common))
+
+	signal := ClientConfig{
+		Compression: CompressionNone,
+		Headers:     Headers{"b": "signal", "c": "signal"},
+		CertFile:    "signal-cert.pem",
+		KeyFile:     "signal-key.pem",
+	}
+	assert.Equal(t, ClientConfig{
+		Compression: CompressionNone,
+		Headers:     Headers{"a": "common", "b": "signal", "c": "signal"},
+		CAFile:      "common-ca.pem",
+		CertFile:    "signal-cert.pem",
+		KeyFile:     "signal-key.pem",
+	}, signal.withDefaults(&common))
+}
+
+func TestHTTPMetricsClientOptions(t *testing.T) {
+	defer restoreEnvAfterExecution()()
+	mcfg := MetricsConfig{
+		MetricsEndpoint: "https://localhost:3232",
+		Client:          ClientConfig{Headers: Headers{"X-Scope-OrgID": "tenant-1"}},
+		CommonClient:    ClientConfig{Compression: CompressionGzip, Headers: Headers{"X-Scope-OrgID": "tenant-2", "Foo": "bar"}},
+		Retry:           RetryConfig{InitialInterval: time.Second, MaxElapsedTime: time.Minute},
+	}
+	testMetricsHTTPOptions(t, otlpOptions{
+		Endpoint:    "localhost:3232",
+		HTTPHeaders: map[string]string{"X-Scope-OrgID": "tenant-1", "Foo": "bar"},
+		Gzip:        true,
+		Retry:       RetryConfig{InitialInterval: time.Second, MaxInterval: 30 * time.Second, MaxElapsedTime: time.Minute},
+	}, &mcfg)
+
+	mcfg.Client.Compression = "zstd"
+	_, err := getHTTPMetricEndpointOptions(&mcfg)
+	require.Error(t, err)
+
+	mcfg.Client.Compression = CompressionGzip
+	mcfg.Client.CertFile = "cert.pem"
+	_, err = getGRPCMetricEndpointOptions(&mcfg)
+	require.Error(t, err, "a client certificate without key should fail")
+}
+
+func TestClientOptions_PartialRetry(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		retry    RetryConfig
+		expected RetryConfig
+	}{
+		{name: "unset", retry: RetryConfig{}, expected: RetryConfig{}},
+		{
+			name:     "only initial interval",
+			retry:    RetryConfig{InitialInterval: time.Second},
+			expected: RetryConfig{InitialInterval: time.Second, MaxInterval: 30 * time.Second, MaxElapsedTime: time.Minute},
+		},
+		{
+			name:     "only max elapsed time",
+			retry:    RetryConfig{MaxElapsedTime: 2 * time.Minute},
+			expected: RetryConfig{InitialInterval: 5 * time.Second, MaxInterval: 30 * time.Second, MaxElapsedTime: 2 * time.Minute},
+		},
+		{
+			name:     "all values",
+			retry:    RetryConfig{InitialInterval: time.Second, MaxInterval: 10 * time.Second, MaxElapsedTime: 20 * time.Second},
+			expected: RetryConfig{InitialInterval: time.Second, MaxInterval: 10 * time.Second, MaxElapsedTime: 20 * time.Second},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := otlpOptions{}
+			require.NoError(t, opts.setupClient(&ClientConfig{}, &tc.retry))
+			assert.Equal(t, tc.expected, opts.Retry)
+		})
+	}
+}
+
+func TestMetricsExporter_GzipHeadersAndMTLS(t *testing.T) {
+	defer restoreEnvAfterExecution()()
+	dir := t.TempDir()
+	clientCert, clientKey := path.Join(dir, "client.pem"), path.Join(dir, "client-key.pem")
+	writeSelfSignedCert(t, clientCert, clientKey)
+
+	requests := make(chan *http.Request, 10)
+	coll := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		requests <- req
+	}))
+	coll.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	coll.StartTLS()
+	defer coll.Close()
+
+	// the collector certificate is verified against the provided CA file
+	caFile := path.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: coll.Certificate().Raw}), 0o600))
+
+	exporter, err := InstantiateMetricsExporter(context.Background(), &MetricsConfig{
+		MetricsEndpoint: coll.URL,
+		MetricsProtocol: ProtocolHTTPProtobuf,
+		Client: ClientConfig{
+			Compression: CompressionGzip,
+			Headers:     Headers{"X-Scope-OrgID": "tenant-1"},
+			CAFile:      caFile,
+			CertFile:    clientCert,
+			KeyFile:     clientKey,
+		},
+	}, slog.Default())
+	require.NoError(t, err)
+	require.NoError(t, exporter.Export(context.Background(), &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{Metrics: []metricdata.Metrics{{
+			Name: "foo",
+			Data: metricdata.Gauge[int64]{DataPoints: []metricdata.DataPoint[int64]{{Value: 3}}},
+		}}}},
+	}))
+
+	select {
+	case req := <-requests:
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "tenant-1", req.Header.Get("X-Scope-OrgID"))
+		require.NotNil(t, req.TLS)
+		assert.Len(t, req.TLS.PeerCertificates, 1)
+	case <-time.After(timeout):
+		require.Fail(t, "timeout while waiting for the exported metrics")
+	}
+}
+
+func writeSelfSignedCert(t *testing.T, certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "beyla"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/golang-lru/v2/simplelru"
@@ -19,6 +20,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
 	"google.golang.org/grpc/credentials"
 
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
@@ -38,6 +40,7 @@ const (
 	envTracesProtocol  = "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"
 	envMetricsProtocol = "OTEL_EXPORTER_OTLP_METRICS_PROTOCOL"
 	envProtocol        = "OTEL_EXPORTER_OTLP_PROTOCOL"
+	envExemplars       = "OTEL_GO_X_EXEMPLAR"
 )
 
 // Buckets defines the histograms bucket boundaries, and allows users to
@@ -79,42 +82,75 @@ func Resource(service svc.ID) *resource.Resource {
 	return resource.NewWithAttributes(semconv.SchemaURL, attrs...)
 }
 
+var timeNow = time.Now
+
 // ReporterPool keeps an LRU cache of different OTEL reporters given a service name.
-// TODO: evict reporters after a time without being accessed
+// If a time-to-live is set, the reporters that haven't been accessed during that time
+// are evicted, as they likely belong to services that are not running anymore.
 type ReporterPool[T any] struct {
-	pool *simplelru.LRU[svc.UID, T]
+	pool *simplelru.LRU[svc.UID, *expirable[T]]
+	ttl  time.Duration
 
 	itemConstructor func(svc.ID) (T, error)
 }
 
+type expirable[T any] struct {
+	lastAccess time.Time
+	value      T
+}
+
 // NewReporterPool creates a ReporterPool instance given a cache length,
+// a time-to-live for the unused items (zero means that items never expire),
 // an eviction callback to be invoked each time an element is removed
 // from the cache, and a constructor function that will specify how to
 // instantiate the generic OTEL metrics/traces reporter.
 func NewReporterPool[T any](
 	cacheLen int,
+	ttl time.Duration,
 	callback simplelru.EvictCallback[svc.UID, T],
 	itemConstructor func(id svc.ID) (T, error),
 ) ReporterPool[T] {
-	pool, _ := simplelru.NewLRU[svc.UID, T](cacheLen, callback)
-	return ReporterPool[T]{pool: pool, itemConstructor: itemConstructor}
+	pool, _ := simplelru.NewLRU[svc.UID, *expirable[T]](cacheLen, func(key svc.UID, value *expirable[T]) {
+		callback(key, value.value)
+	})
+	return ReporterPool[T]{pool: pool, ttl: ttl, itemConstructor: itemConstructor}
 }
 
 // For retrieves the associated item for the given service name, or
 // creates a new one if it does not exist
 func (rp *ReporterPool[T]) For(service svc.ID) (T, error) {
-	if m, ok := rp.pool.Get(service.UID); ok {
-		return m, nil
+	rp.expireOldest()
+	now := timeNow()
+	if e, ok := rp.pool.Get(service.UID); ok {
+		e.lastAccess = now
+		return e.value, nil
 	}
 	m, err := rp.itemConstructor(service)
 	if err != nil {
 		var t T
 		return t, fmt.Errorf("creating resource for service %q: %w", &service, err)
 	}
-	rp.pool.Add(service.UID, m)
+	rp.pool.Add(service.UID, &expirable[T]{lastAccess: now, value: m})
 	return m, nil
 }
 
+// expireOldest evicts the items that haven't been accessed during the time-to-live.
+// Since the LRU cache keeps the items sorted by access time, it stops at the first
+// item that has not expired.
+func (rp *ReporterPool[T]) expireOldest() {
+	if rp.ttl <= 0 {
+		return
+	}
+	deadline := timeNow().Add(-rp.ttl)
+	for {
+		uid, e, ok := rp.pool.GetOldest()
+		if !ok || !e.lastAccess.Before(deadline) {
+			return
+		}
+		rp.pool.Remove(uid)
+	}
+}
+
 // Intermediate representation of option functions suitable for testing
 type otlpOptions struct {
 	Endpoint      string
@@ -122,6 +158,21 @@ type otlpOptions struct {
 	URLPath       string
 	SkipTLSVerify bool
 	HTTPHeaders   map[string]string
+	Gzip          bool
+	// TLSConfig is only set when a custom CA or client certificate is provided.
+	// It already considers the SkipTLSVerify value.
+	TLSConfig *tls.Config
+	Retry     RetryConfig
+}
+
+func (o *otlpOptions) tlsConfig() *tls.Config {
+	if o.TLSConfig != nil {
+		return o.TLSConfig
+	}
+	if o.SkipTLSVerify {
+		return &tls.Config{InsecureSkipVerify: true}
+	}
+	return nil
 }
 
 func (o *otlpOptions) AsMetricHTTP() []otlpmetrichttp.Option {
@@ -134,12 +185,23 @@ func (o *otlpOptions) AsMetricHTTP() []otlpmetrichttp.Option {
 	if o.URLPath != "" {
 		opts = append(opts, otlpmetrichttp.WithURLPath(o.URLPath))
 	}
-	if o.SkipTLSVerify {
-		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
+	if tlsCfg := o.tlsConfig(); tlsCfg != nil {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsCfg))
 	}
 	if len(o.HTTPHeaders) > 0 {
 		opts = append(opts, otlpmetrichttp.WithHeaders(o.HTTPHeaders))
 	}
+	if o.Gzip {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	if o.Retry.isSet() {
+		opts = append(opts, otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: o.Retry.InitialInterval,
+			MaxInterval:     o.Retry.MaxInterval,
+			MaxElapsedTime:  o.Retry.MaxElapsedTime,
+		}))
+	}
 	return opts
 }
 
@@ -150,8 +212,22 @@ func (o *otlpOptions) AsMetricGRPC() []otlpmetricgrpc.Option {
 	if o.Insecure {
 		opts = append(opts, otlpmetricgrpc.WithInsecure())
 	}
-	if o.SkipTLSVerify {
-		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if tlsCfg := o.tlsConfig(); tlsCfg != nil {
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if len(o.HTTPHeaders) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(o.HTTPHeaders))
+	}
+	if o.Gzip {
+		opts = append(opts, otlpmetricgrpc.WithCompressor(CompressionGzip))
+	}
+	if o.Retry.isSet() {
+		opts = append(opts, otlpmetricgrpc.WithRetry(otlpmetricgrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: o.Retry.InitialInterval,
+			MaxInterval:     o.Retry.MaxInterval,
+			MaxElapsedTime:  o.Retry.MaxElapsedTime,
+		}))
 	}
 	return opts
 }
@@ -166,12 +242,23 @@ func (o *otlpOptions) AsTraceHTTP() []otlptracehttp.Option {
 	if o.URLPath != "" {
 		opts = append(opts, otlptracehttp.WithURLPath(o.URLPath))
 	}
-	if o.SkipTLSVerify {
-		opts = append(opts, otlptracehttp.WithTLSClientConfig(&tls.Config{InsecureSkipVerify: true}))
+	if tlsCfg := o.tlsConfig(); tlsCfg != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsCfg))
 	}
 	if len(o.HTTPHeaders) > 0 {
 		opts = append(opts, otlptracehttp.WithHeaders(o.HTTPHeaders))
 	}
+	if o.Gzip {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if o.Retry.isSet() {
+		opts = append(opts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: o.Retry.InitialInterval,
+			MaxInterval:     o.Retry.MaxInterval,
+			MaxElapsedTime:  o.Retry.MaxElapsedTime,
+		}))
+	}
 	return opts
 }
 
@@ -182,8 +269,22 @@ func (o *otlpOptions) AsTraceGRPC() []otlptracegrpc.Option {
 	if o.Insecure {
 		opts = append(opts, otlptracegrpc.WithInsecure())
 	}
-	if o.SkipTLSVerify {
-		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})))
+	if tlsCfg := o.tlsConfig(); tlsCfg != nil {
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(credentials.NewTLS(tlsCfg)))
+	}
+	if len(o.HTTPHeaders) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(o.HTTPHeaders))
+	}
+	if o.Gzip {
+		opts = append(opts, otlptracegrpc.WithCompressor(CompressionGzip))
+	}
+	if o.Retry.isSet() {
+		opts = append(opts, otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{
+			Enabled:         true,
+			InitialInterval: o.Retry.InitialInterval,
+			MaxInterval:     o.Retry.MaxInterval,
+			MaxElapsedTime:  o.Retry.MaxElapsedTime,
+		}))
 	}
 	return opts
 }
@@ -244,56 +345,45 @@ func (l *LogrAdaptor) WithName(name string) logr.LogSink {
 	return &LogrAdaptor{inner: l.inner.With("name", name)}
 }
 
-// OpenTelemetry 1.23 semantic convention
-const (
-	HTTPRequestMethodKey      = attribute.Key("http.request.method")
-	HTTPResponseStatusCodeKey = attribute.Key("http.response.status_code")
-	HTTPUrlPathKey            = attribute.Key("url.path")
-	HTTPUrlFullKey            = attribute.Key("url.full")
-	ClientAddrKey             = attribute.Key("client.address")
-	ClientPortKey             = attribute.Key("client.port")
-	ServerAddrKey             = attribute.Key("server.address")
-	ServerPortKey             = attribute.Key("server.port")
-	HTTPRequestBodySizeKey    = attribute.Key("http.request.body.size")
-	HTTPResponseBodySizeKey   = attribute.Key("http.response.body.size")
-)
+// The helpers below build the attributes whose name depends on the selected semantic
+// conventions version
 
-func HTTPRequestMethod(val string) attribute.KeyValue {
-	return HTTPRequestMethodKey.String(val)
+func HTTPRequestMethod(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.HTTPRequestMethod, val)
 }
 
-func HTTPResponseStatusCode(val int) attribute.KeyValue {
-	return HTTPResponseStatusCodeKey.Int(val)
+func HTTPResponseStatusCode(names *attributes.Names, val int) attribute.KeyValue {
+	return attribute.Int(names.HTTPResponseStatusCode, val)
 }
 
-func HTTPUrlPath(val string) attribute.KeyValue {
-	return HTTPUrlPathKey.String(val)
+func HTTPUrlPath(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.URLPath, val)
 }
 
-func HTTPUrlFull(val string) attribute.KeyValue {
-	return HTTPUrlFullKey.String(val)
+func HTTPUrlFull(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.URLFull, val)
 }
 
-func ClientAddr(val string) attribute.KeyValue {
-	return ClientAddrKey.String(val)
+func ClientAddr(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.ClientAddr, val)
 }
 
-func ClientPort(val int) attribute.KeyValue {
-	return ClientPortKey.Int(val)
+func ServerAddr(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.ServerAddr, val)
 }
 
-func ServerAddr(val string) attribute.KeyValue {
-	return ServerAddrKey.String(val)
+func ServerPort(names *attributes.Names, val int) attribute.KeyValue {
+	return attribute.Int(names.ServerPort, val)
 }
 
-func ServerPort(val int) attribute.KeyValue {
-	return ServerPortKey.Int(val)
+func HostAddr(names *attributes.Names, val string) attribute.KeyValue {
+	return attribute.String(names.HostAddr, val)
 }
 
-func HTTPRequestBodySize(val int) attribute.KeyValue {
-	return HTTPRequestBodySizeKey.Int(val)
+func HostPort(names *attributes.Names, val int) attribute.KeyValue {
+	return attribute.Int(names.HostPort, val)
 }
 
-func HTTPResponseBodySize(val int) attribute.KeyValue {
-	return HTTPResponseBodySizeKey.Int(val)
+func HTTPRequestBodySize(names *attributes.Names, val int) attribute.KeyValue {
+	return attribute.Int(names.HTTPRequestBodySize, val)
 }
@@ -3,8 +3,12 @@ package otel
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 func TestOtlpOptions_AsMetricHTTP(t *testing.T) {
@@ -84,3 +88,44 @@ func TestOtlpOptions_AsTraceGRPC(t *testing.T) {
 		})
 	}
 }
+
+func TestReporterPool_ExpireUnused(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var evicted []svc.UID
+	pool := NewReporterPool[string](10, time.Minute,
+		func(uid svc.UID, _ string) {
+			evicted = append(evicted, uid)
+		},
+		func(id svc.ID) (string, error) {
+			return id.Name, nil
+		})
+	foo := svc.ID{UID: "foo", Name: "foo"}
+	bar := svc.ID{UID: "bar", Name: "bar"}
+	baz := svc.ID{UID: "baz", Name: "baz"}
+
+	_, err := pool.For(foo)
+	require.NoError(t, err)
+	_, err = pool.For(bar)
+	require.NoError(t, err)
+
+	// accessing foo avoids its expiration
+	now = now.Add(40 * time.Second)
+	_, err = pool.For(foo)
+	require.NoError(t, err)
+	assert.Empty(t, evicted)
+
+	now = now.Add(40 * time.Second)
+	_, err = pool.For(baz)
+	require.NoError(t, err)
+	assert.Equal(t, []svc.UID{"bar"}, evicted)
+
+	now = now.Add(2 * time.Minute)
+	v, err := pool.For(bar)
+	require.NoError(t, err)
+	assert.Equal(t, "bar", v)
+	assert.Equal(t, []svc.UID{"bar", "foo", "baz"}, evicted)
+	assert.Equal(t, 1, pool.pool.Len())
+}
@@ -0,0 +1,611 @@
+package otel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grafana/beyla/pkg/buildinfo"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func llog() *slog.Logger {
+	return slog.With("component", "otel.LogsReporter")
+}
+
+// DurationKey is the log record attribute that contains the duration of the request, in seconds
+const DurationKey = attribute.Key("duration")
+
+// default values of the batching options. They are the same as the OpenTelemetry SDK's batch log record processor.
+const (
+	defaultLogsMaxExportBatchSize = 512
+	defaultLogsMaxQueueSize       = 2048
+	defaultLogsBatchTimeout       = time.Second
+	defaultLogsExportTimeout      = 30 * time.Second
+)
+
+// LogsConfig for the OTLP logs exporter, which submits an access log record for each request.
+// Unlike traces and metrics, it is not enabled by the common OTEL_EXPORTER_OTLP_ENDPOINT, as
+// access logs can be high-volume data that need to be explicitly enabled.
+type LogsConfig struct {
+	LogsEndpoint string `yaml:"endpoint" env:"OTEL_EXPORTER_OTLP_LOGS_ENDPOINT"`
+
+	Protocol     Protocol `yaml:"protocol" env:"OTEL_EXPORTER_OTLP_PROTOCOL"`
+	LogsProtocol Protocol `yaml:"-" env:"OTEL_EXPORTER_OTLP_LOGS_PROTOCOL"`
+
+	// InsecureSkipVerify is not standard, so we don't follow the same naming convention
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"BEYLA_OTEL_INSECURE_SKIP_VERIFY"`
+
+	// Client configuration from the YAML section or the OTEL_EXPORTER_OTLP_LOGS_* variables
+	Client ClientConfig `yaml:",inline" envPrefix:"OTEL_EXPORTER_OTLP_LOGS_"`
+	// CommonClient configuration from the OTEL_EXPORTER_OTLP_* variables. Client values take precedence.
+	CommonClient ClientConfig `yaml:"-" envPrefix:"OTEL_EXPORTER_OTLP_"`
+
+	Retry RetryConfig `yaml:"retry" envPrefix:"BEYLA_OTEL_LOGS_RETRY_"`
+
+	// Sampler of the log records. It works the same as the traces sampler, so if both traces and
+	// logs use the same sampler, the logs will be submitted for the same requests as the traces.
+	Sampler Sampler `yaml:"sampler"`
+
+	// Configuration options below this line will remain undocumented at the moment,
+	// but can be useful for performance-tuning of some customers.
+	MaxExportBatchSize int           `yaml:"max_export_batch_size" env:"BEYLA_OTLP_LOGS_MAX_EXPORT_BATCH_SIZE"`
+	MaxQueueSize       int           `yaml:"max_queue_size" env:"BEYLA_OTLP_LOGS_MAX_QUEUE_SIZE"`
+	BatchTimeout       time.Duration `yaml:"batch_timeout" env:"BEYLA_OTLP_LOGS_BATCH_TIMEOUT"`
+	ExportTimeout      time.Duration `yaml:"export_timeout" env:"BEYLA_OTLP_LOGS_EXPORT_TIMEOUT"`
+
+	// SemConv version that defines the names of the log record attributes.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+}
+
+// Enabled specifies that the OTEL logs node is enabled if and only if
+// the OTEL logs endpoint is defined.
+// If not enabled, this node won't be instantiated
+func (m LogsConfig) Enabled() bool { //nolint:gocritic
+	return m.LogsEndpoint != ""
+}
+
+func (m *LogsConfig) GetProtocol() Protocol {
+	if m.LogsProtocol != "" {
+		return m.LogsProtocol
+	}
+	if m.Protocol != "" {
+		return m.Protocol
+	}
+	return m.GuessProtocol()
+}
+
+func (m *LogsConfig) GuessProtocol() Protocol {
+	// If no explicit protocol is set, we guess it it from the logs enpdoint port
+	// (assuming it uses a standard port or a development-like form like 14317, 24317, 14318...)
+	ep, err := parseLogsEndpoint(m)
+	if err == nil {
+		if strings.HasSuffix(ep.Port(), UsualPortGRPC) {
+			return ProtocolGRPC
+		} else if strings.HasSuffix(ep.Port(), UsualPortHTTP) {
+			return ProtocolHTTPProtobuf
+		}
+	}
+	return ProtocolHTTPProtobuf
+}
+
+// logsExporter submits the log records to the OTLP endpoint
+type logsExporter interface {
+	export(ctx context.Context, req *collogs.ExportLogsServiceRequest) error
+	shutdown()
+}
+
+// permanentError is returned by the logs exporters when retrying the export would fail again
+type permanentError struct {
+	error
+}
+
+// LogsReporter implements the graph node that receives request.Span
+// instances and forwards them as OTEL access log records.
+type LogsReporter struct {
+	ctx      context.Context
+	cfg      *LogsConfig
+	sampler  trace.Sampler
+	exporter logsExporter
+	metrics  imetrics.Reporter
+
+	batch *logsBatch
+	// queue of batches that are pending to be exported
+	queue chan *logsBatch
+}
+
+func ReportLogs(ctx context.Context, cfg *LogsConfig, ctxInfo *global.ContextInfo) (node.TerminalFunc[[]request.Span], error) {
+	lr, err := newLogsReporter(ctx, cfg, ctxInfo)
+	if err != nil {
+		return nil, fmt.Errorf("can't instantiate OTEL logs reporter: %w", err)
+	}
+	return lr.reportLogs, nil
+}
+
+func newLogsReporter(ctx context.Context, cfg *LogsConfig, ctxInfo *global.ContextInfo) (*LogsReporter, error) {
+	log := llog()
+	r := LogsReporter{
+		ctx:     ctx,
+		cfg:     cfg,
+		sampler: cfg.Sampler.Implementation(),
+		metrics: ctxInfo.Metrics,
+		batch:   newLogsBatch(),
+	}
+	if r.metrics == nil {
+		r.metrics = imetrics.NoopReporter{}
+	}
+	if cfg.MaxExportBatchSize <= 0 {
+		cfg.MaxExportBatchSize = defaultLogsMaxExportBatchSize
+	}
+	if cfg.MaxQueueSize <= 0 {
+		cfg.MaxQueueSize = defaultLogsMaxQueueSize
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = defaultLogsBatchTimeout
+	}
+	if cfg.ExportTimeout <= 0 {
+		cfg.ExportTimeout = defaultLogsExportTimeout
+	}
+	r.queue = make(chan *logsBatch, max(1, cfg.MaxQueueSize/cfg.MaxExportBatchSize))
+
+	switch proto := cfg.GetProtocol(); proto {
+	case ProtocolHTTPJSON, ProtocolHTTPProtobuf, "": // as the OTEL SDK exporters, we always submit protobuf over HTTP
+		log.Debug("instantiating HTTP LogsReporter", "protocol", proto)
+		opts, err := getHTTPLogsEndpointOptions(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("can't instantiate OTEL HTTP logs exporter: %w", err)
+		}
+		r.exporter = newHTTPLogsExporter(&opts)
+	case ProtocolGRPC:
+		log.Debug("instantiating GRPC LogsReporter", "protocol", proto)
+		opts, err := getGRPCLogsEndpointOptions(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("can't instantiate OTEL GRPC logs exporter: %w", err)
+		}
+		if r.exporter, err = newGRPCLogsExporter(&opts); err != nil {
+			return nil, fmt.Errorf("can't instantiate OTEL GRPC logs exporter: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid protocol value: %q. Accepted values are: %s, %s, %s",
+			proto, ProtocolGRPC, ProtocolHTTPJSON, ProtocolHTTPProtobuf)
+	}
+	return &r, nil
+}
+
+func (r *LogsReporter) reportLogs(input <-chan []request.Span) {
+	exported := make(chan struct{})
+	go func() {
+		r.exportLoop()
+		close(exported)
+	}()
+	ticker := time.NewTicker(r.cfg.BatchTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case spans, ok := <-input:
+			if !ok {
+				r.flush()
+				close(r.queue)
+				<-exported
+				return
+			}
+			r.addRecords(spans)
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
+
+func (r *LogsReporter) addRecords(spans []request.Span) {
+	for i := range spans {
+		span := &spans[i]
+		// logs follow the same filtering criteria as traces
+		if span.IgnoreSpan == request.IgnoreTraces || !r.sampled(span) {
+			continue
+		}
+		r.batch.add(&span.ServiceID, logRecord(span, r.cfg.SemConv.Names()))
+		if r.batch.len >= r.cfg.MaxExportBatchSize {
+			r.flush()
+		}
+	}
+}
+
+func (r *LogsReporter) sampled(span *request.Span) bool {
+	res := r.sampler.ShouldSample(trace.SamplingParameters{
+		ParentContext: HandleTraceparent(r.ctx, span),
+		TraceID:       span.TraceID,
+		Name:          TraceName(span),
+		Kind:          SpanKind(span),
+	})
+	return res.Decision == trace.RecordAndSample
+}
+
+// flush enqueues the current batch for its export. If the queue is full,
+// the batch is dropped to avoid blocking the pipeline.
+func (r *LogsReporter) flush() {
+	if r.batch.len == 0 {
+		return
+	}
+	select {
+	case r.queue <- r.batch:
+	default:
+		llog().Warn("logs export queue is full. Dropping log records", "len", r.batch.len)
+		r.metrics.OTELLogExportError(errors.New("export queue is full"))
+	}
+	r.batch = newLogsBatch()
+}
+
+func (r *LogsReporter) exportLoop() {
+	defer r.exporter.shutdown()
+	for batch := range r.queue {
+		ctx, cancel := r.exportContext()
+		err := r.send(ctx, batch.request())
+		cancel()
+		if err != nil {
+			llog().Error("can't export log records", "error", err, "len", batch.len)
+			r.metrics.OTELLogExportError(err)
+			continue
+		}
+		r.metrics.OTELLogExport(batch.len)
+	}
+}
+
+// exportContext returns the context of the export requests. Once the reporter context is
+// cancelled (e.g. Beyla is shutting down), the last batches are still exported with a new
+// context, bounded by the export timeout.
+func (r *LogsReporter) exportContext() (context.Context, context.CancelFunc) {
+	if r.ctx.Err() == nil {
+		return r.ctx, func() {}
+	}
+	return context.WithTimeout(context.Background(), r.cfg.ExportTimeout)
+}
+
+// send the export request, retrying it with an exponential backoff if the error is recoverable
+func (r *LogsReporter) send(ctx context.Context, req *collogs.ExportLogsServiceRequest) error {
+	retry := r.cfg.Retry.withDefaults()
+	deadline := time.Now().Add(retry.MaxElapsedTime)
+	backoff := retry.InitialInterval
+	for {
+		reqCtx, cancel := context.WithTimeout(ctx, r.cfg.ExportTimeout)
+		err := r.exporter.export(reqCtx, req)
+		cancel()
+		var pe permanentError
+		if err == nil || errors.As(err, &pe) || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
+		llog().Debug("error exporting log records. Retrying", "error", err, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > retry.MaxInterval {
+			backoff = retry.MaxInterval
+		}
+	}
+}
+
+// logsBatch groups the log records by service, which are submitted as different OTEL resources
+type logsBatch struct {
+	len       int
+	resources []*logspb.ResourceLogs
+	scopes    map[svc.UID]*logspb.ScopeLogs
+}
+
+func newLogsBatch() *logsBatch {
+	return &logsBatch{scopes: map[svc.UID]*logspb.ScopeLogs{}}
+}
+
+func (b *logsBatch) add(service *svc.ID, record *logspb.LogRecord) {
+	scope, ok := b.scopes[service.UID]
+	if !ok {
+		scope = &logspb.ScopeLogs{
+			Scope: &commonpb.InstrumentationScope{Name: reporterName, Version: buildinfo.Version},
+		}
+		b.scopes[service.UID] = scope
+		b.resources = append(b.resources, &logspb.ResourceLogs{
+			Resource:  &resourcepb.Resource{Attributes: protoAttributes(Resource(*service).Attributes())},
+			ScopeLogs: []*logspb.ScopeLogs{scope},
+		})
+	}
+	scope.LogRecords = append(scope.LogRecords, record)
+	b.len++
+}
+
+func (b *logsBatch) request() *collogs.ExportLogsServiceRequest {
+	return &collogs.ExportLogsServiceRequest{ResourceLogs: b.resources}
+}
+
+// logRecord converts the span into an access log record, which can be correlated
+// with the traces through its trace and span IDs
+func logRecord(span *request.Span, names *attributes.Names) *logspb.LogRecord {
+	t := span.Timings()
+	start := SpanStartTime(t)
+	duration := t.End.Sub(start)
+
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(start.UnixNano()),
+		ObservedTimeUnixNano: uint64(t.End.UnixNano()),
+		SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+		SeverityText:         "INFO",
+		Body: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{
+			StringValue: accessLogLine(span, duration),
+		}},
+		Attributes: protoAttributes(append(TraceAttributes(span, names), DurationKey.Float64(duration.Seconds()))),
+		Flags:      uint32(span.Flags),
+	}
+	if SpanStatusCode(span) == codes.Error {
+		record.SeverityNumber = logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+		record.SeverityText = "ERROR"
+	}
+	if span.TraceID.IsValid() {
+		record.TraceId = span.TraceID[:]
+	}
+	if span.SpanID.IsValid() {
+		record.SpanId = span.SpanID[:]
+	}
+	return record
+}
+
+// accessLogLine returns a human-readable summary of the request, e.g. "GET /users/{id} 200 1.5ms"
+func accessLogLine(span *request.Span, duration time.Duration) string {
+	name := TraceName(span)
+	switch span.Type {
+	case request.EventTypeHTTP:
+		if span.Route == "" {
+			name += " " + span.Path
+		}
+	case request.EventTypeHTTPClient:
+		name += " " + span.Path
+	}
+	return fmt.Sprintf("%s %d %s", name, span.Status, duration)
+}
+
+func protoAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	kvs := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		kv := &commonpb.KeyValue{Key: string(attr.Key), Value: &commonpb.AnyValue{}}
+		switch attr.Value.Type() {
+		case attribute.BOOL:
+			kv.Value.Value = &commonpb.AnyValue_BoolValue{BoolValue: attr.Value.AsBool()}
+		case attribute.INT64:
+			kv.Value.Value = &commonpb.AnyValue_IntValue{IntValue: attr.Value.AsInt64()}
+		case attribute.FLOAT64:
+			kv.Value.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: attr.Value.AsFloat64()}
+		default:
+			kv.Value.Value = &commonpb.AnyValue_StringValue{StringValue: attr.Value.Emit()}
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs
+}
+
+type httpLogsExporter struct {
+	url     string
+	headers map[string]string
+	gzip    bool
+	client  *http.Client
+}
+
+func newHTTPLogsExporter(opts *otlpOptions) *httpLogsExporter {
+	scheme := "https"
+	if opts.Insecure {
+		scheme = "http"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = opts.tlsConfig()
+	return &httpLogsExporter{
+		url:     scheme + "://" + opts.Endpoint + opts.URLPath,
+		headers: opts.HTTPHeaders,
+		gzip:    opts.Gzip,
+		client:  &http.Client{Transport: transport},
+	}
+}
+
+func (e *httpLogsExporter) export(ctx context.Context, req *collogs.ExportLogsServiceRequest) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return permanentError{fmt.Errorf("encoding request: %w", err)}
+	}
+	if e.gzip {
+		buf := bytes.Buffer{}
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return permanentError{fmt.Errorf("compressing request: %w", err)}
+		}
+		if err := gz.Close(); err != nil {
+			return permanentError{fmt.Errorf("compressing request: %w", err)}
+		}
+		body = buf.Bytes()
+	}
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{fmt.Errorf("creating request: %w", err)}
+	}
+	hreq.Header.Set("Content-Type", "application/x-protobuf")
+	hreq.Header.Set("User-Agent", "beyla/"+buildinfo.Version)
+	if e.gzip {
+		hreq.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.headers {
+		hreq.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(hreq)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	err = fmt.Errorf("OTLP endpoint returned %s", resp.Status)
+	// retryable response codes, as defined by the OTLP specification
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return err
+	}
+	return permanentError{err}
+}
+
+func (e *httpLogsExporter) shutdown() {
+	e.client.CloseIdleConnections()
+}
+
+type grpcLogsExporter struct {
+	conn     *grpc.ClientConn
+	client   collogs.LogsServiceClient
+	headers  metadata.MD
+	callOpts []grpc.CallOption
+}
+
+func newGRPCLogsExporter(opts *otlpOptions) (*grpcLogsExporter, error) {
+	creds := insecure.NewCredentials()
+	if !opts.Insecure {
+		tlsCfg := opts.tlsConfig()
+		if tlsCfg == nil {
+			tlsCfg = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	}
+	conn, err := grpc.Dial(opts.Endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", opts.Endpoint, err)
+	}
+	e := &grpcLogsExporter{
+		conn:    conn,
+		client:  collogs.NewLogsServiceClient(conn),
+		headers: metadata.New(opts.HTTPHeaders),
+	}
+	if opts.Gzip {
+		e.callOpts = append(e.callOpts, grpc.UseCompressor(grpcgzip.Name))
+	}
+	return e, nil
+}
+
+func (e *grpcLogsExporter) export(ctx context.Context, req *collogs.ExportLogsServiceRequest) error {
+	if len(e.headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, e.headers)
+	}
+	_, err := e.client.Export(ctx, req, e.callOpts...)
+	if err == nil {
+		return nil
+	}
+	// retryable status codes, as defined by the OTLP specification
+	switch status.Code(err) {
+	case grpccodes.Canceled, grpccodes.DeadlineExceeded, grpccodes.ResourceExhausted, grpccodes.Aborted,
+		grpccodes.OutOfRange, grpccodes.Unavailable, grpccodes.DataLoss:
+		return err
+	}
+	return permanentError{err}
+}
+
+func (e *grpcLogsExporter) shutdown() {
+	if err := e.conn.Close(); err != nil {
+		llog().Debug("closing GRPC connection", "error", err)
+	}
+}
+
+func parseLogsEndpoint(cfg *LogsConfig) (*url.URL, error) {
+	murl, err := url.Parse(cfg.LogsEndpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing endpoint URL %s: %w", cfg.LogsEndpoint, err)
+	}
+	if murl.Scheme == "" || murl.Host == "" {
+		return nil, fmt.Errorf("URL %q must have a scheme and a host", cfg.LogsEndpoint)
+	}
+	return murl, nil
+}
+
+func getHTTPLogsEndpointOptions(cfg *LogsConfig) (otlpOptions, error) {
+	opts := otlpOptions{}
+	log := llog().With("transport", "http")
+
+	murl, err := parseLogsEndpoint(cfg)
+	if err != nil {
+		return opts, err
+	}
+
+	log.Debug("Configuring exporter", "protocol",
+		cfg.Protocol, "logsProtocol", cfg.LogsProtocol, "endpoint", murl.Host)
+	opts.Endpoint = murl.Host
+	if murl.Scheme == "http" || murl.Scheme == "unix" {
+		log.Debug("Specifying insecure connection", "scheme", murl.Scheme)
+		opts.Insecure = true
+	}
+	// as the OTEL SDK exporters, we submit the logs to the default path if the endpoint does not specify any
+	opts.URLPath = murl.Path
+	if opts.URLPath == "" || opts.URLPath == "/" {
+		opts.URLPath = "/v1/logs"
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Debug("Setting InsecureSkipVerify")
+		opts.SkipTLSVerify = true
+	}
+
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+func getGRPCLogsEndpointOptions(cfg *LogsConfig) (otlpOptions, error) {
+	opts := otlpOptions{}
+	log := llog().With("transport", "grpc")
+	murl, err := parseLogsEndpoint(cfg)
+	if err != nil {
+		return opts, err
+	}
+
+	log.Debug("Configuring exporter", "protocol",
+		cfg.Protocol, "logsProtocol", cfg.LogsProtocol, "endpoint", murl.Host)
+	opts.Endpoint = murl.Host
+	if murl.Scheme == "http" || murl.Scheme == "unix" {
+		log.Debug("Specifying insecure connection", "scheme", murl.Scheme)
+		opts.Insecure = true
+	}
+
+	if cfg.InsecureSkipVerify {
+		log.Debug("Setting InsecureSkipVerify")
+		opts.SkipTLSVerify = true
+	}
+
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
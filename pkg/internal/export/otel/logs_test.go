@@ -0,0 +1,352 @@
+package otel
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	trace2 "go.opentelemetry.io/otel/trace"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+func TestLogsConfig_Enabled(t *testing.T) {
+	assert.True(t, LogsConfig{LogsEndpoint: "http://foo:4318"}.Enabled())
+	assert.False(t, LogsConfig{}.Enabled())
+}
+
+func TestLogsConfig_GuessProtocol(t *testing.T) {
+	assert.Equal(t, ProtocolGRPC, (&LogsConfig{LogsEndpoint: "http://foo:14317"}).GetProtocol())
+	assert.Equal(t, ProtocolHTTPProtobuf, (&LogsConfig{LogsEndpoint: "http://foo:4318"}).GetProtocol())
+	assert.Equal(t, ProtocolGRPC, (&LogsConfig{LogsEndpoint: "http://foo:4318", Protocol: ProtocolGRPC}).GetProtocol())
+	assert.Equal(t, ProtocolHTTPJSON, (&LogsConfig{LogsEndpoint: "http://foo:4318", Protocol: ProtocolGRPC,
+		LogsProtocol: ProtocolHTTPJSON}).GetProtocol())
+}
+
+func TestHTTPLogsEndpointOptions(t *testing.T) {
+	opts, err := getHTTPLogsEndpointOptions(&LogsConfig{
+		LogsEndpoint: "https://localhost:3131",
+		Client:       ClientConfig{Compression: CompressionGzip},
+		CommonClient: ClientConfig{Headers: Headers{"X-Scope-OrgID": "tenant-1"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, otlpOptions{
+		Endpoint:    "localhost:3131",
+		URLPath:     "/v1/logs",
+		Gzip:        true,
+		HTTPHeaders: map[string]string{"X-Scope-OrgID": "tenant-1"},
+	}, opts)
+
+	opts, err = getHTTPLogsEndpointOptions(&LogsConfig{LogsEndpoint: "http://localhost:3131/custom/logs"})
+	require.NoError(t, err)
+	assert.Equal(t, otlpOptions{Endpoint: "localhost:3131", URLPath: "/custom/logs", Insecure: true}, opts)
+
+	_, err = getHTTPLogsEndpointOptions(&LogsConfig{LogsEndpoint: "localhost:3131"})
+	require.Error(t, err)
+}
+
+func TestLogs_HTTP(t *testing.T) {
+	received := make(chan *collogs.ExportLogsServiceRequest, 10)
+	var calls atomic.Int32
+	coll := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// the first request fails, to verify that it is retried
+		if calls.Add(1) == 1 {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "/v1/logs", req.URL.Path)
+		assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+		assert.Equal(t, "gzip", req.Header.Get("Content-Encoding"))
+		assert.Equal(t, "tenant-1", req.Header.Get("X-Scope-OrgID"))
+		gz, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(gz)
+		require.NoError(t, err)
+		msg := &collogs.ExportLogsServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, msg))
+		received <- msg
+	}))
+	defer coll.Close()
+
+	internalMetrics := &fakeInternalLogs{}
+	input := make(chan []request.Span, 10)
+	exported := runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: coll.URL,
+		Client: ClientConfig{
+			Compression: CompressionGzip,
+			Headers:     Headers{"X-Scope-OrgID": "tenant-1"},
+		},
+		Retry: RetryConfig{InitialInterval: time.Millisecond, MaxElapsedTime: timeout},
+	}, internalMetrics, input)
+	input <- testLogSpans()
+	close(input)
+	waitClosed(t, exported)
+
+	msg := testutil.ReadChannel(t, received, timeout)
+	require.Len(t, msg.ResourceLogs, 2)
+	records := msg.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 2)
+	assert.Equal(t, "GET /users/{id} 200 1ms", records[0].Body.GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_INFO, records[0].SeverityNumber)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}, records[0].TraceId)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5, 6, 7, 8}, records[0].SpanId)
+	assert.InDelta(t, 0.001, logAttribute(records[0], DurationKey).GetDoubleValue(), 1e-9)
+	assert.Equal(t, "/users/{id}", logAttribute(records[0], "http.route").GetStringValue())
+	assert.Equal(t, "POST /login 500 2ms", records[1].Body.GetStringValue())
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, records[1].SeverityNumber)
+
+	require.Len(t, msg.ResourceLogs[1].ScopeLogs[0].LogRecords, 1)
+	assert.Equal(t, "foo.bar/Baz 0 3ms",
+		msg.ResourceLogs[1].ScopeLogs[0].LogRecords[0].Body.GetStringValue())
+
+	assert.Equal(t, 3, internalMetrics.records())
+	assert.Zero(t, internalMetrics.errs.Load())
+}
+
+func TestLogs_HTTP_NonRecoverableError(t *testing.T) {
+	var calls atomic.Int32
+	coll := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer coll.Close()
+
+	internalMetrics := &fakeInternalLogs{}
+	input := make(chan []request.Span, 10)
+	exported := runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: coll.URL,
+		Retry:        RetryConfig{InitialInterval: time.Millisecond, MaxElapsedTime: timeout},
+	}, internalMetrics, input)
+	input <- testLogSpans()
+	close(input)
+	waitClosed(t, exported)
+
+	assert.EqualValues(t, 1, calls.Load())
+	assert.EqualValues(t, 1, internalMetrics.errs.Load())
+	assert.Zero(t, internalMetrics.records())
+}
+
+func TestLogs_HTTP_PartialRetry(t *testing.T) {
+	var calls, failures atomic.Int32
+	coll := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		if calls.Add(1) <= failures.Load() {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer coll.Close()
+
+	// with only the max elapsed time, the retries wait for the default initial interval (5s),
+	// which exceeds the max elapsed time, so the request is not retried
+	failures.Store(100)
+	internalMetrics := &fakeInternalLogs{}
+	input := make(chan []request.Span, 10)
+	exported := runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: coll.URL,
+		Retry:        RetryConfig{MaxElapsedTime: 100 * time.Millisecond},
+	}, internalMetrics, input)
+	input <- testLogSpans()
+	close(input)
+	waitClosed(t, exported)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.EqualValues(t, 1, internalMetrics.errs.Load())
+
+	// with only the initial interval, the requests are retried until the default max elapsed time (1m)
+	calls.Store(0)
+	failures.Store(2)
+	internalMetrics = &fakeInternalLogs{}
+	input = make(chan []request.Span, 10)
+	exported = runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: coll.URL,
+		Retry:        RetryConfig{InitialInterval: time.Millisecond},
+	}, internalMetrics, input)
+	input <- testLogSpans()
+	close(input)
+	waitClosed(t, exported)
+	assert.EqualValues(t, 3, calls.Load())
+	assert.Zero(t, internalMetrics.errs.Load())
+	assert.Equal(t, 3, internalMetrics.records())
+}
+
+func TestLogs_FlushAfterCancel(t *testing.T) {
+	received := make(chan struct{}, 10)
+	coll := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		received <- struct{}{}
+	}))
+	defer coll.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	node, err := ReportLogs(ctx, &LogsConfig{LogsEndpoint: coll.URL, BatchTimeout: time.Hour},
+		&global.ContextInfo{Metrics: &fakeInternalLogs{}})
+	require.NoError(t, err)
+	input := make(chan []request.Span, 10)
+	input <- testLogSpans()
+	// the last batch is exported even if the context has been cancelled
+	cancel()
+	close(input)
+	node(input)
+	testutil.ReadChannel(t, received, timeout)
+}
+
+func TestLogs_Sampling(t *testing.T) {
+	received := make(chan *collogs.ExportLogsServiceRequest, 10)
+	coll := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		msg := &collogs.ExportLogsServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, msg))
+		received <- msg
+	}))
+	defer coll.Close()
+
+	input := make(chan []request.Span, 10)
+	exported := runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: coll.URL,
+		Sampler:      Sampler{Name: "parentbased_always_off"},
+	}, &fakeInternalLogs{}, input)
+	spans := testLogSpans()
+	// only the requests whose parent is sampled are logged
+	spans[1].TraceID = trace2.TraceID{16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+	spans[1].ParentSpanID = trace2.SpanID{8, 7, 6, 5, 4, 3, 2, 1}
+	spans[1].Flags = uint8(trace2.FlagsSampled)
+	input <- spans
+	close(input)
+	waitClosed(t, exported)
+
+	msg := testutil.ReadChannel(t, received, timeout)
+	require.Len(t, msg.ResourceLogs, 1)
+	require.Len(t, msg.ResourceLogs[0].ScopeLogs[0].LogRecords, 1)
+	assert.Equal(t, "POST /login 500 2ms", msg.ResourceLogs[0].ScopeLogs[0].LogRecords[0].Body.GetStringValue())
+}
+
+func TestLogs_GRPC(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := grpc.NewServer()
+	coll := &fakeLogsService{received: make(chan *collogs.ExportLogsServiceRequest, 10)}
+	collogs.RegisterLogsServiceServer(srv, coll)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	internalMetrics := &fakeInternalLogs{}
+	input := make(chan []request.Span, 10)
+	exported := runLogsReporter(t, &LogsConfig{
+		LogsEndpoint: "http://" + lis.Addr().String(),
+		LogsProtocol: ProtocolGRPC,
+		Client: ClientConfig{
+			Compression: CompressionGzip,
+			Headers:     Headers{"X-Scope-OrgID": "tenant-1"},
+		},
+	}, internalMetrics, input)
+	input <- testLogSpans()
+	close(input)
+	waitClosed(t, exported)
+
+	msg := testutil.ReadChannel(t, coll.received, timeout)
+	require.Len(t, msg.ResourceLogs, 2)
+	assert.Len(t, msg.ResourceLogs[0].ScopeLogs[0].LogRecords, 2)
+	assert.Len(t, msg.ResourceLogs[1].ScopeLogs[0].LogRecords, 1)
+	assert.Equal(t, []string{"tenant-1"}, coll.md.Get("X-Scope-OrgID"))
+	assert.Equal(t, 3, internalMetrics.records())
+}
+
+func runLogsReporter(t *testing.T, cfg *LogsConfig, internalMetrics imetrics.Reporter, input chan []request.Span) <-chan struct{} {
+	node, err := ReportLogs(context.Background(), cfg, &global.ContextInfo{Metrics: internalMetrics})
+	require.NoError(t, err)
+	exported := make(chan struct{})
+	go func() {
+		node(input)
+		close(exported)
+	}()
+	return exported
+}
+
+func waitClosed(t *testing.T, ch <-chan struct{}) {
+	select {
+	case <-ch:
+	case <-time.After(timeout):
+		require.Fail(t, "timeout while waiting for the logs to be exported")
+	}
+}
+
+func testLogSpans() []request.Span {
+	start := time.Now().UnixNano()
+	return []request.Span{{
+		Type: request.EventTypeHTTP, Method: "GET", Path: "/users/1234", Route: "/users/{id}", Status: 200,
+		RequestStart: start, Start: start, End: start + int64(time.Millisecond),
+		TraceID:   trace2.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:    trace2.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		ServiceID: svc.ID{Name: "foo", UID: "foo"},
+	}, {
+		Type: request.EventTypeHTTP, Method: "POST", Path: "/login", Status: 500,
+		RequestStart: start, Start: start, End: start + int64(2*time.Millisecond),
+		ServiceID: svc.ID{Name: "foo", UID: "foo"},
+	}, {
+		// ignored requests are not logged
+		Type: request.EventTypeHTTP, Method: "GET", Path: "/health", Status: 200,
+		RequestStart: start, Start: start, End: start + int64(time.Millisecond),
+		IgnoreSpan: request.IgnoreTraces,
+		ServiceID:  svc.ID{Name: "foo", UID: "foo"},
+	}, {
+		Type: request.EventTypeGRPC, Path: "foo.bar/Baz", Status: 0,
+		RequestStart: start, Start: start, End: start + int64(3*time.Millisecond),
+		ServiceID: svc.ID{Name: "bar", UID: "bar"},
+	}}
+}
+
+func logAttribute(record *logspb.LogRecord, key attribute.Key) *commonpb.AnyValue {
+	for _, kv := range record.Attributes {
+		if kv.Key == string(key) {
+			return kv.Value
+		}
+	}
+	return nil
+}
+
+type fakeLogsService struct {
+	collogs.UnimplementedLogsServiceServer
+	received chan *collogs.ExportLogsServiceRequest
+	md       metadata.MD
+}
+
+func (f *fakeLogsService) Export(ctx context.Context, req *collogs.ExportLogsServiceRequest) (*collogs.ExportLogsServiceResponse, error) {
+	f.md, _ = metadata.FromIncomingContext(ctx)
+	f.received <- req
+	return &collogs.ExportLogsServiceResponse{}, nil
+}
+
+type fakeInternalLogs struct {
+	imetrics.NoopReporter
+	sum  atomic.Int32
+	errs atomic.Int32
+}
+
+func (f *fakeInternalLogs) OTELLogExport(len int) {
+	f.sum.Add(int32(len))
+}
+
+func (f *fakeInternalLogs) OTELLogExportError(_ error) {
+	f.errs.Add(1)
+}
+
+func (f *fakeInternalLogs) records() int {
+	return int(f.sum.Load())
+}
@@ -18,7 +18,10 @@ import (
 	"go.opentelemetry.io/otel/sdk/instrumentation"
 	"go.opentelemetry.io/otel/sdk/metric"
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	trace2 "go.opentelemetry.io/otel/trace"
 
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/cardinality"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
@@ -30,14 +33,6 @@ func mlog() *slog.Logger {
 }
 
 const (
-	HTTPServerDuration    = "http.server.request.duration"
-	HTTPClientDuration    = "http.client.request.duration"
-	RPCServerDuration     = "rpc.server.duration"
-	RPCClientDuration     = "rpc.client.duration"
-	SQLClientDuration     = "sql.client.duration"
-	HTTPServerRequestSize = "http.server.request.body.size"
-	HTTPClientRequestSize = "http.client.request.body.size"
-
 	UsualPortGRPC = "4317"
 	UsualPortHTTP = "4318"
 
@@ -60,17 +55,36 @@ type MetricsConfig struct {
 	// InsecureSkipVerify is not standard, so we don't follow the same naming convention
 	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"BEYLA_OTEL_INSECURE_SKIP_VERIFY"`
 
+	// Client configuration from the YAML section or the OTEL_EXPORTER_OTLP_METRICS_* variables
+	Client ClientConfig `yaml:",inline" envPrefix:"OTEL_EXPORTER_OTLP_METRICS_"`
+	// CommonClient configuration from the OTEL_EXPORTER_OTLP_* variables. Client values take precedence.
+	CommonClient ClientConfig `yaml:"-" envPrefix:"OTEL_EXPORTER_OTLP_"`
+
+	Retry RetryConfig `yaml:"retry" envPrefix:"BEYLA_OTEL_METRICS_RETRY_"`
+
 	// ReportTarget specifies whether http.target should be submitted as a metric attribute. It is disabled by
 	// default to avoid cardinality explosion in paths with IDs. In that case, it is recommended to group these
 	// requests in the Routes node
 	ReportTarget   bool `yaml:"report_target" env:"BEYLA_METRICS_REPORT_TARGET"`
 	ReportPeerInfo bool `yaml:"report_peer" env:"BEYLA_METRICS_REPORT_PEER"`
 
+	// DisableExemplars avoids attaching the trace_id and span_id of the sampled spans as exemplars
+	// of the duration histograms.
+	DisableExemplars bool `yaml:"disable_exemplars" env:"BEYLA_METRICS_DISABLE_EXEMPLARS"`
+
 	Buckets              Buckets `yaml:"buckets"`
 	HistogramAggregation string  `yaml:"histogram_aggregation" env:"OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION"`
 
 	ReportersCacheLen int `yaml:"reporters_cache_len" env:"BEYLA_METRICS_REPORT_CACHE_LEN"`
 
+	// TTL is the time since the metrics of a service were updated for the last time until
+	// they stop being reported. Zero means that metrics never expire.
+	TTL time.Duration `yaml:"ttl" env:"BEYLA_OTEL_METRICS_TTL"`
+
+	// CardinalityLimits restrict the number of distinct attribute sets that are reported for each
+	// metric and service.
+	CardinalityLimits cardinality.Limits `yaml:"cardinality_limits"`
+
 	// SDKLogLevel works independently from the global LogLevel because it prints GBs of logs in Debug mode
 	// and the Info messages leak internal details that are not usually valuable for the final user.
 	SDKLogLevel string `yaml:"otel_sdk_log_level" env:"BEYLA_OTEL_SDK_LOG_LEVEL"`
@@ -78,6 +92,14 @@ type MetricsConfig struct {
 	// Features of metrics that are can be exported. Accepted values are "application" and "network".
 	Features []string `yaml:"features" env:"BEYLA_OTEL_METRIC_FEATURES" envSeparator:","`
 
+	// AttributeSelection specifies which optional attributes are reported for each metrics section.
+	// It needs to be explicitly set up before building the graph
+	AttributeSelection attributes.Selection `yaml:"-"`
+
+	// SemConv version that defines the names of the metrics and their attributes.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+
 	// Grafana configuration needs to be explicitly set up before building the graph
 	Grafana *GrafanaOTLP `yaml:"-"`
 }
@@ -129,12 +151,28 @@ type MetricsReporter struct {
 	cfg       *MetricsConfig
 	exporter  metric.Exporter
 	reporters ReporterPool[*Metrics]
+
+	// names of the metrics and attributes, according to the semantic conventions version
+	names *attributes.Names
+
+	// optional attributes that are reported for each metrics section
+	attrHTTP       []attributes.Name
+	attrHTTPClient []attributes.Name
+	attrGRPC       []attributes.Name
+	attrGRPCClient []attributes.Name
+	// Kubernetes metadata that is not selected by any metrics section, and must
+	// be removed from the resource attributes
+	excludedMetadata map[string]struct{}
+
+	// limiter is nil if the cardinality limits are disabled
+	limiter *cardinality.Limiter[attribute.Distinct]
 }
 
 // Metrics is a set of metrics associated to a given OTEL MeterProvider.
 // There is a Metrics instance for each service/process instrumented by Beyla.
 type Metrics struct {
 	ctx                   context.Context
+	exemplars             bool
 	provider              *metric.MeterProvider
 	httpDuration          instrument.Float64Histogram
 	httpClientDuration    instrument.Float64Histogram
@@ -161,16 +199,29 @@ func ReportMetrics(
 func newMetricsReporter(ctx context.Context, cfg *MetricsConfig, ctxInfo *global.ContextInfo) (*MetricsReporter, error) {
 	log := mlog()
 	mr := MetricsReporter{
-		ctx: ctx,
-		cfg: cfg,
+		ctx:   ctx,
+		cfg:   cfg,
+		names: cfg.SemConv.Names(),
 	}
-	mr.reporters = NewReporterPool[*Metrics](cfg.ReportersCacheLen,
+	if !cfg.DisableExemplars {
+		enableExemplars()
+	}
+	mr.selectAttributes(ctxInfo)
+	if cfg.CardinalityLimits.Enabled() {
+		mr.limiter = cardinality.NewLimiter[attribute.Distinct](cfg.CardinalityLimits, func(metric string, service *svc.ID) {
+			ctxInfo.Metrics.CardinalityLimitHit(metric, service.String())
+		})
+	}
+	mr.reporters = NewReporterPool[*Metrics](cfg.ReportersCacheLen, cfg.TTL,
 		func(id svc.UID, v *Metrics) {
 			llog := log.With("service", id)
 			llog.Debug("evicting metrics reporter from cache")
+			if mr.limiter != nil {
+				mr.limiter.ForgetService(id)
+			}
 			go func() {
-				if err := v.provider.ForceFlush(ctx); err != nil {
-					llog.Warn("error flushing evicted metrics provider", "error", err)
+				if err := v.provider.Shutdown(ctx); err != nil {
+					llog.Warn("error shutting down evicted metrics provider", "error", err)
 				}
 			}()
 		}, mr.newMetricSet)
@@ -188,20 +239,22 @@ func (mr *MetricsReporter) newMetricSet(service svc.ID) (*Metrics, error) {
 	mlog := mlog().With("service", service)
 	mlog.Debug("creating new Metrics reporter")
 	useExponentialHistograms := isExponentialAggregation(mr.cfg, mlog)
-	resources := Resource(service)
+	names := mr.names
+	resources := Resource(mr.filterMetadata(service))
 	m := Metrics{
-		ctx: mr.ctx,
+		ctx:       mr.ctx,
+		exemplars: !mr.cfg.DisableExemplars,
 		provider: metric.NewMeterProvider(
 			metric.WithResource(resources),
-			metric.WithReader(metric.NewPeriodicReader(mr.exporter,
+			metric.WithReader(metric.NewPeriodicReader(sharedExporter{Exporter: mr.exporter},
 				metric.WithInterval(mr.cfg.Interval))),
-			metric.WithView(otelHistogramConfig(HTTPServerDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(HTTPClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(RPCServerDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(RPCClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(SQLClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(HTTPServerRequestSize, mr.cfg.Buckets.RequestSizeHistogram, useExponentialHistograms)),
-			metric.WithView(otelHistogramConfig(HTTPClientRequestSize, mr.cfg.Buckets.RequestSizeHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.HTTPServerDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.HTTPClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.RPCServerDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.RPCClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.SQLClientDuration, mr.cfg.Buckets.DurationHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.HTTPServerRequestSize, mr.cfg.Buckets.RequestSizeHistogram, useExponentialHistograms)),
+			metric.WithView(otelHistogramConfig(names.HTTPClientRequestSize, mr.cfg.Buckets.RequestSizeHistogram, useExponentialHistograms)),
 		),
 	}
 	// time units for HTTP and GRPC durations are in seconds, according to the OTEL specification:
@@ -209,31 +262,31 @@ func (mr *MetricsReporter) newMetricSet(service svc.ID) (*Metrics, error) {
 	// TODO: set ExplicitBucketBoundaries here and in prometheus from the previous specification
 	var err error
 	meter := m.provider.Meter(reporterName)
-	m.httpDuration, err = meter.Float64Histogram(HTTPServerDuration, instrument.WithUnit("s"))
+	m.httpDuration, err = meter.Float64Histogram(names.HTTPServerDuration, instrument.WithUnit("s"))
 	if err != nil {
 		return nil, fmt.Errorf("creating http duration histogram metric: %w", err)
 	}
-	m.httpClientDuration, err = meter.Float64Histogram(HTTPClientDuration, instrument.WithUnit("s"))
+	m.httpClientDuration, err = meter.Float64Histogram(names.HTTPClientDuration, instrument.WithUnit("s"))
 	if err != nil {
 		return nil, fmt.Errorf("creating http duration histogram metric: %w", err)
 	}
-	m.grpcDuration, err = meter.Float64Histogram(RPCServerDuration, instrument.WithUnit("s"))
+	m.grpcDuration, err = meter.Float64Histogram(names.RPCServerDuration, instrument.WithUnit("s"))
 	if err != nil {
 		return nil, fmt.Errorf("creating grpc duration histogram metric: %w", err)
 	}
-	m.grpcClientDuration, err = meter.Float64Histogram(RPCClientDuration, instrument.WithUnit("s"))
+	m.grpcClientDuration, err = meter.Float64Histogram(names.RPCClientDuration, instrument.WithUnit("s"))
 	if err != nil {
 		return nil, fmt.Errorf("creating grpc duration histogram metric: %w", err)
 	}
-	m.sqlClientDuration, err = meter.Float64Histogram(SQLClientDuration, instrument.WithUnit("s"))
+	m.sqlClientDuration, err = meter.Float64Histogram(names.SQLClientDuration, instrument.WithUnit("s"))
 	if err != nil {
 		return nil, fmt.Errorf("creating sql client duration histogram metric: %w", err)
 	}
-	m.httpRequestSize, err = meter.Float64Histogram(HTTPServerRequestSize, instrument.WithUnit("By"))
+	m.httpRequestSize, err = meter.Float64Histogram(names.HTTPServerRequestSize, instrument.WithUnit("By"))
 	if err != nil {
 		return nil, fmt.Errorf("creating http size histogram metric: %w", err)
 	}
-	m.httpClientRequestSize, err = meter.Float64Histogram(HTTPClientRequestSize, instrument.WithUnit("By"))
+	m.httpClientRequestSize, err = meter.Float64Histogram(names.HTTPClientRequestSize, instrument.WithUnit("By"))
 	if err != nil {
 		return nil, fmt.Errorf("creating http size histogram metric: %w", err)
 	}
@@ -349,55 +402,116 @@ func otelHistogramConfig(metricName string, buckets []float64, useExponentialHis
 
 }
 
-func (mr *MetricsReporter) grpcAttributes(span *request.Span) []attribute.KeyValue {
-	attrs := []attribute.KeyValue{
-		semconv.RPCMethod(span.Path),
-		semconv.RPCSystemGRPC,
-		semconv.RPCGRPCStatusCodeKey.Int(span.Status),
+// selectAttributes stores the optional attributes that are reported for each metrics section
+func (mr *MetricsReporter) selectAttributes(ctxInfo *global.ContextInfo) {
+	// The route attribute is reported by default, as it is only set
+	// by the routes decorator. The Kubernetes metadata is reported as part of the
+	// resource attributes, so they are not added to the metric attributes.
+	defaults := []attributes.Name{attributes.HTTPRoute}
+	if mr.cfg.ReportTarget {
+		defaults = append(defaults, attributes.URLPath)
 	}
 	if mr.cfg.ReportPeerInfo {
-		if span.Type == request.EventTypeGRPC {
-			attrs = append(attrs, ClientAddr(span.Peer))
-		} else {
-			attrs = append(attrs, ServerAddr(span.Peer))
-		}
+		defaults = append(defaults, attributes.ClientAddr, attributes.ServerAddr, attributes.ServerPort)
 	}
+	sel := mr.cfg.AttributeSelection
+	mr.attrHTTP = sel.For(attributes.SectionHTTPServer, defaults)
+	mr.attrHTTPClient = sel.For(attributes.SectionHTTPClient, defaults)
+	mr.attrGRPC = sel.For(attributes.SectionRPCServer, defaults)
+	mr.attrGRPCClient = sel.For(attributes.SectionRPCClient, defaults)
 
-	return attrs
-}
-
-func (mr *MetricsReporter) httpServerAttributes(span *request.Span) []attribute.KeyValue {
-	attrs := []attribute.KeyValue{
-		HTTPRequestMethod(span.Method),
-		HTTPResponseStatusCode(span.Status),
+	if !ctxInfo.K8sEnabled {
+		return
 	}
-	if mr.cfg.ReportTarget {
-		attrs = append(attrs, HTTPUrlPath(span.Path))
+	// As the Kubernetes metadata is shared by all the metrics of a service, a Kubernetes
+	// attribute is only removed if it is not selected by any metrics section
+	mr.excludedMetadata = map[string]struct{}{}
+	for _, name := range attributes.K8sNames {
+		mr.excludedMetadata[name.OTEL()] = struct{}{}
+	}
+	for _, section := range []attributes.Section{
+		attributes.SectionHTTPServer, attributes.SectionHTTPClient, attributes.SectionRPCServer,
+		attributes.SectionRPCClient, attributes.SectionSQLClient,
+	} {
+		for _, name := range sel.For(section, attributes.K8sNames) {
+			delete(mr.excludedMetadata, name.OTEL())
+		}
 	}
-	if mr.cfg.ReportPeerInfo {
-		attrs = append(attrs, ClientAddr(span.Peer))
+}
+
+// filterMetadata returns a copy of the service ID without the Kubernetes metadata that
+// has been excluded by the user
+func (mr *MetricsReporter) filterMetadata(service svc.ID) svc.ID {
+	if len(mr.excludedMetadata) == 0 {
+		return service
 	}
-	if span.Route != "" {
-		attrs = append(attrs, semconv.HTTPRoute(span.Route))
+	metadata := make(map[string]string, len(service.Metadata))
+	for k, v := range service.Metadata {
+		if _, ok := mr.excludedMetadata[k]; !ok {
+			metadata[k] = v
+		}
 	}
+	service.Metadata = metadata
+	return service
+}
 
+// optionalAttributes appends to the attrs slice the optional attributes that have been selected for
+// a given metrics section
+func (mr *MetricsReporter) optionalAttributes(attrs []attribute.KeyValue, span *request.Span, optional []attributes.Name) []attribute.KeyValue {
+	for _, name := range optional {
+		switch name {
+		case attributes.URLPath:
+			attrs = append(attrs, HTTPUrlPath(mr.names, span.Path))
+		case attributes.ClientAddr:
+			attrs = append(attrs, ClientAddr(mr.names, span.Peer))
+		case attributes.ServerAddr:
+			attrs = append(attrs, ServerAddr(mr.names, MetricServerAddr(span)))
+		case attributes.ServerPort:
+			attrs = append(attrs, ServerPort(mr.names, span.HostPort))
+		case attributes.HTTPRoute:
+			if span.Route != "" {
+				attrs = append(attrs, semconv.HTTPRoute(span.Route))
+			}
+		}
+	}
 	return attrs
 }
 
-func (mr *MetricsReporter) httpClientAttributes(span *request.Span) []attribute.KeyValue {
+// MetricServerAddr returns the server address that is reported in the metrics of the span.
+// The metrics of the gRPC client spans report the peer address, without port.
+func MetricServerAddr(span *request.Span) string {
+	if span.Type == request.EventTypeGRPCClient {
+		return span.Peer
+	}
+	return span.Host
+}
+
+func (mr *MetricsReporter) grpcAttributes(span *request.Span) []attribute.KeyValue {
 	attrs := []attribute.KeyValue{
-		HTTPRequestMethod(span.Method),
-		HTTPResponseStatusCode(span.Status),
+		semconv.RPCMethod(span.Path),
+		semconv.RPCSystemGRPC,
+		semconv.RPCGRPCStatusCodeKey.Int(span.Status),
 	}
-	if mr.cfg.ReportPeerInfo {
-		attrs = append(attrs, ServerAddr(span.Host))
-		attrs = append(attrs, ServerPort(span.HostPort))
+	if span.Type == request.EventTypeGRPC {
+		return mr.optionalAttributes(attrs, span, mr.attrGRPC)
 	}
-	if span.Route != "" {
-		attrs = append(attrs, semconv.HTTPRoute(span.Route))
+	return mr.optionalAttributes(attrs, span, mr.attrGRPCClient)
+}
+
+func (mr *MetricsReporter) httpServerAttributes(span *request.Span) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		HTTPRequestMethod(mr.names, span.Method),
+		HTTPResponseStatusCode(mr.names, span.Status),
 	}
+	return mr.optionalAttributes(attrs, span, mr.attrHTTP)
+}
 
-	return attrs
+func (mr *MetricsReporter) httpClientAttributes(span *request.Span) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		HTTPRequestMethod(mr.names, span.Method),
+		HTTPResponseStatusCode(mr.names, span.Status),
+	}
+	return mr.optionalAttributes(attrs, span, mr.attrHTTPClient)
 }
 
 func (mr *MetricsReporter) metricAttributes(span *request.Span) attribute.Set {
@@ -423,31 +537,85 @@ func (mr *MetricsReporter) metricAttributes(span *request.Span) attribute.Set {
 	return attribute.NewSet(attrs...)
 }
 
+// limitCardinality returns the passed attributes set if it can be recorded according to the
+// cardinality limits. Otherwise, it returns the attributes of the overflow series of the service.
+// The request body size metrics share the attributes with their respective duration metrics,
+// so they are limited together.
+func (mr *MetricsReporter) limitCardinality(span *request.Span, attrs attribute.Set) attribute.Set {
+	if mr.limiter == nil || mr.limiter.Allow(mr.durationMetricName(span), &span.ServiceID, attrs.Equivalent()) {
+		return attrs
+	}
+	// The Kubernetes metadata is part of the resource, so only the service name
+	// needs to be kept to identify the service
+	overflow := make([]attribute.KeyValue, 0, attrs.Len())
+	for iter := attrs.Iter(); iter.Next(); {
+		kv := iter.Attribute()
+		if kv.Key != semconv.ServiceNameKey {
+			kv = kv.Key.String(cardinality.OverflowValue)
+		}
+		overflow = append(overflow, kv)
+	}
+	return attribute.NewSet(overflow...)
+}
+
+func (mr *MetricsReporter) durationMetricName(span *request.Span) string {
+	switch span.Type {
+	case request.EventTypeHTTP:
+		return mr.names.HTTPServerDuration
+	case request.EventTypeHTTPClient:
+		return mr.names.HTTPClientDuration
+	case request.EventTypeGRPC:
+		return mr.names.RPCServerDuration
+	case request.EventTypeGRPCClient:
+		return mr.names.RPCClientDuration
+	case request.EventTypeSQLClient:
+		return mr.names.SQLClientDuration
+	}
+	return ""
+}
+
 func (r *Metrics) record(span *request.Span, attrs attribute.Set) {
 	t := span.Timings()
 	duration := t.End.Sub(t.RequestStart).Seconds()
 	attrOpt := instrument.WithAttributeSet(attrs)
+	durationCtx := r.durationContext(span)
 	switch span.Type {
 	case request.EventTypeHTTP:
 		// TODO: for more accuracy, there must be a way to set the metric time from the actual span end time
-		r.httpDuration.Record(r.ctx, duration, attrOpt)
+		r.httpDuration.Record(durationCtx, duration, attrOpt)
 		r.httpRequestSize.Record(r.ctx, float64(span.ContentLength), attrOpt)
 	case request.EventTypeGRPC:
-		r.grpcDuration.Record(r.ctx, duration, attrOpt)
+		r.grpcDuration.Record(durationCtx, duration, attrOpt)
 	case request.EventTypeGRPCClient:
-		r.grpcClientDuration.Record(r.ctx, duration, attrOpt)
+		r.grpcClientDuration.Record(durationCtx, duration, attrOpt)
 	case request.EventTypeHTTPClient:
-		r.httpClientDuration.Record(r.ctx, duration, attrOpt)
+		r.httpClientDuration.Record(durationCtx, duration, attrOpt)
 		r.httpClientRequestSize.Record(r.ctx, float64(span.ContentLength), attrOpt)
 	case request.EventTypeSQLClient:
-		r.sqlClientDuration.Record(r.ctx, duration, attrOpt)
+		r.sqlClientDuration.Record(durationCtx, duration, attrOpt)
 	}
 }
 
+// durationContext returns the context to record the duration of the span. If exemplars are enabled
+// and the span belongs to a sampled trace, the returned context carries the span context, so the
+// OTEL SDK attaches the trace and span IDs as an exemplar of the recorded measurement.
+func (r *Metrics) durationContext(span *request.Span) context.Context {
+	if !r.exemplars || !span.Sampled() {
+		return r.ctx
+	}
+	return trace2.ContextWithSpanContext(r.ctx, trace2.NewSpanContext(trace2.SpanContextConfig{
+		TraceID:    span.TraceID,
+		SpanID:     span.SpanID,
+		TraceFlags: trace2.TraceFlags(span.Flags),
+	}))
+}
+
 func (mr *MetricsReporter) reportMetrics(input <-chan []request.Span) {
-	var lastSvcUID svc.UID
-	var reporter *Metrics
 	for spans := range input {
+		// the reporter is queried again for each batch of spans, so the cache keeps
+		// track of the last access of each service, to expire its metrics
+		var lastSvcUID svc.UID
+		var reporter *Metrics
 		for i := range spans {
 			s := &spans[i]
 
@@ -473,12 +641,23 @@ func (mr *MetricsReporter) reportMetrics(input <-chan []request.Span) {
 				lastSvcUID = s.ServiceID.UID
 				reporter = lm
 			}
-			reporter.record(s, mr.metricAttributes(s))
+			reporter.record(s, mr.limitCardinality(s, mr.metricAttributes(s)))
 		}
 	}
 	mr.close()
 }
 
+// sharedExporter wraps the metrics exporter that is shared by the metrics providers of
+// all the services, so shutting down the provider of an evicted service does not shut
+// down the exporter.
+type sharedExporter struct {
+	metric.Exporter
+}
+
+func (sharedExporter) Shutdown(_ context.Context) error {
+	return nil
+}
+
 func getHTTPMetricEndpointOptions(cfg *MetricsConfig) (otlpOptions, error) {
 	opts := otlpOptions{}
 	log := mlog().With("transport", "http")
@@ -514,6 +693,11 @@ func getHTTPMetricEndpointOptions(cfg *MetricsConfig) (otlpOptions, error) {
 
 	cfg.Grafana.setupOptions(&opts)
 
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
+
 	return opts, nil
 }
 
@@ -537,6 +721,11 @@ func getGRPCMetricEndpointOptions(cfg *MetricsConfig) (otlpOptions, error) {
 		log.Debug("Setting InsecureSkipVerify")
 		opts.SkipTLSVerify = true
 	}
+
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
 	return opts, nil
 }
 
@@ -590,3 +779,15 @@ func setMetricsProtocol(cfg *MetricsConfig) {
 	// unset. Guessing it
 	os.Setenv(envMetricsProtocol, string(cfg.GuessProtocol()))
 }
+
+// HACK: at the time of writing this, the exemplars support in the OTEL metrics SDK is experimental and
+// can only be enabled through the OTEL_GO_X_EXEMPLAR environment variable. If the user didn't explicitly
+// set it, we enable it. The default "trace_based" exemplar filter will only record exemplars
+// for the measurements whose context carries a sampled span.
+// TODO: remove this once the exemplars are stable in the OTEL metrics SDK
+func enableExemplars() {
+	if _, ok := os.LookupEnv(envExemplars); ok {
+		return
+	}
+	os.Setenv(envExemplars, "true")
+}
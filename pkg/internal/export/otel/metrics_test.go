@@ -13,10 +13,15 @@ import (
 	"github.com/mariomac/pipes/pkg/node"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	trace2 "go.opentelemetry.io/otel/trace"
 
+	"github.com/grafana/beyla/pkg/internal/export/cardinality"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 const timeout = 5 * time.Second
@@ -338,6 +343,123 @@ func TestMetricsConfig_Disabled(t *testing.T) {
 	assert.False(t, MetricsConfig{Grafana: &GrafanaOTLP{Submit: []string{"traces", "metrics"}, InstanceID: "33221"}}.Enabled())
 }
 
+func TestMetrics_ExemplarsContext(t *testing.T) {
+	traceID, _ := trace2.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace2.SpanIDFromHex("0102030405060708")
+	sampled := request.Span{TraceID: traceID, SpanID: spanID, Flags: 1}
+	notSampled := request.Span{TraceID: traceID, SpanID: spanID, Flags: 0}
+
+	t.Run("exemplars enabled", func(t *testing.T) {
+		m := Metrics{ctx: context.Background(), exemplars: true}
+		sc := trace2.SpanContextFromContext(m.durationContext(&sampled))
+		assert.True(t, sc.IsSampled())
+		assert.Equal(t, traceID, sc.TraceID())
+		assert.Equal(t, spanID, sc.SpanID())
+
+		assert.False(t, trace2.SpanContextFromContext(m.durationContext(&notSampled)).IsValid())
+		assert.False(t, trace2.SpanContextFromContext(m.durationContext(&request.Span{Flags: 1})).IsValid())
+	})
+	t.Run("exemplars disabled", func(t *testing.T) {
+		m := Metrics{ctx: context.Background(), exemplars: false}
+		assert.False(t, trace2.SpanContextFromContext(m.durationContext(&sampled)).IsValid())
+	})
+}
+
+func TestMetrics_EnableExemplars(t *testing.T) {
+	t.Run("enabled by default", func(t *testing.T) {
+		defer restoreEnvAfterExecution()()
+		require.NoError(t, os.Unsetenv(envExemplars))
+		_, err := newMetricsReporter(context.Background(),
+			&MetricsConfig{CommonEndpoint: "http://host:3333", ReportersCacheLen: 16},
+			&global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+		require.NoError(t, err)
+		assert.Equal(t, "true", os.Getenv(envExemplars))
+	})
+	t.Run("do not override user-provided value", func(t *testing.T) {
+		defer restoreEnvAfterExecution()()
+		require.NoError(t, os.Setenv(envExemplars, "false"))
+		_, err := newMetricsReporter(context.Background(),
+			&MetricsConfig{CommonEndpoint: "http://host:3333", ReportersCacheLen: 16},
+			&global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+		require.NoError(t, err)
+		assert.Equal(t, "false", os.Getenv(envExemplars))
+	})
+	t.Run("disabled exemplars", func(t *testing.T) {
+		defer restoreEnvAfterExecution()()
+		require.NoError(t, os.Unsetenv(envExemplars))
+		_, err := newMetricsReporter(context.Background(),
+			&MetricsConfig{CommonEndpoint: "http://host:3333", ReportersCacheLen: 16, DisableExemplars: true},
+			&global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+		require.NoError(t, err)
+		_, ok := os.LookupEnv(envExemplars)
+		assert.False(t, ok)
+	})
+}
+
+func TestMetrics_CardinalityLimits(t *testing.T) {
+	hits := &limitHitsMetrics{hits: map[string]int{}}
+	mr, err := newMetricsReporter(context.Background(),
+		&MetricsConfig{
+			CommonEndpoint: "http://host:3333", ReportersCacheLen: 16, DisableExemplars: true,
+			CardinalityLimits: cardinality.Limits{MaxSeriesPerMetric: 2},
+		},
+		&global.ContextInfo{Metrics: hits})
+	require.NoError(t, err)
+	mr.selectAttributes(&global.ContextInfo{})
+
+	span := func(route string) *request.Span {
+		return &request.Span{
+			Type: request.EventTypeHTTP, Method: "GET", Status: 200, Route: route,
+			ServiceID: svc.ID{UID: "foo", Name: "foo", Namespace: "ns"},
+		}
+	}
+	record := func(route string) attribute.Set {
+		s := span(route)
+		return mr.limitCardinality(s, mr.metricAttributes(s))
+	}
+	assert.Equal(t, mr.metricAttributes(span("/a")), record("/a"))
+	assert.Equal(t, mr.metricAttributes(span("/b")), record("/b"))
+	assert.Equal(t, mr.metricAttributes(span("/a")), record("/a"))
+
+	overflow := attribute.NewSet(
+		attribute.String("http.request.method", cardinality.OverflowValue),
+		attribute.String("http.response.status_code", cardinality.OverflowValue),
+		semconv.HTTPRouteKey.String(cardinality.OverflowValue),
+		semconv.ServiceName("foo"),
+	)
+	assert.Equal(t, overflow, record("/c"))
+	assert.Equal(t, overflow, record("/d"))
+
+	assert.Equal(t, map[string]int{"http.server.request.duration/ns/foo": 2}, hits.hits)
+}
+
+func TestMetrics_GRPCClientServerAddr(t *testing.T) {
+	mr, err := newMetricsReporter(context.Background(),
+		&MetricsConfig{
+			CommonEndpoint: "http://host:3333", ReportersCacheLen: 16, ReportPeerInfo: true,
+		},
+		&global.ContextInfo{})
+	require.NoError(t, err)
+	mr.selectAttributes(&global.ContextInfo{})
+
+	attrs := mr.metricAttributes(&request.Span{
+		Type: request.EventTypeGRPCClient, Path: "/foo.Bar/Baz",
+		Peer: "2.2.2.2", Host: "server.local", HostPort: 8080,
+	})
+	addr, ok := attrs.Value("server.address")
+	require.True(t, ok)
+	assert.Equal(t, "2.2.2.2", addr.AsString())
+}
+
+type limitHitsMetrics struct {
+	imetrics.NoopReporter
+	hits map[string]int
+}
+
+func (l *limitHitsMetrics) CardinalityLimitHit(metric, service string) {
+	l.hits[metric+"/"+service]++
+}
+
 func (f *fakeInternalMetrics) OTELMetricExport(len int) {
 	f.cnt.Add(1)
 	f.sum.Add(int32(len))
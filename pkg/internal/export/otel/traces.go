@@ -19,6 +19,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
 	trace2 "go.opentelemetry.io/otel/trace"
 
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
@@ -41,6 +42,13 @@ type TracesConfig struct {
 	// InsecureSkipVerify is not standard, so we don't follow the same naming convention
 	InsecureSkipVerify bool `yaml:"insecure_skip_verify" env:"BEYLA_OTEL_INSECURE_SKIP_VERIFY"`
 
+	// Client configuration from the YAML section or the OTEL_EXPORTER_OTLP_TRACES_* variables
+	Client ClientConfig `yaml:",inline" envPrefix:"OTEL_EXPORTER_OTLP_TRACES_"`
+	// CommonClient configuration from the OTEL_EXPORTER_OTLP_* variables. Client values take precedence.
+	CommonClient ClientConfig `yaml:"-" envPrefix:"OTEL_EXPORTER_OTLP_"`
+
+	Retry RetryConfig `yaml:"retry" envPrefix:"BEYLA_OTEL_TRACES_RETRY_"`
+
 	Sampler Sampler `yaml:"sampler"`
 
 	// Configuration options below this line will remain undocumented at the moment,
@@ -58,6 +66,10 @@ type TracesConfig struct {
 
 	// Grafana configuration needs to be explicitly set up before building the graph
 	Grafana *GrafanaOTLP `yaml:"-"`
+
+	// SemConv version that defines the names of the span attributes.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
 }
 
 // Enabled specifies that the OTEL traces node is enabled if and only if
@@ -125,17 +137,6 @@ func ReportTraces(ctx context.Context, cfg *TracesConfig, ctxInfo *global.Contex
 
 func newTracesReporter(ctx context.Context, cfg *TracesConfig, ctxInfo *global.ContextInfo) (*TracesReporter, error) {
 	log := tlog()
-	r := TracesReporter{ctx: ctx, cfg: cfg}
-	r.reporters = NewReporterPool[*Tracers](cfg.ReportersCacheLen,
-		func(k svc.UID, v *Tracers) {
-			llog := log.With("service", k)
-			llog.Debug("evicting traces reporter from cache")
-			go func() {
-				if err := v.provider.ForceFlush(v.ctx); err != nil {
-					llog.Warn("error flushing evicted traces provider", "error", err)
-				}
-			}()
-		}, r.newTracers)
 	// Instantiate the OTLP HTTP or GRPC traceExporter
 	var err error
 	var exporter trace.SpanExporter
@@ -155,7 +156,33 @@ func newTracesReporter(ctx context.Context, cfg *TracesConfig, ctxInfo *global.C
 			proto, ProtocolGRPC, ProtocolHTTPJSON, ProtocolHTTPProtobuf)
 	}
 
-	r.traceExporter = instrumentTraceExporter(exporter, ctxInfo.Metrics)
+	return newExporterTracesReporter(ctx, cfg, instrumentTraceExporter(exporter, ctxInfo.Metrics)), nil
+}
+
+// ReportTracesTo returns a traces reporter node that builds the spans exactly as the OTEL traces
+// reporter, but submits them through the provided exporter. It allows reusing the span-building
+// logic for exporters in other formats. Only the sampling, batching and cache options of the
+// passed configuration are considered.
+func ReportTracesTo(ctx context.Context, cfg *TracesConfig, exporter trace.SpanExporter) node.TerminalFunc[[]request.Span] {
+	SetupInternalOTELSDKLogger(cfg.SDKLogLevel)
+	return newExporterTracesReporter(ctx, cfg, exporter).reportTraces
+}
+
+func newExporterTracesReporter(ctx context.Context, cfg *TracesConfig, exporter trace.SpanExporter) *TracesReporter {
+	log := tlog()
+	r := TracesReporter{ctx: ctx, cfg: cfg, traceExporter: exporter}
+	// traces reporters never expire: all of them share the same span processor, which
+	// would be stopped if the provider of an expired reporter was shut down
+	r.reporters = NewReporterPool[*Tracers](cfg.ReportersCacheLen, 0,
+		func(k svc.UID, v *Tracers) {
+			llog := log.With("service", k)
+			llog.Debug("evicting traces reporter from cache")
+			go func() {
+				if err := v.provider.ForceFlush(v.ctx); err != nil {
+					llog.Warn("error flushing evicted traces provider", "error", err)
+				}
+			}()
+		}, r.newTracers)
 
 	var opts []trace.BatchSpanProcessorOption
 	if cfg.MaxExportBatchSize > 0 {
@@ -172,7 +199,7 @@ func newTracesReporter(ctx context.Context, cfg *TracesConfig, ctxInfo *global.C
 	}
 
 	r.bsp = trace.NewBatchSpanProcessor(r.traceExporter, opts...)
-	return &r, nil
+	return &r
 }
 
 func httpTracer(ctx context.Context, cfg *TracesConfig) (*otlptrace.Exporter, error) {
@@ -220,7 +247,7 @@ func (r *TracesReporter) close() {
 		v, _ := r.reporters.pool.Get(key)
 		plog := log.With("serviceName", key)
 		plog.Debug("shutting down traces provider")
-		if err := v.provider.Shutdown(r.ctx); err != nil {
+		if err := v.value.provider.Shutdown(r.ctx); err != nil {
 			log.Error("closing traces provider", err)
 		}
 	}
@@ -282,19 +309,21 @@ func SpanStatusCode(span *request.Span) codes.Code {
 	return codes.Unset
 }
 
-func TraceAttributes(span *request.Span) []attribute.KeyValue {
+// TraceAttributes returns the attributes of the span, named according to the passed
+// semantic conventions naming table.
+func TraceAttributes(span *request.Span, names *attributes.Names) []attribute.KeyValue {
 	var attrs []attribute.KeyValue
 
 	switch span.Type {
 	case request.EventTypeHTTP:
 		attrs = []attribute.KeyValue{
-			HTTPRequestMethod(span.Method),
-			HTTPResponseStatusCode(span.Status),
-			HTTPUrlPath(span.Path),
-			ClientAddr(span.Peer),
-			ServerAddr(span.Host),
-			ServerPort(span.HostPort),
-			HTTPRequestBodySize(int(span.ContentLength)),
+			HTTPRequestMethod(names, span.Method),
+			HTTPResponseStatusCode(names, span.Status),
+			HTTPUrlPath(names, span.Path),
+			ClientAddr(names, span.Peer),
+			HostAddr(names, span.Host),
+			HostPort(names, span.HostPort),
+			HTTPRequestBodySize(names, int(span.ContentLength)),
 		}
 		if span.Route != "" {
 			attrs = append(attrs, semconv.HTTPRoute(span.Route))
@@ -304,26 +333,26 @@ func TraceAttributes(span *request.Span) []attribute.KeyValue {
 			semconv.RPCMethod(span.Path),
 			semconv.RPCSystemGRPC,
 			semconv.RPCGRPCStatusCodeKey.Int(span.Status),
-			ClientAddr(span.Peer),
-			ServerAddr(span.Host),
-			ServerPort(span.HostPort),
+			ClientAddr(names, span.Peer),
+			HostAddr(names, span.Host),
+			HostPort(names, span.HostPort),
 		}
 	case request.EventTypeHTTPClient:
 		attrs = []attribute.KeyValue{
-			HTTPRequestMethod(span.Method),
-			HTTPResponseStatusCode(span.Status),
-			HTTPUrlFull(span.Path),
-			ServerAddr(span.Host),
-			ServerPort(span.HostPort),
-			HTTPRequestBodySize(int(span.ContentLength)),
+			HTTPRequestMethod(names, span.Method),
+			HTTPResponseStatusCode(names, span.Status),
+			HTTPUrlFull(names, span.Path),
+			ServerAddr(names, span.Host),
+			ServerPort(names, span.HostPort),
+			HTTPRequestBodySize(names, int(span.ContentLength)),
 		}
 	case request.EventTypeGRPCClient:
 		attrs = []attribute.KeyValue{
 			semconv.RPCMethod(span.Path),
 			semconv.RPCSystemGRPC,
 			semconv.RPCGRPCStatusCodeKey.Int(span.Status),
-			ServerAddr(span.Host),
-			ServerPort(span.HostPort),
+			ServerAddr(names, span.Host),
+			ServerPort(names, span.HostPort),
 		}
 	case request.EventTypeSQLClient:
 		operation := span.Method
@@ -413,7 +442,7 @@ func (r *TracesReporter) makeSpan(parentCtx context.Context, tracer trace2.Trace
 	ctx, sp := tracer.Start(parentCtx, TraceName(span),
 		trace2.WithTimestamp(realStart),
 		trace2.WithSpanKind(SpanKind(span)),
-		trace2.WithAttributes(TraceAttributes(span)...),
+		trace2.WithAttributes(TraceAttributes(span, r.cfg.SemConv.Names())...),
 	)
 
 	sp.SetStatus(SpanStatusCode(span), "")
@@ -454,7 +483,13 @@ func (r *TracesReporter) reportTraces(input <-chan []request.Span) {
 				continue
 			}
 
-			// small optimization: read explanation in MetricsReporter.reportMetrics
+			// optimization: do not query the resources' cache if the
+			// previously processed span belongs to the same service name
+			// as the current.
+			// This will save querying OTEL resource reporters when there is
+			// only a single instrumented process.
+			// In multi-process tracing, this is likely to happen as most
+			// tracers group traces belonging to the same service in the same slice.
 			if span.ServiceID.UID != lastSvcUID || reporter == nil {
 				lm, err := r.reporters.For(span.ServiceID)
 				if err != nil {
@@ -550,6 +585,11 @@ func getHTTPTracesEndpointOptions(cfg *TracesConfig) (otlpOptions, error) {
 
 	cfg.Grafana.setupOptions(&opts)
 
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
+
 	return opts, nil
 }
 
@@ -574,6 +614,10 @@ func getGRPCTracesEndpointOptions(cfg *TracesConfig) (otlpOptions, error) {
 		opts.SkipTLSVerify = true
 	}
 
+	client := cfg.Client.withDefaults(&cfg.CommonClient)
+	if err := opts.setupClient(&client, &cfg.Retry); err != nil {
+		return opts, err
+	}
 	return opts, nil
 }
 
@@ -20,6 +20,7 @@ import (
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
 	"go.opentelemetry.io/otel/trace"
 
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
@@ -503,7 +504,7 @@ func restoreEnvAfterExecution() func() {
 		val    string
 		exists bool
 	}{
-		{name: envTracesProtocol}, {name: envMetricsProtocol}, {name: envProtocol},
+		{name: envTracesProtocol}, {name: envMetricsProtocol}, {name: envProtocol}, {name: envExemplars},
 	}
 	for _, v := range vals {
 		v.val, v.exists = os.LookupEnv(v.name)
@@ -519,6 +520,53 @@ func restoreEnvAfterExecution() func() {
 	}
 }
 
+func TestTraceAttributes_SemConv(t *testing.T) {
+	server := &request.Span{
+		Type: request.EventTypeHTTP, Method: "GET", Status: 200, Path: "/foo",
+		Peer: "1.1.1.1", Host: "2.2.2.2", HostPort: 8080, ContentLength: 10,
+	}
+	client := &request.Span{
+		Type: request.EventTypeHTTPClient, Method: "POST", Status: 201, Path: "http://bar/baz",
+		Host: "bar", HostPort: 80, ContentLength: 20,
+	}
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.request.method", "GET"),
+		attribute.Int("http.response.status_code", 200),
+		attribute.String("url.path", "/foo"),
+		attribute.String("client.address", "1.1.1.1"),
+		attribute.String("server.address", "2.2.2.2"),
+		attribute.Int("server.port", 8080),
+		attribute.Int("http.request.body.size", 10),
+	}, TraceAttributes(server, attributes.SemConv123.Names()))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+		attribute.Int("http.status_code", 200),
+		attribute.String("http.target", "/foo"),
+		attribute.String("net.sock.peer.addr", "1.1.1.1"),
+		attribute.String("net.host.name", "2.2.2.2"),
+		attribute.Int("net.host.port", 8080),
+		attribute.Int("http.request_content_length", 10),
+	}, TraceAttributes(server, attributes.SemConv119.Names()))
+
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.request.method", "POST"),
+		attribute.Int("http.response.status_code", 201),
+		attribute.String("url.full", "http://bar/baz"),
+		attribute.String("server.address", "bar"),
+		attribute.Int("server.port", 80),
+		attribute.Int("http.request.body.size", 20),
+	}, TraceAttributes(client, attributes.SemConv123.Names()))
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("http.method", "POST"),
+		attribute.Int("http.status_code", 201),
+		attribute.String("http.url", "http://bar/baz"),
+		attribute.String("net.peer.name", "bar"),
+		attribute.Int("net.peer.port", 80),
+		attribute.Int("http.request_content_length", 20),
+	}, TraceAttributes(client, attributes.SemConv119.Names()))
+}
+
 func TestTraces_HTTPStatus(t *testing.T) {
 	type testPair struct {
 		httpCode   int
@@ -0,0 +1,103 @@
+package prom
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var timeNow = time.Now
+
+// Expirer wraps a Prometheus metric vector and drops the series that haven't been
+// updated for a given time-to-live. Expired series are removed when the metric
+// is collected, so the Expirer must be registered instead of the wrapped vector.
+type Expirer[T prometheus.Metric] struct {
+	mt       sync.Mutex
+	wrapped  *prometheus.MetricVec
+	ttl      time.Duration
+	entries  map[string]*expirable
+	onExpire func(key string)
+}
+
+type expirable struct {
+	labelValues []string
+	lastAccess  time.Time
+}
+
+// NewExpirer wraps the passed metric vector. A zero ttl disables the expiration of
+// series. The onExpire function, if not nil, is invoked for each expired series,
+// passing the same key as returned by seriesKey.
+func NewExpirer[T prometheus.Metric](wrapped *prometheus.MetricVec, ttl time.Duration, onExpire func(key string)) *Expirer[T] {
+	return &Expirer[T]{
+		wrapped:  wrapped,
+		ttl:      ttl,
+		entries:  map[string]*expirable{},
+		onExpire: onExpire,
+	}
+}
+
+// WithLabelValues returns the series for the given label values, as the
+// WithLabelValues method of the wrapped vector, and updates its last access time.
+func (ex *Expirer[T]) WithLabelValues(lv ...string) T {
+	if ex.ttl <= 0 {
+		return ex.metric(lv)
+	}
+	// locking the whole operation avoids returning a series that is
+	// concurrently removed during the collection
+	ex.mt.Lock()
+	defer ex.mt.Unlock()
+	m := ex.metric(lv)
+	key := seriesKey(lv)
+	now := timeNow()
+	if e, ok := ex.entries[key]; ok {
+		e.lastAccess = now
+	} else {
+		ex.entries[key] = &expirable{labelValues: lv, lastAccess: now}
+	}
+	return m
+}
+
+func (ex *Expirer[T]) metric(lv []string) T {
+	m, err := ex.wrapped.GetMetricWithLabelValues(lv...)
+	if err != nil {
+		panic(err)
+	}
+	return m.(T)
+}
+
+// Describe wraps the prometheus.Collector Describe method
+func (ex *Expirer[T]) Describe(ch chan<- *prometheus.Desc) {
+	ex.wrapped.Describe(ch)
+}
+
+// Collect wraps the prometheus.Collector Collect method, removing before the
+// series that have expired
+func (ex *Expirer[T]) Collect(ch chan<- prometheus.Metric) {
+	ex.removeOutdated()
+	ex.wrapped.Collect(ch)
+}
+
+func (ex *Expirer[T]) removeOutdated() {
+	if ex.ttl <= 0 {
+		return
+	}
+	deadline := timeNow().Add(-ex.ttl)
+	ex.mt.Lock()
+	defer ex.mt.Unlock()
+	for key, e := range ex.entries {
+		if e.lastAccess.Before(deadline) {
+			ex.wrapped.DeleteLabelValues(e.labelValues...)
+			delete(ex.entries, key)
+			if ex.onExpire != nil {
+				ex.onExpire(key)
+			}
+		}
+	}
+}
+
+// seriesKey returns a string that uniquely identifies the label values of a series
+func seriesKey(lv []string) string {
+	return strings.Join(lv, labelValuesSeparator)
+}
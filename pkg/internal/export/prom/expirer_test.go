@@ -0,0 +1,67 @@
+package prom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpirer(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var expired []string
+	ex := NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_histogram",
+	}, []string{"svc", "route"}).MetricVec, time.Minute, func(key string) {
+		expired = append(expired, key)
+	})
+
+	ex.WithLabelValues("foo", "/a").Observe(1)
+	ex.WithLabelValues("foo", "/b").Observe(1)
+	assert.Equal(t, 2, countSeries(ex))
+
+	// updating a series avoids its expiration
+	now = now.Add(40 * time.Second)
+	ex.WithLabelValues("foo", "/a").Observe(1)
+	assert.Equal(t, 2, countSeries(ex))
+	assert.Empty(t, expired)
+
+	now = now.Add(40 * time.Second)
+	assert.Equal(t, 1, countSeries(ex))
+	assert.Equal(t, []string{seriesKey([]string{"foo", "/b"})}, expired)
+
+	// expired series are created again if they are updated
+	ex.WithLabelValues("foo", "/b").Observe(1)
+	assert.Equal(t, 2, countSeries(ex))
+}
+
+func TestExpirer_NoTTL(t *testing.T) {
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	ex := NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "test_histogram",
+	}, []string{"svc"}).MetricVec, 0, nil)
+
+	ex.WithLabelValues("foo").Observe(1)
+	now = now.Add(time.Hour)
+	assert.Equal(t, 1, countSeries(ex))
+}
+
+func countSeries(c prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 10)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	count := 0
+	for range ch {
+		count++
+	}
+	return count
+}
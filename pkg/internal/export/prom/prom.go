@@ -3,6 +3,7 @@ package prom
 import (
 	"context"
 	"runtime"
+	"slices"
 	"strconv"
 	"time"
 
@@ -11,23 +12,19 @@ import (
 
 	"github.com/grafana/beyla/pkg/buildinfo"
 	"github.com/grafana/beyla/pkg/internal/connector"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/cardinality"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/kube"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
 )
 
 // using labels and names that are equivalent names to the OTEL attributes
-// but following the different naming conventions
+// but following the different naming conventions. The names of the metrics and labels
+// that depend on the semantic conventions version are defined in the promNames struct.
 const (
-	HTTPServerDuration    = "http_server_request_duration_seconds"
-	HTTPClientDuration    = "http_client_request_duration_seconds"
-	RPCServerDuration     = "rpc_server_duration_seconds"
-	RPCClientDuration     = "rpc_client_duration_seconds"
-	SQLClientDuration     = "sql_client_duration_seconds"
-	HTTPServerRequestSize = "http_server_request_body_size_bytes"
-	HTTPClientRequestSize = "http_client_request_body_size_bytes"
-
 	// target will expose the process hostname-pid (or K8s Pod).
 	// It is advised for users that to use relabeling rules to
 	// override the "instance" attribute with "target" in the
@@ -36,27 +33,20 @@ const (
 	targetInstanceKey    = "target_instance"
 	serviceNameKey       = "service_name"
 	serviceNamespaceKey  = "service_namespace"
-	httpMethodKey        = "http_request_method"
-	httpRouteKey         = "http_route"
-	httpStatusCodeKey    = "http_response_status_code"
-	httpTargetKey        = "url_path"
-	clientAddrKey        = "client_address"
-	serverAddrKey        = "server_address"
-	serverPortKey        = "server_port"
 	rpcGRPCStatusCodeKey = "rpc_grpc_status_code"
 	rpcMethodKey         = "rpc_method"
 	rpcSystemGRPC        = "rpc_system"
 	DBOperationKey       = "db_operation"
 
-	k8sNamespaceName   = "k8s_namespace_name"
-	k8sPodName         = "k8s_pod_name"
-	k8sDeploymentName  = "k8s_deployment_name"
-	k8sStatefulSetName = "k8s_statefulset_name"
-	k8sReplicaSetName  = "k8s_replicaset_name"
-	k8sDaemonSetName   = "k8s_daemonset_name"
-	k8sNodeName        = "k8s_node_name"
-	k8sPodUID          = "k8s_pod_uid"
-	k8sPodStartTime    = "k8s_pod_start_time"
+	// number of labels at the beginning of each label set that identify the service
+	// (target_instance, service_name and service_namespace)
+	serviceLabelsLen = 3
+	// separator of the label values when they are used as a series key
+	labelValuesSeparator = "\xff"
+
+	// exemplar labels
+	traceIDKey = "trace_id"
+	spanIDKey  = "span_id"
 
 	// default values for the histogram configuration
 	// from https://grafana.com/docs/mimir/latest/send/native-histograms/#migrate-from-classic-histograms
@@ -75,7 +65,6 @@ const (
 // not adding version, as it is a fixed value
 var beylaInfoLabelNames = []string{LanguageLabel}
 
-// TODO: TLS
 type PrometheusConfig struct {
 	Port           int    `yaml:"port" env:"BEYLA_PROMETHEUS_PORT"`
 	Path           string `yaml:"path" env:"BEYLA_PROMETHEUS_PATH"`
@@ -84,27 +73,105 @@ type PrometheusConfig struct {
 
 	DisableBuildInfo bool `yaml:"disable_build_info" env:"BEYLA_PROMETHEUS_DISABLE_BUILD_INFO"`
 
+	// Server specifies the TLS and authentication settings of the HTTP server. As the server
+	// is shared with the internal metrics endpoint, they also apply to the internal metrics.
+	Server connector.ServerConfig `yaml:",inline"`
+
+	// DisableExemplars avoids attaching the trace_id and span_id of the sampled spans as exemplars
+	// of the duration histograms.
+	DisableExemplars bool `yaml:"disable_exemplars" env:"BEYLA_METRICS_DISABLE_EXEMPLARS"`
+
 	Buckets otel.Buckets `yaml:"buckets"`
 
+	// TTL is the time since a metric series was updated for the last time until it is
+	// removed from the exposed metrics. Zero means that series never expire.
+	TTL time.Duration `yaml:"ttl" env:"BEYLA_PROMETHEUS_TTL"`
+
+	// CardinalityLimits restrict the number of distinct series that are reported for each
+	// metric and service.
+	CardinalityLimits cardinality.Limits `yaml:"cardinality_limits"`
+
+	// Features of metrics that are can be exported. Accepted values are "application" and "network".
+	Features []string `yaml:"features" env:"BEYLA_PROMETHEUS_FEATURES" envSeparator:","`
+
+	// AttributeSelection specifies which optional labels are reported for each metrics section.
+	// It needs to be explicitly set up before building the graph
+	AttributeSelection attributes.Selection `yaml:"-"`
+
+	// SemConv version that defines the names of the metrics and their labels.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+
 	Registry *prometheus.Registry `yaml:"-"`
 }
 
+// EndpointEnabled specifies that the Prometheus endpoint is enabled, either because
+// the scrape port is defined or because the metrics are collected into an external registry.
 // nolint:gocritic
-func (p PrometheusConfig) Enabled() bool {
+func (p PrometheusConfig) EndpointEnabled() bool {
 	return p.Port != 0 || p.Registry != nil
 }
 
+// Enabled specifies that the application metrics are exported through the Prometheus endpoint.
+// nolint:gocritic
+func (p PrometheusConfig) Enabled() bool {
+	return p.EndpointEnabled() && slices.Contains(p.Features, otel.FeatureApplication)
+}
+
+// promNames of the metrics and labels that depend on the semantic conventions version
+type promNames struct {
+	*attributes.Names
+
+	httpServerDuration    string
+	httpClientDuration    string
+	rpcServerDuration     string
+	rpcClientDuration     string
+	sqlClientDuration     string
+	httpServerRequestSize string
+	httpClientRequestSize string
+
+	httpMethod     string
+	httpStatusCode string
+}
+
+func newPromNames(version attributes.SemConvVersion) *promNames {
+	names := version.Names()
+	return &promNames{
+		Names:                 names,
+		httpServerDuration:    attributes.PromName(names.HTTPServerDuration) + "_seconds",
+		httpClientDuration:    attributes.PromName(names.HTTPClientDuration) + "_seconds",
+		rpcServerDuration:     attributes.PromName(names.RPCServerDuration) + "_seconds",
+		rpcClientDuration:     attributes.PromName(names.RPCClientDuration) + "_seconds",
+		sqlClientDuration:     attributes.PromName(names.SQLClientDuration) + "_seconds",
+		httpServerRequestSize: attributes.PromName(names.HTTPServerRequestSize) + "_bytes",
+		httpClientRequestSize: attributes.PromName(names.HTTPClientRequestSize) + "_bytes",
+		httpMethod:            attributes.PromName(names.HTTPRequestMethod),
+		httpStatusCode:        attributes.PromName(names.HTTPResponseStatusCode),
+	}
+}
+
 type metricsReporter struct {
-	cfg *PrometheusConfig
+	cfg   *PrometheusConfig
+	names *promNames
 
 	beylaInfo             *prometheus.GaugeVec
-	httpDuration          *prometheus.HistogramVec
-	httpClientDuration    *prometheus.HistogramVec
-	grpcDuration          *prometheus.HistogramVec
-	grpcClientDuration    *prometheus.HistogramVec
-	sqlClientDuration     *prometheus.HistogramVec
-	httpRequestSize       *prometheus.HistogramVec
-	httpClientRequestSize *prometheus.HistogramVec
+	httpDuration          *Expirer[prometheus.Histogram]
+	httpClientDuration    *Expirer[prometheus.Histogram]
+	grpcDuration          *Expirer[prometheus.Histogram]
+	grpcClientDuration    *Expirer[prometheus.Histogram]
+	sqlClientDuration     *Expirer[prometheus.Histogram]
+	httpRequestSize       *Expirer[prometheus.Histogram]
+	httpClientRequestSize *Expirer[prometheus.Histogram]
+
+	// optional labels that are reported for each metrics section
+	attrHTTP       []attributes.Name
+	attrHTTPClient []attributes.Name
+	attrGRPC       []attributes.Name
+	attrGRPCClient []attributes.Name
+	attrSQL        []attributes.Name
+
+	// limiter is nil if the cardinality limits are disabled
+	limiter *cardinality.Limiter[string]
 
 	promConnect *connector.PrometheusManager
 
@@ -121,13 +188,35 @@ func PrometheusEndpoint(ctx context.Context, cfg *PrometheusConfig, ctxInfo *glo
 }
 
 func newReporter(ctx context.Context, cfg *PrometheusConfig, ctxInfo *global.ContextInfo) *metricsReporter {
+	names := newPromNames(cfg.SemConv)
+	defaults := DefaultAttributes(cfg, ctxInfo)
+	attrHTTP := cfg.AttributeSelection.For(attributes.SectionHTTPServer, defaults)
+	attrHTTPClient := cfg.AttributeSelection.For(attributes.SectionHTTPClient, defaults)
+	attrGRPC := cfg.AttributeSelection.For(attributes.SectionRPCServer, defaults)
+	attrGRPCClient := cfg.AttributeSelection.For(attributes.SectionRPCClient, defaults)
+	attrSQL := cfg.AttributeSelection.For(attributes.SectionSQLClient, defaults)
+
+	var limiter *cardinality.Limiter[string]
+	if cfg.CardinalityLimits.Enabled() {
+		limiter = cardinality.NewLimiter[string](cfg.CardinalityLimits, func(metric string, service *svc.ID) {
+			ctxInfo.Metrics.CardinalityLimitHit(metric, service.String())
+		})
+	}
+
 	// If service name is not explicitly set, we take the service name as set by the
 	// executable inspector
 	mr := &metricsReporter{
-		bgCtx:       ctx,
-		ctxInfo:     ctxInfo,
-		cfg:         cfg,
-		promConnect: ctxInfo.Prometheus,
+		bgCtx:          ctx,
+		ctxInfo:        ctxInfo,
+		cfg:            cfg,
+		names:          names,
+		promConnect:    ctxInfo.Prometheus,
+		attrHTTP:       attrHTTP,
+		attrHTTPClient: attrHTTPClient,
+		attrGRPC:       attrGRPC,
+		attrGRPCClient: attrGRPCClient,
+		attrSQL:        attrSQL,
+		limiter:        limiter,
 		beylaInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Name: BeylaBuildInfo,
 			Help: "A metric with a constant '1' value labeled by version, revision, branch, " +
@@ -141,62 +230,62 @@ func newReporter(ctx context.Context, cfg *PrometheusConfig, ctxInfo *global.Con
 				"revision":  buildinfo.Revision,
 			},
 		}, beylaInfoLabelNames),
-		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            HTTPServerDuration,
+		httpDuration: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.httpServerDuration,
 			Help:                            "duration of HTTP service calls from the server side, in seconds",
 			Buckets:                         cfg.Buckets.DurationHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesHTTP(cfg, ctxInfo)),
-		httpClientDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            HTTPClientDuration,
+		}, labelNamesHTTP(names, attrHTTP)).MetricVec, cfg.TTL, forgetSeries(limiter, names.httpServerDuration)),
+		httpClientDuration: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.httpClientDuration,
 			Help:                            "duration of HTTP service calls from the client side, in seconds",
 			Buckets:                         cfg.Buckets.DurationHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesHTTPClient(cfg, ctxInfo)),
-		grpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            RPCServerDuration,
+		}, labelNamesHTTP(names, attrHTTPClient)).MetricVec, cfg.TTL, forgetSeries(limiter, names.httpClientDuration)),
+		grpcDuration: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.rpcServerDuration,
 			Help:                            "duration of RCP service calls from the server side, in seconds",
 			Buckets:                         cfg.Buckets.DurationHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesGRPC(cfg, ctxInfo)),
-		grpcClientDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            RPCClientDuration,
+		}, labelNamesGRPC(names, attrGRPC)).MetricVec, cfg.TTL, forgetSeries(limiter, names.rpcServerDuration)),
+		grpcClientDuration: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.rpcClientDuration,
 			Help:                            "duration of GRPC service calls from the client side, in seconds",
 			Buckets:                         cfg.Buckets.DurationHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesGRPCClient(cfg, ctxInfo)),
-		sqlClientDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            SQLClientDuration,
+		}, labelNamesGRPC(names, attrGRPCClient)).MetricVec, cfg.TTL, forgetSeries(limiter, names.rpcClientDuration)),
+		sqlClientDuration: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.sqlClientDuration,
 			Help:                            "duration of SQL client operations, in seconds",
 			Buckets:                         cfg.Buckets.DurationHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesSQL(ctxInfo)),
-		httpRequestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            HTTPServerRequestSize,
+		}, labelNamesSQL(names, attrSQL)).MetricVec, cfg.TTL, forgetSeries(limiter, names.sqlClientDuration)),
+		httpRequestSize: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.httpServerRequestSize,
 			Help:                            "size, in bytes, of the HTTP request body as received at the server side",
 			Buckets:                         cfg.Buckets.RequestSizeHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesHTTP(cfg, ctxInfo)),
-		httpClientRequestSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
-			Name:                            HTTPClientRequestSize,
+		}, labelNamesHTTP(names, attrHTTP)).MetricVec, cfg.TTL, nil),
+		httpClientRequestSize: NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:                            names.httpClientRequestSize,
 			Help:                            "size, in bytes, of the HTTP request body as sent from the client side",
 			Buckets:                         cfg.Buckets.RequestSizeHistogram,
 			NativeHistogramBucketFactor:     defaultHistogramBucketFactor,
 			NativeHistogramMaxBucketNumber:  defaultHistogramMaxBucketNumber,
 			NativeHistogramMinResetDuration: defaultHistogramMinResetDuration,
-		}, labelNamesHTTPClient(cfg, ctxInfo)),
+		}, labelNamesHTTP(names, attrHTTPClient)).MetricVec, cfg.TTL, nil),
 	}
 
 	var registeredMetrics []prometheus.Collector
@@ -240,175 +329,205 @@ func (r *metricsReporter) observe(span *request.Span) {
 	duration := t.End.Sub(t.RequestStart).Seconds()
 	switch span.Type {
 	case request.EventTypeHTTP:
-		lv := r.labelValuesHTTP(span)
-		r.httpDuration.WithLabelValues(lv...).Observe(duration)
+		lv := r.limitCardinality(r.names.httpServerDuration, span, labelValuesHTTP(span, r.attrHTTP), r.attrHTTP)
+		r.observeDuration(r.httpDuration.WithLabelValues(lv...), span, duration)
 		r.httpRequestSize.WithLabelValues(lv...).Observe(float64(span.ContentLength))
 	case request.EventTypeHTTPClient:
-		lv := r.labelValuesHTTPClient(span)
-		r.httpClientDuration.WithLabelValues(lv...).Observe(duration)
+		lv := r.limitCardinality(r.names.httpClientDuration, span, labelValuesHTTP(span, r.attrHTTPClient), r.attrHTTPClient)
+		r.observeDuration(r.httpClientDuration.WithLabelValues(lv...), span, duration)
 		r.httpClientRequestSize.WithLabelValues(lv...).Observe(float64(span.ContentLength))
 	case request.EventTypeGRPC:
-		r.grpcDuration.WithLabelValues(r.labelValuesGRPC(span)...).Observe(duration)
+		lv := r.limitCardinality(r.names.rpcServerDuration, span, labelValuesGRPC(span, r.attrGRPC), r.attrGRPC)
+		r.observeDuration(r.grpcDuration.WithLabelValues(lv...), span, duration)
 	case request.EventTypeGRPCClient:
-		r.grpcClientDuration.WithLabelValues(r.labelValuesGRPC(span)...).Observe(duration)
+		lv := r.limitCardinality(r.names.rpcClientDuration, span, labelValuesGRPC(span, r.attrGRPCClient), r.attrGRPCClient)
+		r.observeDuration(r.grpcClientDuration.WithLabelValues(lv...), span, duration)
 	case request.EventTypeSQLClient:
-		r.sqlClientDuration.WithLabelValues(r.labelValuesSQL(span)...).Observe(duration)
-	}
-}
-
-// labelNamesSQL must return the label names in the same order as would be returned
-// by labelValuesSQL
-func labelNamesSQL(ctxInfo *global.ContextInfo) []string {
-	names := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, DBOperationKey}
-	if ctxInfo.K8sEnabled {
-		names = appendK8sLabelNames(names)
+		lv := r.limitCardinality(r.names.sqlClientDuration, span, labelValuesSQL(span, r.attrSQL), r.attrSQL)
+		r.observeDuration(r.sqlClientDuration.WithLabelValues(lv...), span, duration)
 	}
-	return names
 }
 
-// labelValuesSQL must return the label names in the same order as would be returned
-// by labelNamesSQL
-func (r *metricsReporter) labelValuesSQL(span *request.Span) []string {
-	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Method}
-	if r.ctxInfo.K8sEnabled {
-		values = appendK8sLabelValues(values, span)
+// limitCardinality returns the passed label values if they can be recorded according to the
+// cardinality limits. Otherwise, it returns the label values of the overflow series of the
+// service. The request body size metrics share the label values with their respective
+// duration metrics, so they are limited together.
+func (r *metricsReporter) limitCardinality(metric string, span *request.Span, lv []string, optional []attributes.Name) []string {
+	if r.limiter == nil || r.limiter.Allow(metric, &span.ServiceID, seriesKey(lv)) {
+		return lv
 	}
-	return values
+	return overflowLabelValues(lv, optional)
 }
 
-// labelNamesGRPC must return the label names in the same order as would be returned
-// by labelValuesGRPC
-func labelNamesGRPC(cfg *PrometheusConfig, ctxInfo *global.ContextInfo) []string {
-	// TODO: let user configure which keys are going to be added
-	names := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, rpcMethodKey, rpcSystemGRPC, rpcGRPCStatusCodeKey}
-	if cfg.ReportPeerInfo {
-		names = append(names, clientAddrKey)
+// forgetSeries returns a function that removes the expired series of a metric
+// from the cardinality limiter, if it is enabled.
+func forgetSeries(limiter *cardinality.Limiter[string], metric string) func(key string) {
+	if limiter == nil {
+		return nil
 	}
-	if ctxInfo.K8sEnabled {
-		names = appendK8sLabelNames(names)
+	return func(key string) {
+		limiter.Forget(metric, key)
 	}
-	return names
 }
 
-// labelNamesGRPCClient must return the label names in the same order as would be returned
-// by labelValuesGRPC
-func labelNamesGRPCClient(cfg *PrometheusConfig, ctxInfo *global.ContextInfo) []string {
-	// TODO: let user configure which keys are going to be added
-	names := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, rpcMethodKey, rpcSystemGRPC, rpcGRPCStatusCodeKey}
-	if cfg.ReportPeerInfo {
-		names = append(names, serverAddrKey)
+// overflowLabelValues replaces by the overflow value all the label values but the ones
+// identifying the service and its Kubernetes metadata.
+func overflowLabelValues(lv []string, optional []attributes.Name) []string {
+	firstOptional := len(lv) - len(optional)
+	for i := serviceLabelsLen; i < firstOptional; i++ {
+		lv[i] = cardinality.OverflowValue
 	}
-	if ctxInfo.K8sEnabled {
-		names = appendK8sLabelNames(names)
+	for i, name := range optional {
+		if !name.IsK8s() {
+			lv[firstOptional+i] = cardinality.OverflowValue
+		}
 	}
-	return names
+	return lv
 }
 
-// labelValuesGRPC must return the label names in the same order as would be returned
-// by labelNamesGRPC
-func (r *metricsReporter) labelValuesGRPC(span *request.Span) []string {
-	// serviceNameKey, rpcMethodKey, rpcSystemGRPC, rpcGRPCStatusCodeKey
-	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Path, "grpc", strconv.Itoa(span.Status)}
-	if r.cfg.ReportPeerInfo {
-		values = append(values, span.Peer) // netSockPeerAddrKey
-	}
-	if r.ctxInfo.K8sEnabled {
-		values = appendK8sLabelValues(values, span)
+// observeDuration records the duration of the span in the passed histogram. If exemplars are enabled
+// and the span belongs to a sampled trace, its trace and span IDs are attached as an exemplar,
+// so the metric sample can be linked to the trace that originated it.
+func (r *metricsReporter) observeDuration(o prometheus.Observer, span *request.Span, duration float64) {
+	if !r.cfg.DisableExemplars && span.Sampled() {
+		if eo, ok := o.(prometheus.ExemplarObserver); ok {
+			eo.ObserveWithExemplar(duration, prometheus.Labels{
+				traceIDKey: span.TraceID.String(),
+				spanIDKey:  span.SpanID.String(),
+			})
+			return
+		}
 	}
-	return values
+	o.Observe(duration)
 }
 
-// labelNamesHTTPClient must return the label names in the same order as would be returned
-// by labelValuesHTTPClient
-func labelNamesHTTPClient(cfg *PrometheusConfig, ctxInfo *global.ContextInfo) []string {
-	names := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, httpMethodKey, httpStatusCodeKey}
-	if cfg.ReportPeerInfo {
-		names = append(names, serverAddrKey, serverPortKey)
+// DefaultAttributes returns the optional labels that are reported when the user
+// does not explicitly select them
+func DefaultAttributes(cfg *PrometheusConfig, ctxInfo *global.ContextInfo) []attributes.Name {
+	var defaults []attributes.Name
+	if cfg.ReportTarget {
+		defaults = append(defaults, attributes.URLPath)
 	}
-	if ctxInfo.K8sEnabled {
-		names = appendK8sLabelNames(names)
+	if cfg.ReportPeerInfo {
+		defaults = append(defaults, attributes.ClientAddr, attributes.ServerAddr, attributes.ServerPort)
 	}
 	if ctxInfo.ReportRoutes {
-		names = append(names, httpRouteKey)
+		defaults = append(defaults, attributes.HTTPRoute)
+	}
+	if ctxInfo.K8sEnabled {
+		defaults = append(defaults, attributes.K8sNames...)
 	}
-	return names
+	return defaults
 }
 
-// labelValuesHTTPClient must return the label names in the same order as would be returned
-// by labelNamesHTTPClient
-func (r *metricsReporter) labelValuesHTTPClient(span *request.Span) []string {
-	// httpMethodKey, httpStatusCodeKey
-	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Method, strconv.Itoa(span.Status)}
-	if r.cfg.ReportPeerInfo {
-		// netSockPeerAddrKey, netSockPeerPortKey
-		values = append(values, span.Host, strconv.Itoa(span.HostPort))
-	}
-	if r.ctxInfo.K8sEnabled {
-		values = appendK8sLabelValues(values, span)
+// spanLabelsNames caches the names of each semantic conventions version, to avoid
+// calculating them for each span
+var spanLabelsNames = map[attributes.SemConvVersion]*promNames{
+	attributes.SemConv119: newPromNames(attributes.SemConv119),
+	attributes.SemConv123: newPromNames(attributes.SemConv123),
+}
+
+// SpanLabels returns the names and values of the labels that are attached to the metrics of
+// the span, in the same order. The optional argument contains the optional labels that are
+// selected for the metrics section of the span. The label names follow the given semantic
+// conventions version.
+func SpanLabels(span *request.Span, version attributes.SemConvVersion, optional []attributes.Name) (names, values []string) {
+	pn, ok := spanLabelsNames[version]
+	if !ok {
+		pn = newPromNames(version)
 	}
-	if r.ctxInfo.ReportRoutes {
-		values = append(values, span.Route) // httpRouteKey
+	switch span.Type {
+	case request.EventTypeHTTP, request.EventTypeHTTPClient:
+		return labelNamesHTTP(pn, optional), labelValuesHTTP(span, optional)
+	case request.EventTypeGRPC, request.EventTypeGRPCClient:
+		return labelNamesGRPC(pn, optional), labelValuesGRPC(span, optional)
+	case request.EventTypeSQLClient:
+		return labelNamesSQL(pn, optional), labelValuesSQL(span, optional)
 	}
-	return values
+	return nil, nil
+}
+
+// labelNamesSQL must return the label names in the same order as would be returned
+// by labelValuesSQL
+func labelNamesSQL(names *promNames, optional []attributes.Name) []string {
+	labels := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, DBOperationKey}
+	return appendOptionalLabelNames(labels, names, optional)
+}
+
+// labelValuesSQL must return the label names in the same order as would be returned
+// by labelNamesSQL
+func labelValuesSQL(span *request.Span, optional []attributes.Name) []string {
+	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Method}
+	return appendOptionalLabelValues(values, span, optional)
+}
+
+// labelNamesGRPC must return the label names in the same order as would be returned
+// by labelValuesGRPC
+func labelNamesGRPC(names *promNames, optional []attributes.Name) []string {
+	labels := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, rpcMethodKey, rpcSystemGRPC, rpcGRPCStatusCodeKey}
+	return appendOptionalLabelNames(labels, names, optional)
+}
+
+// labelValuesGRPC must return the label names in the same order as would be returned
+// by labelNamesGRPC
+func labelValuesGRPC(span *request.Span, optional []attributes.Name) []string {
+	// serviceNameKey, rpcMethodKey, rpcSystemGRPC, rpcGRPCStatusCodeKey
+	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Path, "grpc", strconv.Itoa(span.Status)}
+	return appendOptionalLabelValues(values, span, optional)
 }
 
 // labelNamesHTTP must return the label names in the same order as would be returned
 // by labelValuesHTTP
-func labelNamesHTTP(cfg *PrometheusConfig, ctxInfo *global.ContextInfo) []string {
-	names := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, httpMethodKey, httpStatusCodeKey}
-	if cfg.ReportTarget {
-		names = append(names, httpTargetKey)
-	}
-	if cfg.ReportPeerInfo {
-		names = append(names, clientAddrKey)
-	}
-	if ctxInfo.ReportRoutes {
-		names = append(names, httpRouteKey)
-	}
-	if ctxInfo.K8sEnabled {
-		names = appendK8sLabelNames(names)
-	}
-	return names
+func labelNamesHTTP(names *promNames, optional []attributes.Name) []string {
+	labels := []string{targetInstanceKey, serviceNameKey, serviceNamespaceKey, names.httpMethod, names.httpStatusCode}
+	return appendOptionalLabelNames(labels, names, optional)
 }
 
-// labelValuesGRPC must return the label names in the same order as would be returned
+// labelValuesHTTP must return the label names in the same order as would be returned
 // by labelNamesHTTP
-func (r *metricsReporter) labelValuesHTTP(span *request.Span) []string {
-	// httpMethodKey, httpStatusCodeKey
+func labelValuesHTTP(span *request.Span, optional []attributes.Name) []string {
+	// httpMethod, httpStatusCode
 	values := []string{span.ServiceID.Instance, span.ServiceID.Name, span.ServiceID.Namespace, span.Method, strconv.Itoa(span.Status)}
-	if r.cfg.ReportTarget {
-		values = append(values, span.Path) // httpTargetKey
-	}
-	if r.cfg.ReportPeerInfo {
-		values = append(values, span.Peer) // netSockPeerAddrKey
-	}
-	if r.ctxInfo.ReportRoutes {
-		values = append(values, span.Route) // httpRouteKey
-	}
-	if r.ctxInfo.K8sEnabled {
-		values = appendK8sLabelValues(values, span)
-	}
-	return values
+	return appendOptionalLabelValues(values, span, optional)
 }
 
-func appendK8sLabelNames(names []string) []string {
-	names = append(names, k8sNamespaceName, k8sPodName, k8sNodeName, k8sPodUID, k8sPodStartTime,
-		k8sDeploymentName, k8sReplicaSetName, k8sStatefulSetName, k8sDaemonSetName)
-	return names
+func appendOptionalLabelNames(labels []string, names *promNames, optional []attributes.Name) []string {
+	for _, name := range optional {
+		labels = append(labels, attributes.PromName(names.Attribute(name)))
+	}
+	return labels
 }
 
-func appendK8sLabelValues(values []string, span *request.Span) []string {
-	// must follow the order in appendK8sLabelNames
-	values = append(values,
-		span.ServiceID.Metadata[kube.NamespaceName],
-		span.ServiceID.Metadata[kube.PodName],
-		span.ServiceID.Metadata[kube.NodeName],
-		span.ServiceID.Metadata[kube.PodUID],
-		span.ServiceID.Metadata[kube.PodStartTime],
-		span.ServiceID.Metadata[kube.DeploymentName],
-		span.ServiceID.Metadata[kube.ReplicaSetName],
-		span.ServiceID.Metadata[kube.StatefulSetName],
-		span.ServiceID.Metadata[kube.DaemonSetName],
-	)
+func appendOptionalLabelValues(values []string, span *request.Span, optional []attributes.Name) []string {
+	// must follow the order in appendOptionalLabelNames
+	for _, name := range optional {
+		switch name {
+		case attributes.URLPath:
+			values = append(values, span.Path)
+		case attributes.ClientAddr:
+			values = append(values, span.Peer)
+		case attributes.ServerAddr:
+			values = append(values, otel.MetricServerAddr(span))
+		case attributes.ServerPort:
+			values = append(values, strconv.Itoa(span.HostPort))
+		case attributes.HTTPRoute:
+			values = append(values, span.Route)
+		default:
+			values = append(values, span.ServiceID.Metadata[k8sMetadataKeys[name]])
+		}
+	}
 	return values
 }
+
+// k8sMetadataKeys maps the Kubernetes labels to the keys of the service metadata
+var k8sMetadataKeys = map[attributes.Name]string{
+	attributes.K8sNamespaceName:   kube.NamespaceName,
+	attributes.K8sPodName:         kube.PodName,
+	attributes.K8sNodeName:        kube.NodeName,
+	attributes.K8sPodUID:          kube.PodUID,
+	attributes.K8sPodStartTime:    kube.PodStartTime,
+	attributes.K8sDeploymentName:  kube.DeploymentName,
+	attributes.K8sReplicaSetName:  kube.ReplicaSetName,
+	attributes.K8sStatefulSetName: kube.StatefulSetName,
+	attributes.K8sDaemonSetName:   kube.DaemonSetName,
+}
@@ -0,0 +1,77 @@
+package prom
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/cardinality"
+	"github.com/grafana/beyla/pkg/internal/kube"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+func TestLimitCardinality(t *testing.T) {
+	optional := []attributes.Name{attributes.HTTPRoute, attributes.K8sPodName}
+	var hits []string
+	r := metricsReporter{
+		limiter: cardinality.NewLimiter[string](cardinality.Limits{MaxSeriesPerService: 1},
+			func(metric string, service *svc.ID) {
+				hits = append(hits, metric+"/"+service.String())
+			}),
+	}
+	span := func(route string) *request.Span {
+		return &request.Span{
+			Type: request.EventTypeHTTP, Method: "GET", Status: 200, Route: route,
+			ServiceID: svc.ID{
+				Instance: "foo-1", Name: "foo", Namespace: "ns",
+				Metadata: map[string]string{kube.PodName: "foo-pod"},
+			},
+		}
+	}
+	limit := func(route string) []string {
+		s := span(route)
+		return r.limitCardinality("http_server_request_duration_seconds", s, labelValuesHTTP(s, optional), optional)
+	}
+
+	assert.Equal(t, []string{"foo-1", "foo", "ns", "GET", "200", "/a", "foo-pod"}, limit("/a"))
+	assert.Equal(t, []string{"foo-1", "foo", "ns", "GET", "200", "/a", "foo-pod"}, limit("/a"))
+	// the overflow series keeps the service and Kubernetes labels
+	overflow := []string{"foo-1", "foo", "ns", "__overflow__", "__overflow__", "__overflow__", "foo-pod"}
+	assert.Equal(t, overflow, limit("/b"))
+	assert.Equal(t, overflow, limit("/c"))
+
+	assert.Equal(t, []string{
+		"http_server_request_duration_seconds/ns/foo", "http_server_request_duration_seconds/ns/foo",
+	}, hits)
+}
+
+func TestSemConvNames(t *testing.T) {
+	optional := []attributes.Name{attributes.URLPath, attributes.ClientAddr}
+	span := &request.Span{Type: request.EventTypeHTTP, Method: "GET", Status: 200, Path: "/a", Peer: "1.1.1.1"}
+
+	names, values := SpanLabels(span, attributes.SemConv123, optional)
+	assert.Equal(t, []string{"target_instance", "service_name", "service_namespace",
+		"http_request_method", "http_response_status_code", "url_path", "client_address"}, names)
+	assert.Equal(t, []string{"", "", "", "GET", "200", "/a", "1.1.1.1"}, values)
+
+	names, values = SpanLabels(span, attributes.SemConv119, optional)
+	assert.Equal(t, []string{"target_instance", "service_name", "service_namespace",
+		"http_method", "http_status_code", "http_target", "net_sock_peer_addr"}, names)
+	assert.Equal(t, []string{"", "", "", "GET", "200", "/a", "1.1.1.1"}, values)
+
+	// gRPC client spans report the peer as server address
+	grpcClient := &request.Span{Type: request.EventTypeGRPCClient, Path: "/foo.Bar/Baz",
+		Peer: "2.2.2.2", Host: "server.local", HostPort: 8080}
+	names, values = SpanLabels(grpcClient, attributes.SemConv123, []attributes.Name{attributes.ServerAddr})
+	assert.Equal(t, "server_address", names[len(names)-1])
+	assert.Equal(t, "2.2.2.2", values[len(values)-1])
+
+	current := newPromNames(attributes.SemConv123)
+	assert.Equal(t, "http_server_request_duration_seconds", current.httpServerDuration)
+	assert.Equal(t, "http_client_request_body_size_bytes", current.httpClientRequestSize)
+	legacy := newPromNames(attributes.SemConv119)
+	assert.Equal(t, "http_server_duration_seconds", legacy.httpServerDuration)
+	assert.Equal(t, "http_client_request_size_bytes", legacy.httpClientRequestSize)
+}
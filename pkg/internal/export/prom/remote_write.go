@@ -0,0 +1,243 @@
+package prom
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/mariomac/pipes/pkg/node"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/beyla/pkg/buildinfo"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/cardinality"
+	"github.com/grafana/beyla/pkg/internal/export/otel"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func rwlog() *slog.Logger {
+	return slog.With("component", "prom.RemoteWrite")
+}
+
+// RemoteWriteConfig pushes the Prometheus metrics to a remote-write endpoint
+// (e.g. Mimir, Cortex, Thanos or the Prometheus remote-write receiver), for
+// environments where Beyla can't be scraped.
+type RemoteWriteConfig struct {
+	Endpoint string `yaml:"endpoint" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_ENDPOINT"`
+	// Interval between two consecutive pushes
+	Interval time.Duration `yaml:"interval" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_INTERVAL"`
+	// Timeout of each push request
+	Timeout time.Duration `yaml:"timeout" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_TIMEOUT"`
+	// Headers that are added to each push request (e.g. X-Scope-OrgID or Authorization)
+	Headers map[string]string `yaml:"headers" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_HEADERS"`
+	// MaxRetries of a failed push that can be recovered (network errors, 5xx and 429 responses)
+	MaxRetries int `yaml:"max_retries" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_MAX_RETRIES"`
+	// RetryBackoff is the time before the first retry. It is doubled on each retry.
+	RetryBackoff time.Duration `yaml:"retry_backoff" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_RETRY_BACKOFF"`
+	// NativeHistograms submits the histograms in native format instead of classic buckets.
+	// It requires the remote endpoint to accept native histograms.
+	NativeHistograms bool `yaml:"native_histograms" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_NATIVE_HISTOGRAMS"`
+
+	ReportTarget     bool `yaml:"report_target" env:"BEYLA_METRICS_REPORT_TARGET"`
+	ReportPeerInfo   bool `yaml:"report_peer" env:"BEYLA_METRICS_REPORT_PEER"`
+	DisableBuildInfo bool `yaml:"disable_build_info" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_DISABLE_BUILD_INFO"`
+
+	Buckets otel.Buckets `yaml:"buckets"`
+	// TTL is the time since a metric series was updated for the last time until it stops
+	// being pushed. Zero (the default) means that series never expire.
+	TTL               time.Duration      `yaml:"ttl" env:"BEYLA_PROMETHEUS_REMOTE_WRITE_TTL"`
+	CardinalityLimits cardinality.Limits `yaml:"cardinality_limits"`
+
+	// AttributeSelection specifies which optional labels are reported for each metrics section.
+	// It needs to be explicitly set up before building the graph
+	AttributeSelection attributes.Selection `yaml:"-"`
+
+	// SemConv version that defines the names of the metrics and their labels.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+}
+
+// nolint:gocritic
+func (c RemoteWriteConfig) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// metricsConfig returns the configuration of the metric families that are pushed
+func (c *RemoteWriteConfig) metricsConfig(registry *prometheus.Registry) *PrometheusConfig {
+	return &PrometheusConfig{
+		ReportTarget:       c.ReportTarget,
+		ReportPeerInfo:     c.ReportPeerInfo,
+		DisableBuildInfo:   c.DisableBuildInfo,
+		DisableExemplars:   true,
+		Buckets:            c.Buckets,
+		TTL:                c.TTL,
+		CardinalityLimits:  c.CardinalityLimits,
+		AttributeSelection: c.AttributeSelection,
+		SemConv:            c.SemConv,
+		Features:           []string{otel.FeatureApplication},
+		Registry:           registry,
+	}
+}
+
+type remoteWriter struct {
+	cfg      *RemoteWriteConfig
+	ctxInfo  *global.ContextInfo
+	registry *prometheus.Registry
+	client   *http.Client
+}
+
+// RemoteWriteEndpoint records the same metric families as the Prometheus scrape endpoint,
+// and periodically pushes them to the remote-write endpoint.
+func RemoteWriteEndpoint(ctx context.Context, cfg *RemoteWriteConfig, ctxInfo *global.ContextInfo) (node.TerminalFunc[[]request.Span], error) {
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("prometheus_remote_write interval must be positive. Got: %s", cfg.Interval)
+	}
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(ctx, cfg.metricsConfig(registry), ctxInfo)
+	rw := &remoteWriter{
+		cfg:      cfg,
+		ctxInfo:  ctxInfo,
+		registry: registry,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+	return func(input <-chan []request.Span) {
+		pushCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			rw.pushLoop(pushCtx)
+			close(done)
+		}()
+		reporter.collectMetrics(input)
+		cancel()
+		<-done
+	}, nil
+}
+
+// pushLoop pushes the metrics on each interval, until the context is cancelled.
+// The last metrics are pushed before returning.
+func (rw *remoteWriter) pushLoop(ctx context.Context) {
+	ticker := time.NewTicker(rw.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the parent context might also be cancelled, so we can't use it for the last push
+			rw.push(context.Background())
+			return
+		case <-ticker.C:
+			rw.push(ctx)
+		}
+	}
+}
+
+func (rw *remoteWriter) push(ctx context.Context) {
+	log := rwlog()
+	families, err := rw.registry.Gather()
+	if err != nil {
+		log.Warn("error gathering metrics. Some metrics might be missing", "error", err)
+	}
+	series := toTimeSeries(families, rw.cfg.NativeHistograms)
+	if len(series) == 0 {
+		return
+	}
+	body := snappy.Encode(nil, marshalWriteRequest(series, timeNow().UnixMilli()))
+	if err := rw.send(ctx, body); err != nil {
+		log.Error("can't push metrics to the remote write endpoint", "error", err)
+		rw.ctxInfo.Metrics.PrometheusRemoteWriteError(errorReason(err))
+		return
+	}
+	rw.ctxInfo.Metrics.PrometheusRemoteWrite(len(series))
+}
+
+// send the request, retrying it if the error is recoverable
+func (rw *remoteWriter) send(ctx context.Context, body []byte) error {
+	backoff := rw.cfg.RetryBackoff
+	for retry := 0; ; retry++ {
+		err := rw.sendOnce(ctx, body)
+		var nr nonRecoverableError
+		if err == nil || errors.As(err, &nr) || retry >= rw.cfg.MaxRetries {
+			return err
+		}
+		rwlog().Debug("error pushing metrics. Retrying", "error", err, "retry", retry+1, "backoff", backoff)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+type nonRecoverableError struct {
+	error
+}
+
+func (e nonRecoverableError) Unwrap() error {
+	return e.error
+}
+
+// statusError is returned when the remote endpoint responds with a non-2xx status code
+type statusError struct {
+	code int
+	msg  string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("remote endpoint returned %d %s: %s", e.code, http.StatusText(e.code), e.msg)
+}
+
+// errorReason classifies the errors of a push into a bounded set of values, so they
+// can be used as a metric label: the HTTP status code of the response, "timeout",
+// "network" for the rest of connection errors, or "other".
+func errorReason(err error) string {
+	var se statusError
+	if errors.As(err, &se) {
+		return strconv.Itoa(se.code)
+	}
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return "timeout"
+	}
+	if ne != nil {
+		return "network"
+	}
+	return "other"
+}
+
+func (rw *remoteWriter) sendOnce(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nonRecoverableError{fmt.Errorf("creating request: %w", err)}
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "beyla/"+buildinfo.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range rw.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := rw.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = statusError{code: resp.StatusCode, msg: string(bytes.TrimSpace(msg))}
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+	return nonRecoverableError{err}
+}
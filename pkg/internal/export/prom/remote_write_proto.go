@@ -0,0 +1,221 @@
+package prom
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Field numbers of the Prometheus remote-write protocol messages, as defined in
+// https://github.com/prometheus/prometheus/blob/main/prompb/types.proto
+// https://github.com/prometheus/prometheus/blob/main/prompb/remote.proto
+const (
+	writeRequestTimeseries = 1
+
+	timeSeriesLabels     = 1
+	timeSeriesSamples    = 2
+	timeSeriesHistograms = 4
+
+	labelName  = 1
+	labelValue = 2
+
+	sampleValue     = 1
+	sampleTimestamp = 2
+
+	histogramCountInt      = 1
+	histogramCountFloat    = 2
+	histogramSum           = 3
+	histogramSchema        = 4
+	histogramZeroThreshold = 5
+	histogramZeroCountInt  = 6
+	histogramNegSpans      = 8
+	histogramNegDeltas     = 9
+	histogramPosSpans      = 11
+	histogramPosDeltas     = 12
+	histogramTimestamp     = 15
+
+	bucketSpanOffset = 1
+	bucketSpanLength = 2
+)
+
+const metricNameLabel = "__name__"
+
+type promLabel struct {
+	name  string
+	value string
+}
+
+// timeSeries to be submitted through remote write. It contains either
+// a sample value or a native histogram
+type timeSeries struct {
+	labels    []promLabel
+	value     float64
+	histogram *dto.Histogram
+}
+
+// toTimeSeries converts the gathered metric families into remote-write time series.
+// If nativeHistograms is true, the histograms containing native buckets are submitted
+// as native histograms. Otherwise, they are submitted as classic histograms.
+func toTimeSeries(families []*dto.MetricFamily, nativeHistograms bool) []timeSeries {
+	var series []timeSeries
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series = append(series, timeSeries{labels: seriesLabels(name, m), value: m.GetCounter().GetValue()})
+			case dto.MetricType_GAUGE:
+				series = append(series, timeSeries{labels: seriesLabels(name, m), value: m.GetGauge().GetValue()})
+			case dto.MetricType_UNTYPED:
+				series = append(series, timeSeries{labels: seriesLabels(name, m), value: m.GetUntyped().GetValue()})
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				if nativeHistograms && h.Schema != nil {
+					series = append(series, timeSeries{labels: seriesLabels(name, m), histogram: h})
+				} else {
+					series = appendClassicHistogram(series, name, m)
+				}
+			}
+		}
+	}
+	return series
+}
+
+func appendClassicHistogram(series []timeSeries, name string, m *dto.Metric) []timeSeries {
+	h := m.GetHistogram()
+	infSeen := false
+	for _, b := range h.GetBucket() {
+		if math.IsInf(b.GetUpperBound(), +1) {
+			infSeen = true
+		}
+		series = append(series, timeSeries{
+			labels: seriesLabels(name+"_bucket", m, promLabel{name: "le", value: formatBound(b.GetUpperBound())}),
+			value:  float64(b.GetCumulativeCount()),
+		})
+	}
+	if !infSeen {
+		series = append(series, timeSeries{
+			labels: seriesLabels(name+"_bucket", m, promLabel{name: "le", value: "+Inf"}),
+			value:  float64(h.GetSampleCount()),
+		})
+	}
+	return append(series,
+		timeSeries{labels: seriesLabels(name+"_sum", m), value: h.GetSampleSum()},
+		timeSeries{labels: seriesLabels(name+"_count", m), value: float64(h.GetSampleCount())},
+	)
+}
+
+func formatBound(b float64) string {
+	if math.IsInf(b, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(b, 'g', -1, 64)
+}
+
+// seriesLabels returns the labels of a metric, including the metric name,
+// sorted by name as required by the remote-write protocol
+func seriesLabels(name string, m *dto.Metric, extra ...promLabel) []promLabel {
+	labels := make([]promLabel, 0, len(m.GetLabel())+len(extra)+1)
+	labels = append(labels, promLabel{name: metricNameLabel, value: name})
+	for _, lp := range m.GetLabel() {
+		labels = append(labels, promLabel{name: lp.GetName(), value: lp.GetValue()})
+	}
+	labels = append(labels, extra...)
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].name < labels[j].name
+	})
+	return labels
+}
+
+// marshalWriteRequest encodes the time series as a remote-write WriteRequest
+// protobuf message. All the samples are reported with the same timestamp.
+func marshalWriteRequest(series []timeSeries, timestamp int64) []byte {
+	var out, ts []byte
+	for i := range series {
+		ts = marshalTimeSeries(ts[:0], &series[i], timestamp)
+		out = protowire.AppendTag(out, writeRequestTimeseries, protowire.BytesType)
+		out = protowire.AppendBytes(out, ts)
+	}
+	return out
+}
+
+func marshalTimeSeries(b []byte, s *timeSeries, timestamp int64) []byte {
+	for _, l := range s.labels {
+		var lb []byte
+		lb = appendString(lb, labelName, l.name)
+		lb = appendString(lb, labelValue, l.value)
+		b = appendMessage(b, timeSeriesLabels, lb)
+	}
+	if s.histogram != nil {
+		return appendMessage(b, timeSeriesHistograms, marshalHistogram(s.histogram, timestamp))
+	}
+	var sb []byte
+	sb = appendDouble(sb, sampleValue, s.value)
+	sb = protowire.AppendTag(sb, sampleTimestamp, protowire.VarintType)
+	sb = protowire.AppendVarint(sb, uint64(timestamp))
+	return appendMessage(b, timeSeriesSamples, sb)
+}
+
+func marshalHistogram(h *dto.Histogram, timestamp int64) []byte {
+	var b []byte
+	if h.SampleCountFloat != nil {
+		b = appendDouble(b, histogramCountFloat, h.GetSampleCountFloat())
+	} else {
+		b = protowire.AppendTag(b, histogramCountInt, protowire.VarintType)
+		b = protowire.AppendVarint(b, h.GetSampleCount())
+	}
+	b = appendDouble(b, histogramSum, h.GetSampleSum())
+	b = protowire.AppendTag(b, histogramSchema, protowire.VarintType)
+	b = protowire.AppendVarint(b, protowire.EncodeZigZag(int64(h.GetSchema())))
+	b = appendDouble(b, histogramZeroThreshold, h.GetZeroThreshold())
+	b = protowire.AppendTag(b, histogramZeroCountInt, protowire.VarintType)
+	b = protowire.AppendVarint(b, h.GetZeroCount())
+	b = appendSpans(b, histogramNegSpans, h.GetNegativeSpan())
+	b = appendDeltas(b, histogramNegDeltas, h.GetNegativeDelta())
+	b = appendSpans(b, histogramPosSpans, h.GetPositiveSpan())
+	b = appendDeltas(b, histogramPosDeltas, h.GetPositiveDelta())
+	b = protowire.AppendTag(b, histogramTimestamp, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(timestamp))
+}
+
+func appendSpans(b []byte, field protowire.Number, spans []*dto.BucketSpan) []byte {
+	for _, s := range spans {
+		var sb []byte
+		sb = protowire.AppendTag(sb, bucketSpanOffset, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, protowire.EncodeZigZag(int64(s.GetOffset())))
+		sb = protowire.AppendTag(sb, bucketSpanLength, protowire.VarintType)
+		sb = protowire.AppendVarint(sb, uint64(s.GetLength()))
+		b = appendMessage(b, field, sb)
+	}
+	return b
+}
+
+// appendDeltas encodes the bucket deltas as a packed repeated sint64 field
+func appendDeltas(b []byte, field protowire.Number, deltas []int64) []byte {
+	if len(deltas) == 0 {
+		return b
+	}
+	var db []byte
+	for _, d := range deltas {
+		db = protowire.AppendVarint(db, protowire.EncodeZigZag(d))
+	}
+	return appendMessage(b, field, db)
+}
+
+func appendMessage(b []byte, field protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+func appendString(b []byte, field protowire.Number, s string) []byte {
+	b = protowire.AppendTag(b, field, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendDouble(b []byte, field protowire.Number, v float64) []byte {
+	b = protowire.AppendTag(b, field, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
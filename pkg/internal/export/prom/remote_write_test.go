@@ -0,0 +1,236 @@
+package prom
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+const timeout = 5 * time.Second
+
+func TestRemoteWrite(t *testing.T) {
+	for _, native := range []bool{false, true} {
+		t.Run(map[bool]string{false: "classic histograms", true: "native histograms"}[native], func(t *testing.T) {
+			received := make(chan []decodedSeries, 10)
+			var calls atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				// the first request fails, to verify that it is retried
+				if calls.Add(1) == 1 {
+					rw.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				assert.Equal(t, "snappy", req.Header.Get("Content-Encoding"))
+				assert.Equal(t, "application/x-protobuf", req.Header.Get("Content-Type"))
+				assert.Equal(t, "tenant-1", req.Header.Get("X-Scope-OrgID"))
+				compressed, err := io.ReadAll(req.Body)
+				require.NoError(t, err)
+				body, err := snappy.Decode(nil, compressed)
+				require.NoError(t, err)
+				received <- decodeWriteRequest(t, body)
+			}))
+			defer receiver.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			node, err := RemoteWriteEndpoint(ctx, &RemoteWriteConfig{
+				Endpoint:         receiver.URL,
+				Interval:         10 * time.Millisecond,
+				Timeout:          time.Second,
+				MaxRetries:       3,
+				RetryBackoff:     time.Millisecond,
+				Headers:          map[string]string{"X-Scope-OrgID": "tenant-1"},
+				NativeHistograms: native,
+				DisableBuildInfo: true,
+			}, &global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+			require.NoError(t, err)
+
+			spans := make(chan []request.Span, 1)
+			go node(spans)
+			spans <- []request.Span{{
+				Type: request.EventTypeHTTP, Method: "GET", Status: 200,
+				RequestStart: 0, Start: 0, End: int64(2 * time.Second),
+				ServiceID: svc.ID{Name: "foo", Instance: "foo-1"},
+			}}
+
+			test.Eventually(t, timeout, func(t require.TestingT) {
+				var series []decodedSeries
+				select {
+				case series = <-received:
+				case <-time.After(timeout):
+					require.Fail(t, "timeout waiting for remote write requests")
+				}
+				labels := map[string]string{
+					"http_request_method":       "GET",
+					"http_response_status_code": "200",
+					"service_name":              "foo",
+					"service_namespace":         "",
+					"target_instance":           "foo-1",
+				}
+				if native {
+					h := find(series, "http_server_request_duration_seconds", labels)
+					require.NotNil(t, h)
+					assert.Equal(t, 1, h.histograms)
+					assert.Empty(t, h.samples)
+				} else {
+					count := find(series, "http_server_request_duration_seconds"+"_count", labels)
+					require.NotNil(t, count)
+					assert.Equal(t, []float64{1}, count.samples)
+					sum := find(series, "http_server_request_duration_seconds"+"_sum", labels)
+					require.NotNil(t, sum)
+					assert.Equal(t, []float64{2}, sum.samples)
+					labels["le"] = "+Inf"
+					inf := find(series, "http_server_request_duration_seconds"+"_bucket", labels)
+					require.NotNil(t, inf)
+					assert.Equal(t, []float64{1}, inf.samples)
+				}
+			})
+			close(spans)
+		})
+	}
+}
+
+func TestRemoteWrite_NonRecoverableErrors(t *testing.T) {
+	var calls atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		rw.WriteHeader(http.StatusBadRequest)
+	}))
+	defer receiver.Close()
+	rw := remoteWriter{
+		cfg:    &RemoteWriteConfig{Endpoint: receiver.URL, MaxRetries: 3, RetryBackoff: time.Millisecond},
+		client: http.DefaultClient,
+	}
+	err := rw.send(context.Background(), []byte("foo"))
+	require.Error(t, err)
+	assert.EqualValues(t, 1, calls.Load())
+	assert.Equal(t, "400", errorReason(err))
+}
+
+func TestRemoteWrite_ErrorReason(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		// the response body must not be part of the error reason
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = rw.Write([]byte("ingester 10.0.0.3 is unhealthy"))
+	}))
+	rw := remoteWriter{
+		cfg:    &RemoteWriteConfig{Endpoint: receiver.URL},
+		client: http.DefaultClient,
+	}
+	err := rw.send(context.Background(), []byte("foo"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ingester 10.0.0.3 is unhealthy")
+	assert.Equal(t, "503", errorReason(err))
+
+	// connection errors
+	receiver.Close()
+	err = rw.send(context.Background(), []byte("foo"))
+	require.Error(t, err)
+	assert.Equal(t, "network", errorReason(err))
+
+	// timeouts
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	err = rw.send(ctx, []byte("foo"))
+	require.Error(t, err)
+	assert.Equal(t, "timeout", errorReason(err))
+
+	assert.Equal(t, "other", errorReason(errors.New("foo")))
+}
+
+type decodedSeries struct {
+	labels     map[string]string
+	samples    []float64
+	histograms int
+}
+
+func find(series []decodedSeries, name string, labels map[string]string) *decodedSeries {
+	for i := range series {
+		s := &series[i]
+		if s.labels[metricNameLabel] != name || len(s.labels) != len(labels)+1 {
+			continue
+		}
+		matches := true
+		for k, v := range labels {
+			if s.labels[k] != v {
+				matches = false
+			}
+		}
+		if matches {
+			return s
+		}
+	}
+	return nil
+}
+
+// decodeWriteRequest parses the subset of the remote-write protobuf messages that
+// is required by the tests
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	var series []decodedSeries
+	forEachField(t, b, func(num protowire.Number, _ protowire.Type, ts []byte) {
+		require.EqualValues(t, writeRequestTimeseries, num)
+		s := decodedSeries{labels: map[string]string{}}
+		forEachField(t, ts, func(num protowire.Number, _ protowire.Type, v []byte) {
+			switch num {
+			case timeSeriesLabels:
+				var name, value string
+				forEachField(t, v, func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == labelName {
+						name = string(v)
+					} else {
+						value = string(v)
+					}
+				})
+				s.labels[name] = value
+			case timeSeriesSamples:
+				forEachField(t, v, func(num protowire.Number, _ protowire.Type, v []byte) {
+					if num == sampleValue {
+						bits, _ := protowire.ConsumeFixed64(v)
+						s.samples = append(s.samples, math.Float64frombits(bits))
+					}
+				})
+			case timeSeriesHistograms:
+				s.histograms++
+			}
+		})
+		series = append(series, s)
+	})
+	return series
+}
+
+// forEachField invokes the passed function for each field of the message. For
+// length-delimited fields, the value contains the field contents. For the rest, it
+// contains the raw encoded value.
+func forEachField(t *testing.T, b []byte, fn func(num protowire.Number, typ protowire.Type, value []byte)) {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		var value []byte
+		if typ == protowire.BytesType {
+			value, n = protowire.ConsumeBytes(b)
+		} else {
+			n = protowire.ConsumeFieldValue(num, typ, b)
+			value = b[:n]
+		}
+		require.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+		fn(num, typ, value)
+	}
+}
@@ -0,0 +1,380 @@
+// Package statsd provides an exporter that submits the application metrics to a StatsD
+// agent, in DogStatsD format, over UDP or a Unix datagram socket.
+package statsd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func sdlog() *slog.Logger {
+	return slog.With("component", "statsd.Exporter")
+}
+
+// names of the reported counters, without the configured prefix. The names of the duration
+// metrics depend on the semantic conventions version.
+const (
+	HTTPServerRequests = "http.server.requests"
+	HTTPClientRequests = "http.client.requests"
+	RPCServerRequests  = "rpc.server.requests"
+	RPCClientRequests  = "rpc.client.requests"
+	SQLClientRequests  = "sql.client.requests"
+)
+
+// DogStatsD metric types
+const (
+	typeTiming  = "ms"
+	typeCounter = "c"
+)
+
+// Config of the StatsD metrics exporter
+type Config struct {
+	// Endpoint of the StatsD agent. It accepts the host:port or udp://host:port formats for UDP,
+	// and unix:///path/to/socket for Unix datagram sockets.
+	Endpoint string `yaml:"endpoint" env:"BEYLA_STATSD_ENDPOINT"`
+	// Prefix that is prepended to the name of all the metrics
+	Prefix string `yaml:"prefix" env:"BEYLA_STATSD_PREFIX"`
+	// FlushInterval is the period in which the metrics are aggregated in the client side
+	// before they are sent to the agent.
+	FlushInterval time.Duration `yaml:"flush_interval" env:"BEYLA_STATSD_FLUSH_INTERVAL"`
+	// MaxPacketSize is the maximum size, in bytes, of each datagram that is sent to the agent
+	MaxPacketSize int `yaml:"max_packet_size" env:"BEYLA_STATSD_MAX_PACKET_SIZE"`
+	// MaxTimingSamples is the maximum number of values that are sent, for each timing series,
+	// in a flush interval. When a series receives more values, they are randomly sampled and
+	// reported with the corresponding sample rate.
+	MaxTimingSamples int `yaml:"max_timing_samples" env:"BEYLA_STATSD_MAX_TIMING_SAMPLES"`
+	// MultiValueTimings sends all the sampled values of a timing series in the same line, with the
+	// DogStatsD multi-value format. Plain StatsD agents don't accept it, so it is disabled by default
+	// and each value is sent in its own line.
+	MultiValueTimings bool `yaml:"multi_value_timings" env:"BEYLA_STATSD_MULTI_VALUE_TIMINGS"`
+
+	ReportTarget   bool `yaml:"report_target" env:"BEYLA_METRICS_REPORT_TARGET"`
+	ReportPeerInfo bool `yaml:"report_peer" env:"BEYLA_METRICS_REPORT_PEER"`
+
+	// AttributeSelection specifies which optional tags are reported for each metrics section.
+	// It needs to be explicitly set up before building the graph
+	AttributeSelection attributes.Selection `yaml:"-"`
+
+	// SemConv version that defines the names of the duration metrics and the tags.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+}
+
+// nolint:gocritic
+func (c Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// network and address of the agent, according to the endpoint format
+func (c *Config) address() (network, address string, err error) {
+	scheme, addr, found := strings.Cut(c.Endpoint, "://")
+	if !found {
+		return "udp", c.Endpoint, nil
+	}
+	switch scheme {
+	case "udp", "udp4", "udp6":
+		return scheme, addr, nil
+	case "unix", "unixgram":
+		return "unixgram", addr, nil
+	}
+	return "", "", fmt.Errorf("unsupported StatsD endpoint scheme %q. Accepted values: udp, unix", scheme)
+}
+
+// series of a metric, identified by its name and tags
+type series struct {
+	name string
+	tags string
+}
+
+// timing values of a series that are sampled between two flushes
+type timing struct {
+	// observed number of values
+	count int64
+	// randomly sampled values, up to the configured maximum number of samples
+	samples []float64
+}
+
+// add a value to the sampled timing values, following the reservoir sampling algorithm
+// so each observed value has the same probability of being reported.
+func (t *timing) add(value float64, maxSamples int) {
+	t.count++
+	if len(t.samples) < maxSamples {
+		t.samples = append(t.samples, value)
+		return
+	}
+	if i := rand.Int63n(t.count); i < int64(maxSamples) {
+		t.samples[i] = value
+	}
+}
+
+// sampleRate returns the ratio of the observed values that are reported
+func (t *timing) sampleRate() float64 {
+	return float64(len(t.samples)) / float64(t.count)
+}
+
+type exporter struct {
+	cfg     *Config
+	network string
+	address string
+	dial    func(network, address string) (net.Conn, error)
+	conn    net.Conn
+	names   *attributes.Names
+
+	// optional tags for each metric section
+	attrHTTP       []attributes.Name
+	attrHTTPClient []attributes.Name
+	attrGRPC       []attributes.Name
+	attrGRPCClient []attributes.Name
+	attrSQL        []attributes.Name
+
+	mt sync.Mutex
+	// client-side aggregation of the metrics between two flushes
+	counters map[series]int64
+	timings  map[series]*timing
+}
+
+// MetricsEndpoint aggregates the metrics of the spans and periodically sends them to
+// the StatsD agent.
+func MetricsEndpoint(ctx context.Context, cfg *Config, ctxInfo *global.ContextInfo) (node.TerminalFunc[[]request.Span], error) {
+	exp, err := newExporter(cfg, ctxInfo)
+	if err != nil {
+		return nil, err
+	}
+	return func(input <-chan []request.Span) {
+		flushCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			exp.flushLoop(flushCtx)
+			close(done)
+		}()
+		for spans := range input {
+			exp.record(spans)
+		}
+		cancel()
+		<-done
+	}, nil
+}
+
+func newExporter(cfg *Config, ctxInfo *global.ContextInfo) (*exporter, error) {
+	if cfg.FlushInterval <= 0 {
+		return nil, fmt.Errorf("statsd_export flush_interval must be positive. Got: %s", cfg.FlushInterval)
+	}
+	if cfg.MaxTimingSamples <= 0 {
+		return nil, fmt.Errorf("statsd_export max_timing_samples must be positive. Got: %d", cfg.MaxTimingSamples)
+	}
+	network, address, err := cfg.address()
+	if err != nil {
+		return nil, err
+	}
+	defaults := prom.DefaultAttributes(&prom.PrometheusConfig{
+		ReportTarget:   cfg.ReportTarget,
+		ReportPeerInfo: cfg.ReportPeerInfo,
+	}, ctxInfo)
+	return &exporter{
+		cfg:            cfg,
+		network:        network,
+		address:        address,
+		dial:           net.Dial,
+		names:          cfg.SemConv.Names(),
+		attrHTTP:       cfg.AttributeSelection.For(attributes.SectionHTTPServer, defaults),
+		attrHTTPClient: cfg.AttributeSelection.For(attributes.SectionHTTPClient, defaults),
+		attrGRPC:       cfg.AttributeSelection.For(attributes.SectionRPCServer, defaults),
+		attrGRPCClient: cfg.AttributeSelection.For(attributes.SectionRPCClient, defaults),
+		attrSQL:        cfg.AttributeSelection.For(attributes.SectionSQLClient, defaults),
+		counters:       map[series]int64{},
+		timings:        map[series]*timing{},
+	}, nil
+}
+
+func (e *exporter) record(spans []request.Span) {
+	e.mt.Lock()
+	defer e.mt.Unlock()
+	for i := range spans {
+		span := &spans[i]
+		var duration, requests string
+		var optional []attributes.Name
+		switch span.Type {
+		case request.EventTypeHTTP:
+			duration, requests, optional = e.names.HTTPServerDuration, HTTPServerRequests, e.attrHTTP
+		case request.EventTypeHTTPClient:
+			duration, requests, optional = e.names.HTTPClientDuration, HTTPClientRequests, e.attrHTTPClient
+		case request.EventTypeGRPC:
+			duration, requests, optional = e.names.RPCServerDuration, RPCServerRequests, e.attrGRPC
+		case request.EventTypeGRPCClient:
+			duration, requests, optional = e.names.RPCClientDuration, RPCClientRequests, e.attrGRPCClient
+		case request.EventTypeSQLClient:
+			duration, requests, optional = e.names.SQLClientDuration, SQLClientRequests, e.attrSQL
+		default:
+			continue
+		}
+		tags := tagsOf(prom.SpanLabels(span, e.cfg.SemConv, optional))
+		t := span.Timings()
+		durationSeries := series{name: duration, tags: tags}
+		tm, ok := e.timings[durationSeries]
+		if !ok {
+			tm = &timing{}
+			e.timings[durationSeries] = tm
+		}
+		tm.add(float64(t.End.Sub(t.RequestStart))/float64(time.Millisecond), e.cfg.MaxTimingSamples)
+		e.counters[series{name: requests, tags: tags}]++
+	}
+}
+
+// tagsOf returns the DogStatsD tags section of a metric line. Tags with empty values are omitted.
+func tagsOf(names, values []string) string {
+	sb := strings.Builder{}
+	for i, name := range names {
+		if values[i] == "" {
+			continue
+		}
+		if sb.Len() > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte(':')
+		sb.WriteString(tagValueReplacer.Replace(values[i]))
+	}
+	return sb.String()
+}
+
+// tagValueReplacer removes the characters that have a special meaning in the DogStatsD protocol
+var tagValueReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+
+func (e *exporter) flushLoop(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.flush()
+			if e.conn != nil {
+				_ = e.conn.Close()
+			}
+			return
+		case <-ticker.C:
+			e.flush()
+		}
+	}
+}
+
+// flush sends the aggregated metrics to the agent and resets them
+func (e *exporter) flush() {
+	e.mt.Lock()
+	counters, timings := e.counters, e.timings
+	e.counters, e.timings = map[series]int64{}, map[series]*timing{}
+	e.mt.Unlock()
+	if len(counters) == 0 && len(timings) == 0 {
+		return
+	}
+	if e.conn == nil {
+		conn, err := e.dial(e.network, e.address)
+		if err != nil {
+			sdlog().Warn("can't connect to the StatsD agent. Discarding metrics", "endpoint", e.cfg.Endpoint, "error", err)
+			return
+		}
+		e.conn = conn
+	}
+	for _, packet := range e.packets(counters, timings) {
+		if _, err := e.conn.Write(packet); err != nil {
+			sdlog().Warn("can't send metrics to the StatsD agent", "endpoint", e.cfg.Endpoint, "error", err)
+			// forcing reconnection in the next flush, as the socket might have been recreated
+			_ = e.conn.Close()
+			e.conn = nil
+			return
+		}
+	}
+}
+
+// packets encodes the metric lines, grouped in datagrams that do not exceed the maximum
+// packet size. A single line larger than the maximum size is sent in its own datagram.
+func (e *exporter) packets(counters map[series]int64, timings map[series]*timing) [][]byte {
+	lines := make([]string, 0, len(counters)+len(timings))
+	for s, count := range counters {
+		lines = append(lines, e.line(s, strconv.FormatInt(count, 10), typeCounter, ""))
+	}
+	for s, t := range timings {
+		lines = append(lines, e.timingLines(s, t)...)
+	}
+	// sorting to provide a deterministic output
+	sort.Strings(lines)
+
+	var packets [][]byte
+	var current []byte
+	for _, line := range lines {
+		if len(current) > 0 && len(current)+1+len(line) > e.cfg.MaxPacketSize {
+			packets = append(packets, current)
+			current = nil
+		}
+		if len(current) > 0 {
+			current = append(current, '\n')
+		}
+		current = append(current, line...)
+	}
+	if len(current) > 0 {
+		packets = append(packets, current)
+	}
+	return packets
+}
+
+// timingLines encodes the sampled values of a timing series, one line per value (name:v|ms).
+// If the multi-value timings are enabled, they are encoded as DogStatsD multi-value
+// lines (name:v1:v2:...|ms), which are split when they don't fit into the maximum packet size.
+func (e *exporter) timingLines(s series, t *timing) []string {
+	var rate string
+	if sr := t.sampleRate(); sr < 1 {
+		rate = strconv.FormatFloat(sr, 'g', 4, 64)
+	}
+	if !e.cfg.MultiValueTimings {
+		lines := make([]string, 0, len(t.samples))
+		for _, v := range t.samples {
+			lines = append(lines, e.line(s, strconv.FormatFloat(v, 'f', -1, 64), typeTiming, rate))
+		}
+		return lines
+	}
+	// length of the line without the values section
+	overhead := len(e.line(s, "", typeTiming, rate))
+	var lines []string
+	values := strings.Builder{}
+	for _, v := range t.samples {
+		value := strconv.FormatFloat(v, 'f', -1, 64)
+		if values.Len() > 0 && overhead+values.Len()+1+len(value) > e.cfg.MaxPacketSize {
+			lines = append(lines, e.line(s, values.String(), typeTiming, rate))
+			values.Reset()
+		}
+		if values.Len() > 0 {
+			values.WriteByte(':')
+		}
+		values.WriteString(value)
+	}
+	if values.Len() > 0 {
+		lines = append(lines, e.line(s, values.String(), typeTiming, rate))
+	}
+	return lines
+}
+
+// line encodes a metric line. The sample rate section is omitted if the rate is empty.
+func (e *exporter) line(s series, value, metricType, rate string) string {
+	line := e.cfg.Prefix + s.name + ":" + value + "|" + metricType
+	if rate != "" {
+		line += "|@" + rate
+	}
+	if s.tags != "" {
+		line += "|#" + s.tags
+	}
+	return line
+}
@@ -0,0 +1,220 @@
+package statsd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/imetrics"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+const timeout = 5 * time.Second
+
+func TestConfig_Address(t *testing.T) {
+	for _, tc := range []struct{ endpoint, network, address string }{
+		{endpoint: "localhost:8125", network: "udp", address: "localhost:8125"},
+		{endpoint: "udp://localhost:8125", network: "udp", address: "localhost:8125"},
+		{endpoint: "udp6://[::1]:8125", network: "udp6", address: "[::1]:8125"},
+		{endpoint: "unix:///var/run/dsd.socket", network: "unixgram", address: "/var/run/dsd.socket"},
+	} {
+		t.Run(tc.endpoint, func(t *testing.T) {
+			network, address, err := (&Config{Endpoint: tc.endpoint}).address()
+			require.NoError(t, err)
+			assert.Equal(t, tc.network, network)
+			assert.Equal(t, tc.address, address)
+		})
+	}
+	_, _, err := (&Config{Endpoint: "tcp://localhost:8125"}).address()
+	assert.Error(t, err)
+}
+
+func TestMetricsEndpoint_UDP(t *testing.T) {
+	agent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer agent.Close()
+
+	lines := runExporter(t, &Config{
+		Endpoint:          agent.LocalAddr().String(),
+		Prefix:            "beyla.",
+		FlushInterval:     time.Hour,
+		MaxPacketSize:     1432,
+		MaxTimingSamples:  100,
+		MultiValueTimings: true,
+		AttributeSelection: attributes.Selection{
+			attributes.SectionHTTPServer: attributes.InclusionLists{Include: []string{"url.path"}},
+		},
+	}, agent, 8)
+
+	assert.Equal(t, []string{
+		"beyla.http.client.request.duration:3|ms|#service_name:frontend,http_request_method:POST,http_response_status_code:500",
+		"beyla.http.client.requests:1|c|#service_name:frontend,http_request_method:POST,http_response_status_code:500",
+		"beyla.http.server.request.duration:2:1.5|ms|#service_name:frontend,service_namespace:shop,http_request_method:GET,http_response_status_code:200,url_path:/foo_bar",
+		"beyla.http.server.requests:2|c|#service_name:frontend,service_namespace:shop,http_request_method:GET,http_response_status_code:200,url_path:/foo_bar",
+		"beyla.rpc.server.duration:1|ms|#service_name:backend,rpc_method:/Get,rpc_system:grpc,rpc_grpc_status_code:0",
+		"beyla.rpc.server.requests:1|c|#service_name:backend,rpc_method:/Get,rpc_system:grpc,rpc_grpc_status_code:0",
+		"beyla.sql.client.duration:4|ms|#service_name:backend,db_operation:SELECT",
+		"beyla.sql.client.requests:1|c|#service_name:backend,db_operation:SELECT",
+	}, lines)
+}
+
+func TestMetricsEndpoint_UnixDatagram(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dsd.socket")
+	agent, err := net.ListenPacket("unixgram", path)
+	require.NoError(t, err)
+	defer agent.Close()
+
+	lines := runExporter(t, &Config{
+		Endpoint:      "unix://" + path,
+		FlushInterval: time.Hour,
+		// forcing each line and timing value to be sent in a different datagram
+		MaxPacketSize:    1,
+		MaxTimingSamples: 100,
+	}, agent, 9)
+	assert.Len(t, lines, 9)
+	assert.Contains(t, lines, "sql.client.requests:1|c|#service_name:backend,db_operation:SELECT")
+	assert.Contains(t, lines, "http.server.request.duration:1.5|ms|#service_name:frontend,service_namespace:shop,http_request_method:GET,http_response_status_code:200")
+}
+
+func TestExporter_TimingsAreSampled(t *testing.T) {
+	exp, err := newExporter(&Config{
+		Endpoint:          "localhost:8125",
+		FlushInterval:     time.Hour,
+		MaxPacketSize:     1432,
+		MaxTimingSamples:  10,
+		MultiValueTimings: true,
+	}, &global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+	require.NoError(t, err)
+
+	ms := int64(time.Millisecond)
+	spans := make([]request.Span, 10000)
+	for i := range spans {
+		spans[i] = request.Span{Type: request.EventTypeHTTP, Method: "GET", Status: 200,
+			RequestStart: 0, End: int64(i+1) * ms, ServiceID: svc.ID{Name: "frontend"}}
+	}
+	exp.record(spans)
+
+	// the number of lines does not depend on the number of requests
+	packets := exp.packets(exp.counters, exp.timings)
+	require.Len(t, packets, 1)
+	lines := strings.Split(string(packets[0]), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, "http.server.request.duration", lines[0][:strings.IndexByte(lines[0], ':')])
+	values, tags, _ := strings.Cut(lines[0][strings.IndexByte(lines[0], ':')+1:], "|")
+	assert.Len(t, strings.Split(values, ":"), 10)
+	assert.Equal(t, "ms|@0.001|#service_name:frontend,http_request_method:GET,http_response_status_code:200", tags)
+	assert.Equal(t, "http.server.requests:10000|c|#service_name:frontend,http_request_method:GET,http_response_status_code:200", lines[1])
+}
+
+func TestExporter_TimingLinesFitIntoPackets(t *testing.T) {
+	exp, err := newExporter(&Config{
+		Endpoint:          "localhost:8125",
+		FlushInterval:     time.Hour,
+		MaxPacketSize:     64,
+		MaxTimingSamples:  100,
+		MultiValueTimings: true,
+	}, &global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+	require.NoError(t, err)
+
+	ms := int64(time.Millisecond)
+	spans := make([]request.Span, 50)
+	for i := range spans {
+		spans[i] = request.Span{Type: request.EventTypeSQLClient, RequestStart: 0, End: int64(i+1000) * ms}
+	}
+	exp.record(spans)
+
+	reported := 0
+	for _, packet := range exp.packets(nil, exp.timings) {
+		assert.LessOrEqual(t, len(packet), 64)
+		for _, line := range strings.Split(string(packet), "\n") {
+			require.True(t, strings.HasPrefix(line, "sql.client.duration:"), line)
+			require.True(t, strings.HasSuffix(line, "|ms"), line)
+			reported += len(strings.Split(strings.TrimSuffix(strings.TrimPrefix(line, "sql.client.duration:"), "|ms"), ":"))
+		}
+	}
+	// no values are sampled out, as they don't exceed the maximum number of samples
+	assert.Equal(t, 50, reported)
+}
+
+func TestExporter_SingleValueTimingLines(t *testing.T) {
+	exp, err := newExporter(&Config{
+		Endpoint:         "localhost:8125",
+		FlushInterval:    time.Hour,
+		MaxPacketSize:    1432,
+		MaxTimingSamples: 2,
+	}, &global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+	require.NoError(t, err)
+
+	ms := int64(time.Millisecond)
+	exp.record([]request.Span{
+		{Type: request.EventTypeSQLClient, RequestStart: 0, End: 3 * ms},
+		{Type: request.EventTypeSQLClient, RequestStart: 0, End: 3 * ms},
+	})
+	assert.Equal(t, []string{"sql.client.duration:3|ms", "sql.client.duration:3|ms"},
+		exp.timingLines(series{name: "sql.client.duration"}, exp.timings[series{name: "sql.client.duration"}]))
+
+	// sampled values are reported with their sample rate
+	exp.record([]request.Span{
+		{Type: request.EventTypeSQLClient, RequestStart: 0, End: 3 * ms},
+		{Type: request.EventTypeSQLClient, RequestStart: 0, End: 3 * ms},
+	})
+	assert.Equal(t, []string{"sql.client.duration:3|ms|@0.5", "sql.client.duration:3|ms|@0.5"},
+		exp.timingLines(series{name: "sql.client.duration"}, exp.timings[series{name: "sql.client.duration"}]))
+}
+
+func TestNewExporter_Errors(t *testing.T) {
+	ctxInfo := &global.ContextInfo{Metrics: imetrics.NoopReporter{}}
+	_, err := MetricsEndpoint(context.Background(), &Config{Endpoint: "localhost:8125"}, ctxInfo)
+	assert.Error(t, err)
+	_, err = MetricsEndpoint(context.Background(),
+		&Config{Endpoint: "http://localhost:8125", FlushInterval: time.Second, MaxTimingSamples: 1}, ctxInfo)
+	assert.Error(t, err)
+	_, err = MetricsEndpoint(context.Background(),
+		&Config{Endpoint: "localhost:8125", FlushInterval: time.Second}, ctxInfo)
+	assert.Error(t, err)
+}
+
+// runExporter submits some spans to an exporter and returns the sorted metric lines
+// that are received by the agent after the exporter is closed.
+func runExporter(t *testing.T, cfg *Config, agent net.PacketConn, expectedLines int) []string {
+	exporter, err := MetricsEndpoint(context.Background(), cfg, &global.ContextInfo{Metrics: imetrics.NoopReporter{}})
+	require.NoError(t, err)
+
+	frontend := svc.ID{Name: "frontend", Namespace: "shop"}
+	backend := svc.ID{Name: "backend"}
+	ms := int64(time.Millisecond)
+	in := make(chan []request.Span, 10)
+	in <- []request.Span{
+		{Type: request.EventTypeHTTP, Method: "GET", Path: "/foo|bar", Status: 200, RequestStart: 10 * ms, End: 12 * ms, ServiceID: frontend},
+		{Type: request.EventTypeHTTP, Method: "GET", Path: "/foo|bar", Status: 200, RequestStart: 10 * ms, End: 10*ms + 1500*int64(time.Microsecond), ServiceID: frontend},
+		{Type: request.EventTypeHTTPClient, Method: "POST", Status: 500, RequestStart: 10 * ms, End: 13 * ms, ServiceID: svc.ID{Name: "frontend"}},
+	}
+	in <- []request.Span{
+		{Type: request.EventTypeGRPC, Path: "/Get", RequestStart: 10 * ms, End: 11 * ms, ServiceID: backend},
+		{Type: request.EventTypeSQLClient, Method: "SELECT", RequestStart: 10 * ms, End: 14 * ms, ServiceID: backend},
+	}
+	close(in)
+	// the metrics are flushed when the input channel is closed
+	exporter(in)
+
+	var lines []string
+	buf := make([]byte, 65536)
+	require.NoError(t, agent.SetReadDeadline(time.Now().Add(timeout)))
+	for len(lines) < expectedLines {
+		n, _, err := agent.ReadFrom(buf)
+		require.NoError(t, err)
+		lines = append(lines, strings.Split(string(buf[:n]), "\n")...)
+	}
+	sort.Strings(lines)
+	return lines
+}
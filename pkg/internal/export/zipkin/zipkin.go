@@ -0,0 +1,263 @@
+// Package zipkin provides an exporter that submits the traces to a Zipkin-compatible
+// endpoint, in Zipkin v2 JSON format.
+package zipkin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/buildinfo"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
+	"github.com/grafana/beyla/pkg/internal/export/otel"
+	"github.com/grafana/beyla/pkg/internal/request"
+)
+
+func zlog() *slog.Logger {
+	return slog.With("component", "zipkin.Exporter")
+}
+
+// defaultPath of the Zipkin v2 spans API, used if the endpoint URL does not specify any path
+const defaultPath = "/api/v2/spans"
+
+// minQueueSize of the spans pending to be exported. It's the default value of the OTEL SDK.
+const minQueueSize = 2048
+
+// Config of the Zipkin traces exporter
+type Config struct {
+	// Endpoint of the Zipkin v2 spans API (e.g. http://zipkin:9411/api/v2/spans)
+	Endpoint string `yaml:"endpoint" env:"BEYLA_ZIPKIN_ENDPOINT"`
+	// Headers that are added to each request (e.g. Authorization)
+	Headers map[string]string `yaml:"headers" env:"BEYLA_ZIPKIN_HEADERS"`
+	// Timeout of each export request
+	Timeout time.Duration `yaml:"timeout" env:"BEYLA_ZIPKIN_TIMEOUT"`
+	// BatchSize is the maximum number of spans that are submitted in each request
+	BatchSize int `yaml:"batch_size" env:"BEYLA_ZIPKIN_BATCH_SIZE"`
+	// BatchTimeout is the maximum time the spans are buffered before they are submitted
+	BatchTimeout time.Duration `yaml:"batch_timeout" env:"BEYLA_ZIPKIN_BATCH_TIMEOUT"`
+
+	Sampler otel.Sampler `yaml:"sampler"`
+
+	ReportersCacheLen int `yaml:"reporters_cache_len" env:"BEYLA_TRACES_REPORT_CACHE_LEN"`
+
+	// SemConv version that defines the names of the span tags.
+	// It needs to be explicitly set up before building the graph
+	SemConv attributes.SemConvVersion `yaml:"-"`
+}
+
+// nolint:gocritic
+func (c Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// tracesConfig returns the configuration of the OTEL traces reporter that builds the spans
+func (c *Config) tracesConfig() *otel.TracesConfig {
+	return &otel.TracesConfig{
+		Sampler:            c.Sampler,
+		MaxExportBatchSize: c.BatchSize,
+		MaxQueueSize:       max(c.BatchSize, minQueueSize),
+		BatchTimeout:       c.BatchTimeout,
+		ExportTimeout:      c.Timeout,
+		ReportersCacheLen:  c.ReportersCacheLen,
+		SemConv:            c.SemConv,
+	}
+}
+
+// TracesEndpoint builds the spans with the same logic as the OTEL traces reporter, so
+// they have the same names and attributes, and submits them in Zipkin v2 JSON format.
+func TracesEndpoint(ctx context.Context, cfg *Config) (node.TerminalFunc[[]request.Span], error) {
+	exporter, err := newExporter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return otel.ReportTracesTo(ctx, cfg.tracesConfig(), exporter), nil
+}
+
+// Exporter submits the OpenTelemetry SDK spans in Zipkin v2 JSON format
+type Exporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	names   *attributes.Names
+}
+
+func newExporter(cfg *Config) (*Exporter, error) {
+	eurl, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("parsing zipkin endpoint URL %s: %w", cfg.Endpoint, err)
+	}
+	if eurl.Scheme == "" || eurl.Host == "" {
+		return nil, fmt.Errorf("zipkin endpoint URL %q must have a scheme and a host", cfg.Endpoint)
+	}
+	if eurl.Path == "" || eurl.Path == "/" {
+		eurl.Path = defaultPath
+	}
+	return &Exporter{
+		url:     eurl.String(),
+		headers: cfg.Headers,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		names:   cfg.SemConv.Names(),
+	}, nil
+}
+
+func (e *Exporter) ExportSpans(ctx context.Context, spans []trace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	zspans := make([]Span, 0, len(spans))
+	for _, s := range spans {
+		zspans = append(zspans, toZipkin(s, e.names))
+	}
+	body, err := json.Marshal(zspans)
+	if err != nil {
+		return fmt.Errorf("encoding zipkin spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating zipkin request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "beyla/"+buildinfo.Version)
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending zipkin spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	err = fmt.Errorf("zipkin endpoint returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	zlog().Debug("error submitting spans", "error", err, "len", len(spans))
+	return err
+}
+
+func (e *Exporter) Shutdown(_ context.Context) error {
+	e.client.CloseIdleConnections()
+	return nil
+}
+
+// Span in Zipkin v2 format: https://zipkin.io/zipkin-api/#/default/post_spans
+type Span struct {
+	TraceID        string            `json:"traceId"`
+	ID             string            `json:"id"`
+	ParentID       string            `json:"parentId,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Kind           string            `json:"kind,omitempty"`
+	Timestamp      int64             `json:"timestamp,omitempty"`
+	Duration       int64             `json:"duration,omitempty"`
+	LocalEndpoint  *Endpoint         `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *Endpoint         `json:"remoteEndpoint,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+type Endpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+func toZipkin(s trace.ReadOnlySpan, names *attributes.Names) Span {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range s.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	zs := Span{
+		TraceID:   s.SpanContext().TraceID().String(),
+		ID:        s.SpanContext().SpanID().String(),
+		Name:      s.Name(),
+		Timestamp: s.StartTime().UnixMicro(),
+		Duration:  s.EndTime().Sub(s.StartTime()).Microseconds(),
+		Tags:      make(map[string]string, len(attrs)),
+	}
+	if s.Parent().SpanID().IsValid() {
+		zs.ParentID = s.Parent().SpanID().String()
+	}
+	// zipkin does not accept zero durations, so we round them up to the minimum unit
+	if zs.Duration <= 0 {
+		zs.Duration = 1
+	}
+
+	zs.LocalEndpoint = &Endpoint{}
+	// the service information, which is stored as resource attributes in OTEL, is
+	// set as the local endpoint service name and the tags
+	for _, kv := range s.Resource().Attributes() {
+		if kv.Key == semconv.ServiceNameKey {
+			zs.LocalEndpoint.ServiceName = kv.Value.Emit()
+		} else {
+			zs.Tags[string(kv.Key)] = kv.Value.Emit()
+		}
+	}
+	for k, v := range attrs {
+		zs.Tags[string(k)] = v.Emit()
+	}
+
+	switch s.SpanKind() {
+	case trace2.SpanKindServer:
+		zs.Kind = "SERVER"
+		setAddress(zs.LocalEndpoint, attrs[attribute.Key(names.HostAddr)], attrs[attribute.Key(names.HostPort)])
+		zs.RemoteEndpoint = remoteEndpoint(attrs[attribute.Key(names.ClientAddr)], attrs[attribute.Key(names.ClientPort)])
+	case trace2.SpanKindClient:
+		zs.Kind = "CLIENT"
+		zs.RemoteEndpoint = remoteEndpoint(attrs[attribute.Key(names.ServerAddr)], attrs[attribute.Key(names.ServerPort)])
+	}
+
+	if s.Status().Code == codes.Error {
+		zs.Tags["otel.status_code"] = "ERROR"
+		zs.Tags["error"] = s.Status().Description
+		if zs.Tags["error"] == "" {
+			zs.Tags["error"] = "true"
+		}
+	}
+	return zs
+}
+
+func remoteEndpoint(addr, port attribute.Value) *Endpoint {
+	ep := &Endpoint{}
+	if !setAddress(ep, addr, port) && addr.AsString() != "" {
+		// the remote address is a host name, which is reported as the service name of the remote endpoint
+		ep.ServiceName = addr.AsString()
+	}
+	if *ep == (Endpoint{}) {
+		return nil
+	}
+	return ep
+}
+
+// setAddress sets the IP and port of the endpoint. It returns false if the
+// address is not a valid IP.
+func setAddress(ep *Endpoint, addr, port attribute.Value) bool {
+	if p, err := strconv.Atoi(port.Emit()); err == nil && p > 0 {
+		ep.Port = p
+	}
+	ip := net.ParseIP(addr.AsString())
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ep.IPv4 = ip4.String()
+	} else {
+		ep.IPv6 = ip.String()
+	}
+	return true
+}
@@ -0,0 +1,119 @@
+package zipkin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	trace2 "go.opentelemetry.io/otel/trace"
+
+	"github.com/grafana/beyla/pkg/internal/request"
+	"github.com/grafana/beyla/pkg/internal/svc"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+const timeout = 5 * time.Second
+
+func TestTracesEndpoint(t *testing.T) {
+	received := make(chan []Span, 10)
+	zipkin := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, defaultPath, req.URL.Path)
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer foo", req.Header.Get("Authorization"))
+		var spans []Span
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&spans))
+		received <- spans
+	}))
+	defer zipkin.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	node, err := TracesEndpoint(ctx, &Config{
+		Endpoint:          zipkin.URL,
+		Headers:           map[string]string{"Authorization": "Bearer foo"},
+		BatchTimeout:      10 * time.Millisecond,
+		ReportersCacheLen: 16,
+	})
+	require.NoError(t, err)
+
+	traceID := trace2.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	start := time.Now().UnixNano()
+	input := make(chan []request.Span, 1)
+	go node(input)
+	input <- []request.Span{{
+		Type: request.EventTypeHTTP, Method: "GET", Path: "/users/1234", Route: "/users/{id}", Status: 500,
+		Peer: "10.0.0.1", Host: "10.0.0.2", HostPort: 8080,
+		RequestStart: start, Start: start + int64(time.Millisecond), End: start + int64(3*time.Millisecond),
+		TraceID: traceID, SpanID: trace2.SpanID{1, 2, 3, 4, 5, 6, 7, 8},
+		ServiceID: svc.ID{Name: "users", Namespace: "shop", UID: "users"},
+	}, {
+		Type: request.EventTypeHTTPClient, Method: "POST", Path: "/login", Status: 200,
+		Host: "auth-service", HostPort: 9090,
+		RequestStart: start, Start: start, End: start + int64(2*time.Millisecond),
+		TraceID: traceID, SpanID: trace2.SpanID{8, 7, 6, 5, 4, 3, 2, 1},
+		ServiceID: svc.ID{Name: "users", Namespace: "shop", UID: "users"},
+	}}
+
+	spans := map[string]Span{}
+	for len(spans) < 4 {
+		for _, s := range testutil.ReadChannel(t, received, timeout) {
+			spans[s.Name] = s
+		}
+	}
+	close(input)
+
+	server := spans["GET /users/{id}"]
+	assert.Equal(t, traceID.String(), server.TraceID)
+	assert.Equal(t, "SERVER", server.Kind)
+	assert.Empty(t, server.ParentID)
+	assert.Equal(t, int64(3000), server.Duration)
+	assert.Equal(t, &Endpoint{ServiceName: "users", IPv4: "10.0.0.2", Port: 8080}, server.LocalEndpoint)
+	assert.Equal(t, &Endpoint{IPv4: "10.0.0.1"}, server.RemoteEndpoint)
+	assert.Equal(t, "/users/{id}", server.Tags["http.route"])
+	assert.Equal(t, "500", server.Tags["http.response.status_code"])
+	assert.Equal(t, "shop", server.Tags["service.namespace"])
+	assert.Equal(t, "true", server.Tags["error"])
+
+	// the inner spans are the same as in the OTEL traces exporter
+	for _, name := range []string{"in queue", "processing"} {
+		inner := spans[name]
+		assert.Equal(t, traceID.String(), inner.TraceID)
+		assert.Equal(t, server.ID, inner.ParentID)
+		assert.Empty(t, inner.Kind)
+		assert.Nil(t, inner.RemoteEndpoint)
+	}
+	assert.Equal(t, "0102030405060708", spans["processing"].ID)
+
+	client := spans["POST"]
+	assert.Equal(t, "CLIENT", client.Kind)
+	assert.Equal(t, "0807060504030201", client.ID)
+	assert.Equal(t, &Endpoint{ServiceName: "users"}, client.LocalEndpoint)
+	assert.Equal(t, &Endpoint{ServiceName: "auth-service", Port: 9090}, client.RemoteEndpoint)
+	assert.NotContains(t, client.Tags, "error")
+}
+
+func TestExporter_Errors(t *testing.T) {
+	zipkin := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusBadRequest)
+		_, _ = rw.Write([]byte("wrong span"))
+	}))
+	defer zipkin.Close()
+
+	_, err := newExporter(&Config{Endpoint: "zipkin:9411"})
+	require.Error(t, err)
+
+	exp, err := newExporter(&Config{Endpoint: zipkin.URL + "/custom/path"})
+	require.NoError(t, err)
+	assert.Equal(t, zipkin.URL+"/custom/path", exp.url)
+	require.NoError(t, exp.ExportSpans(context.Background(), nil))
+	err = exp.ExportSpans(context.Background(), []trace.ReadOnlySpan{tracetest.SpanStub{Name: "foo"}.Snapshot()})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "wrong span")
+}
@@ -26,17 +26,36 @@ type Reporter interface {
 	OTELTraceExport(i int)
 	// OTELTraceExportError is invoked every time the OpenTelemetry Traces export fails with an error
 	OTELTraceExportError(err error)
+	// OTELLogExport is invoked every time the OpenTelemetry Logs exporter successfully exports log records to
+	// a remote collector. It accounts the length, in log records, for each invocation.
+	OTELLogExport(len int)
+	// OTELLogExportError is invoked every time the OpenTelemetry Logs export fails with an error
+	OTELLogExportError(err error)
 	// PrometheusRequest is invoked every time the Prometheus exporter is invoked, for a given port and path
 	PrometheusRequest(port, path string)
+	// PrometheusRemoteWrite is invoked every time the Prometheus remote write exporter successfully pushes
+	// metrics to the remote endpoint. It accounts the length, in time series, for each invocation.
+	PrometheusRemoteWrite(len int)
+	// PrometheusRemoteWriteError is invoked every time a Prometheus remote write push fails. The reason
+	// is a bounded value that classifies the error (e.g. the HTTP status code, or "timeout")
+	PrometheusRemoteWriteError(reason string)
+	// CardinalityLimitHit is invoked every time an observation for a given metric and service is aggregated
+	// into the overflow series because the cardinality limits have been exceeded
+	CardinalityLimitHit(metric, service string)
 }
 
 // NoopReporter is a metrics Reporter that just does nothing
 type NoopReporter struct{}
 
-func (n NoopReporter) Start(_ context.Context)       {}
-func (n NoopReporter) TracerFlush(_ int)             {}
-func (n NoopReporter) OTELMetricExport(_ int)        {}
-func (n NoopReporter) OTELMetricExportError(_ error) {}
-func (n NoopReporter) OTELTraceExport(_ int)         {}
-func (n NoopReporter) OTELTraceExportError(_ error)  {}
-func (n NoopReporter) PrometheusRequest(_, _ string) {}
+func (n NoopReporter) Start(_ context.Context)             {}
+func (n NoopReporter) TracerFlush(_ int)                   {}
+func (n NoopReporter) OTELMetricExport(_ int)              {}
+func (n NoopReporter) OTELMetricExportError(_ error)       {}
+func (n NoopReporter) OTELTraceExport(_ int)               {}
+func (n NoopReporter) OTELTraceExportError(_ error)        {}
+func (n NoopReporter) OTELLogExport(_ int)                 {}
+func (n NoopReporter) OTELLogExportError(_ error)          {}
+func (n NoopReporter) PrometheusRequest(_, _ string)       {}
+func (n NoopReporter) PrometheusRemoteWrite(_ int)         {}
+func (n NoopReporter) PrometheusRemoteWriteError(_ string) {}
+func (n NoopReporter) CardinalityLimitHit(_, _ string)     {}
@@ -27,7 +27,12 @@ type PrometheusReporter struct {
 	otelMetricExportErrs *prometheus.CounterVec
 	otelTraceExports     prometheus.Counter
 	otelTraceExportErrs  *prometheus.CounterVec
+	otelLogExports       prometheus.Counter
+	otelLogExportErrs    *prometheus.CounterVec
 	prometheusRequests   *prometheus.CounterVec
+	remoteWrites         prometheus.Counter
+	remoteWriteErrs      *prometheus.CounterVec
+	cardinalityLimitHits *prometheus.CounterVec
 }
 
 func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusManager) *PrometheusReporter {
@@ -57,10 +62,30 @@ func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusM
 			Name: "otel_trace_export_errors",
 			Help: "error count on each failed OTEL trace export",
 		}, []string{"error"}),
+		otelLogExports: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "otel_log_exports",
+			Help: "length of the log record batches submitted to the remote OTEL collector",
+		}),
+		otelLogExportErrs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "otel_log_export_errors",
+			Help: "error count on each failed OTEL log export",
+		}, []string{"error"}),
 		prometheusRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Name: "prometheus_http_requests",
 			Help: "requests towards the Prometheus Scrape endpoint",
 		}, []string{"port", "path"}),
+		remoteWrites: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_remote_writes",
+			Help: "length of the time series batches pushed to the Prometheus remote write endpoint",
+		}),
+		remoteWriteErrs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "prometheus_remote_write_errors",
+			Help: "error count on each failed Prometheus remote write push, by HTTP status code or error class",
+		}, []string{"error"}),
+		cardinalityLimitHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cardinality_limit_hits",
+			Help: "observations aggregated into the overflow series because the cardinality limits were exceeded",
+		}, []string{"metric", "service"}),
 	}
 	manager.Register(cfg.Port, cfg.Path,
 		pr.tracerFlushes,
@@ -68,7 +93,12 @@ func NewPrometheusReporter(cfg *PrometheusConfig, manager *connector.PrometheusM
 		pr.otelMetricExportErrs,
 		pr.otelTraceExports,
 		pr.otelTraceExportErrs,
-		pr.prometheusRequests)
+		pr.otelLogExports,
+		pr.otelLogExportErrs,
+		pr.prometheusRequests,
+		pr.remoteWrites,
+		pr.remoteWriteErrs,
+		pr.cardinalityLimitHits)
 
 	return pr
 }
@@ -97,6 +127,26 @@ func (p *PrometheusReporter) OTELTraceExportError(err error) {
 	p.otelTraceExportErrs.WithLabelValues(err.Error()).Inc()
 }
 
+func (p *PrometheusReporter) OTELLogExport(len int) {
+	p.otelLogExports.Add(float64(len))
+}
+
+func (p *PrometheusReporter) OTELLogExportError(err error) {
+	p.otelLogExportErrs.WithLabelValues(err.Error()).Inc()
+}
+
 func (p *PrometheusReporter) PrometheusRequest(port, path string) {
 	p.prometheusRequests.WithLabelValues(port, path).Inc()
 }
+
+func (p *PrometheusReporter) PrometheusRemoteWrite(len int) {
+	p.remoteWrites.Add(float64(len))
+}
+
+func (p *PrometheusReporter) PrometheusRemoteWriteError(reason string) {
+	p.remoteWriteErrs.WithLabelValues(reason).Inc()
+}
+
+func (p *PrometheusReporter) CardinalityLimitHit(metric, service string) {
+	p.cardinalityLimitHits.WithLabelValues(metric, service).Inc()
+}
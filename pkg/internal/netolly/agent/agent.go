@@ -32,6 +32,7 @@ import (
 	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
 	"github.com/grafana/beyla/pkg/internal/netolly/flow"
 	"github.com/grafana/beyla/pkg/internal/netolly/ifaces"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
 )
 
 const (
@@ -85,7 +86,8 @@ func (s Status) String() string {
 
 // Flows reporting agent
 type Flows struct {
-	cfg *beyla.Config
+	cfg     *beyla.Config
+	ctxInfo *global.ContextInfo
 
 	// input data providers
 	interfaces ifaces.Informer
@@ -101,6 +103,10 @@ type Flows struct {
 	interfaceNamer flow.InterfaceNamer
 	agentIP        net.IP
 
+	// kernelFilters is the number of filter rules that are already evaluated by the eBPF program.
+	// The rest of the rules are evaluated in the user space.
+	kernelFilters int
+
 	status Status
 }
 
@@ -110,11 +116,13 @@ type ebpfFlowFetcher interface {
 	Register(iface ifaces.Interface) error
 
 	LookupAndDeleteMap() map[ebpf.NetFlowId][]ebpf.NetFlowMetrics
+	LookupAndDeleteTCPStats() map[ebpf.TCPConnID]ebpf.TCPStats
 	ReadRingBuf() (ringbuf.Record, error)
 }
 
-// FlowsAgent instantiates a new agent, given a configuration.
-func FlowsAgent(cfg *beyla.Config) (*Flows, error) {
+// FlowsAgent instantiates a new agent, given a configuration and the context information
+// that is shared with other Beyla components.
+func FlowsAgent(ctxInfo *global.ContextInfo, cfg *beyla.Config) (*Flows, error) {
 	alog := alog()
 	alog.Info("initializing Flows agent")
 
@@ -149,16 +157,27 @@ func FlowsAgent(cfg *beyla.Config) (*Flows, error) {
 
 	ingress, egress := flowDirections(&cfg.NetworkFlows)
 
-	fetcher, err := ebpf.NewFlowFetcher(cfg.NetworkFlows.Sampling, cfg.NetworkFlows.CacheMaxFlows, ingress, egress)
+	filters, err := cfg.NetworkFlows.Filters.KernelRules()
+	if err != nil {
+		return nil, fmt.Errorf("configuring flow filters: %w", err)
+	}
+	fetcher, err := ebpf.NewFlowFetcher(cfg.NetworkFlows.Sampling, cfg.NetworkFlows.CacheMaxFlows, ingress, egress, filters)
 	if err != nil {
 		return nil, err
 	}
 
-	return flowsAgent(cfg, informer, fetcher, exportFunc, agentIP)
+	flows, err := flowsAgent(ctxInfo, cfg, informer, captureFetcher(ctxInfo, fetcher), exportFunc, agentIP)
+	if err != nil {
+		return nil, err
+	}
+	flows.kernelFilters = fetcher.KernelFilters()
+	return flows, nil
 }
 
 // flowsAgent is a private constructor with injectable dependencies, usable for tests
-func flowsAgent(cfg *beyla.Config,
+func flowsAgent(
+	ctxInfo *global.ContextInfo,
+	cfg *beyla.Config,
 	informer ifaces.Informer,
 	fetcher ebpfFlowFetcher,
 	exporter node.TerminalFunc[[]*ebpf.Record],
@@ -188,6 +207,7 @@ func flowsAgent(cfg *beyla.Config,
 		interfaces:     registerer,
 		filter:         filter,
 		cfg:            cfg,
+		ctxInfo:        ctxInfo,
 		mapTracer:      mapTracer,
 		rbTracer:       rbTracer,
 		agentIP:        agentIP,
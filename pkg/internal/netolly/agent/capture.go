@@ -0,0 +1,302 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/ringbuf"
+
+	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/capture"
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/ifaces"
+	"github.com/grafana/beyla/pkg/internal/pipe/global"
+)
+
+// capturingFetcher records the raw flows and the interfaces that are read from the
+// eBPF flow fetcher, so they can be replayed later.
+type capturingFetcher struct {
+	ebpfFlowFetcher
+	recorder *capture.Recorder
+}
+
+func (cf *capturingFetcher) Register(iface ifaces.Interface) error {
+	if err := cf.ebpfFlowFetcher.Register(iface); err != nil {
+		return err
+	}
+	cf.recorder.RecordEvent(capture.TracerNetIface, iface)
+	return nil
+}
+
+func (cf *capturingFetcher) ReadRingBuf() (ringbuf.Record, error) {
+	record, err := cf.ebpfFlowFetcher.ReadRingBuf()
+	if err == nil {
+		cf.recorder.Record(capture.TracerNetRingbuf, record.RawSample)
+	}
+	return record, err
+}
+
+func (cf *capturingFetcher) LookupAndDeleteMap() map[ebpf.NetFlowId][]ebpf.NetFlowMetrics {
+	flows := cf.ebpfFlowFetcher.LookupAndDeleteMap()
+	raw, err := encodeFlowsMap(flows)
+	if err != nil {
+		alog().Warn("can't capture evicted flows", "error", err)
+	} else {
+		cf.recorder.Record(capture.TracerNetMap, raw)
+	}
+	return flows
+}
+
+func (cf *capturingFetcher) LookupAndDeleteTCPStats() map[ebpf.TCPConnID]ebpf.TCPStats {
+	stats := cf.ebpfFlowFetcher.LookupAndDeleteTCPStats()
+	if len(stats) == 0 {
+		return stats
+	}
+	raw, err := encodeTCPStats(stats)
+	if err != nil {
+		alog().Warn("can't capture evicted TCP statistics", "error", err)
+	} else {
+		cf.recorder.Record(capture.TracerNetTCP, raw)
+	}
+	return stats
+}
+
+// encodeFlowsMap encodes the contents of the flows map in little endian. Each entry is encoded as
+// the flow ID, followed by the number of per-CPU metrics, followed by the metrics.
+func encodeFlowsMap(flows map[ebpf.NetFlowId][]ebpf.NetFlowMetrics) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for id, metrics := range flows {
+		if err := binary.Write(&buf, binary.LittleEndian, &id); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buf, binary.LittleEndian, uint32(len(metrics))); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buf, binary.LittleEndian, metrics); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeFlowsMap(raw []byte) (map[ebpf.NetFlowId][]ebpf.NetFlowMetrics, error) {
+	flows := map[ebpf.NetFlowId][]ebpf.NetFlowMetrics{}
+	in := bytes.NewReader(raw)
+	for in.Len() > 0 {
+		var id ebpf.NetFlowId
+		if err := binary.Read(in, binary.LittleEndian, &id); err != nil {
+			return nil, fmt.Errorf("decoding flow ID: %w", err)
+		}
+		var n uint32
+		if err := binary.Read(in, binary.LittleEndian, &n); err != nil {
+			return nil, fmt.Errorf("decoding flow metrics length: %w", err)
+		}
+		metrics := make([]ebpf.NetFlowMetrics, n)
+		if err := binary.Read(in, binary.LittleEndian, metrics); err != nil {
+			return nil, fmt.Errorf("decoding flow metrics: %w", err)
+		}
+		flows[id] = append(flows[id], metrics...)
+	}
+	return flows, nil
+}
+
+// encodeTCPStats encodes the contents of the TCP statistics map in little endian. Each entry
+// is encoded as the connection ID, followed by its statistics.
+func encodeTCPStats(stats map[ebpf.TCPConnID]ebpf.TCPStats) ([]byte, error) {
+	buf := bytes.Buffer{}
+	for id, st := range stats {
+		if err := binary.Write(&buf, binary.LittleEndian, &id); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buf, binary.LittleEndian, &st); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeTCPStats(raw []byte) (map[ebpf.TCPConnID]ebpf.TCPStats, error) {
+	stats := map[ebpf.TCPConnID]ebpf.TCPStats{}
+	in := bytes.NewReader(raw)
+	for in.Len() > 0 {
+		var id ebpf.TCPConnID
+		if err := binary.Read(in, binary.LittleEndian, &id); err != nil {
+			return nil, fmt.Errorf("decoding TCP connection ID: %w", err)
+		}
+		var st ebpf.TCPStats
+		if err := binary.Read(in, binary.LittleEndian, &st); err != nil {
+			return nil, fmt.Errorf("decoding TCP statistics: %w", err)
+		}
+		stats[id] = st
+	}
+	return stats, nil
+}
+
+// NetReplay forwards the captured flows through the same decoding and processing
+// functions as the flows agent, without any eBPF.
+type NetReplay struct {
+	flows *Flows
+
+	ifaces  chan ifaces.Event
+	records chan ringbuf.Record
+	done    chan struct{}
+	closed  sync.Once
+
+	mt sync.Mutex
+	// evicted flows that are pending to be read by the map tracer
+	evicted map[ebpf.NetFlowId][]ebpf.NetFlowMetrics
+	// evicted TCP statistics that are pending to be read by the map tracer
+	tcpStats map[ebpf.TCPConnID]ebpf.TCPStats
+}
+
+// FlowsReplay instantiates a flows agent whose flows are read from the captured records that are
+// fed into the returned NetReplay.
+func FlowsReplay(ctxInfo *global.ContextInfo, cfg *beyla.Config) (*Flows, *NetReplay, error) {
+	nr := &NetReplay{
+		ifaces:   make(chan ifaces.Event, cfg.ChannelBufferLen),
+		records:  make(chan ringbuf.Record),
+		done:     make(chan struct{}),
+		evicted:  map[ebpf.NetFlowId][]ebpf.NetFlowMetrics{},
+		tcpStats: map[ebpf.TCPConnID]ebpf.TCPStats{},
+	}
+	exportFunc, err := buildFlowExporter(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	agentIP, err := fetchAgentIP(&cfg.NetworkFlows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("acquiring Agent IP: %w", err)
+	}
+	flows, err := flowsAgent(ctxInfo, cfg, nr, nr, exportFunc, agentIP)
+	if err != nil {
+		return nil, nil, err
+	}
+	nr.flows = flows
+	return flows, nr, nil
+}
+
+// Feed a captured record into the replay. It blocks until the record is read by the agent,
+// or the replay is closed.
+func (nr *NetReplay) Feed(rec *capture.Record, offset time.Duration) error {
+	switch rec.Tracer {
+	case capture.TracerNetIface:
+		iface := ifaces.Interface{}
+		if err := json.Unmarshal(rec.Event, &iface); err != nil {
+			return fmt.Errorf("decoding captured interface: %w", err)
+		}
+		select {
+		case nr.ifaces <- ifaces.Event{Type: ifaces.EventAdded, Interface: iface}:
+		case <-nr.done:
+		}
+	case capture.TracerNetRingbuf:
+		flow, err := ebpf.ReadFrom(bytes.NewReader(rec.Raw))
+		if err != nil {
+			return fmt.Errorf("decoding captured flow: %w", err)
+		}
+		shiftTimes(&flow.Metrics, offset)
+		buf := bytes.Buffer{}
+		if err := binary.Write(&buf, binary.LittleEndian, &flow); err != nil {
+			return fmt.Errorf("encoding replayed flow: %w", err)
+		}
+		select {
+		case nr.records <- ringbuf.Record{RawSample: buf.Bytes()}:
+		case <-nr.done:
+		}
+	case capture.TracerNetMap:
+		flows, err := decodeFlowsMap(rec.Raw)
+		if err != nil {
+			return err
+		}
+		nr.mt.Lock()
+		for id, metrics := range flows {
+			for i := range metrics {
+				shiftTimes(&metrics[i], offset)
+			}
+			nr.evicted[id] = append(nr.evicted[id], metrics...)
+		}
+		nr.mt.Unlock()
+		nr.flows.mapTracer.Flush()
+	case capture.TracerNetTCP:
+		// TCP statistics are captured before their flows, so they are just
+		// stored until the next flows eviction
+		stats, err := decodeTCPStats(rec.Raw)
+		if err != nil {
+			return err
+		}
+		nr.mt.Lock()
+		for id, st := range stats {
+			nr.tcpStats[id] = st
+		}
+		nr.mt.Unlock()
+	default:
+		return fmt.Errorf("unexpected tracer for network replay: %q", rec.Tracer)
+	}
+	return nil
+}
+
+// shiftTimes of the flow from the monotonic clock of the capture to the monotonic clock of the replay
+func shiftTimes(metrics *ebpf.NetFlowMetrics, offset time.Duration) {
+	if metrics.StartMonoTimeNs != 0 {
+		metrics.StartMonoTimeNs = uint64(int64(metrics.StartMonoTimeNs) + int64(offset))
+	}
+	if metrics.EndMonoTimeNs != 0 {
+		metrics.EndMonoTimeNs = uint64(int64(metrics.EndMonoTimeNs) + int64(offset))
+	}
+}
+
+// Subscribe implements ifaces.Informer, providing the captured interfaces
+func (nr *NetReplay) Subscribe(_ context.Context) (<-chan ifaces.Event, error) {
+	return nr.ifaces, nil
+}
+
+// Register implements ebpfFlowFetcher. The captured flows are already filtered by interface.
+func (nr *NetReplay) Register(_ ifaces.Interface) error {
+	return nil
+}
+
+func (nr *NetReplay) LookupAndDeleteMap() map[ebpf.NetFlowId][]ebpf.NetFlowMetrics {
+	nr.mt.Lock()
+	defer nr.mt.Unlock()
+	evicted := nr.evicted
+	nr.evicted = map[ebpf.NetFlowId][]ebpf.NetFlowMetrics{}
+	return evicted
+}
+
+func (nr *NetReplay) LookupAndDeleteTCPStats() map[ebpf.TCPConnID]ebpf.TCPStats {
+	nr.mt.Lock()
+	defer nr.mt.Unlock()
+	stats := nr.tcpStats
+	nr.tcpStats = map[ebpf.TCPConnID]ebpf.TCPStats{}
+	return stats
+}
+
+func (nr *NetReplay) ReadRingBuf() (ringbuf.Record, error) {
+	select {
+	case rec := <-nr.records:
+		return rec, nil
+	case <-nr.done:
+		return ringbuf.Record{}, ringbuf.ErrClosed
+	}
+}
+
+func (nr *NetReplay) Close() error {
+	nr.closed.Do(func() {
+		close(nr.done)
+	})
+	return nil
+}
+
+// captureFetcher wraps the fetcher with a capturingFetcher, if capture is enabled
+func captureFetcher(ctxInfo *global.ContextInfo, fetcher ebpfFlowFetcher) ebpfFlowFetcher {
+	if ctxInfo.Capture == nil {
+		return fetcher
+	}
+	slog.Debug("capturing raw network flows")
+	return &capturingFetcher{ebpfFlowFetcher: fetcher, recorder: ctxInfo.Capture}
+}
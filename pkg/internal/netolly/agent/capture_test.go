@@ -0,0 +1,56 @@
+package agent
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+func TestEncodeDecodeFlowsMap(t *testing.T) {
+	id1, id2 := ebpf.NetFlowId{SrcPort: 123, DstPort: 456}, ebpf.NetFlowId{SrcPort: 789, DstPort: 80}
+	flows := map[ebpf.NetFlowId][]ebpf.NetFlowMetrics{
+		id1: {{Bytes: 10, Packets: 1}, {Bytes: 20, Packets: 2}},
+		id2: {{Bytes: 30, Packets: 3, StartMonoTimeNs: 100, EndMonoTimeNs: 200}},
+	}
+	raw, err := encodeFlowsMap(flows)
+	require.NoError(t, err)
+	decoded, err := decodeFlowsMap(raw)
+	require.NoError(t, err)
+	assert.Equal(t, flows, decoded)
+
+	_, err = decodeFlowsMap(raw[:len(raw)-1])
+	require.Error(t, err)
+}
+
+func TestShiftTimes(t *testing.T) {
+	metrics := ebpf.NetFlowMetrics{StartMonoTimeNs: 100, EndMonoTimeNs: 200}
+	shiftTimes(&metrics, time.Microsecond)
+	assert.Equal(t, ebpf.NetFlowMetrics{StartMonoTimeNs: 1100, EndMonoTimeNs: 1200}, metrics)
+
+	// unset times are not shifted
+	metrics = ebpf.NetFlowMetrics{}
+	shiftTimes(&metrics, time.Microsecond)
+	assert.Equal(t, ebpf.NetFlowMetrics{}, metrics)
+}
+
+func TestEncodeDecodeTCPStats(t *testing.T) {
+	id1 := ebpf.TCPConnID{SrcPort: 123, DstPort: 456}
+	id2 := ebpf.TCPConnID{SrcPort: 789, DstPort: 80}
+	id2.SrcIP[15], id2.DstIP[15] = 1, 2
+	stats := map[ebpf.TCPConnID]ebpf.TCPStats{
+		id1: {SrttUs: 1200, Retransmits: 2},
+		id2: {Retransmits: 3},
+	}
+	raw, err := encodeTCPStats(stats)
+	require.NoError(t, err)
+	decoded, err := decodeTCPStats(raw)
+	require.NoError(t, err)
+	assert.Equal(t, stats, decoded)
+
+	_, err = decodeTCPStats(raw[:len(raw)-1])
+	require.Error(t, err)
+}
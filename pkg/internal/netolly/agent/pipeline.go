@@ -8,27 +8,38 @@ import (
 
 	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
 	"github.com/grafana/beyla/pkg/internal/netolly/export"
+	"github.com/grafana/beyla/pkg/internal/netolly/export/file"
+	"github.com/grafana/beyla/pkg/internal/netolly/export/ipfix"
 	"github.com/grafana/beyla/pkg/internal/netolly/export/otel"
+	"github.com/grafana/beyla/pkg/internal/netolly/export/prom"
 	"github.com/grafana/beyla/pkg/internal/netolly/flow"
 	"github.com/grafana/beyla/pkg/internal/netolly/transform/cidr"
 	"github.com/grafana/beyla/pkg/internal/netolly/transform/k8s"
+	"github.com/grafana/beyla/pkg/internal/netolly/transform/nat"
+	"github.com/grafana/beyla/pkg/internal/netolly/transform/process"
 )
 
 // FlowsPipeline defines the different nodes in the Beyla's NetO11y module,
 // as well as how they are interconnected
 // TODO: add flow_printer node
 type FlowsPipeline struct {
-	MapTracer     `sendTo:"Deduper"`
-	RingBufTracer `sendTo:"Deduper"`
+	MapTracer     `sendTo:"Filter"`
+	RingBufTracer `sendTo:"Filter"`
 
+	Filter     flow.Filter           `forwardTo:"NAT"`
+	NAT        nat.Resolver          `forwardTo:"Deduper"`
 	Deduper    flow.Deduper          `forwardTo:"Kubernetes"`
 	Kubernetes k8s.MetadataDecorator `forwardTo:"ReverseDNS"`
-	ReverseDNS flow.ReverseDNS       `forwardTo:"CIDRs"`
+	ReverseDNS flow.ReverseDNS       `forwardTo:"Process"`
+	Process    process.Decorator     `forwardTo:"CIDRs"`
 	CIDRs      cidr.Definitions      `forwardTo:"Decorator"`
-	Decorator  `sendTo:"Exporter,Printer"`
+	Decorator  `sendTo:"Exporter,Prometheus,Printer,File,IPFIX"`
 
-	Exporter otel.MetricsConfig
-	Printer  export.FlowPrinterEnabled
+	Exporter   otel.MetricsConfig
+	Prometheus prom.PrometheusConfig
+	Printer    export.FlowPrinterConfig
+	File       file.FlowsConfig
+	IPFIX      ipfix.Config
 }
 
 type MapTracer struct{}
@@ -57,6 +68,8 @@ func (f *Flows) buildAndStartPipeline(ctx context.Context) (graph.Graph, error)
 		return f.rbTracer.TraceLoop(ctx), nil
 	})
 
+	graph.RegisterMiddle(gb, flow.FilterProvider)
+	graph.RegisterMiddle(gb, nat.ResolverProvider)
 	graph.RegisterMiddle(gb, flow.DeduperProvider)
 	graph.RegisterMiddle(gb, func(_ Decorator) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
 		// If deduper is enabled, we know that interfaces are unset.
@@ -74,28 +87,56 @@ func (f *Flows) buildAndStartPipeline(ctx context.Context) (graph.Graph, error)
 		return k8s.MetadataDecoratorProvider(ctx, cfg)
 	})
 	graph.RegisterMiddle(gb, flow.ReverseDNSProvider)
+	graph.RegisterMiddle(gb, process.DecoratorProvider)
 
-	// Terminal nodes export the flow record information out of the pipeline: OTEL and printer
+	// Terminal nodes export the flow record information out of the pipeline: OTEL, Prometheus, printer, file and IPFIX
 	graph.RegisterTerminal(gb, otel.MetricsExporterProvider)
+	graph.RegisterTerminal(gb, func(cfg prom.PrometheusConfig) (node.TerminalFunc[[]*ebpf.Record], error) {
+		return prom.PrometheusEndpoint(ctx, &cfg, f.ctxInfo.Prometheus)
+	})
 	graph.RegisterTerminal(gb, export.FlowPrinterProvider)
+	graph.RegisterTerminal(gb, file.FlowsExporterProvider)
+	graph.RegisterTerminal(gb, ipfix.ExporterProvider)
 
 	var deduperExpireTime = f.cfg.NetworkFlows.DeduperFCExpiry
 	if deduperExpireTime <= 0 {
 		deduperExpireTime = 2 * f.cfg.NetworkFlows.CacheActiveTimeout
 	}
 	return gb.Build(&FlowsPipeline{
+		Filter: flow.Filter{
+			Rules:       f.cfg.NetworkFlows.Filters,
+			KernelRules: f.kernelFilters,
+		},
+		NAT: nat.Resolver{Enable: f.cfg.NetworkFlows.ConntrackNAT},
 		Deduper: flow.Deduper{
 			Type:       f.cfg.NetworkFlows.Deduper,
 			ExpireTime: deduperExpireTime,
 		},
 		Kubernetes: k8s.MetadataDecorator{Kubernetes: &f.cfg.Attributes.Kubernetes},
-		// TODO: allow prometheus exporting
 		ReverseDNS: f.cfg.NetworkFlows.ReverseDNS,
-		CIDRs:      f.cfg.NetworkFlows.CIDRs,
+		Process: process.Decorator{
+			AllowedAttributes: f.cfg.NetworkFlows.AllowedAttributes,
+			Services:          f.ctxInfo.InstrumentedServices,
+		},
+		CIDRs: f.cfg.NetworkFlows.CIDRs,
 		Exporter: otel.MetricsConfig{
 			Metrics:           &f.cfg.Metrics,
 			AllowedAttributes: f.cfg.NetworkFlows.AllowedAttributes,
+			RTTBuckets:        f.cfg.NetworkFlows.RTTBuckets,
+			Histograms:        f.cfg.NetworkFlows.Histograms,
+		},
+		Prometheus: prom.PrometheusConfig{
+			Config:            &f.cfg.Prometheus,
+			AllowedAttributes: f.cfg.NetworkFlows.AllowedAttributes,
+			RTTBuckets:        f.cfg.NetworkFlows.RTTBuckets,
+			Histograms:        f.cfg.NetworkFlows.Histograms,
+		},
+		File:  file.FlowsConfig{File: &f.cfg.NetworkFlows.FileExport},
+		IPFIX: f.cfg.NetworkFlows.IPFIX,
+		Printer: export.FlowPrinterConfig{
+			Print:  f.cfg.NetworkFlows.Print,
+			Format: f.cfg.NetworkFlows.PrintFormat,
+			Output: f.cfg.NetworkFlows.PrintOutput,
 		},
-		Printer: export.FlowPrinterEnabled(f.cfg.NetworkFlows.Print),
 	})
 }
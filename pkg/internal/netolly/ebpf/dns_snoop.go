@@ -0,0 +1,136 @@
+//go:build linux
+
+package ebpf
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ethHeaderLen  = 14
+	ipv6HeaderLen = 40
+	protocolUDP   = 17
+	dnsPort       = 53
+)
+
+// DNSSnooper captures the DNS responses (UDP packets from port 53) that are sent or received
+// through any network interface of the host, by means of an eBPF socket filter.
+// The socket is managed by the Go runtime poller, so Close unblocks any pending ReadPacket
+// and the socket descriptor is not released while it is being read.
+type DNSSnooper struct {
+	file   *os.File
+	conn   syscall.RawConn
+	prog   *ebpf.Program
+	closed atomic.Bool
+}
+
+func NewDNSSnooper() (*DNSSnooper, error) {
+	prog, err := ebpf.NewProgram(dnsSocketFilterSpec())
+	if err != nil {
+		return nil, fmt.Errorf("loading DNS socket filter: %w", err)
+	}
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, int(htons(unix.ETH_P_ALL)))
+	if err != nil {
+		prog.Close()
+		return nil, fmt.Errorf("opening raw socket: %w", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ATTACH_BPF, prog.FD()); err != nil {
+		_ = unix.Close(fd)
+		prog.Close()
+		return nil, fmt.Errorf("attaching DNS socket filter: %w", err)
+	}
+	// as the socket is non-blocking, the file is registered in the runtime poller
+	file := os.NewFile(uintptr(fd), "dns-snoop")
+	conn, err := file.SyscallConn()
+	if err != nil {
+		_ = file.Close()
+		prog.Close()
+		return nil, fmt.Errorf("accessing raw socket: %w", err)
+	}
+	return &DNSSnooper{file: file, conn: conn, prog: prog}, nil
+}
+
+// ReadPacket blocks until a DNS response is captured, and copies it into the
+// provided buffer, starting from the Ethernet header. It returns net.ErrClosed
+// after the snooper is closed.
+func (s *DNSSnooper) ReadPacket(buf []byte) (int, error) {
+	var n int
+	var recvErr error
+	err := s.conn.Read(func(fd uintptr) bool {
+		n, _, recvErr = unix.Recvfrom(int(fd), buf, 0)
+		// returning false makes the poller wait until the socket is readable again
+		return !errors.Is(recvErr, unix.EAGAIN) && !errors.Is(recvErr, unix.EINTR)
+	})
+	if err != nil {
+		// the poller returns its own error when the file is closed during the read
+		if s.closed.Load() {
+			return 0, net.ErrClosed
+		}
+		return 0, err
+	}
+	return n, recvErr
+}
+
+// Close unblocks any pending ReadPacket. The socket is closed by the runtime
+// poller once it isn't being read anymore.
+func (s *DNSSnooper) Close() error {
+	if s.closed.Swap(true) {
+		return nil
+	}
+	return errors.Join(s.file.Close(), s.prog.Close())
+}
+
+// dnsSocketFilterSpec returns a socket filter that only accepts the IPv4 and IPv6
+// UDP packets whose source port is 53. It is assembled here, as it is small
+// enough to not require being compiled from C.
+func dnsSocketFilterSpec() *ebpf.ProgramSpec {
+	return &ebpf.ProgramSpec{
+		Name:    "dns_snoop",
+		Type:    ebpf.SocketFilter,
+		License: "GPL",
+		Instructions: asm.Instructions{
+			// the legacy packet load instructions require the context in R6.
+			// They return the loaded value in host byte order
+			asm.Mov.Reg(asm.R6, asm.R1),
+			asm.LoadAbs(12, asm.Half), // Ethernet protocol
+			asm.JEq.Imm(asm.R0, unix.ETH_P_IP, "ipv4"),
+			asm.JEq.Imm(asm.R0, unix.ETH_P_IPV6, "ipv6"),
+			asm.Ja.Label("drop"),
+
+			asm.LoadAbs(ethHeaderLen+9, asm.Byte).WithSymbol("ipv4"), // IPv4 protocol
+			asm.JNE.Imm(asm.R0, protocolUDP, "drop"),
+			// IPv4 headers have variable length: IHL * 4
+			asm.LoadAbs(ethHeaderLen, asm.Byte),
+			asm.And.Imm(asm.R0, 0x0f),
+			asm.LSh.Imm(asm.R0, 2),
+			asm.Mov.Reg(asm.R7, asm.R0),
+			asm.LoadInd(asm.R0, asm.R7, ethHeaderLen, asm.Half), // UDP source port
+			asm.JEq.Imm(asm.R0, dnsPort, "accept"),
+			asm.Ja.Label("drop"),
+
+			asm.LoadAbs(ethHeaderLen+6, asm.Byte).WithSymbol("ipv6"), // IPv6 next header
+			asm.JNE.Imm(asm.R0, protocolUDP, "drop"),
+			asm.LoadAbs(ethHeaderLen+ipv6HeaderLen, asm.Half), // UDP source port
+			asm.JEq.Imm(asm.R0, dnsPort, "accept"),
+
+			asm.Mov.Imm(asm.R0, 0).WithSymbol("drop"),
+			asm.Return(),
+			// accept the whole packet
+			asm.Mov.Imm32(asm.R0, -1).WithSymbol("accept"),
+			asm.Return(),
+		},
+	}
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
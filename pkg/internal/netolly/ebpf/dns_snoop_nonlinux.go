@@ -0,0 +1,19 @@
+//go:build !linux
+
+package ebpf
+
+import "errors"
+
+type DNSSnooper struct{}
+
+func NewDNSSnooper() (*DNSSnooper, error) {
+	return nil, errors.New("DNS snooping is only supported on Linux")
+}
+
+func (s *DNSSnooper) ReadPacket(_ []byte) (int, error) {
+	return 0, nil
+}
+
+func (s *DNSSnooper) Close() error {
+	return nil
+}
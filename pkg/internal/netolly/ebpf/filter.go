@@ -0,0 +1,33 @@
+package ebpf
+
+const (
+	// MaxKernelFilterRules is the maximum number of filter rules that the eBPF flows program can evaluate
+	MaxKernelFilterRules = 16
+
+	FilterAccept = 0
+	FilterDrop   = 1
+
+	FilterAnyDirection = 0xFF
+)
+
+// FilterRule to accept or drop the packets before they are aggregated into flows.
+// The addresses are already masked by their prefix length, which is counted over the 128 bits
+// of the IPAddr. A zero prefix length or protocol matches any value, and the port ranges are inclusive.
+// Contents in this struct must match byte-by-byte with the filter_rule struct in bpf/flow.h
+type FilterRule struct {
+	SrcIP        IPAddr
+	DstIP        IPAddr
+	SrcPrefixLen uint8
+	DstPrefixLen uint8
+	Protocol     uint8
+	// Direction is DirectionIngress, DirectionEgress or FilterAnyDirection
+	Direction uint8
+	// Action is FilterAccept or FilterDrop
+	Action       uint8
+	_            uint8
+	SrcPortStart uint16
+	SrcPortEnd   uint16
+	DstPortStart uint16
+	DstPortEnd   uint16
+	_            [2]uint8
+}
@@ -0,0 +1,19 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterRuleLayout(t *testing.T) {
+	spec, err := LoadNet()
+	require.NoError(t, err)
+
+	filters, ok := spec.Maps[flowFiltersMap]
+	require.True(t, ok)
+	assert.EqualValues(t, filters.ValueSize, binary.Size(FilterRule{}))
+	assert.EqualValues(t, MaxKernelFilterRules, filters.MaxEntries)
+}
@@ -12,6 +12,22 @@ import (
 	"github.com/cilium/ebpf"
 )
 
+type NetFilterRule struct {
+	SrcIp        struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	DstIp        struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	SrcPrefixLen uint8
+	DstPrefixLen uint8
+	Protocol     uint8
+	Direction    uint8
+	Action       uint8
+	Pad          uint8
+	SrcPortStart uint16
+	SrcPortEnd   uint16
+	DstPortStart uint16
+	DstPortEnd   uint16
+	PadEnd       [2]uint8
+}
+
 type NetFlowId NetFlowIdT
 
 type NetFlowIdT struct {
@@ -33,6 +49,8 @@ type NetFlowMetricsT struct {
 	StartMonoTimeNs uint64
 	EndMonoTimeNs   uint64
 	Flags           uint16
+	Resets          uint32
+	Syns            uint32
 	Errno           uint8
 }
 
@@ -41,6 +59,18 @@ type NetFlowRecordT struct {
 	Metrics NetFlowMetrics
 }
 
+type NetTcpConnId struct {
+	SrcIp   struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	DstIp   struct{ In6U struct{ U6Addr8 [16]uint8 } }
+	SrcPort uint16
+	DstPort uint16
+}
+
+type NetTcpStats struct {
+	SrttUs      uint32
+	Retransmits uint32
+}
+
 // LoadNet returns the embedded CollectionSpec for Net.
 func LoadNet() (*ebpf.CollectionSpec, error) {
 	reader := bytes.NewReader(_NetBytes)
@@ -82,8 +112,10 @@ type NetSpecs struct {
 //
 // It can be passed ebpf.CollectionSpec.Assign.
 type NetProgramSpecs struct {
-	EgressFlowParse  *ebpf.ProgramSpec `ebpf:"egress_flow_parse"`
-	IngressFlowParse *ebpf.ProgramSpec `ebpf:"ingress_flow_parse"`
+	EgressFlowParse       *ebpf.ProgramSpec `ebpf:"egress_flow_parse"`
+	FlowTcpRcvEstablished *ebpf.ProgramSpec `ebpf:"flow_tcp_rcv_established"`
+	FlowTcpRetransmitSkb  *ebpf.ProgramSpec `ebpf:"flow_tcp_retransmit_skb"`
+	IngressFlowParse      *ebpf.ProgramSpec `ebpf:"ingress_flow_parse"`
 }
 
 // NetMapSpecs contains maps before they are loaded into the kernel.
@@ -92,6 +124,8 @@ type NetProgramSpecs struct {
 type NetMapSpecs struct {
 	AggregatedFlows *ebpf.MapSpec `ebpf:"aggregated_flows"`
 	DirectFlows     *ebpf.MapSpec `ebpf:"direct_flows"`
+	FlowFilters     *ebpf.MapSpec `ebpf:"flow_filters"`
+	TcpFlowStats    *ebpf.MapSpec `ebpf:"tcp_flow_stats"`
 }
 
 // NetObjects contains all objects after they have been loaded into the kernel.
@@ -115,12 +149,16 @@ func (o *NetObjects) Close() error {
 type NetMaps struct {
 	AggregatedFlows *ebpf.Map `ebpf:"aggregated_flows"`
 	DirectFlows     *ebpf.Map `ebpf:"direct_flows"`
+	FlowFilters     *ebpf.Map `ebpf:"flow_filters"`
+	TcpFlowStats    *ebpf.Map `ebpf:"tcp_flow_stats"`
 }
 
 func (m *NetMaps) Close() error {
 	return _NetClose(
 		m.AggregatedFlows,
 		m.DirectFlows,
+		m.FlowFilters,
+		m.TcpFlowStats,
 	)
 }
 
@@ -128,13 +166,17 @@ func (m *NetMaps) Close() error {
 //
 // It can be passed to LoadNetObjects or ebpf.CollectionSpec.LoadAndAssign.
 type NetPrograms struct {
-	EgressFlowParse  *ebpf.Program `ebpf:"egress_flow_parse"`
-	IngressFlowParse *ebpf.Program `ebpf:"ingress_flow_parse"`
+	EgressFlowParse       *ebpf.Program `ebpf:"egress_flow_parse"`
+	FlowTcpRcvEstablished *ebpf.Program `ebpf:"flow_tcp_rcv_established"`
+	FlowTcpRetransmitSkb  *ebpf.Program `ebpf:"flow_tcp_retransmit_skb"`
+	IngressFlowParse      *ebpf.Program `ebpf:"ingress_flow_parse"`
 }
 
 func (p *NetPrograms) Close() error {
 	return _NetClose(
 		p.EgressFlowParse,
+		p.FlowTcpRcvEstablished,
+		p.FlowTcpRetransmitSkb,
 		p.IngressFlowParse,
 	)
 }
@@ -35,6 +35,10 @@ type IPAddr [net.IPv6len]uint8
 type Record struct {
 	NetFlowRecordT
 
+	// TCP health statistics of the flow. They are only set for TCP flows
+	// whose statistics could be sampled during the eviction period.
+	TCP TCPStats
+
 	// Attrs of the flow record: source/destination, Interface, Beyla IP, etc...
 	Attrs RecordAttrs
 }
@@ -51,6 +55,25 @@ type RecordAttrs struct {
 	// BeylaIP provides information about the source of the flow (the Agent that traced it)
 	BeylaIP  string
 	Metadata map[string]string
+
+	// NAT is only set if the flow was translated by the network address translation of the host
+	NAT *NAT
+}
+
+// Endpoints of a flow: source and destination addresses and ports
+type Endpoints struct {
+	SrcIP   IPAddr
+	DstIP   IPAddr
+	SrcPort uint16
+	DstPort uint16
+}
+
+// NAT contains the endpoints of a flow before and after the network address translation
+// (e.g. from a Kubernetes Service ClusterIP to the IP of a backend Pod, or from a Pod IP to
+// the IP of its Node). Both are set in the same direction as the flow.
+type NAT struct {
+	Original   Endpoints
+	Translated Endpoints
 }
 
 func NewRecord(
@@ -76,6 +99,8 @@ func (fm *NetFlowMetrics) Accumulate(src *NetFlowMetrics) {
 	fm.Bytes += src.Bytes
 	fm.Packets += src.Packets
 	fm.Flags |= src.Flags
+	fm.Resets += src.Resets
+	fm.Syns += src.Syns
 }
 
 // SrcIP is never null. Returned as pointer for efficiency.
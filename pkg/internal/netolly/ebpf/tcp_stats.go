@@ -0,0 +1,41 @@
+package ebpf
+
+// TCPConnID identifies the TCP statistics of the flows that have the same
+// source and destination addresses and ports.
+// Contents in this struct must match byte-by-byte with the tcp_conn_id struct in bpf/flow.h
+type TCPConnID struct {
+	SrcIP   IPAddr
+	DstIP   IPAddr
+	SrcPort uint16
+	DstPort uint16
+}
+
+// TCPStats contains the health statistics of a TCP connection since the last eviction.
+// Contents in this struct must match byte-by-byte with the tcp_stats struct in bpf/flow.h
+type TCPStats struct {
+	// SrttUs is the last sampled smoothed round-trip time, in microseconds.
+	// Zero if it could not be sampled.
+	SrttUs uint32
+	// Retransmits of TCP segments
+	Retransmits uint32
+}
+
+// TCPConnID returns the identifier of the TCP statistics that correspond to the flow.
+func (fi *NetFlowId) TCPConnID() TCPConnID {
+	return TCPConnID{
+		SrcIP:   *fi.SrcIP(),
+		DstIP:   *fi.DstIP(),
+		SrcPort: fi.SrcPort,
+		DstPort: fi.DstPort,
+	}
+}
+
+// IsTCP returns whether the flow transports TCP segments
+func (fi *NetFlowId) IsTCP() bool {
+	return fi.TransportProtocol == TransportTCP
+}
+
+// Empty returns whether no statistics were collected
+func (ts *TCPStats) Empty() bool {
+	return *ts == TCPStats{}
+}
@@ -0,0 +1,19 @@
+package ebpf
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTCPStatsLayout(t *testing.T) {
+	spec, err := LoadNet()
+	require.NoError(t, err)
+
+	tcpStats, ok := spec.Maps[tcpFlowStatsMap]
+	require.True(t, ok)
+	assert.EqualValues(t, tcpStats.KeySize, binary.Size(TCPConnID{}))
+	assert.EqualValues(t, tcpStats.ValueSize, binary.Size(TCPStats{}))
+}
@@ -28,6 +28,8 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/link"
 	"github.com/cilium/ebpf/ringbuf"
 	"github.com/cilium/ebpf/rlimit"
 	"github.com/vishvananda/netlink"
@@ -44,7 +46,10 @@ const (
 	// constants defined in flows.c as "volatile const"
 	constSampling      = "sampling"
 	constTraceMessages = "trace_messages"
+	constFilterRules   = "filter_rules"
 	aggregatedFlowsMap = "aggregated_flows"
+	tcpFlowStatsMap    = "tcp_flow_stats"
+	flowFiltersMap     = "flow_filters"
 )
 
 func tlog() *slog.Logger {
@@ -57,6 +62,7 @@ func tlog() *slog.Logger {
 // in the map
 type FlowFetcher struct {
 	objects        *NetObjects
+	kprobes        []link.Link
 	qdiscs         map[ifaces.Interface]*netlink.GenericQdisc
 	egressFilters  map[ifaces.Interface]*netlink.BpfFilter
 	ingressFilters map[ifaces.Interface]*netlink.BpfFilter
@@ -64,11 +70,14 @@ type FlowFetcher struct {
 	cacheMaxSize   int
 	enableIngress  bool
 	enableEgress   bool
+	// kernelFilters is the number of filter rules that are evaluated by the eBPF program
+	kernelFilters int
 }
 
 func NewFlowFetcher(
 	sampling, cacheMaxSize int,
 	ingress, egress bool,
+	filters []FilterRule,
 ) (*FlowFetcher, error) {
 	tlog := tlog()
 	if err := rlimit.RemoveMemlock(); err != nil {
@@ -82,22 +91,43 @@ func NewFlowFetcher(
 		return nil, fmt.Errorf("loading BPF data: %w", err)
 	}
 
-	// Resize aggregated flows map according to user-provided configuration
-	spec.Maps[aggregatedFlowsMap].MaxEntries = uint32(cacheMaxSize)
+	// Resize aggregated flows and TCP statistics maps according to user-provided configuration
+	for _, name := range []string{aggregatedFlowsMap, tcpFlowStatsMap} {
+		mapSpec, ok := spec.Maps[name]
+		if !ok {
+			return nil, fmt.Errorf("BPF map %q not found", name)
+		}
+		mapSpec.MaxEntries = uint32(cacheMaxSize)
+	}
 
 	traceMsgs := 0
 	if tlog.Enabled(context.TODO(), slog.LevelDebug) {
 		traceMsgs = 1
 	}
-	if err := spec.RewriteConstants(map[string]interface{}{
+	constants := map[string]interface{}{
 		constSampling:      uint32(sampling),
 		constTraceMessages: uint8(traceMsgs),
-	}); err != nil {
+	}
+	opts := &ebpf.CollectionOptions{}
+	filtersMap, err := loadFilterRules(spec, filters)
+	if err != nil {
+		return nil, err
+	}
+	kernelFilters := 0
+	if filtersMap != nil {
+		// the map is cloned when the collection is loaded
+		defer filtersMap.Close()
+		opts.MapReplacements = map[string]*ebpf.Map{flowFiltersMap: filtersMap}
+		kernelFilters = min(len(filters), MaxKernelFilterRules)
+		constants[constFilterRules] = uint32(kernelFilters)
+	}
+	if err := spec.RewriteConstants(constants); err != nil {
 		return nil, fmt.Errorf("rewriting BPF constants definition: %w", err)
 	}
-	if err := spec.LoadAndAssign(&objects, nil); err != nil {
+	if err := spec.LoadAndAssign(&objects, opts); err != nil {
 		return nil, fmt.Errorf("loading and assigning BPF objects: %w", err)
 	}
+	kprobes := attachTCPKprobes(&objects)
 
 	// read events from igress+egress ringbuffer
 	flows, err := ringbuf.NewReader(objects.DirectFlows)
@@ -106,6 +136,7 @@ func NewFlowFetcher(
 	}
 	return &FlowFetcher{
 		objects:        &objects,
+		kprobes:        kprobes,
 		ringbufReader:  flows,
 		egressFilters:  map[ifaces.Interface]*netlink.BpfFilter{},
 		ingressFilters: map[ifaces.Interface]*netlink.BpfFilter{},
@@ -113,9 +144,60 @@ func NewFlowFetcher(
 		cacheMaxSize:   cacheMaxSize,
 		enableIngress:  ingress,
 		enableEgress:   egress,
+		kernelFilters:  kernelFilters,
 	}, nil
 }
 
+// loadFilterRules creates the eBPF map with the filter rules that can be evaluated in the kernel.
+// It returns nil if there are no rules.
+func loadFilterRules(spec *ebpf.CollectionSpec, filters []FilterRule) (*ebpf.Map, error) {
+	if len(filters) == 0 {
+		return nil, nil
+	}
+	mapSpec := spec.Maps[flowFiltersMap]
+	if len(filters) > MaxKernelFilterRules {
+		tlog().Info("too many filter rules. Some of them will be evaluated in user space",
+			"rules", len(filters), "kernelRules", MaxKernelFilterRules)
+		filters = filters[:MaxKernelFilterRules]
+	}
+	filtersMap, err := ebpf.NewMap(mapSpec)
+	if err != nil {
+		return nil, fmt.Errorf("creating filter rules map: %w", err)
+	}
+	for i := range filters {
+		if err := filtersMap.Put(uint32(i), &filters[i]); err != nil {
+			filtersMap.Close()
+			return nil, fmt.Errorf("storing filter rule %d: %w", i, err)
+		}
+	}
+	return filtersMap, nil
+}
+
+// KernelFilters returns the number of filter rules, from the beginning of the provided rules,
+// that are evaluated in the kernel space.
+func (m *FlowFetcher) KernelFilters() int {
+	return m.kernelFilters
+}
+
+// attachTCPKprobes to the kernel functions that allow sampling the TCP statistics.
+// Failing to attach them is not fatal: the flows would just miss some statistics.
+func attachTCPKprobes(objs *NetObjects) []link.Link {
+	var kprobes []link.Link
+	for funcName, prog := range map[string]*ebpf.Program{
+		"tcp_rcv_established": objs.FlowTcpRcvEstablished,
+		"tcp_retransmit_skb":  objs.FlowTcpRetransmitSkb,
+	} {
+		kp, err := link.Kprobe(funcName, prog, nil)
+		if err != nil {
+			tlog().Warn("can't attach kprobe. Some TCP statistics won't be reported",
+				"function", funcName, "error", err)
+			continue
+		}
+		kprobes = append(kprobes, kp)
+	}
+	return kprobes
+}
+
 // Register and links the eBPF fetcher into the system. The program should invoke Unregister
 // before exiting.
 func (m *FlowFetcher) Register(iface ifaces.Interface) error {
@@ -238,6 +320,12 @@ func (m *FlowFetcher) Close() error {
 			errs = append(errs, err)
 		}
 	}
+	for _, kp := range m.kprobes {
+		if err := kp.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.kprobes = nil
 	if m.objects != nil {
 		errs = append(errs, m.closeObjects()...)
 	}
@@ -287,6 +375,15 @@ func (m *FlowFetcher) closeObjects() []error {
 	if err := m.objects.DirectFlows.Close(); err != nil {
 		errs = append(errs, err)
 	}
+	if err := m.objects.TcpFlowStats.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := m.objects.FlowTcpRcvEstablished.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := m.objects.FlowTcpRetransmitSkb.Close(); err != nil {
+		errs = append(errs, err)
+	}
 	m.objects = nil
 	return errs
 }
@@ -348,3 +445,22 @@ func (m *FlowFetcher) LookupAndDeleteMap() map[NetFlowId][]NetFlowMetrics {
 	}
 	return flows
 }
+
+// LookupAndDeleteTCPStats reads all the entries from the TCP statistics eBPF map and removes
+// them from it.
+func (m *FlowFetcher) LookupAndDeleteTCPStats() map[TCPConnID]TCPStats {
+	statsMap := m.objects.TcpFlowStats
+
+	iterator := statsMap.Iterate()
+	stats := map[TCPConnID]TCPStats{}
+
+	id := TCPConnID{}
+	st := TCPStats{}
+	for iterator.Next(&id, &st) {
+		if err := statsMap.Delete(id); err != nil {
+			tlog().Warn("couldn't delete TCP stats entry", "connId", id)
+		}
+		stats[id] = st
+	}
+	return stats
+}
@@ -8,4 +8,7 @@ const (
 	DirectionEgress  = 1
 
 	InterfaceUnset = 0xFFFFFFFF
+
+	// TransportTCP is the IANA protocol number of TCP
+	TransportTCP = 6
 )
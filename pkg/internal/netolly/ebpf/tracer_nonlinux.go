@@ -11,10 +11,14 @@ import (
 type FlowFetcher struct {
 }
 
-func NewFlowFetcher(_, _ int, _, _ bool) (*FlowFetcher, error) {
+func NewFlowFetcher(_, _ int, _, _ bool, _ []FilterRule) (*FlowFetcher, error) {
 	return nil, nil
 }
 
+func (m *FlowFetcher) KernelFilters() int {
+	return 0
+}
+
 func (m *FlowFetcher) Register(_ ifaces.Interface) error {
 	return nil
 }
@@ -30,3 +34,7 @@ func (m *FlowFetcher) ReadRingBuf() (ringbuf.Record, error) {
 func (m *FlowFetcher) LookupAndDeleteMap() map[NetFlowId][]NetFlowMetrics {
 	return nil
 }
+
+func (m *FlowFetcher) LookupAndDeleteTCPStats() map[TCPConnID]TCPStats {
+	return nil
+}
@@ -1,6 +1,7 @@
 package export
 
 import (
+	"strconv"
 	"strings"
 
 	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
@@ -60,6 +61,20 @@ func attributeFor(exposedName, internalName string) Attribute {
 		getter = func(r *ebpf.Record) string { return directionStr(r.Id.Direction) }
 	case "iface":
 		getter = func(r *ebpf.Record) string { return r.Attrs.Interface }
+	case "transport":
+		getter = func(r *ebpf.Record) string { return transportStr(r.Id.TransportProtocol) }
+	case "src.port":
+		getter = func(r *ebpf.Record) string { return strconv.Itoa(int(r.Id.SrcPort)) }
+	case "dst.port":
+		getter = func(r *ebpf.Record) string { return strconv.Itoa(int(r.Id.DstPort)) }
+	case "dst.service.port":
+		getter = func(r *ebpf.Record) string {
+			// the name of the Kubernetes port, if the flow was decorated with it
+			if name, ok := r.Attrs.Metadata[internalName]; ok {
+				return name
+			}
+			return servicePort(&r.Id)
+		}
 	default:
 		getter = func(r *ebpf.Record) string { return r.Attrs.Metadata[internalName] }
 	}
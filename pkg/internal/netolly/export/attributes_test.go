@@ -0,0 +1,83 @@
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+func TestTransportAndPortAttributes(t *testing.T) {
+	promAttrs := BuildPromAttributeGetters([]string{"transport", "src_port", "dst.port", "dst_service_port"})
+	otelAttrs := BuildOTELAttributeGetters([]string{"transport", "src_port", "dst.port", "dst_service_port"})
+	assert.Equal(t, []string{"transport", "src_port", "dst_port", "dst_service_port"}, attrNames(promAttrs))
+	assert.Equal(t, []string{"transport", "src.port", "dst.port", "dst.service.port"}, attrNames(otelAttrs))
+
+	flow := portsFlow(6, 34567, 5432)
+	assert.Equal(t, []string{"tcp", "34567", "5432", "postgresql"}, attrValues(promAttrs, flow))
+	assert.Equal(t, []string{"tcp", "34567", "5432", "postgresql"}, attrValues(otelAttrs, flow))
+
+	// the name from the Kubernetes decoration takes precedence
+	flow.Attrs.Metadata = map[string]string{"dst.service.port": "db"}
+	assert.Equal(t, []string{"tcp", "34567", "5432", "db"}, attrValues(promAttrs, flow))
+}
+
+func TestServicePort(t *testing.T) {
+	type testCase struct {
+		protocol         uint8
+		srcPort, dstPort uint16
+		expected         string
+	}
+	for _, tc := range []testCase{
+		{protocol: 6, srcPort: 45678, dstPort: 443, expected: "https"},
+		{protocol: 6, srcPort: 443, dstPort: 45678, expected: "https"},
+		{protocol: 17, srcPort: 53, dstPort: 60000, expected: "dns"},
+		// both well-known: the lowest is taken
+		{protocol: 6, srcPort: 8080, dstPort: 80, expected: "http"},
+		{protocol: 6, srcPort: 443, dstPort: 6379, expected: "https"},
+		// unnamed ports are grouped to keep a low cardinality
+		{protocol: 6, srcPort: 45678, dstPort: 7070, expected: "other"},
+		{protocol: 132, srcPort: 3868, dstPort: 50000, expected: "other"},
+		{protocol: 6, srcPort: 45678, dstPort: 56789, expected: "other"},
+		// protocols without ports
+		{protocol: 1, srcPort: 0, dstPort: 0, expected: ""},
+		{protocol: 58, srcPort: 0, dstPort: 0, expected: ""},
+	} {
+		assert.Equalf(t, tc.expected, servicePort(&portsFlow(tc.protocol, tc.srcPort, tc.dstPort).Id),
+			"%+v", tc)
+	}
+}
+
+func TestTransportStr(t *testing.T) {
+	assert.Equal(t, "icmp", transportStr(1))
+	assert.Equal(t, "tcp", transportStr(6))
+	assert.Equal(t, "udp", transportStr(17))
+	assert.Equal(t, "icmpv6", transportStr(58))
+	assert.Equal(t, "sctp", transportStr(132))
+	assert.Equal(t, "47", transportStr(47))
+}
+
+func portsFlow(protocol uint8, srcPort, dstPort uint16) *ebpf.Record {
+	flow := &ebpf.Record{}
+	flow.Id.TransportProtocol = protocol
+	flow.Id.SrcPort = srcPort
+	flow.Id.DstPort = dstPort
+	return flow
+}
+
+func attrNames(attrs []Attribute) []string {
+	names := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		names = append(names, attr.Name)
+	}
+	return names
+}
+
+func attrValues(attrs []Attribute, flow *ebpf.Record) []string {
+	values := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		values = append(values, attr.Get(flow))
+	}
+	return values
+}
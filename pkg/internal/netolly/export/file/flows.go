@@ -0,0 +1,119 @@
+// Package file writes the network flow records into a local file, as OTLP-JSON log records
+package file
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gavv/monotime"
+	"github.com/google/uuid"
+	"github.com/mariomac/pipes/pkg/node"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+
+	"github.com/grafana/beyla/pkg/internal/export/file"
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+)
+
+func flog() *slog.Logger {
+	return slog.With("component", "flows.FileExporter")
+}
+
+type FlowsConfig struct {
+	File *file.RotationConfig
+}
+
+func (fc FlowsConfig) Enabled() bool {
+	return fc.File != nil && fc.File.Enabled()
+}
+
+// FlowsExporterProvider writes each batch of flow records as an OTLP-JSON line, where
+// each flow is a log record.
+func FlowsExporterProvider(cfg FlowsConfig) (node.TerminalFunc[[]*ebpf.Record], error) {
+	writer, err := file.NewRotatingWriter(cfg.File)
+	if err != nil {
+		return nil, fmt.Errorf("instantiating flows file exporter: %w", err)
+	}
+	fe := flowsExporter{
+		writer:     writer,
+		instanceID: uuid.New().String(),
+		clock:      time.Now,
+		monoClock:  monotime.Now,
+	}
+	return fe.write, nil
+}
+
+type flowsExporter struct {
+	writer     *file.RotatingWriter
+	marshaler  plog.JSONMarshaler
+	instanceID string
+	clock      func() time.Time
+	monoClock  func() time.Duration
+}
+
+func (fe *flowsExporter) write(in <-chan []*ebpf.Record) {
+	log := flog()
+	defer func() {
+		if err := fe.writer.Close(); err != nil {
+			log.Warn("error closing flows file", "error", err)
+		}
+	}()
+	for flows := range in {
+		if len(flows) == 0 {
+			continue
+		}
+		line, err := fe.marshaler.MarshalLogs(fe.toLogs(flows))
+		if err != nil {
+			log.Error("can't encode flows", "error", err)
+			continue
+		}
+		if err := fe.writer.WriteLine(line); err != nil {
+			log.Error("can't write flows", "error", err, "len", len(flows))
+		}
+	}
+}
+
+func (fe *flowsExporter) toLogs(flows []*ebpf.Record) plog.Logs {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.SetSchemaUrl(semconv.SchemaURL)
+	res := rl.Resource().Attributes()
+	// same resource as the OTEL metrics exporter
+	res.PutStr(string(semconv.ServiceNameKey), "beyla-network-flows")
+	res.PutStr(string(semconv.ServiceInstanceIDKey), fe.instanceID)
+	res.PutStr(string(semconv.TelemetrySDKLanguageKey), semconv.TelemetrySDKLanguageGo.Value.AsString())
+	res.PutStr(string(semconv.TelemetrySDKNameKey), "beyla")
+
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	records.EnsureCapacity(len(flows))
+	// the flow times are taken from the kernel monotonic clock, so they are converted to wall clock
+	now, monoNow := fe.clock(), fe.monoClock()
+	for _, flow := range flows {
+		fe.toLogRecord(flow, now, monoNow, records.AppendEmpty())
+	}
+	return logs
+}
+
+func (fe *flowsExporter) toLogRecord(flow *ebpf.Record, now time.Time, monoNow time.Duration, dst plog.LogRecord) {
+	dst.SetTimestamp(pcommon.NewTimestampFromTime(now.Add(-(monoNow - time.Duration(flow.Metrics.EndMonoTimeNs)))))
+	dst.SetObservedTimestamp(pcommon.NewTimestampFromTime(now))
+	dst.SetSeverityNumber(plog.SeverityNumberInfo)
+	dst.Body().SetStr("network_flow")
+
+	attrs := logAttributes{Map: dst.Attributes()}
+	attrs.EnsureCapacity(export.FlowAttributesLen(flow))
+	export.WriteFlowAttributes(attrs, flow, now, monoNow)
+}
+
+// logAttributes implements export.FlowAttributesWriter for the log record attributes,
+// which don't have a time type so the times are written as RFC3339 strings
+type logAttributes struct {
+	pcommon.Map
+}
+
+func (la logAttributes) PutTime(name string, value time.Time) {
+	la.PutStr(name, value.Format(time.RFC3339Nano))
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+
+	"github.com/grafana/beyla/pkg/internal/export/file"
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+func TestFlowsConfig_Enabled(t *testing.T) {
+	assert.False(t, FlowsConfig{}.Enabled())
+	assert.False(t, FlowsConfig{File: &file.RotationConfig{}}.Enabled())
+	assert.True(t, FlowsConfig{File: &file.RotationConfig{Path: "flows.jsonl"}}.Enabled())
+}
+
+func TestFlowsExporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flows.jsonl")
+	writer, err := file.NewRotatingWriter(&file.RotationConfig{Path: path})
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fe := flowsExporter{
+		writer:     writer,
+		instanceID: "instance-1",
+		clock:      func() time.Time { return now },
+		monoClock:  func() time.Duration { return 10 * time.Second },
+	}
+
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{
+		SrcName: "client", DstName: "server", Interface: "eth0", BeylaIP: "1.2.3.4",
+		Metadata: map[string]string{"k8s.src.namespace": "shop"},
+	}}
+	flow.Id.Direction = ebpf.DirectionEgress
+	flow.Id.SrcPort, flow.Id.DstPort = 34567, 8080
+	flow.Id.TransportProtocol = 6
+	copy(flow.Id.SrcIp.In6U.U6Addr8[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 1})
+	copy(flow.Id.DstIp.In6U.U6Addr8[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 2})
+	flow.Metrics.Bytes, flow.Metrics.Packets = 1234, 3
+	flow.Metrics.StartMonoTimeNs = uint64(7 * time.Second)
+	flow.Metrics.EndMonoTimeNs = uint64(9 * time.Second)
+
+	in := make(chan []*ebpf.Record, 2)
+	in <- []*ebpf.Record{flow, flow}
+	in <- []*ebpf.Record{flow}
+	close(in)
+	fe.write(in)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// one line for each batch of flows
+	lines := bytes.Split(bytes.TrimSpace(content), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	logs, err := (&plog.JSONUnmarshaler{}).UnmarshalLogs(lines[0])
+	require.NoError(t, err)
+	assert.Equal(t, 2, logs.LogRecordCount())
+	rl := logs.ResourceLogs().At(0)
+	assert.Equal(t, map[string]any{
+		"service.name":           "beyla-network-flows",
+		"service.instance.id":    "instance-1",
+		"telemetry.sdk.language": "go",
+		"telemetry.sdk.name":     "beyla",
+	}, rl.Resource().Attributes().AsRaw())
+
+	record := rl.ScopeLogs().At(0).LogRecords().At(0)
+	assert.Equal(t, now.Add(-time.Second), record.Timestamp().AsTime())
+	assert.Equal(t, now, record.ObservedTimestamp().AsTime())
+	assert.Equal(t, "network_flow", record.Body().Str())
+	assert.Equal(t, map[string]any{
+		"beyla.ip":          "1.2.3.4",
+		"iface":             "eth0",
+		"direction":         "egress",
+		"src.address":       "10.0.0.1",
+		"dst.address":       "10.0.0.2",
+		"src.name":          "client",
+		"dst.name":          "server",
+		"src.port":          int64(34567),
+		"dst.port":          int64(8080),
+		"transport":         int64(6),
+		"eth.protocol":      int64(0),
+		"if.index":          int64(0),
+		"bytes":             int64(1234),
+		"packets":           int64(3),
+		"tcp.flags":         int64(0),
+		"tcp.resets":        int64(0),
+		"tcp.syns":          int64(0),
+		"start":             "2024-01-02T03:04:02Z",
+		"end":               "2024-01-02T03:04:04Z",
+		"k8s.src.namespace": "shop",
+	}, record.Attributes().AsRaw())
+
+	// the writer is closed when the input channel is closed
+	require.Error(t, writer.WriteLine([]byte("closed")))
+}
+
+func TestFlowsExporter_NAT(t *testing.T) {
+	fe := flowsExporter{
+		clock:     time.Now,
+		monoClock: func() time.Duration { return 0 },
+	}
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{NAT: &ebpf.NAT{
+		Original:   ebpf.Endpoints{SrcPort: 45678, DstPort: 80},
+		Translated: ebpf.Endpoints{SrcPort: 45678, DstPort: 8080},
+	}}}
+	copy(flow.Attrs.NAT.Original.DstIP[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 96, 0, 10})
+	copy(flow.Attrs.NAT.Translated.DstIP[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 244, 2, 7})
+
+	logs := fe.toLogs([]*ebpf.Record{flow})
+	attrs := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().AsRaw()
+	assert.Equal(t, "10.96.0.10", attrs["nat.original.dst.address"])
+	assert.Equal(t, int64(80), attrs["nat.original.dst.port"])
+	assert.Equal(t, "10.244.2.7", attrs["nat.translated.dst.address"])
+	assert.Equal(t, int64(8080), attrs["nat.translated.dst.port"])
+	assert.Equal(t, int64(45678), attrs["nat.translated.src.port"])
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+// flowAttributes that are reported for each flow, besides the numeric fields and the metadata
+var flowAttributes = BuildOTELAttributeGetters([]string{
+	"beyla.ip", "iface", "direction", "src.address", "dst.address", "src.name", "dst.name",
+})
+
+// FlowAttributesWriter receives the flattened attributes of a flow record
+type FlowAttributesWriter interface {
+	PutStr(name, value string)
+	PutInt(name string, value int64)
+	PutTime(name string, value time.Time)
+}
+
+// WriteFlowAttributes flattens all the attributes of a flow record into the provided writer,
+// so all the exporters that report each individual flow (e.g. the JSON printer and the
+// file exporter) write the same attributes. The metadata attributes are placed at the same
+// level as the rest of attributes.
+// The flow times are taken from the kernel monotonic clock, so they are converted to wall clock
+// from the now and monoNow arguments.
+func WriteFlowAttributes(dst FlowAttributesWriter, f *ebpf.Record, now time.Time, monoNow time.Duration) {
+	for _, attr := range flowAttributes {
+		dst.PutStr(attr.Name, attr.Get(f))
+	}
+	dst.PutInt("src.port", int64(f.Id.SrcPort))
+	dst.PutInt("dst.port", int64(f.Id.DstPort))
+	dst.PutInt("transport", int64(f.Id.TransportProtocol))
+	dst.PutInt("eth.protocol", int64(f.Id.EthProtocol))
+	dst.PutInt("if.index", int64(f.Id.IfIndex))
+	dst.PutInt("bytes", int64(f.Metrics.Bytes))
+	dst.PutInt("packets", int64(f.Metrics.Packets))
+	dst.PutInt("tcp.flags", int64(f.Metrics.Flags))
+	dst.PutTime("start", now.Add(-(monoNow - time.Duration(f.Metrics.StartMonoTimeNs))))
+	dst.PutTime("end", now.Add(-(monoNow - time.Duration(f.Metrics.EndMonoTimeNs))))
+	if f.Id.IsTCP() {
+		dst.PutInt("tcp.resets", int64(f.Metrics.Resets))
+		dst.PutInt("tcp.syns", int64(f.Metrics.Syns))
+	}
+	if !f.TCP.Empty() {
+		dst.PutInt("tcp.srtt_us", int64(f.TCP.SrttUs))
+		dst.PutInt("tcp.retransmits", int64(f.TCP.Retransmits))
+	}
+	if f.Attrs.NAT != nil {
+		writeEndpoints(dst, "nat.original.", &f.Attrs.NAT.Original)
+		writeEndpoints(dst, "nat.translated.", &f.Attrs.NAT.Translated)
+	}
+	for k, v := range f.Attrs.Metadata {
+		dst.PutStr(k, v)
+	}
+}
+
+// FlowAttributesLen returns the approximate number of attributes that WriteFlowAttributes
+// writes for the flow, to preallocate the destination
+func FlowAttributesLen(f *ebpf.Record) int {
+	return len(flowAttributes) + len(f.Attrs.Metadata) + 18
+}
+
+func writeEndpoints(dst FlowAttributesWriter, prefix string, e *ebpf.Endpoints) {
+	dst.PutStr(prefix+"src.address", e.SrcIP.IP().String())
+	dst.PutInt(prefix+"src.port", int64(e.SrcPort))
+	dst.PutStr(prefix+"dst.address", e.DstIP.IP().String())
+	dst.PutInt(prefix+"dst.port", int64(e.DstPort))
+}
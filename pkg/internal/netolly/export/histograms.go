@@ -0,0 +1,74 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+// FlowHistograms configures the optional histograms of the duration, the bytes and the
+// packets of each flow record.
+type FlowHistograms struct {
+	Enable bool `yaml:"enable" env:"BEYLA_NETWORK_FLOW_HISTOGRAMS"`
+	// DurationBuckets of the flow duration histogram, in seconds
+	DurationBuckets []float64 `yaml:"duration_buckets" env:"BEYLA_NETWORK_FLOW_DURATION_BUCKETS" envSeparator:","`
+	// BytesBuckets of the bytes per flow histogram
+	BytesBuckets []float64 `yaml:"bytes_buckets" env:"BEYLA_NETWORK_FLOW_BYTES_BUCKETS" envSeparator:","`
+	// PacketsBuckets of the packets per flow histogram
+	PacketsBuckets []float64 `yaml:"packets_buckets" env:"BEYLA_NETWORK_FLOW_PACKETS_BUCKETS" envSeparator:","`
+}
+
+var DefaultFlowHistograms = FlowHistograms{
+	DurationBuckets: []float64{0, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	BytesBuckets: []float64{0, 64, 256, 1024, 4096, 16384, 65536, 262144, 1048576,
+		4194304, 16777216, 67108864},
+	PacketsBuckets: []float64{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 4096, 16384},
+}
+
+// DefaultRTTBuckets of the TCP round-trip time histogram, in seconds. They start in the tens of
+// microseconds, as the round-trip time between the endpoints of the same host or data center
+// is usually below one millisecond.
+var DefaultRTTBuckets = []float64{0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+func (fh *FlowHistograms) Validate() error {
+	if !fh.Enable {
+		return nil
+	}
+	if err := ValidateBuckets(fh.DurationBuckets); err != nil {
+		return fmt.Errorf("duration_buckets: %w", err)
+	}
+	if err := ValidateBuckets(fh.BytesBuckets); err != nil {
+		return fmt.Errorf("bytes_buckets: %w", err)
+	}
+	if err := ValidateBuckets(fh.PacketsBuckets); err != nil {
+		return fmt.Errorf("packets_buckets: %w", err)
+	}
+	return nil
+}
+
+// ValidateBuckets checks that at least one bucket boundary is defined, and that they
+// are in strictly increasing order
+func ValidateBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return errors.New("at least one bucket boundary must be defined")
+	}
+	for i := 1; i < len(buckets); i++ {
+		if buckets[i] <= buckets[i-1] {
+			return fmt.Errorf("bucket boundaries must be in strictly increasing order: %v", buckets)
+		}
+	}
+	return nil
+}
+
+// FlowDuration returns the time between the first and the last packet of the flow record.
+// As the flows are periodically evicted, the duration of the long-lived connections is split
+// into many flow records.
+func FlowDuration(flow *ebpf.Record) time.Duration {
+	if flow.Metrics.EndMonoTimeNs < flow.Metrics.StartMonoTimeNs {
+		return 0
+	}
+	return time.Duration(flow.Metrics.EndMonoTimeNs - flow.Metrics.StartMonoTimeNs)
+}
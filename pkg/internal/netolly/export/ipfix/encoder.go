@@ -0,0 +1,255 @@
+package ipfix
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+)
+
+const (
+	ipfixVersion   = 10
+	headerLen      = 16
+	setHeaderLen   = 4
+	templateSetID  = 2
+	templateIPv4   = 256
+	templateIPv6   = 257
+	maxMessageSize = 65535
+	// varLength is the length of the information elements with variable length
+	varLength = 65535
+	// enterpriseBit is set in the ID of the enterprise-specific information elements
+	enterpriseBit = 0x8000
+)
+
+// IANA-assigned information elements: https://www.iana.org/assignments/ipfix/ipfix.xhtml
+const (
+	ieOctetDeltaCount          = 1
+	iePacketDeltaCount         = 2
+	ieProtocolIdentifier       = 4
+	ieTCPControlBits           = 6
+	ieSourceTransportPort      = 7
+	ieSourceIPv4Address        = 8
+	ieDestinationTransportPort = 11
+	ieDestinationIPv4Address   = 12
+	ieSourceIPv6Address        = 27
+	ieDestinationIPv6Address   = 28
+	ieFlowDirection            = 61
+	ieFlowStartMilliseconds    = 152
+	ieFlowEndMilliseconds      = 153
+)
+
+// TCP flags as defined in RFC 9293, and the custom flags that are set by the eBPF flows program
+// instead of the combination of SYN, FIN or RST with ACK
+const (
+	flagFIN    = 0x01
+	flagSYN    = 0x02
+	flagRST    = 0x04
+	flagACK    = 0x10
+	flagSYNACK = 0x100
+	flagFINACK = 0x200
+	flagRSTACK = 0x400
+)
+
+// enterpriseAttributes are submitted as enterprise-specific information elements, as
+// variable-length strings. The ID of each information element is its position in this list, plus one.
+var enterpriseAttributes = export.BuildOTELAttributeGetters([]string{
+	"src.name",
+	"dst.name",
+	"k8s.src.namespace",
+	"k8s.src.owner.name",
+	"k8s.src.owner.type",
+	"k8s.dst.namespace",
+	"k8s.dst.owner.name",
+	"k8s.dst.owner.type",
+	"k8s.cluster.name",
+})
+
+// encoder of flow records into IPFIX messages
+type encoder struct {
+	domainID uint32
+	maxSize  int
+	// sequence number of the next data record
+	sequence  uint32
+	templates []byte
+}
+
+func newEncoder(domainID, enterpriseID uint32, maxSize int) *encoder {
+	return &encoder{
+		domainID:  domainID,
+		maxSize:   maxSize,
+		templates: templateSet(enterpriseID),
+	}
+}
+
+// templateSet returns the set with the templates of the IPv4 and IPv6 data records
+func templateSet(enterpriseID uint32) []byte {
+	set := binary.BigEndian.AppendUint16(nil, templateSetID)
+	set = binary.BigEndian.AppendUint16(set, 0) // length, set below
+	for _, tmpl := range []struct {
+		id       uint16
+		src, dst uint16
+		ipLen    uint16
+	}{
+		{id: templateIPv4, src: ieSourceIPv4Address, dst: ieDestinationIPv4Address, ipLen: 4},
+		{id: templateIPv6, src: ieSourceIPv6Address, dst: ieDestinationIPv6Address, ipLen: 16},
+	} {
+		fields := [][2]uint16{
+			{tmpl.src, tmpl.ipLen},
+			{tmpl.dst, tmpl.ipLen},
+			{ieSourceTransportPort, 2},
+			{ieDestinationTransportPort, 2},
+			{ieProtocolIdentifier, 1},
+			{ieOctetDeltaCount, 8},
+			{iePacketDeltaCount, 8},
+			{ieFlowStartMilliseconds, 8},
+			{ieFlowEndMilliseconds, 8},
+			{ieFlowDirection, 1},
+			{ieTCPControlBits, 2},
+		}
+		set = binary.BigEndian.AppendUint16(set, tmpl.id)
+		set = binary.BigEndian.AppendUint16(set, uint16(len(fields)+len(enterpriseAttributes)))
+		for _, f := range fields {
+			set = binary.BigEndian.AppendUint16(set, f[0])
+			set = binary.BigEndian.AppendUint16(set, f[1])
+		}
+		for i := range enterpriseAttributes {
+			set = binary.BigEndian.AppendUint16(set, enterpriseBit|uint16(i+1))
+			set = binary.BigEndian.AppendUint16(set, varLength)
+			set = binary.BigEndian.AppendUint32(set, enterpriseID)
+		}
+	}
+	binary.BigEndian.PutUint16(set[2:], uint16(len(set)))
+	return set
+}
+
+// reset the sequence number, e.g. when a new transport session is started
+func (e *encoder) reset() {
+	e.sequence = 0
+}
+
+// message being built
+type message struct {
+	buf      []byte
+	setID    uint16
+	setStart int
+	records  uint32
+}
+
+func (m *message) closeSet() {
+	if m.setID != 0 {
+		binary.BigEndian.PutUint16(m.buf[m.setStart+2:], uint16(len(m.buf)-m.setStart))
+		m.setID = 0
+	}
+}
+
+// encode the flows into as many IPFIX messages as required to not exceed the maximum message size.
+// The flow times are taken from the kernel monotonic clock, so they are converted to wall clock
+// from the provided current wall and monotonic times.
+func (e *encoder) encode(flows []*ebpf.Record, now time.Time, monoNow time.Duration, withTemplates bool) [][]byte {
+	var messages [][]byte
+	msg := e.newMessage(withTemplates)
+	for _, flow := range flows {
+		tmplID, record := encodeRecord(flow, now, monoNow)
+		newLen := len(msg.buf) + len(record)
+		if msg.setID != tmplID {
+			newLen += setHeaderLen
+		}
+		if newLen > e.maxSize && msg.records > 0 {
+			messages = append(messages, e.finish(msg, now))
+			msg = e.newMessage(false)
+		}
+		if msg.setID != tmplID {
+			msg.closeSet()
+			msg.setID = tmplID
+			msg.setStart = len(msg.buf)
+			msg.buf = binary.BigEndian.AppendUint16(msg.buf, tmplID)
+			msg.buf = binary.BigEndian.AppendUint16(msg.buf, 0) // length, set on closeSet
+		}
+		msg.buf = append(msg.buf, record...)
+		msg.records++
+	}
+	if msg.records > 0 || len(msg.buf) > headerLen {
+		messages = append(messages, e.finish(msg, now))
+	}
+	return messages
+}
+
+func (e *encoder) newMessage(withTemplates bool) *message {
+	msg := &message{buf: make([]byte, headerLen, e.maxSize)}
+	if withTemplates {
+		msg.buf = append(msg.buf, e.templates...)
+	}
+	return msg
+}
+
+// finish the message by writing its header
+func (e *encoder) finish(msg *message, now time.Time) []byte {
+	msg.closeSet()
+	binary.BigEndian.PutUint16(msg.buf[0:], ipfixVersion)
+	binary.BigEndian.PutUint16(msg.buf[2:], uint16(len(msg.buf)))
+	binary.BigEndian.PutUint32(msg.buf[4:], uint32(now.Unix()))
+	binary.BigEndian.PutUint32(msg.buf[8:], e.sequence)
+	binary.BigEndian.PutUint32(msg.buf[12:], e.domainID)
+	e.sequence += msg.records
+	return msg.buf
+}
+
+// encodeRecord returns the data record of the flow, and the ID of its template
+func encodeRecord(flow *ebpf.Record, now time.Time, monoNow time.Duration) (uint16, []byte) {
+	tmplID := uint16(templateIPv6)
+	src, dst := flow.Id.SrcIP().IP(), flow.Id.DstIP().IP()
+	if src4, dst4 := src.To4(), dst.To4(); src4 != nil && dst4 != nil {
+		tmplID = templateIPv4
+		src, dst = src4, dst4
+	}
+	rec := make([]byte, 0, 128)
+	rec = append(rec, src...)
+	rec = append(rec, dst...)
+	rec = binary.BigEndian.AppendUint16(rec, flow.Id.SrcPort)
+	rec = binary.BigEndian.AppendUint16(rec, flow.Id.DstPort)
+	rec = append(rec, flow.Id.TransportProtocol)
+	rec = binary.BigEndian.AppendUint64(rec, flow.Metrics.Bytes)
+	rec = binary.BigEndian.AppendUint64(rec, uint64(flow.Metrics.Packets))
+	rec = binary.BigEndian.AppendUint64(rec, wallMillis(flow.Metrics.StartMonoTimeNs, now, monoNow))
+	rec = binary.BigEndian.AppendUint64(rec, wallMillis(flow.Metrics.EndMonoTimeNs, now, monoNow))
+	rec = append(rec, flow.Id.Direction)
+	rec = binary.BigEndian.AppendUint16(rec, tcpControlBits(flow.Metrics.Flags))
+	for _, attr := range enterpriseAttributes {
+		rec = appendString(rec, attr.Get(flow))
+	}
+	return tmplID, rec
+}
+
+func wallMillis(monoNs uint64, now time.Time, monoNow time.Duration) uint64 {
+	return uint64(now.Add(-(monoNow - time.Duration(monoNs))).UnixMilli())
+}
+
+// tcpControlBits converts the custom flags of the eBPF flows program into standard TCP flags
+func tcpControlBits(flags uint16) uint16 {
+	bits := flags & 0xff
+	if flags&flagSYNACK != 0 {
+		bits |= flagSYN | flagACK
+	}
+	if flags&flagFINACK != 0 {
+		bits |= flagFIN | flagACK
+	}
+	if flags&flagRSTACK != 0 {
+		bits |= flagRST | flagACK
+	}
+	return bits
+}
+
+// appendString encodes a variable-length information element, as defined in RFC 7011, section 7
+func appendString(rec []byte, str string) []byte {
+	if len(str) > varLength-1 {
+		str = str[:varLength-1]
+	}
+	if len(str) < 255 {
+		rec = append(rec, uint8(len(str)))
+	} else {
+		rec = append(rec, 255)
+		rec = binary.BigEndian.AppendUint16(rec, uint16(len(str)))
+	}
+	return append(rec, str...)
+}
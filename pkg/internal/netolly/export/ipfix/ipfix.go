@@ -0,0 +1,171 @@
+// Package ipfix submits the network flow records to an IPFIX collector (RFC 7011), over UDP or TCP.
+package ipfix
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/gavv/monotime"
+	"github.com/mariomac/pipes/pkg/node"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	TransportUDP = "udp"
+	TransportTCP = "tcp"
+)
+
+// DefaultEnterpriseID is the Private Enterprise Number that IANA reserves for documentation
+// purposes (RFC 5612). Users should override it with the number that is expected by their collector.
+const DefaultEnterpriseID = 32473
+
+// ioTimeout bounds the time to connect to the collector and to write each batch of flows,
+// so an unresponsive collector does not block the flows pipeline
+const ioTimeout = 5 * time.Second
+
+// maxUDPMessageSize keeps the UDP datagrams below the usual Ethernet MTU, to avoid fragmentation
+const maxUDPMessageSize = 1400
+
+func ilog() *slog.Logger {
+	return slog.With("component", "flows.IPFIXExporter")
+}
+
+// Config of the IPFIX flows exporter
+type Config struct {
+	// Endpoint of the IPFIX collector, as host:port. If empty, the exporter is disabled.
+	Endpoint string `yaml:"endpoint" env:"BEYLA_NETWORK_IPFIX_ENDPOINT"`
+	// Transport protocol used to submit the flows: udp (default) or tcp
+	Transport string `yaml:"transport" env:"BEYLA_NETWORK_IPFIX_TRANSPORT"`
+	// TemplateRefresh is the period after which the templates are submitted again over UDP, as
+	// the collector could have been restarted or missed them. If zero, the templates are submitted
+	// in every message. Over TCP, the templates are submitted once for each connection.
+	TemplateRefresh time.Duration `yaml:"template_refresh" env:"BEYLA_NETWORK_IPFIX_TEMPLATE_REFRESH"`
+	// ObservationDomainID that identifies the Beyla instance in the collector
+	ObservationDomainID uint32 `yaml:"observation_domain_id" env:"BEYLA_NETWORK_IPFIX_OBSERVATION_DOMAIN_ID"`
+	// EnterpriseID is the Private Enterprise Number of the information elements that carry the
+	// Kubernetes metadata of the flows.
+	EnterpriseID uint32 `yaml:"enterprise_id" env:"BEYLA_NETWORK_IPFIX_ENTERPRISE_ID"`
+}
+
+// nolint:gocritic
+func (c Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+func (c *Config) Validate() error {
+	if c.Endpoint != "" {
+		if _, _, err := net.SplitHostPort(c.Endpoint); err != nil {
+			return fmt.Errorf("invalid endpoint %q. Expected format: host:port", c.Endpoint)
+		}
+	}
+	switch c.Transport {
+	case "", TransportUDP, TransportTCP:
+	default:
+		return fmt.Errorf("unsupported transport %q. Accepted values: %s, %s", c.Transport, TransportUDP, TransportTCP)
+	}
+	if c.TemplateRefresh < 0 {
+		return fmt.Errorf("template_refresh can't be negative: %s", c.TemplateRefresh)
+	}
+	return nil
+}
+
+func (c *Config) transport() string {
+	if c.Transport == "" {
+		return TransportUDP
+	}
+	return c.Transport
+}
+
+// ExporterProvider submits each batch of flow records to the IPFIX collector.
+// nolint:gocritic
+func ExporterProvider(cfg Config) (node.TerminalFunc[[]*ebpf.Record], error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	exp := newExporter(&cfg)
+	return exp.Do, nil
+}
+
+type exporter struct {
+	cfg       *Config
+	dial      func(network, address string, timeout time.Duration) (net.Conn, error)
+	conn      net.Conn
+	timeout   time.Duration
+	clock     func() time.Time
+	monoClock func() time.Duration
+	encoder   *encoder
+	// lastTemplates is the last time the templates were submitted
+	lastTemplates time.Time
+}
+
+func newExporter(cfg *Config) *exporter {
+	maxSize := maxUDPMessageSize
+	if cfg.transport() == TransportTCP {
+		maxSize = maxMessageSize
+	}
+	return &exporter{
+		cfg:       cfg,
+		dial:      net.DialTimeout,
+		timeout:   ioTimeout,
+		clock:     time.Now,
+		monoClock: monotime.Now,
+		encoder:   newEncoder(cfg.ObservationDomainID, cfg.EnterpriseID, maxSize),
+	}
+}
+
+func (e *exporter) Do(in <-chan []*ebpf.Record) {
+	log := ilog()
+	defer e.close()
+	for flows := range in {
+		if len(flows) == 0 {
+			continue
+		}
+		if err := e.submit(flows); err != nil {
+			log.Warn("can't submit flows to the IPFIX collector. Discarding them and reconnecting with the next batch",
+				"error", err, "len", len(flows))
+			e.close()
+		}
+	}
+}
+
+func (e *exporter) submit(flows []*ebpf.Record) error {
+	if e.conn == nil {
+		conn, err := e.dial(e.cfg.transport(), e.cfg.Endpoint, e.timeout)
+		if err != nil {
+			return fmt.Errorf("connecting to %s: %w", e.cfg.Endpoint, err)
+		}
+		e.conn = conn
+		// a new transport session starts its own sequence, and needs to receive the templates
+		e.encoder.reset()
+		e.lastTemplates = time.Time{}
+	}
+	now := e.clock()
+	withTemplates := e.lastTemplates.IsZero() ||
+		(e.cfg.transport() == TransportUDP && now.Sub(e.lastTemplates) >= e.cfg.TemplateRefresh)
+	messages := e.encoder.encode(flows, now, e.monoClock(), withTemplates)
+	if err := e.conn.SetWriteDeadline(time.Now().Add(e.timeout)); err != nil {
+		return fmt.Errorf("setting write deadline: %w", err)
+	}
+	for _, msg := range messages {
+		if _, err := e.conn.Write(msg); err != nil {
+			return fmt.Errorf("writing IPFIX message: %w", err)
+		}
+	}
+	if withTemplates {
+		e.lastTemplates = now
+	}
+	return nil
+}
+
+func (e *exporter) close() {
+	if e.conn == nil {
+		return
+	}
+	if err := e.conn.Close(); err != nil {
+		ilog().Debug("closing IPFIX connection", "error", err)
+	}
+	e.conn = nil
+}
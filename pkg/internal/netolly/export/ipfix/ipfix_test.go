@@ -0,0 +1,345 @@
+package ipfix
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const timeout = 5 * time.Second
+
+var startTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func TestExporter_UDP(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer collector.Close()
+
+	exp := newExporter(&Config{
+		Endpoint:            collector.LocalAddr().String(),
+		TemplateRefresh:     time.Hour,
+		ObservationDomainID: 33,
+		EnterpriseID:        DefaultEnterpriseID,
+	})
+	// each batch is submitted 31 minutes after the previous one
+	now := startTime.Add(-31 * time.Minute)
+	exp.clock = func() time.Time {
+		now = now.Add(31 * time.Minute)
+		return now
+	}
+	exp.monoClock = func() time.Duration { return 10 * time.Second }
+
+	in := make(chan []*ebpf.Record, 10)
+	go exp.Do(in)
+
+	in <- []*ebpf.Record{testFlowV4(), testFlowV6()}
+	msg := readMessage(t, collector)
+	assert.Equal(t, uint16(ipfixVersion), msg.version)
+	assert.Equal(t, uint32(33), msg.domainID)
+	assert.Equal(t, uint32(0), msg.sequence)
+	assert.Equal(t, uint32(startTime.Unix()), msg.exportTime)
+	require.Contains(t, msg.templates, uint16(templateIPv4))
+	require.Contains(t, msg.templates, uint16(templateIPv6))
+	require.Len(t, msg.records, 2)
+
+	v4 := msg.records[0]
+	assert.Equal(t, uint16(templateIPv4), v4.templateID)
+	assert.Equal(t, net.IPv4(10, 0, 0, 1).To4(), net.IP(v4.fields[ieSourceIPv4Address]))
+	assert.Equal(t, net.IPv4(10, 0, 0, 2).To4(), net.IP(v4.fields[ieDestinationIPv4Address]))
+	assert.Equal(t, uint16(34567), binary.BigEndian.Uint16(v4.fields[ieSourceTransportPort]))
+	assert.Equal(t, uint16(8080), binary.BigEndian.Uint16(v4.fields[ieDestinationTransportPort]))
+	assert.Equal(t, []byte{6}, v4.fields[ieProtocolIdentifier])
+	assert.Equal(t, uint64(1234), binary.BigEndian.Uint64(v4.fields[ieOctetDeltaCount]))
+	assert.Equal(t, uint64(3), binary.BigEndian.Uint64(v4.fields[iePacketDeltaCount]))
+	assert.Equal(t, uint64(startTime.Add(-3*time.Second).UnixMilli()), binary.BigEndian.Uint64(v4.fields[ieFlowStartMilliseconds]))
+	assert.Equal(t, uint64(startTime.Add(-time.Second).UnixMilli()), binary.BigEndian.Uint64(v4.fields[ieFlowEndMilliseconds]))
+	assert.Equal(t, []byte{ebpf.DirectionEgress}, v4.fields[ieFlowDirection])
+	// SYN_ACK custom flag is converted to SYN|ACK
+	assert.Equal(t, uint16(flagSYN|flagACK), binary.BigEndian.Uint16(v4.fields[ieTCPControlBits]))
+	assert.Equal(t, map[string]string{
+		"src.name":           "client",
+		"dst.name":           "server",
+		"k8s.src.namespace":  "shop",
+		"k8s.src.owner.name": "frontend",
+		"k8s.src.owner.type": "Deployment",
+		"k8s.dst.namespace":  "shop",
+		"k8s.dst.owner.name": "backend",
+		"k8s.dst.owner.type": "StatefulSet",
+		"k8s.cluster.name":   "prod",
+	}, v4.enterprise)
+
+	v6 := msg.records[1]
+	assert.Equal(t, uint16(templateIPv6), v6.templateID)
+	assert.Equal(t, net.ParseIP("fd00::1"), net.IP(v6.fields[ieSourceIPv6Address]))
+	assert.Equal(t, net.ParseIP("fd00::2"), net.IP(v6.fields[ieDestinationIPv6Address]))
+	assert.Equal(t, "", v6.enterprise["k8s.src.namespace"])
+
+	// templates are not submitted again until the refresh period
+	in <- []*ebpf.Record{testFlowV4()}
+	msg = readMessage(t, collector)
+	assert.Empty(t, msg.templates)
+	assert.Equal(t, uint32(2), msg.sequence)
+	require.Len(t, msg.records, 1)
+
+	in <- []*ebpf.Record{testFlowV4()}
+	msg = readMessage(t, collector)
+	assert.Len(t, msg.templates, 2)
+	assert.Equal(t, uint32(3), msg.sequence)
+}
+
+func TestExporter_TCP(t *testing.T) {
+	collector, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer collector.Close()
+
+	exp := newExporter(&Config{
+		Endpoint:     collector.Addr().String(),
+		Transport:    TransportTCP,
+		EnterpriseID: DefaultEnterpriseID,
+	})
+	in := make(chan []*ebpf.Record, 10)
+	go exp.Do(in)
+	in <- []*ebpf.Record{testFlowV4()}
+	in <- []*ebpf.Record{testFlowV4()}
+
+	conn, err := collector.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout)))
+
+	// over TCP, templates are only submitted at the beginning of the connection,
+	// even if the refresh period is zero
+	first := readStreamMessage(t, conn)
+	assert.Len(t, first.templates, 2)
+	require.Len(t, first.records, 1)
+	second := readStreamMessage(t, conn)
+	assert.Empty(t, second.templates)
+	require.Len(t, second.records, 1)
+	assert.Equal(t, uint32(1), second.sequence)
+}
+
+func TestExporter_WriteTimeout(t *testing.T) {
+	exp := newExporter(&Config{Endpoint: "collector:4739", Transport: TransportTCP})
+	exp.timeout = 50 * time.Millisecond
+	// the collector side of the pipe never reads, so the writes block until the deadline
+	var collectors []net.Conn
+	exp.dial = func(_, _ string, _ time.Duration) (net.Conn, error) {
+		client, collector := net.Pipe()
+		collectors = append(collectors, collector)
+		return client, nil
+	}
+	defer func() {
+		for _, c := range collectors {
+			c.Close()
+		}
+	}()
+
+	in := make(chan []*ebpf.Record)
+	done := make(chan struct{})
+	go func() {
+		exp.Do(in)
+		close(done)
+	}()
+	// the unsent batches are discarded, so the exporter keeps accepting new batches
+	for i := 0; i < 3; i++ {
+		select {
+		case in <- []*ebpf.Record{testFlowV4()}:
+		case <-time.After(timeout):
+			require.Fail(t, "the exporter is blocked by the collector")
+		}
+	}
+	close(in)
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		require.Fail(t, "the exporter did not finish")
+	}
+	// a new connection is created after each failed batch
+	assert.Len(t, collectors, 3)
+}
+
+func TestEncoder_SplitMessages(t *testing.T) {
+	enc := newEncoder(1, DefaultEnterpriseID, maxUDPMessageSize)
+	flows := make([]*ebpf.Record, 50)
+	for i := range flows {
+		flows[i] = testFlowV4()
+	}
+	messages := enc.encode(flows, startTime, 10*time.Second, true)
+	require.Greater(t, len(messages), 1)
+	records := 0
+	templates := map[uint16][][2]uint16{}
+	for i, raw := range messages {
+		assert.LessOrEqual(t, len(raw), maxUDPMessageSize)
+		msg := decodeMessage(t, raw, templates)
+		assert.Equal(t, uint32(records), msg.sequence)
+		if i == 0 {
+			assert.Len(t, msg.templates, 2)
+		} else {
+			assert.Empty(t, msg.templates)
+		}
+		records += len(msg.records)
+	}
+	assert.Equal(t, len(flows), records)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	require.NoError(t, (&Config{}).Validate())
+	require.NoError(t, (&Config{Transport: TransportTCP}).Validate())
+	require.Error(t, (&Config{Transport: "sctp"}).Validate())
+	require.Error(t, (&Config{TemplateRefresh: -time.Second}).Validate())
+	require.NoError(t, (&Config{Endpoint: "collector:4739"}).Validate())
+	require.Error(t, (&Config{Endpoint: "collector"}).Validate())
+}
+
+func testFlowV4() *ebpf.Record {
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{
+		SrcName: "client", DstName: "server",
+		Metadata: map[string]string{
+			"k8s.src.namespace":  "shop",
+			"k8s.src.owner.name": "frontend",
+			"k8s.src.owner.type": "Deployment",
+			"k8s.dst.namespace":  "shop",
+			"k8s.dst.owner.name": "backend",
+			"k8s.dst.owner.type": "StatefulSet",
+			"k8s.cluster.name":   "prod",
+		},
+	}}
+	flow.Id.Direction = ebpf.DirectionEgress
+	flow.Id.SrcPort, flow.Id.DstPort = 34567, 8080
+	flow.Id.TransportProtocol = 6
+	copy(flow.Id.SrcIp.In6U.U6Addr8[:], net.IPv4(10, 0, 0, 1))
+	copy(flow.Id.DstIp.In6U.U6Addr8[:], net.IPv4(10, 0, 0, 2))
+	flow.Metrics.Bytes, flow.Metrics.Packets, flow.Metrics.Flags = 1234, 3, flagSYNACK
+	flow.Metrics.StartMonoTimeNs = uint64(7 * time.Second)
+	flow.Metrics.EndMonoTimeNs = uint64(9 * time.Second)
+	return flow
+}
+
+func testFlowV6() *ebpf.Record {
+	flow := &ebpf.Record{}
+	flow.Id.TransportProtocol = 17
+	copy(flow.Id.SrcIp.In6U.U6Addr8[:], net.ParseIP("fd00::1"))
+	copy(flow.Id.DstIp.In6U.U6Addr8[:], net.ParseIP("fd00::2"))
+	return flow
+}
+
+type decodedRecord struct {
+	templateID uint16
+	fields     map[uint16][]byte
+	enterprise map[string]string
+}
+
+type decodedMessage struct {
+	version    uint16
+	exportTime uint32
+	sequence   uint32
+	domainID   uint32
+	// templates by ID, and their field specifiers as (ID, length) pairs
+	templates map[uint16][][2]uint16
+	records   []decodedRecord
+}
+
+// persistent templates for decoding the messages that don't include them
+var knownTemplates = map[uint16][][2]uint16{}
+
+func readMessage(t *testing.T, conn net.PacketConn) *decodedMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(timeout)))
+	buf := make([]byte, maxMessageSize)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return decodeMessage(t, buf[:n], knownTemplates)
+}
+
+func readStreamMessage(t *testing.T, conn net.Conn) *decodedMessage {
+	t.Helper()
+	header := make([]byte, headerLen)
+	_, err := readFull(conn, header)
+	require.NoError(t, err)
+	msg := make([]byte, binary.BigEndian.Uint16(header[2:]))
+	copy(msg, header)
+	_, err = readFull(conn, msg[headerLen:])
+	require.NoError(t, err)
+	return decodeMessage(t, msg, knownTemplates)
+}
+
+func readFull(conn net.Conn, buf []byte) (int, error) {
+	read := 0
+	for read < len(buf) {
+		n, err := conn.Read(buf[read:])
+		if err != nil {
+			return read, err
+		}
+		read += n
+	}
+	return read, nil
+}
+
+func decodeMessage(t *testing.T, raw []byte, templates map[uint16][][2]uint16) *decodedMessage {
+	t.Helper()
+	require.GreaterOrEqual(t, len(raw), headerLen)
+	msg := &decodedMessage{
+		version:    binary.BigEndian.Uint16(raw[0:]),
+		exportTime: binary.BigEndian.Uint32(raw[4:]),
+		sequence:   binary.BigEndian.Uint32(raw[8:]),
+		domainID:   binary.BigEndian.Uint32(raw[12:]),
+		templates:  map[uint16][][2]uint16{},
+	}
+	require.Equal(t, len(raw), int(binary.BigEndian.Uint16(raw[2:])))
+	sets := raw[headerLen:]
+	for len(sets) > 0 {
+		setID := binary.BigEndian.Uint16(sets[0:])
+		setLen := binary.BigEndian.Uint16(sets[2:])
+		body := sets[setHeaderLen:setLen]
+		sets = sets[setLen:]
+		if setID == templateSetID {
+			for len(body) > 0 {
+				id, count := binary.BigEndian.Uint16(body[0:]), binary.BigEndian.Uint16(body[2:])
+				body = body[4:]
+				var fields [][2]uint16
+				for i := 0; i < int(count); i++ {
+					field := [2]uint16{binary.BigEndian.Uint16(body[0:]), binary.BigEndian.Uint16(body[2:])}
+					body = body[4:]
+					if field[0]&enterpriseBit != 0 {
+						assert.Equal(t, uint32(DefaultEnterpriseID), binary.BigEndian.Uint32(body))
+						body = body[4:]
+					}
+					fields = append(fields, field)
+				}
+				msg.templates[id] = fields
+				templates[id] = fields
+			}
+			continue
+		}
+		fields, ok := templates[setID]
+		require.Truef(t, ok, "unknown template %d", setID)
+		for len(body) > 0 {
+			rec := decodedRecord{templateID: setID, fields: map[uint16][]byte{}, enterprise: map[string]string{}}
+			for _, field := range fields {
+				length := int(field[1])
+				if length == varLength {
+					length = int(body[0])
+					body = body[1:]
+					if length == 255 {
+						length = int(binary.BigEndian.Uint16(body))
+						body = body[2:]
+					}
+				}
+				if field[0]&enterpriseBit != 0 {
+					rec.enterprise[enterpriseAttributes[field[0]&^enterpriseBit-1].Name] = string(body[:length])
+				} else {
+					rec.fields[field[0]] = body[:length]
+				}
+				body = body[length:]
+			}
+			msg.records = append(msg.records, rec)
+		}
+	}
+	return msg
+}
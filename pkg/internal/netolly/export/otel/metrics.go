@@ -19,9 +19,23 @@ import (
 	"github.com/grafana/beyla/pkg/internal/netolly/export"
 )
 
+const (
+	flowBytesName       = "beyla.network.flow.bytes"
+	flowRTTName         = "beyla.network.flow.rtt"
+	flowRetransmitsName = "beyla.network.flow.retransmits"
+	flowResetsName      = "beyla.network.flow.resets"
+	flowSynsName        = "beyla.network.flow.syns"
+	flowDurationName    = "beyla.network.flow.duration"
+	flowSizeName        = "beyla.network.flow.size"
+	flowPacketsName     = "beyla.network.flow.packets"
+)
+
 type MetricsConfig struct {
 	Metrics           *otel.MetricsConfig
 	AllowedAttributes []string
+	// RTTBuckets of the TCP round-trip time histogram, in seconds
+	RTTBuckets []float64
+	Histograms export.FlowHistograms
 }
 
 func (mc MetricsConfig) Enabled() bool {
@@ -48,19 +62,44 @@ func newResource() *resource.Resource {
 	return resource.NewWithAttributes(semconv.SchemaURL, attrs...)
 }
 
-func newMeterProvider(res *resource.Resource, exporter *metric.Exporter) (*metric.MeterProvider, error) {
-	meterProvider := metric.NewMeterProvider(
+func newMeterProvider(res *resource.Resource, exporter *metric.Exporter, rttBuckets []float64, histograms *export.FlowHistograms) (*metric.MeterProvider, error) {
+	opts := []metric.Option{
 		metric.WithResource(res),
 		metric.WithReader(metric.NewPeriodicReader(*exporter,
 			// Default is 1m. Set to 3s for demonstrative purposes.
 			metric.WithInterval(1*time.Second))),
-	)
+	}
+	opts = appendBucketsView(opts, flowRTTName, rttBuckets)
+	if histograms.Enable {
+		opts = appendBucketsView(opts, flowDurationName, histograms.DurationBuckets)
+		opts = appendBucketsView(opts, flowSizeName, histograms.BytesBuckets)
+		opts = appendBucketsView(opts, flowPacketsName, histograms.PacketsBuckets)
+	}
+	meterProvider := metric.NewMeterProvider(opts...)
 	return meterProvider, nil
 }
 
+func appendBucketsView(opts []metric.Option, instrument string, buckets []float64) []metric.Option {
+	if len(buckets) == 0 {
+		return opts
+	}
+	return append(opts, metric.WithView(metric.NewView(
+		metric.Instrument{Name: instrument},
+		metric.Stream{Aggregation: metric.AggregationExplicitBucketHistogram{Boundaries: buckets}},
+	)))
+}
+
 type metricsExporter struct {
-	flowBytes metric2.Int64Counter
-	attrs     []export.Attribute
+	flowBytes       metric2.Int64Counter
+	flowRTT         metric2.Float64Histogram
+	flowRetransmits metric2.Int64Counter
+	flowResets      metric2.Int64Counter
+	flowSyns        metric2.Int64Counter
+	// flow histograms are nil if they are not enabled
+	flowDuration metric2.Float64Histogram
+	flowSize     metric2.Int64Histogram
+	flowPackets  metric2.Int64Histogram
+	attrs        []export.Attribute
 }
 
 func (me *metricsExporter) attributes(m *ebpf.Record) []attribute.KeyValue {
@@ -83,7 +122,7 @@ func MetricsExporterProvider(cfg MetricsConfig) (node.TerminalFunc[[]*ebpf.Recor
 		return nil, err
 	}
 
-	provider, err := newMeterProvider(newResource(), &exporter)
+	provider, err := newMeterProvider(newResource(), &exporter, cfg.RTTBuckets, &cfg.Histograms)
 
 	if err != nil {
 		log.Error("", "error", err)
@@ -93,7 +132,7 @@ func MetricsExporterProvider(cfg MetricsConfig) (node.TerminalFunc[[]*ebpf.Recor
 	ebpfEvents := provider.Meter("network_ebpf_events")
 
 	flowBytes, err := ebpfEvents.Int64Counter(
-		"beyla.network.flow.bytes",
+		flowBytesName,
 		metric2.WithDescription("total bytes_sent value of network flows observed by probe since its launch"),
 		metric2.WithUnit("{bytes}"),
 	)
@@ -102,25 +141,122 @@ func MetricsExporterProvider(cfg MetricsConfig) (node.TerminalFunc[[]*ebpf.Recor
 		return nil, err
 	}
 
+	flowRTT, err := ebpfEvents.Float64Histogram(
+		flowRTTName,
+		metric2.WithDescription("smoothed round-trip time of the TCP connections observed by probe"),
+		metric2.WithUnit("s"),
+	)
+	if err != nil {
+		log.Error("", "error", err)
+		return nil, err
+	}
+
+	flowRetransmits, err := ebpfEvents.Int64Counter(
+		flowRetransmitsName,
+		metric2.WithDescription("total TCP segments retransmitted in the network flows observed by probe since its launch"),
+		metric2.WithUnit("{segments}"),
+	)
+	if err != nil {
+		log.Error("", "error", err)
+		return nil, err
+	}
+
+	flowResets, err := ebpfEvents.Int64Counter(
+		flowResetsName,
+		metric2.WithDescription("total TCP resets (RST packets) in the network flows observed by probe since its launch"),
+		metric2.WithUnit("{packets}"),
+	)
+	if err != nil {
+		log.Error("", "error", err)
+		return nil, err
+	}
+
+	flowSyns, err := ebpfEvents.Int64Counter(
+		flowSynsName,
+		metric2.WithDescription("total TCP connection attempts (SYN packets without ACK) in the network flows observed by probe since its launch"),
+		metric2.WithUnit("{packets}"),
+	)
 	if err != nil {
 		log.Error("", "error", err)
 		return nil, err
 	}
+	me := &metricsExporter{
+		flowBytes:       flowBytes,
+		flowRTT:         flowRTT,
+		flowRetransmits: flowRetransmits,
+		flowResets:      flowResets,
+		flowSyns:        flowSyns,
+		attrs:           export.BuildOTELAttributeGetters(cfg.AllowedAttributes),
+	}
+	if cfg.Histograms.Enable {
+		if err := me.instantiateHistograms(ebpfEvents); err != nil {
+			log.Error("", "error", err)
+			return nil, err
+		}
+	}
 	log.Debug("restricting attributes not in this list", "attributes", cfg.AllowedAttributes)
-	return (&metricsExporter{
-		flowBytes: flowBytes,
-		attrs:     export.BuildOTELAttributeGetters(cfg.AllowedAttributes),
-	}).Do, nil
+	return me.Do, nil
+}
+
+func (me *metricsExporter) instantiateHistograms(meter metric2.Meter) error {
+	var err error
+	me.flowDuration, err = meter.Float64Histogram(
+		flowDurationName,
+		metric2.WithDescription("time between the first and the last packet of the network flows observed by probe"),
+		metric2.WithUnit("s"),
+	)
+	if err != nil {
+		return err
+	}
+	me.flowSize, err = meter.Int64Histogram(
+		flowSizeName,
+		metric2.WithDescription("bytes of the network flows observed by probe"),
+		metric2.WithUnit("By"),
+	)
+	if err != nil {
+		return err
+	}
+	me.flowPackets, err = meter.Int64Histogram(
+		flowPacketsName,
+		metric2.WithDescription("packets of the network flows observed by probe"),
+		metric2.WithUnit("{packets}"),
+	)
+	return err
 }
 
 func (me *metricsExporter) Do(in <-chan []*ebpf.Record) {
 	for i := range in {
 		for _, v := range i {
-			me.flowBytes.Add(
-				context.Background(),
-				int64(v.Metrics.Bytes),
-				metric2.WithAttributes(me.attributes(v)...),
-			)
+			attrs := metric2.WithAttributes(me.attributes(v)...)
+			me.flowBytes.Add(context.Background(), int64(v.Metrics.Bytes), attrs)
+			me.observeTCP(v, attrs)
+			me.observeHistograms(v, attrs)
 		}
 	}
 }
+
+// observeTCP statistics of the flow. Zero values are not reported, to avoid
+// creating TCP series for flows without TCP activity.
+func (me *metricsExporter) observeTCP(v *ebpf.Record, attrs metric2.MeasurementOption) {
+	if v.TCP.SrttUs > 0 {
+		me.flowRTT.Record(context.Background(), float64(v.TCP.SrttUs)/1e6, attrs)
+	}
+	if v.TCP.Retransmits > 0 {
+		me.flowRetransmits.Add(context.Background(), int64(v.TCP.Retransmits), attrs)
+	}
+	if v.Metrics.Resets > 0 {
+		me.flowResets.Add(context.Background(), int64(v.Metrics.Resets), attrs)
+	}
+	if v.Metrics.Syns > 0 {
+		me.flowSyns.Add(context.Background(), int64(v.Metrics.Syns), attrs)
+	}
+}
+
+func (me *metricsExporter) observeHistograms(v *ebpf.Record, attrs metric2.MeasurementOption) {
+	if me.flowDuration == nil {
+		return
+	}
+	me.flowDuration.Record(context.Background(), export.FlowDuration(v).Seconds(), attrs)
+	me.flowSize.Record(context.Background(), int64(v.Metrics.Bytes), attrs)
+	me.flowPackets.Record(context.Background(), int64(v.Metrics.Packets), attrs)
+}
@@ -1,10 +1,15 @@
 package otel
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
 
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
@@ -104,6 +109,130 @@ func TestMetricAttributes_Filter(t *testing.T) {
 	}
 }
 
+func TestMetricsExporter_TCPStats(t *testing.T) {
+	reader := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	me := &metricsExporter{attrs: export.BuildOTELAttributeGetters([]string{"src.name"})}
+	var err error
+	me.flowBytes, err = meter.Int64Counter(flowBytesName)
+	require.NoError(t, err)
+	me.flowRTT, err = meter.Float64Histogram(flowRTTName)
+	require.NoError(t, err)
+	me.flowRetransmits, err = meter.Int64Counter(flowRetransmitsName)
+	require.NoError(t, err)
+	me.flowResets, err = meter.Int64Counter(flowResetsName)
+	require.NoError(t, err)
+	me.flowSyns, err = meter.Int64Counter(flowSynsName)
+	require.NoError(t, err)
+
+	in := make(chan []*ebpf.Record, 1)
+	tcpFlow := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}
+	tcpFlow.Metrics.Bytes = 100
+	tcpFlow.Metrics.Syns = 1
+	tcpFlow.TCP = ebpf.TCPStats{SrttUs: 2500, Retransmits: 2}
+	in <- []*ebpf.Record{tcpFlow, tcpFlow, {Attrs: ebpf.RecordAttrs{SrcName: "bar"}}}
+	close(in)
+	me.Do(in)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+	// no resets were observed, so the metric is not reported
+	assert.NotContains(t, metrics, flowResetsName)
+
+	syns := metrics[flowSynsName].(metricdata.Sum[int64])
+	require.Len(t, syns.DataPoints, 1)
+	assert.EqualValues(t, 2, syns.DataPoints[0].Value)
+
+	retransmits := metrics[flowRetransmitsName].(metricdata.Sum[int64])
+	require.Len(t, retransmits.DataPoints, 1)
+	assert.EqualValues(t, 4, retransmits.DataPoints[0].Value)
+	assert.Equal(t, attribute.NewSet(attribute.String("src.name", "foo")), retransmits.DataPoints[0].Attributes)
+
+	rtt := metrics[flowRTTName].(metricdata.Histogram[float64])
+	require.Len(t, rtt.DataPoints, 1)
+	assert.EqualValues(t, 2, rtt.DataPoints[0].Count)
+	assert.InDelta(t, 0.005, rtt.DataPoints[0].Sum, 1e-9)
+
+	// the flow without TCP statistics is only reported in the bytes metric
+	bytes := metrics[flowBytesName].(metricdata.Sum[int64])
+	assert.Len(t, bytes.DataPoints, 2)
+}
+
+func TestMetricsExporter_Histograms(t *testing.T) {
+	reader := metric.NewManualReader()
+	opts := []metric.Option{metric.WithReader(reader)}
+	opts = appendBucketsView(opts, flowDurationName, []float64{0.1, 1})
+	opts = appendBucketsView(opts, flowSizeName, []float64{100, 1000})
+	opts = appendBucketsView(opts, flowPacketsName, []float64{1, 10})
+	meter := metric.NewMeterProvider(opts...).Meter("test")
+	me := &metricsExporter{attrs: export.BuildOTELAttributeGetters([]string{"src.name"})}
+	var err error
+	me.flowBytes, err = meter.Int64Counter(flowBytesName)
+	require.NoError(t, err)
+	require.NoError(t, me.instantiateHistograms(meter))
+
+	in := make(chan []*ebpf.Record, 1)
+	short := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}
+	short.Metrics.Bytes, short.Metrics.Packets = 60, 1
+	short.Metrics.StartMonoTimeNs, short.Metrics.EndMonoTimeNs = uint64(time.Second), uint64(time.Second+50*time.Millisecond)
+	long := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}
+	long.Metrics.Bytes, long.Metrics.Packets = 5000, 20
+	long.Metrics.StartMonoTimeNs, long.Metrics.EndMonoTimeNs = uint64(time.Second), uint64(3*time.Second)
+	in <- []*ebpf.Record{short, long}
+	close(in)
+	me.Do(in)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	metrics := map[string]metricdata.Aggregation{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m.Data
+	}
+
+	duration := metrics[flowDurationName].(metricdata.Histogram[float64])
+	require.Len(t, duration.DataPoints, 1)
+	assert.Equal(t, attribute.NewSet(attribute.String("src.name", "foo")), duration.DataPoints[0].Attributes)
+	assert.Equal(t, []float64{0.1, 1}, duration.DataPoints[0].Bounds)
+	assert.Equal(t, []uint64{1, 0, 1}, duration.DataPoints[0].BucketCounts)
+	assert.InDelta(t, 2.05, duration.DataPoints[0].Sum, 1e-9)
+
+	size := metrics[flowSizeName].(metricdata.Histogram[int64])
+	require.Len(t, size.DataPoints, 1)
+	assert.Equal(t, []uint64{1, 0, 1}, size.DataPoints[0].BucketCounts)
+	assert.EqualValues(t, 5060, size.DataPoints[0].Sum)
+
+	packets := metrics[flowPacketsName].(metricdata.Histogram[int64])
+	require.Len(t, packets.DataPoints, 1)
+	assert.Equal(t, []uint64{1, 0, 1}, packets.DataPoints[0].BucketCounts)
+	assert.EqualValues(t, 21, packets.DataPoints[0].Sum)
+}
+
+func TestMetricsExporter_HistogramsDisabled(t *testing.T) {
+	reader := metric.NewManualReader()
+	meter := metric.NewMeterProvider(metric.WithReader(reader)).Meter("test")
+	me := &metricsExporter{attrs: export.BuildOTELAttributeGetters([]string{"src.name"})}
+	var err error
+	me.flowBytes, err = meter.Int64Counter(flowBytesName)
+	require.NoError(t, err)
+
+	in := make(chan []*ebpf.Record, 1)
+	in <- []*ebpf.Record{{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}}
+	close(in)
+	me.Do(in)
+
+	rm := metricdata.ResourceMetrics{}
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	require.Len(t, rm.ScopeMetrics, 1)
+	require.Len(t, rm.ScopeMetrics[0].Metrics, 1)
+	assert.Equal(t, flowBytesName, rm.ScopeMetrics[0].Metrics[0].Name)
+}
+
 func TestMetricsConfig_Enabled(t *testing.T) {
 	assert.True(t, MetricsConfig{Metrics: &otel.MetricsConfig{
 		Features: []string{otel.FeatureApplication, otel.FeatureNetwork}, CommonEndpoint: "foo"}}.Enabled())
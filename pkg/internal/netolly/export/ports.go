@@ -0,0 +1,119 @@
+package export
+
+import (
+	"strconv"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	transportICMP   = 1
+	transportUDP    = 17
+	transportICMPv6 = 58
+	transportSCTP   = 132
+)
+
+// otherServicePort groups the flows whose ports are not well-known, so the number of
+// a port that is not a service port can't cause a cardinality explosion.
+const otherServicePort = "other"
+
+// wellKnownPorts maps the ports of the most common services to their name in the IANA
+// registry, or to the name of the protocol when the IANA name is not commonly used
+// (e.g. dns instead of domain) or the port is not registered (e.g. kafka).
+var wellKnownPorts = map[uint16]string{
+	20:    "ftp-data",
+	21:    "ftp",
+	22:    "ssh",
+	23:    "telnet",
+	25:    "smtp",
+	53:    "dns",
+	67:    "bootps",
+	68:    "bootpc",
+	69:    "tftp",
+	80:    "http",
+	88:    "kerberos",
+	110:   "pop3",
+	123:   "ntp",
+	143:   "imap",
+	161:   "snmp",
+	179:   "bgp",
+	389:   "ldap",
+	443:   "https",
+	445:   "smb",
+	465:   "smtps",
+	514:   "syslog",
+	587:   "submission",
+	636:   "ldaps",
+	853:   "dns-over-tls",
+	993:   "imaps",
+	995:   "pop3s",
+	1433:  "mssql",
+	1521:  "oracle",
+	1883:  "mqtt",
+	2049:  "nfs",
+	2181:  "zookeeper",
+	2379:  "etcd-client",
+	2380:  "etcd-server",
+	3306:  "mysql",
+	3389:  "rdp",
+	4222:  "nats",
+	4317:  "otlp-grpc",
+	4318:  "otlp-http",
+	4739:  "ipfix",
+	5353:  "mdns",
+	5432:  "postgresql",
+	5671:  "amqps",
+	5672:  "amqp",
+	6379:  "redis",
+	6443:  "kubernetes-api",
+	8080:  "http-alt",
+	8443:  "https-alt",
+	9042:  "cassandra",
+	9090:  "prometheus",
+	9092:  "kafka",
+	9200:  "elasticsearch",
+	10250: "kubelet",
+	11211: "memcache",
+	27017: "mongodb",
+}
+
+func transportStr(protocol uint8) string {
+	switch protocol {
+	case transportICMP:
+		return "icmp"
+	case ebpf.TransportTCP:
+		return "tcp"
+	case transportUDP:
+		return "udp"
+	case transportICMPv6:
+		return "icmpv6"
+	case transportSCTP:
+		return "sctp"
+	default:
+		return strconv.Itoa(int(protocol))
+	}
+}
+
+// servicePort returns the name of the server-side port of the flow. To keep a low cardinality,
+// the flows without any well-known port are grouped as "other".
+func servicePort(id *ebpf.NetFlowId) string {
+	switch id.TransportProtocol {
+	case ebpf.TransportTCP, transportUDP, transportSCTP:
+	default:
+		return ""
+	}
+	srcName, srcOk := wellKnownPorts[id.SrcPort]
+	dstName, dstOk := wellKnownPorts[id.DstPort]
+	switch {
+	case srcOk && dstOk:
+		if id.SrcPort < id.DstPort {
+			return srcName
+		}
+		return dstName
+	case dstOk:
+		return dstName
+	case srcOk:
+		return srcName
+	}
+	return otherServicePort
+}
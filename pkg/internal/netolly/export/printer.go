@@ -1,31 +1,63 @@
 package export
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/gavv/monotime"
 	"github.com/mariomac/pipes/pkg/node"
 
+	"github.com/grafana/beyla/pkg/internal/export/debug"
 	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
 )
 
-type FlowPrinterEnabled bool
+// FlowPrinterConfig of the flows printer
+type FlowPrinterConfig struct {
+	Print  bool
+	Format debug.PrintFormat
+	// Output is either "stdout" or the path of the file where the flows are printed
+	Output string
+}
 
-func (fpe FlowPrinterEnabled) Enabled() bool {
-	return bool(fpe)
+func (fpc FlowPrinterConfig) Enabled() bool {
+	return fpc.Print
 }
 
-func FlowPrinterProvider(_ FlowPrinterEnabled) (node.TerminalFunc[[]*ebpf.Record], error) {
+func FlowPrinterProvider(cfg FlowPrinterConfig) (node.TerminalFunc[[]*ebpf.Record], error) {
+	out, err := debug.OpenOutput(cfg.Output)
+	if err != nil {
+		return nil, err
+	}
+	fp := flowPrinter{clock: time.Now, monoClock: monotime.Now}
+	printFlow := fp.printText
+	if cfg.Format == debug.PrintFormatJSON {
+		printFlow = fp.printJSON
+	}
 	return func(in <-chan []*ebpf.Record) {
+		defer out.Close()
+		buf := bufio.NewWriter(out)
 		for flows := range in {
 			for _, flow := range flows {
-				printFlow(flow)
+				if err := printFlow(buf, flow); err != nil {
+					slog.Warn("can't print flow", "component", "export.FlowPrinter", "error", err)
+				}
 			}
+			_ = buf.Flush()
 		}
 	}, nil
 }
 
-func printFlow(f *ebpf.Record) {
+type flowPrinter struct {
+	clock     func() time.Time
+	monoClock func() time.Duration
+}
+
+func (fp *flowPrinter) printText(out io.Writer, f *ebpf.Record) error {
 	sb := strings.Builder{}
 	sb.WriteString("beyla.ip=")
 	sb.WriteString(f.Attrs.BeylaIP)
@@ -49,5 +81,21 @@ func printFlow(f *ebpf.Record) {
 		sb.WriteString(v)
 	}
 
-	fmt.Println("network_flow:", sb.String())
+	_, err := fmt.Fprintln(out, "network_flow:", sb.String())
+	return err
 }
+
+// printJSON prints all the attributes of the flow as a flat JSON object. The metadata
+// attributes are placed at the same level as the rest of attributes.
+func (fp *flowPrinter) printJSON(out io.Writer, f *ebpf.Record) error {
+	obj := make(jsonObject, FlowAttributesLen(f))
+	WriteFlowAttributes(obj, f, fp.clock(), fp.monoClock())
+	return json.NewEncoder(out).Encode(obj)
+}
+
+// jsonObject implements FlowAttributesWriter for the JSON printer
+type jsonObject map[string]any
+
+func (o jsonObject) PutStr(name, value string)            { o[name] = value }
+func (o jsonObject) PutInt(name string, value int64)      { o[name] = value }
+func (o jsonObject) PutTime(name string, value time.Time) { o[name] = value }
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+func TestFlowPrinter_JSON(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fp := flowPrinter{
+		clock:     func() time.Time { return now },
+		monoClock: func() time.Duration { return 10 * time.Second },
+	}
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{
+		SrcName: "client", DstName: "server", Interface: "eth0", BeylaIP: "1.2.3.4",
+		Metadata: map[string]string{"k8s.src.namespace": "shop"},
+	}}
+	flow.Id.Direction = ebpf.DirectionEgress
+	flow.Id.SrcPort, flow.Id.DstPort = 34567, 8080
+	flow.Id.TransportProtocol = 6
+	flow.Id.EthProtocol = 0x800
+	flow.Id.IfIndex = 3
+	copy(flow.Id.SrcIp.In6U.U6Addr8[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 1})
+	copy(flow.Id.DstIp.In6U.U6Addr8[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 0, 0, 2})
+	flow.Metrics.Bytes, flow.Metrics.Packets, flow.Metrics.Flags = 1234, 3, 0x12
+	flow.Metrics.StartMonoTimeNs = uint64(7 * time.Second)
+	flow.Metrics.EndMonoTimeNs = uint64(9 * time.Second)
+
+	out := bytes.Buffer{}
+	require.NoError(t, fp.printJSON(&out, flow))
+	printed := map[string]any{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, map[string]any{
+		"beyla.ip":          "1.2.3.4",
+		"iface":             "eth0",
+		"direction":         "egress",
+		"src.address":       "10.0.0.1",
+		"dst.address":       "10.0.0.2",
+		"src.name":          "client",
+		"dst.name":          "server",
+		"src.port":          float64(34567),
+		"dst.port":          float64(8080),
+		"transport":         float64(6),
+		"eth.protocol":      float64(0x800),
+		"if.index":          float64(3),
+		"bytes":             float64(1234),
+		"packets":           float64(3),
+		"tcp.flags":         float64(0x12),
+		"tcp.resets":        float64(0),
+		"tcp.syns":          float64(0),
+		"start":             "2024-01-02T03:04:02Z",
+		"end":               "2024-01-02T03:04:04Z",
+		"k8s.src.namespace": "shop",
+	}, printed)
+}
+
+func TestFlowPrinter_JSON_TCPStats(t *testing.T) {
+	fp := flowPrinter{
+		clock:     time.Now,
+		monoClock: func() time.Duration { return 0 },
+	}
+	flow := &ebpf.Record{TCP: ebpf.TCPStats{SrttUs: 1500, Retransmits: 2}}
+	flow.Id.TransportProtocol = ebpf.TransportTCP
+	flow.Metrics.Resets, flow.Metrics.Syns = 1, 3
+
+	out := bytes.Buffer{}
+	require.NoError(t, fp.printJSON(&out, flow))
+	printed := map[string]any{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, float64(1500), printed["tcp.srtt_us"])
+	assert.Equal(t, float64(2), printed["tcp.retransmits"])
+	assert.Equal(t, float64(1), printed["tcp.resets"])
+	assert.Equal(t, float64(3), printed["tcp.syns"])
+}
+
+func TestFlowPrinter_JSON_NAT(t *testing.T) {
+	fp := flowPrinter{
+		clock:     time.Now,
+		monoClock: func() time.Duration { return 0 },
+	}
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{NAT: &ebpf.NAT{
+		Original:   ebpf.Endpoints{SrcPort: 45678, DstPort: 80},
+		Translated: ebpf.Endpoints{SrcPort: 45678, DstPort: 8080},
+	}}}
+	copy(flow.Attrs.NAT.Original.DstIP[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 96, 0, 10})
+	copy(flow.Attrs.NAT.Translated.DstIP[:], []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 10, 244, 2, 7})
+
+	out := bytes.Buffer{}
+	require.NoError(t, fp.printJSON(&out, flow))
+	printed := map[string]any{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &printed))
+	assert.Equal(t, "10.96.0.10", printed["nat.original.dst.address"])
+	assert.Equal(t, float64(80), printed["nat.original.dst.port"])
+	assert.Equal(t, "10.244.2.7", printed["nat.translated.dst.address"])
+	assert.Equal(t, float64(8080), printed["nat.translated.dst.port"])
+	assert.Equal(t, float64(45678), printed["nat.translated.src.port"])
+}
+
+func TestFlowPrinter_Text(t *testing.T) {
+	fp := flowPrinter{}
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "client", DstName: "server", Interface: "eth0", BeylaIP: "1.2.3.4"}}
+	out := bytes.Buffer{}
+	require.NoError(t, fp.printText(&out, flow))
+	assert.Equal(t, "network_flow: beyla.ip=1.2.3.4 iface=eth0 direction=0 src.address=:: dst.address=::"+
+		" src.name=client dst.name=server\n", out.String())
+}
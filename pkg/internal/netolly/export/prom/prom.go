@@ -0,0 +1,173 @@
+package prom
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/mariomac/pipes/pkg/node"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/beyla/pkg/internal/connector"
+	"github.com/grafana/beyla/pkg/internal/export/otel"
+	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+)
+
+const (
+	FlowBytes       = "beyla_network_flow_bytes_total"
+	FlowRTT         = "beyla_network_flow_rtt_seconds"
+	FlowRetransmits = "beyla_network_flow_retransmits_total"
+	FlowResets      = "beyla_network_flow_resets_total"
+	FlowSyns        = "beyla_network_flow_syns_total"
+	FlowDuration    = "beyla_network_flow_duration_seconds"
+	FlowSize        = "beyla_network_flow_size_bytes"
+	FlowPackets     = "beyla_network_flow_packets"
+)
+
+// PrometheusConfig for network metrics just wraps the global prom.PrometheusConfig as provided by the user
+type PrometheusConfig struct {
+	Config            *prom.PrometheusConfig
+	AllowedAttributes []string
+	// RTTBuckets of the TCP round-trip time histogram, in seconds
+	RTTBuckets []float64
+	Histograms export.FlowHistograms
+}
+
+// nolint:gocritic
+func (p PrometheusConfig) Enabled() bool {
+	return p.Config != nil && p.Config.EndpointEnabled() && slices.Contains(p.Config.Features, otel.FeatureNetwork)
+}
+
+func plog() *slog.Logger {
+	return slog.With("component", "flows.PrometheusReporter")
+}
+
+type metricsReporter struct {
+	flowBytes       *prom.Expirer[prometheus.Counter]
+	flowRTT         *prom.Expirer[prometheus.Histogram]
+	flowRetransmits *prom.Expirer[prometheus.Counter]
+	flowResets      *prom.Expirer[prometheus.Counter]
+	flowSyns        *prom.Expirer[prometheus.Counter]
+	// flow histograms are nil if they are not enabled
+	flowDuration *prom.Expirer[prometheus.Histogram]
+	flowSize     *prom.Expirer[prometheus.Histogram]
+	flowPackets  *prom.Expirer[prometheus.Histogram]
+	attrs        []export.Attribute
+}
+
+// PrometheusEndpoint exposes the network flow metrics through the Prometheus manager,
+// which might share the port and path with the application metrics.
+func PrometheusEndpoint(ctx context.Context, cfg *PrometheusConfig, promMgr *connector.PrometheusManager) (node.TerminalFunc[[]*ebpf.Record], error) {
+	plog().Debug("restricting attributes not in this list", "attributes", cfg.AllowedAttributes)
+	mr := newReporter(cfg)
+	promMgr.Register(cfg.Config.Port, cfg.Config.Path, mr.collectors()...)
+	return func(in <-chan []*ebpf.Record) {
+		go promMgr.StartHTTP(ctx)
+		mr.observe(in)
+	}, nil
+}
+
+func newReporter(cfg *PrometheusConfig) *metricsReporter {
+	attrs := export.BuildPromAttributeGetters(cfg.AllowedAttributes)
+	labelNames := make([]string, 0, len(attrs))
+	for _, attr := range attrs {
+		labelNames = append(labelNames, attr.Name)
+	}
+	mr := &metricsReporter{
+		attrs: attrs,
+		flowBytes: prom.NewExpirer[prometheus.Counter](prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FlowBytes,
+			Help: "bytes submitted from a source network endpoint to a destination network endpoint",
+		}, labelNames).MetricVec, cfg.Config.TTL, nil),
+		flowRTT: prom.NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    FlowRTT,
+			Help:    "smoothed round-trip time of the TCP connections between a source and a destination network endpoint, in seconds",
+			Buckets: cfg.RTTBuckets,
+		}, labelNames).MetricVec, cfg.Config.TTL, nil),
+		flowRetransmits: prom.NewExpirer[prometheus.Counter](prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FlowRetransmits,
+			Help: "TCP segments retransmitted from a source network endpoint to a destination network endpoint",
+		}, labelNames).MetricVec, cfg.Config.TTL, nil),
+		flowResets: prom.NewExpirer[prometheus.Counter](prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FlowResets,
+			Help: "TCP resets (RST packets) sent from a source network endpoint to a destination network endpoint",
+		}, labelNames).MetricVec, cfg.Config.TTL, nil),
+		flowSyns: prom.NewExpirer[prometheus.Counter](prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: FlowSyns,
+			Help: "TCP connection attempts (SYN packets without ACK) from a source network endpoint to a destination network endpoint",
+		}, labelNames).MetricVec, cfg.Config.TTL, nil),
+	}
+	if cfg.Histograms.Enable {
+		mr.flowDuration = prom.NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    FlowDuration,
+			Help:    "time between the first and the last packet of the flows from a source network endpoint to a destination network endpoint, in seconds",
+			Buckets: cfg.Histograms.DurationBuckets,
+		}, labelNames).MetricVec, cfg.Config.TTL, nil)
+		mr.flowSize = prom.NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    FlowSize,
+			Help:    "bytes of the flows from a source network endpoint to a destination network endpoint",
+			Buckets: cfg.Histograms.BytesBuckets,
+		}, labelNames).MetricVec, cfg.Config.TTL, nil)
+		mr.flowPackets = prom.NewExpirer[prometheus.Histogram](prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    FlowPackets,
+			Help:    "packets of the flows from a source network endpoint to a destination network endpoint",
+			Buckets: cfg.Histograms.PacketsBuckets,
+		}, labelNames).MetricVec, cfg.Config.TTL, nil)
+	}
+	return mr
+}
+
+func (r *metricsReporter) collectors() []prometheus.Collector {
+	collectors := []prometheus.Collector{r.flowBytes, r.flowRTT, r.flowRetransmits, r.flowResets, r.flowSyns}
+	if r.flowDuration != nil {
+		collectors = append(collectors, r.flowDuration, r.flowSize, r.flowPackets)
+	}
+	return collectors
+}
+
+func (r *metricsReporter) observe(in <-chan []*ebpf.Record) {
+	for flows := range in {
+		for _, flow := range flows {
+			labelValues := r.labelValues(flow)
+			r.flowBytes.WithLabelValues(labelValues...).Add(float64(flow.Metrics.Bytes))
+			r.observeTCP(flow, labelValues)
+			r.observeHistograms(flow, labelValues)
+		}
+	}
+}
+
+// observeTCP statistics of the flow. Zero values are not reported, to avoid
+// creating TCP series for flows without TCP activity.
+func (r *metricsReporter) observeTCP(flow *ebpf.Record, labelValues []string) {
+	if flow.TCP.SrttUs > 0 {
+		r.flowRTT.WithLabelValues(labelValues...).Observe(float64(flow.TCP.SrttUs) / 1e6)
+	}
+	if flow.TCP.Retransmits > 0 {
+		r.flowRetransmits.WithLabelValues(labelValues...).Add(float64(flow.TCP.Retransmits))
+	}
+	if flow.Metrics.Resets > 0 {
+		r.flowResets.WithLabelValues(labelValues...).Add(float64(flow.Metrics.Resets))
+	}
+	if flow.Metrics.Syns > 0 {
+		r.flowSyns.WithLabelValues(labelValues...).Add(float64(flow.Metrics.Syns))
+	}
+}
+
+func (r *metricsReporter) observeHistograms(flow *ebpf.Record, labelValues []string) {
+	if r.flowDuration == nil {
+		return
+	}
+	r.flowDuration.WithLabelValues(labelValues...).Observe(export.FlowDuration(flow).Seconds())
+	r.flowSize.WithLabelValues(labelValues...).Observe(float64(flow.Metrics.Bytes))
+	r.flowPackets.WithLabelValues(labelValues...).Observe(float64(flow.Metrics.Packets))
+}
+
+func (r *metricsReporter) labelValues(flow *ebpf.Record) []string {
+	values := make([]string, 0, len(r.attrs))
+	for _, attr := range r.attrs {
+		values = append(values, attr.Get(flow))
+	}
+	return values
+}
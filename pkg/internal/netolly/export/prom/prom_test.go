@@ -0,0 +1,181 @@
+package prom
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/connector"
+	"github.com/grafana/beyla/pkg/internal/export/otel"
+	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/export"
+)
+
+const timeout = 5 * time.Second
+
+func TestPrometheusEndpoint(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port := freePort(t)
+	promCfg := &prom.PrometheusConfig{
+		Port:     port,
+		Path:     "/metrics",
+		Features: []string{otel.FeatureNetwork},
+	}
+	cfg := &PrometheusConfig{
+		Config:            promCfg,
+		AllowedAttributes: []string{"src.name", "dst_name", "k8s.src.namespace"},
+	}
+	require.True(t, cfg.Enabled())
+
+	exporter, err := PrometheusEndpoint(ctx, cfg, &connector.PrometheusManager{})
+	require.NoError(t, err)
+
+	flows := make(chan []*ebpf.Record, 10)
+	go exporter(flows)
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{
+		SrcName:  "foo",
+		DstName:  "bar",
+		Metadata: map[string]string{"k8s.src.namespace": "ns"},
+	}}
+	flow.Metrics.Bytes = 123
+	flows <- []*ebpf.Record{flow, flow}
+
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_bytes_total{dst_name="bar",k8s_src_namespace="ns",src_name="foo"} 246`)
+	})
+}
+
+func TestPrometheusEndpoint_TCPStats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port := freePort(t)
+	cfg := &PrometheusConfig{
+		Config: &prom.PrometheusConfig{
+			Port:     port,
+			Path:     "/metrics",
+			Features: []string{otel.FeatureNetwork},
+		},
+		AllowedAttributes: []string{"src.name", "dst.name"},
+		RTTBuckets:        export.DefaultRTTBuckets,
+	}
+
+	exporter, err := PrometheusEndpoint(ctx, cfg, &connector.PrometheusManager{})
+	require.NoError(t, err)
+
+	flows := make(chan []*ebpf.Record, 10)
+	go exporter(flows)
+	flow := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo", DstName: "bar"}}
+	flow.TCP = ebpf.TCPStats{SrttUs: 400, Retransmits: 3}
+	flow.Metrics.Resets, flow.Metrics.Syns = 1, 2
+	noTCP := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "baz", DstName: "bae"}}
+	flows <- []*ebpf.Record{flow, flow, noTCP}
+
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_retransmits_total{dst_name="bar",src_name="foo"} 6`)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_resets_total{dst_name="bar",src_name="foo"} 2`)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_syns_total{dst_name="bar",src_name="foo"} 4`)
+		// sub-millisecond round-trip times are reported in their own buckets
+		assert.Contains(t, string(body),
+			`beyla_network_flow_rtt_seconds_bucket{dst_name="bar",src_name="foo",le="0.00025"} 0`)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_rtt_seconds_bucket{dst_name="bar",src_name="foo",le="0.0005"} 2`)
+		assert.Contains(t, string(body),
+			`beyla_network_flow_rtt_seconds_count{dst_name="bar",src_name="foo"} 2`)
+		// flows without TCP statistics only report bytes
+		assert.Contains(t, string(body), `beyla_network_flow_bytes_total{dst_name="bae",src_name="baz"}`)
+		assert.NotContains(t, string(body), `beyla_network_flow_resets_total{dst_name="bae"`)
+		assert.NotContains(t, string(body), `beyla_network_flow_rtt_seconds_count{dst_name="bae"`)
+	})
+}
+
+func TestPrometheusEndpoint_Histograms(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	port := freePort(t)
+	cfg := &PrometheusConfig{
+		Config: &prom.PrometheusConfig{
+			Port:     port,
+			Path:     "/metrics",
+			Features: []string{otel.FeatureNetwork},
+		},
+		AllowedAttributes: []string{"src.name"},
+		Histograms: export.FlowHistograms{
+			Enable:          true,
+			DurationBuckets: []float64{0.1, 1},
+			BytesBuckets:    []float64{100, 1000},
+			PacketsBuckets:  []float64{1, 10},
+		},
+	}
+
+	exporter, err := PrometheusEndpoint(ctx, cfg, &connector.PrometheusManager{})
+	require.NoError(t, err)
+
+	flows := make(chan []*ebpf.Record, 10)
+	go exporter(flows)
+	short := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}
+	short.Metrics.Bytes, short.Metrics.Packets = 60, 1
+	short.Metrics.StartMonoTimeNs, short.Metrics.EndMonoTimeNs = uint64(time.Second), uint64(time.Second+50*time.Millisecond)
+	long := &ebpf.Record{Attrs: ebpf.RecordAttrs{SrcName: "foo"}}
+	long.Metrics.Bytes, long.Metrics.Packets = 5000, 20
+	long.Metrics.StartMonoTimeNs, long.Metrics.EndMonoTimeNs = uint64(time.Second), uint64(3*time.Second)
+	flows <- []*ebpf.Record{short, long}
+
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/metrics", port))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `beyla_network_flow_duration_seconds_bucket{src_name="foo",le="0.1"} 1`)
+		assert.Contains(t, string(body), `beyla_network_flow_duration_seconds_bucket{src_name="foo",le="1"} 1`)
+		assert.Contains(t, string(body), `beyla_network_flow_duration_seconds_sum{src_name="foo"} 2.05`)
+		assert.Contains(t, string(body), `beyla_network_flow_size_bytes_bucket{src_name="foo",le="100"} 1`)
+		assert.Contains(t, string(body), `beyla_network_flow_size_bytes_sum{src_name="foo"} 5060`)
+		assert.Contains(t, string(body), `beyla_network_flow_packets_bucket{src_name="foo",le="10"} 1`)
+		assert.Contains(t, string(body), `beyla_network_flow_packets_count{src_name="foo"} 2`)
+	})
+}
+
+func TestPrometheusEndpoint_HistogramsDisabled(t *testing.T) {
+	mr := newReporter(&PrometheusConfig{Config: &prom.PrometheusConfig{}, AllowedAttributes: []string{"src.name"}})
+	assert.Len(t, mr.collectors(), 5)
+	assert.Nil(t, mr.flowDuration)
+}
+
+func TestPrometheusConfig_Enabled(t *testing.T) {
+	assert.False(t, PrometheusConfig{}.Enabled())
+	assert.False(t, PrometheusConfig{Config: &prom.PrometheusConfig{Features: []string{otel.FeatureNetwork}}}.Enabled())
+	assert.False(t, PrometheusConfig{Config: &prom.PrometheusConfig{Port: 1234, Features: []string{otel.FeatureApplication}}}.Enabled())
+	assert.True(t, PrometheusConfig{Config: &prom.PrometheusConfig{Port: 1234, Features: []string{otel.FeatureNetwork}}}.Enabled())
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", ":0")
+	require.NoError(t, err)
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
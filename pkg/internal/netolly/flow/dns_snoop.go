@@ -0,0 +1,237 @@
+package flow
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/mariomac/pipes/pkg/node"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	ethHeaderLen  = 14
+	ipv4HeaderLen = 20
+	ipv6HeaderLen = 40
+	udpHeaderLen  = 8
+	etherTypeIPv4 = 0x0800
+	etherTypeIPv6 = 0x86DD
+	protocolUDP   = 17
+	// snoopBufferLen allows reading the largest UDP datagrams
+	snoopBufferLen = 65536
+	// minSnoopedTTL keeps the snooped names for at least this time, as the flows of a connection
+	// are still reported after the TTL of its DNS record has expired.
+	minSnoopedTTL = time.Minute
+)
+
+type dnsSnooper interface {
+	ReadPacket(buf []byte) (int, error)
+	Close() error
+}
+
+var newDNSSnooper = func() (dnsSnooper, error) {
+	snooper, err := ebpf.NewDNSSnooper()
+	if err != nil {
+		return nil, err
+	}
+	return snooper, nil
+}
+
+// snoopedName is an IP->name entry that has been extracted from a DNS response
+type snoopedName struct {
+	ip   ebpf.IPAddr
+	name string
+	ttl  time.Duration
+}
+
+// dnsCache stores the snooped names until the TTL of their DNS records expires
+type dnsCache struct {
+	mt    sync.Mutex
+	lru   *simplelru.LRU[ebpf.IPAddr, dnsCacheEntry]
+	clock func() time.Time
+}
+
+type dnsCacheEntry struct {
+	name   string
+	expiry time.Time
+}
+
+func newDNSCache(size int) (*dnsCache, error) {
+	lru, err := simplelru.NewLRU[ebpf.IPAddr, dnsCacheEntry](size, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &dnsCache{lru: lru, clock: time.Now}, nil
+}
+
+func (c *dnsCache) add(sn *snoopedName) {
+	ttl := sn.ttl
+	if ttl < minSnoopedTTL {
+		ttl = minSnoopedTTL
+	}
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	c.lru.Add(sn.ip, dnsCacheEntry{name: sn.name, expiry: c.clock().Add(ttl)})
+}
+
+func (c *dnsCache) get(ip ebpf.IPAddr) string {
+	c.mt.Lock()
+	defer c.mt.Unlock()
+	entry, ok := c.lru.Get(ip)
+	if !ok {
+		return ""
+	}
+	if c.clock().After(entry.expiry) {
+		c.lru.Remove(ip)
+		return ""
+	}
+	return entry.name
+}
+
+// snoopedDNSProvider decorates the flows with the names that were queried to the DNS
+// servers, as captured from the DNS responses by an eBPF socket filter.
+func snoopedDNSProvider(cfg *ReverseDNS) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
+	cache, err := newDNSCache(cfg.CacheLen)
+	if err != nil {
+		return nil, err
+	}
+	snooper, err := newDNSSnooper()
+	if err != nil {
+		return nil, err
+	}
+	log := rdlog().With("type", ReverseDNSEBPF)
+	go snoopDNS(log, snooper, cache)
+	return func(in <-chan []*ebpf.Record, out chan<- []*ebpf.Record) {
+		defer func() {
+			if err := snooper.Close(); err != nil {
+				log.Debug("closing DNS snooper", "error", err)
+			}
+		}()
+		log.Debug("starting reverse DNS node")
+		for flows := range in {
+			for _, flow := range flows {
+				if flow.Attrs.SrcName == "" {
+					flow.Attrs.SrcName = cache.get(*flow.Id.SrcIP())
+				}
+				if flow.Attrs.DstName == "" {
+					flow.Attrs.DstName = cache.get(*flow.Id.DstIP())
+				}
+			}
+			out <- flows
+		}
+	}, nil
+}
+
+func snoopDNS(log *slog.Logger, snooper dnsSnooper, cache *dnsCache) {
+	buf := make([]byte, snoopBufferLen)
+	for {
+		n, err := snooper.ReadPacket(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warn("can't read DNS responses. Stopping DNS snooping", "error", err)
+			}
+			return
+		}
+		names, err := parseDNSResponse(buf[:n])
+		if err != nil {
+			log.Debug("ignoring DNS packet", "error", err)
+			continue
+		}
+		for i := range names {
+			cache.add(&names[i])
+		}
+	}
+}
+
+// parseDNSResponse extracts the IP->name entries from the A and AAAA records of a DNS
+// response, which is provided as an Ethernet frame. All the records are associated to the
+// name of the query, even if they belong to the canonical name of a CNAME chain.
+func parseDNSResponse(frame []byte) ([]snoopedName, error) {
+	if len(frame) < ethHeaderLen {
+		return nil, errors.New("frame too short")
+	}
+	var payload []byte
+	switch binary.BigEndian.Uint16(frame[12:]) {
+	case etherTypeIPv4:
+		ip := frame[ethHeaderLen:]
+		if len(ip) < ipv4HeaderLen || ip[9] != protocolUDP {
+			return nil, errors.New("not a valid UDP/IPv4 packet")
+		}
+		// the header length (IHL) includes the options, and is provided in 32-bit words
+		headerLen := int(ip[0]&0x0f) * 4
+		if headerLen < ipv4HeaderLen || headerLen > len(ip) {
+			return nil, fmt.Errorf("invalid IPv4 header length: %d", headerLen)
+		}
+		payload = ip[headerLen:]
+	case etherTypeIPv6:
+		ip := frame[ethHeaderLen:]
+		if len(ip) < ipv6HeaderLen || ip[6] != protocolUDP {
+			return nil, errors.New("not a valid UDP/IPv6 packet")
+		}
+		payload = ip[ipv6HeaderLen:]
+	default:
+		return nil, errors.New("not an IP packet")
+	}
+	if len(payload) < udpHeaderLen {
+		return nil, errors.New("UDP datagram too short")
+	}
+	return parseDNSMessage(payload[udpHeaderLen:])
+}
+
+func parseDNSMessage(msg []byte) ([]snoopedName, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !hdr.Response || hdr.RCode != dnsmessage.RCodeSuccess {
+		return nil, nil
+	}
+	question, err := p.Question()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSuffix(question.Name.String(), ".")
+	var names []snoopedName
+	for {
+		ah, err := p.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		ttl := time.Duration(ah.TTL) * time.Second
+		switch ah.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return names, err
+			}
+			sn := snoopedName{name: name, ttl: ttl}
+			copy(sn.ip[:], net.IP(a.A[:]).To16())
+			names = append(names, sn)
+		case dnsmessage.TypeAAAA:
+			aaaa, err := p.AAAAResource()
+			if err != nil {
+				return names, err
+			}
+			names = append(names, snoopedName{ip: aaaa.AAAA, name: name, ttl: ttl})
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return names, err
+			}
+		}
+	}
+}
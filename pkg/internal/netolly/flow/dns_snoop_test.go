@@ -0,0 +1,205 @@
+package flow
+
+import (
+	"encoding/binary"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mariomac/guara/pkg/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+func TestParseDNSResponse_IPv4(t *testing.T) {
+	frame := ipv4Frame(dnsResponse(t, "api.stripe.com.", dnsmessage.RCodeSuccess))
+
+	names, err := parseDNSResponse(frame)
+	require.NoError(t, err)
+
+	// the A and AAAA records are associated to the queried name, not to the CNAME target
+	assert.Equal(t, []snoopedName{
+		{ip: srcIP, name: "api.stripe.com", ttl: 300 * time.Second},
+		{ip: ipAddr("2001:db8::1"), name: "api.stripe.com", ttl: 60 * time.Second},
+	}, names)
+}
+
+func TestParseDNSResponse_IPv6(t *testing.T) {
+	frame := ipv6Frame(dnsResponse(t, "api.stripe.com.", dnsmessage.RCodeSuccess))
+
+	names, err := parseDNSResponse(frame)
+	require.NoError(t, err)
+	require.Len(t, names, 2)
+	assert.Equal(t, "api.stripe.com", names[0].name)
+}
+
+func TestParseDNSResponse_Ignored(t *testing.T) {
+	// failed responses
+	names, err := parseDNSResponse(ipv4Frame(dnsResponse(t, "api.stripe.com.", dnsmessage.RCodeNameError)))
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// non-IP packets
+	_, err = parseDNSResponse(make([]byte, 64))
+	require.Error(t, err)
+
+	// truncated packets
+	_, err = parseDNSResponse(ipv4Frame([]byte{1, 2, 3})[:20])
+	require.Error(t, err)
+
+	// IPv4 header length (IHL=15 words) beyond the captured frame
+	truncated := ipv4Frame(nil)[:ethHeaderLen+24]
+	truncated[ethHeaderLen] = 0x4f
+	_, err = parseDNSResponse(truncated)
+	require.Error(t, err)
+
+	// IPv4 header length (IHL=4 words) below the minimum header size
+	short := ipv4Frame(dnsResponse(t, "api.stripe.com.", dnsmessage.RCodeSuccess))
+	short[ethHeaderLen] = 0x44
+	_, err = parseDNSResponse(short)
+	require.Error(t, err)
+}
+
+func TestDNSCache_Expiry(t *testing.T) {
+	cache, err := newDNSCache(10)
+	require.NoError(t, err)
+	now := time.Now()
+	cache.clock = func() time.Time { return now }
+
+	cache.add(&snoopedName{ip: srcIP, name: "foo.com", ttl: 5 * time.Minute})
+	// records with short TTLs are kept for a minimum time
+	cache.add(&snoopedName{ip: dstIP, name: "bar.com", ttl: time.Second})
+
+	now = now.Add(50 * time.Second)
+	assert.Equal(t, "foo.com", cache.get(srcIP))
+	assert.Equal(t, "bar.com", cache.get(dstIP))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, "foo.com", cache.get(srcIP))
+	assert.Empty(t, cache.get(dstIP))
+
+	now = now.Add(5 * time.Minute)
+	assert.Empty(t, cache.get(srcIP))
+}
+
+func TestReverseDNS_EBPF(t *testing.T) {
+	netLookupAddr = func(addr string) ([]string, error) {
+		require.Fail(t, "network lookup shouldn't be invoked!", "Got:", addr)
+		return nil, nil
+	}
+	snooper := &fakeSnooper{packets: make(chan []byte, 10)}
+	newDNSSnooper = func() (dnsSnooper, error) {
+		return snooper, nil
+	}
+	// Given a Reverse DNS node in eBPF mode
+	in := make(chan []*ebpf.Record, 10)
+	out := make(chan []*ebpf.Record, 10)
+	reverseDNS, err := ReverseDNSProvider(ReverseDNS{Type: ReverseDNSEBPF, CacheLen: 255})
+	require.NoError(t, err)
+	go reverseDNS(in, out)
+
+	// When a DNS response is captured
+	snooper.packets <- ipv4Frame(dnsResponse(t, "api.stripe.com.", dnsmessage.RCodeSuccess))
+
+	// THEN the flows to the resolved IP are decorated with the queried name
+	test.Eventually(t, timeout, func(ct require.TestingT) {
+		f1 := &ebpf.Record{NetFlowRecordT: ebpf.NetFlowRecordT{Id: ebpf.NetFlowId{IfIndex: 1}}}
+		f1.Id.SrcIp.In6U.U6Addr8 = dstIP
+		f1.Id.DstIp.In6U.U6Addr8 = srcIP
+		in <- []*ebpf.Record{f1}
+
+		decorated := testutil.ReadChannel(t, out, timeout)
+		require.Len(ct, decorated, 1)
+		assert.Empty(ct, decorated[0].Attrs.SrcName)
+		assert.Equal(ct, "api.stripe.com", decorated[0].Attrs.DstName)
+	})
+
+	// AND the snooper is closed when the node stops
+	close(in)
+	test.Eventually(t, timeout, func(t require.TestingT) {
+		assert.True(t, snooper.isClosed())
+	})
+}
+
+type fakeSnooper struct {
+	packets chan []byte
+	closed  atomic.Bool
+}
+
+func (f *fakeSnooper) ReadPacket(buf []byte) (int, error) {
+	pkt, ok := <-f.packets
+	if !ok {
+		return 0, net.ErrClosed
+	}
+	return copy(buf, pkt), nil
+}
+
+func (f *fakeSnooper) Close() error {
+	if !f.closed.Swap(true) {
+		close(f.packets)
+	}
+	return nil
+}
+
+func (f *fakeSnooper) isClosed() bool {
+	return f.closed.Load()
+}
+
+func ipAddr(ip string) ebpf.IPAddr {
+	var addr ebpf.IPAddr
+	copy(addr[:], net.ParseIP(ip).To16())
+	return addr
+}
+
+// dnsResponse resolves the query through a CNAME, with one A and one AAAA record
+func dnsResponse(t *testing.T, query string, rcode dnsmessage.RCode) []byte {
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 1234, Response: true, RCode: rcode})
+	name := dnsmessage.MustNewName(query)
+	require.NoError(t, b.StartQuestions())
+	require.NoError(t, b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}))
+	require.NoError(t, b.StartAnswers())
+	cname := dnsmessage.MustNewName("d1234.cloudfront.net.")
+	require.NoError(t, b.CNAMEResource(
+		dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: 3600},
+		dnsmessage.CNAMEResource{CNAME: cname}))
+	require.NoError(t, b.AResource(
+		dnsmessage.ResourceHeader{Name: cname, Class: dnsmessage.ClassINET, TTL: 300},
+		dnsmessage.AResource{A: [4]byte{140, 82, 121, 4}}))
+	require.NoError(t, b.AAAAResource(
+		dnsmessage.ResourceHeader{Name: cname, Class: dnsmessage.ClassINET, TTL: 60},
+		dnsmessage.AAAAResource{AAAA: ipAddr("2001:db8::1")}))
+	msg, err := b.Finish()
+	require.NoError(t, err)
+	return msg
+}
+
+func ipv4Frame(dns []byte) []byte {
+	frame := binary.BigEndian.AppendUint16(make([]byte, 12), etherTypeIPv4)
+	ip := make([]byte, 24) // header with options
+	ip[0] = 0x46
+	ip[9] = protocolUDP
+	frame = append(frame, ip...)
+	return append(frame, udpDatagram(dns)...)
+}
+
+func ipv6Frame(dns []byte) []byte {
+	frame := binary.BigEndian.AppendUint16(make([]byte, 12), etherTypeIPv6)
+	ip := make([]byte, ipv6HeaderLen)
+	ip[0] = 0x60
+	ip[6] = protocolUDP
+	frame = append(frame, ip...)
+	return append(frame, udpDatagram(dns)...)
+}
+
+func udpDatagram(payload []byte) []byte {
+	udp := binary.BigEndian.AppendUint16(nil, 53)
+	udp = binary.BigEndian.AppendUint16(udp, 40000)
+	udp = binary.BigEndian.AppendUint16(udp, uint16(udpHeaderLen+len(payload)))
+	udp = binary.BigEndian.AppendUint16(udp, 0)
+	return append(udp, payload...)
+}
@@ -0,0 +1,243 @@
+package flow
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mariomac/pipes/pkg/node"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	FilterActionDrop   = "drop"
+	FilterActionAccept = "accept"
+
+	FilterDirectionIngress = "ingress"
+	FilterDirectionEgress  = "egress"
+)
+
+// ipv4MappedPrefixLen is the length of the ::ffff:0:0/96 prefix of the IPv4-mapped IPv6 addresses
+const ipv4MappedPrefixLen = 96
+
+var protocolNumbers = map[string]uint8{
+	"icmp":   1,
+	"tcp":    6,
+	"udp":    17,
+	"icmpv6": 58,
+	"sctp":   132,
+}
+
+func flog() *slog.Logger {
+	return slog.With("component", "flow.Filter")
+}
+
+// FilterRule selects the flows that are dropped or accepted. All the defined properties
+// must match for a flow to be selected. Undefined properties match any value.
+type FilterRule struct {
+	// Action for the matching flows: drop (default) or accept
+	Action string `yaml:"action"`
+	// SrcCIDR and DstCIDR match the source and destination IP addresses
+	SrcCIDR string `yaml:"src_cidr"`
+	DstCIDR string `yaml:"dst_cidr"`
+	// SrcPort and DstPort match the source and destination ports, as a single number or an
+	// inclusive range (e.g. 8000-8999)
+	SrcPort string `yaml:"src_port"`
+	DstPort string `yaml:"dst_port"`
+	// Protocol matches the transport protocol, as a name (tcp, udp, icmp, icmpv6, sctp) or a number
+	Protocol string `yaml:"protocol"`
+	// Direction of the flow: ingress or egress
+	Direction string `yaml:"direction"`
+}
+
+// FilterRules are evaluated in order. The first rule that matches a flow decides whether it is
+// dropped or accepted. The flows that do not match any rule are accepted.
+// The first ebpf.MaxKernelFilterRules rules are evaluated in the kernel space, so the dropped
+// traffic is never aggregated nor sent to the user space.
+type FilterRules []FilterRule
+
+func (fr FilterRules) Validate() error {
+	_, err := fr.KernelRules()
+	return err
+}
+
+// KernelRules converts the filter rules into the format that is evaluated by the eBPF program
+func (fr FilterRules) KernelRules() ([]ebpf.FilterRule, error) {
+	rules := make([]ebpf.FilterRule, 0, len(fr))
+	for i := range fr {
+		rule, err := fr[i].kernelRule()
+		if err != nil {
+			return nil, fmt.Errorf("filter rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func (r *FilterRule) kernelRule() (ebpf.FilterRule, error) {
+	rule := ebpf.FilterRule{
+		Direction:  ebpf.FilterAnyDirection,
+		SrcPortEnd: 0xFFFF,
+		DstPortEnd: 0xFFFF,
+	}
+	var err error
+	switch strings.ToLower(r.Action) {
+	case "", FilterActionDrop:
+		rule.Action = ebpf.FilterDrop
+	case FilterActionAccept:
+		rule.Action = ebpf.FilterAccept
+	default:
+		return rule, fmt.Errorf("unknown action %q. Accepted values: %s, %s", r.Action, FilterActionDrop, FilterActionAccept)
+	}
+	if rule.SrcIP, rule.SrcPrefixLen, err = parseFilterCIDR(r.SrcCIDR); err != nil {
+		return rule, fmt.Errorf("src_cidr: %w", err)
+	}
+	if rule.DstIP, rule.DstPrefixLen, err = parseFilterCIDR(r.DstCIDR); err != nil {
+		return rule, fmt.Errorf("dst_cidr: %w", err)
+	}
+	if rule.SrcPortStart, rule.SrcPortEnd, err = parsePortRange(r.SrcPort); err != nil {
+		return rule, fmt.Errorf("src_port: %w", err)
+	}
+	if rule.DstPortStart, rule.DstPortEnd, err = parsePortRange(r.DstPort); err != nil {
+		return rule, fmt.Errorf("dst_port: %w", err)
+	}
+	if rule.Protocol, err = parseProtocol(r.Protocol); err != nil {
+		return rule, fmt.Errorf("protocol: %w", err)
+	}
+	switch strings.ToLower(r.Direction) {
+	case "":
+	case FilterDirectionIngress:
+		rule.Direction = ebpf.DirectionIngress
+	case FilterDirectionEgress:
+		rule.Direction = ebpf.DirectionEgress
+	default:
+		return rule, fmt.Errorf("unknown direction %q. Accepted values: %s, %s",
+			r.Direction, FilterDirectionIngress, FilterDirectionEgress)
+	}
+	return rule, nil
+}
+
+// parseFilterCIDR returns the masked network address and its prefix length over the 128 bits of
+// the ebpf.IPAddr. An empty CIDR matches any address.
+func parseFilterCIDR(cidr string) (ebpf.IPAddr, uint8, error) {
+	var addr ebpf.IPAddr
+	if cidr == "" {
+		return addr, 0, nil
+	}
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return addr, 0, err
+	}
+	ones, bits := ipNet.Mask.Size()
+	if bits == 8*net.IPv4len {
+		ones += ipv4MappedPrefixLen
+	}
+	copy(addr[:], ipNet.IP.To16())
+	return addr, uint8(ones), nil
+}
+
+func parsePortRange(ports string) (uint16, uint16, error) {
+	if ports == "" {
+		return 0, 0xFFFF, nil
+	}
+	startStr, endStr, isRange := strings.Cut(ports, "-")
+	start, err := strconv.ParseUint(strings.TrimSpace(startStr), 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port %q", ports)
+	}
+	if !isRange {
+		return uint16(start), uint16(start), nil
+	}
+	end, err := strconv.ParseUint(strings.TrimSpace(endStr), 10, 16)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid port range %q", ports)
+	}
+	return uint16(start), uint16(end), nil
+}
+
+func parseProtocol(protocol string) (uint8, error) {
+	if protocol == "" {
+		return 0, nil
+	}
+	if num, ok := protocolNumbers[strings.ToLower(protocol)]; ok {
+		return num, nil
+	}
+	num, err := strconv.ParseUint(protocol, 10, 8)
+	if err != nil || num == 0 {
+		return 0, fmt.Errorf("unknown protocol %q", protocol)
+	}
+	return uint8(num), nil
+}
+
+// Filter evaluates in the user space the filter rules that couldn't be evaluated in the
+// kernel space.
+type Filter struct {
+	Rules FilterRules
+	// KernelRules is the number of rules, from the beginning of the list, that are already
+	// evaluated by the eBPF program
+	KernelRules int
+}
+
+// nolint:gocritic
+func (f Filter) Enabled() bool {
+	return len(f.Rules) > f.KernelRules
+}
+
+// nolint:gocritic
+func FilterProvider(f Filter) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
+	rules, err := f.Rules.KernelRules()
+	if err != nil {
+		return nil, err
+	}
+	return func(in <-chan []*ebpf.Record, out chan<- []*ebpf.Record) {
+		log := flog()
+		log.Debug("starting node", "rules", len(rules), "kernelRules", f.KernelRules)
+		for flows := range in {
+			accepted := flows[:0]
+			for _, flow := range flows {
+				if !filteredOut(rules, flow) {
+					accepted = append(accepted, flow)
+				}
+			}
+			if len(accepted) > 0 {
+				out <- accepted
+			}
+		}
+		log.Debug("stopping node")
+	}, nil
+}
+
+// filteredOut evaluates the rules in the same way as the eBPF program. All the rules
+// are evaluated, as the flows that are accepted in the kernel by a rule would be also
+// accepted here by the same rule.
+func filteredOut(rules []ebpf.FilterRule, flow *ebpf.Record) bool {
+	for i := range rules {
+		if ruleMatches(&rules[i], flow) {
+			return rules[i].Action == ebpf.FilterDrop
+		}
+	}
+	return false
+}
+
+func ruleMatches(rule *ebpf.FilterRule, flow *ebpf.Record) bool {
+	id := &flow.Id
+	return (rule.Direction == ebpf.FilterAnyDirection || rule.Direction == id.Direction) &&
+		(rule.Protocol == 0 || rule.Protocol == id.TransportProtocol) &&
+		id.SrcPort >= rule.SrcPortStart && id.SrcPort <= rule.SrcPortEnd &&
+		id.DstPort >= rule.DstPortStart && id.DstPort <= rule.DstPortEnd &&
+		ipMatches(id.SrcIP(), &rule.SrcIP, rule.SrcPrefixLen) &&
+		ipMatches(id.DstIP(), &rule.DstIP, rule.DstPrefixLen)
+}
+
+func ipMatches(ip, network *ebpf.IPAddr, prefixLen uint8) bool {
+	mask := net.CIDRMask(int(prefixLen), 8*net.IPv6len)
+	for i := range ip {
+		if ip[i]&mask[i] != network[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package flow
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/testutil"
+)
+
+func TestFilterRules_KernelRules(t *testing.T) {
+	rules, err := FilterRules{
+		{SrcCIDR: "10.1.0.0/16", DstPort: "53", Protocol: "udp", Direction: "egress"},
+		{Action: "accept", DstCIDR: "2001:db8::/32", SrcPort: "8000-8999", Protocol: "41"},
+	}.KernelRules()
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+
+	assert.Equal(t, ebpf.FilterRule{
+		SrcIP:        filterIP("10.1.0.0"),
+		SrcPrefixLen: 96 + 16,
+		Protocol:     17,
+		Direction:    ebpf.DirectionEgress,
+		Action:       ebpf.FilterDrop,
+		SrcPortStart: 0, SrcPortEnd: 0xFFFF,
+		DstPortStart: 53, DstPortEnd: 53,
+	}, rules[0])
+	assert.Equal(t, ebpf.FilterRule{
+		DstIP:        filterIP("2001:db8::"),
+		DstPrefixLen: 32,
+		Protocol:     41,
+		Direction:    ebpf.FilterAnyDirection,
+		Action:       ebpf.FilterAccept,
+		SrcPortStart: 8000, SrcPortEnd: 8999,
+		DstPortStart: 0, DstPortEnd: 0xFFFF,
+	}, rules[1])
+}
+
+func TestFilterRules_Validate(t *testing.T) {
+	require.NoError(t, FilterRules{}.Validate())
+	require.NoError(t, FilterRules{{}}.Validate())
+	for _, rule := range []FilterRule{
+		{Action: "reject"},
+		{SrcCIDR: "10.0.0.0"},
+		{DstCIDR: "foo/8"},
+		{SrcPort: "http"},
+		{DstPort: "90-80"},
+		{DstPort: "70000"},
+		{Protocol: "foo"},
+		{Protocol: "0"},
+		{Direction: "both"},
+	} {
+		assert.Errorf(t, FilterRules{{}, rule}.Validate(), "rule %+v", rule)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter, err := FilterProvider(Filter{Rules: FilterRules{
+		// drop DNS traffic
+		{Protocol: "udp", DstPort: "53"},
+		// only accept the ingress traffic from the 10.0.0.0/8 network
+		{Action: "accept", Direction: "ingress", SrcCIDR: "10.0.0.0/8"},
+		{Direction: "ingress"},
+		// drop the egress traffic to the IPv6 documentation network
+		{Direction: "egress", DstCIDR: "2001:db8::/32", DstPort: "8000-8999"},
+	}})
+	require.NoError(t, err)
+	in, out := make(chan []*ebpf.Record, 10), make(chan []*ebpf.Record, 10)
+	go filter(in, out)
+
+	dns := filterFlow(ebpf.DirectionIngress, "10.1.1.1", 33333, "10.2.2.2", 53, 17)
+	fromInternal := filterFlow(ebpf.DirectionIngress, "10.1.1.1", 33333, "10.2.2.2", 53, 6)
+	fromExternal := filterFlow(ebpf.DirectionIngress, "140.82.121.4", 443, "10.2.2.2", 44444, 6)
+	toDocs := filterFlow(ebpf.DirectionEgress, "2001:db8::1", 44444, "2001:db8::2", 8080, 6)
+	toDocsOtherPort := filterFlow(ebpf.DirectionEgress, "2001:db8::1", 44444, "2001:db8::2", 9000, 6)
+	toExternal := filterFlow(ebpf.DirectionEgress, "10.2.2.2", 44444, "140.82.121.4", 443, 6)
+
+	in <- []*ebpf.Record{dns, fromInternal, fromExternal, toDocs, toDocsOtherPort, toExternal}
+	filtered := testutil.ReadChannel(t, out, timeout)
+	assert.Equal(t, []*ebpf.Record{fromInternal, toDocsOtherPort, toExternal}, filtered)
+
+	// batches where all the flows are dropped are not forwarded
+	in <- []*ebpf.Record{dns}
+	in <- []*ebpf.Record{fromExternal, toExternal}
+	filtered = testutil.ReadChannel(t, out, timeout)
+	assert.Equal(t, []*ebpf.Record{toExternal}, filtered)
+}
+
+func TestFilter_Enabled(t *testing.T) {
+	assert.False(t, Filter{}.Enabled())
+	assert.True(t, Filter{Rules: FilterRules{{}, {}}}.Enabled())
+	assert.True(t, Filter{Rules: FilterRules{{}, {}}, KernelRules: 1}.Enabled())
+	assert.False(t, Filter{Rules: FilterRules{{}, {}}, KernelRules: 2}.Enabled())
+}
+
+func filterIP(ip string) ebpf.IPAddr {
+	var addr ebpf.IPAddr
+	copy(addr[:], net.ParseIP(ip).To16())
+	return addr
+}
+
+func filterFlow(direction uint8, srcIP string, srcPort uint16, dstIP string, dstPort uint16, protocol uint8) *ebpf.Record {
+	r := &ebpf.Record{}
+	r.Id.Direction = direction
+	r.Id.SrcIp.In6U.U6Addr8 = filterIP(srcIP)
+	r.Id.DstIp.In6U.U6Addr8 = filterIP(dstIP)
+	r.Id.SrcPort = srcPort
+	r.Id.DstPort = dstPort
+	r.Id.TransportProtocol = protocol
+	return r
+}
@@ -14,6 +14,7 @@ import (
 const (
 	ReverseDNSNone        = "none"
 	ReverseDNSLocalLookup = "local"
+	ReverseDNSEBPF        = "ebpf"
 )
 
 func rdlog() *slog.Logger {
@@ -25,7 +26,10 @@ var netLookupAddr = net.LookupAddr
 // ReverseDNS is currently experimental. It is kept disabled by default and will be hidden
 // from the documentation. This means that it does not impact in the overall Beyla performance.
 type ReverseDNS struct {
-	// Type of ReverseDNS. Values are "none" (default) and "local".
+	// Type of ReverseDNS. Values are "none" (default), "local" and "ebpf".
+	// "local" looks up the hostname of each IP through the local resolver.
+	// "ebpf" snoops the DNS responses that are received by the host, and uses the
+	// queried names, which are usually more meaningful than the PTR records.
 	Type string `yaml:"type" env:"BEYLA_NETWORK_REVERSE_DNS_TYPE"`
 	// CacheLen specifies the max size of the LRU cache that is checked before
 	// performing the name lookup. Default: 256
@@ -33,14 +37,18 @@ type ReverseDNS struct {
 	// CacheTTL specifies the time-to-live of a cached IP->hostname entry. After the
 	// cached entry becomes older than this time, the IP->hostname entry will be looked
 	// up again.
+	// It is ignored for the "ebpf" type, whose entries expire after the TTL of their DNS records.
 	CacheTTL time.Duration `yaml:"cache_expiry" env:"BEYLA_NETWORK_REVERSE_DNS_CACHE_TTL"`
 }
 
 func (r ReverseDNS) Enabled() bool {
-	return r.Type == ReverseDNSLocalLookup
+	return r.Type == ReverseDNSLocalLookup || r.Type == ReverseDNSEBPF
 }
 
 func ReverseDNSProvider(cfg ReverseDNS) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
+	if cfg.Type == ReverseDNSEBPF {
+		return snoopedDNSProvider(&cfg)
+	}
 	// TODO: replace by a cache with fuzzy expiration time to avoid cache stampede
 	cache := expirable.NewLRU[ebpf.IPAddr, string](cfg.CacheLen, nil, cfg.CacheTTL)
 
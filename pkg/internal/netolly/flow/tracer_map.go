@@ -46,6 +46,7 @@ type MapTracer struct {
 
 type mapFetcher interface {
 	LookupAndDeleteMap() map[ebpf.NetFlowId][]ebpf.NetFlowMetrics
+	LookupAndDeleteTCPStats() map[ebpf.TCPConnID]ebpf.TCPStats
 }
 
 func NewMapTracer(fetcher mapFetcher, evictionTimeout time.Duration) *MapTracer {
@@ -109,7 +110,11 @@ func (m *MapTracer) evictionSynchronization(ctx context.Context, out chan<- []*e
 func (m *MapTracer) evictFlows(ctx context.Context, forwardFlows chan<- []*ebpf.Record) {
 	var forwardingFlows []*ebpf.Record
 	laterFlowNs := uint64(0)
-	for flowKey, flowMetrics := range m.mapFetcher.LookupAndDeleteMap() {
+	// TCP statistics are read before the flows, so any flow captured in the meantime
+	// is still accounted in the next eviction
+	tcpStats := m.mapFetcher.LookupAndDeleteTCPStats()
+	flows := m.mapFetcher.LookupAndDeleteMap()
+	for flowKey, flowMetrics := range flows {
 		aggregatedMetrics := m.aggregate(flowMetrics)
 		// we ignore metrics that haven't been aggregated (e.g. all the mapped values are ignored)
 		if aggregatedMetrics.EndMonoTimeNs == 0 {
@@ -119,7 +124,9 @@ func (m *MapTracer) evictFlows(ctx context.Context, forwardFlows chan<- []*ebpf.
 		if aggregatedMetrics.EndMonoTimeNs > laterFlowNs {
 			laterFlowNs = aggregatedMetrics.EndMonoTimeNs
 		}
-		forwardingFlows = append(forwardingFlows, ebpf.NewRecord(flowKey, aggregatedMetrics))
+		record := ebpf.NewRecord(flowKey, aggregatedMetrics)
+		joinTCPStats(record, tcpStats)
+		forwardingFlows = append(forwardingFlows, record)
 	}
 	m.lastEvictionNs = laterFlowNs
 	mtlog := mtlog()
@@ -132,6 +139,20 @@ func (m *MapTracer) evictFlows(ctx context.Context, forwardFlows chan<- []*ebpf.
 	mtlog.Debug("flows evicted", "len", len(forwardingFlows))
 }
 
+// joinTCPStats sets the TCP statistics of the connection that corresponds to the flow record.
+// The same connection might be traced by different interfaces, so each statistics entry
+// is removed once it is joined, to avoid accounting it multiple times.
+func joinTCPStats(record *ebpf.Record, tcpStats map[ebpf.TCPConnID]ebpf.TCPStats) {
+	if !record.Id.IsTCP() {
+		return
+	}
+	connID := record.Id.TCPConnID()
+	if stats, ok := tcpStats[connID]; ok {
+		record.TCP = stats
+		delete(tcpStats, connID)
+	}
+}
+
 func (m *MapTracer) aggregate(metrics []ebpf.NetFlowMetrics) ebpf.NetFlowMetrics {
 	if len(metrics) == 0 {
 		mtlog().Warn("invoked aggregate with no values")
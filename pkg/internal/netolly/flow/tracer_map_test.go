@@ -44,13 +44,14 @@ func TestPacketAggregation(t *testing.T) {
 		},
 	}, {
 		input: []ebpf.NetFlowMetrics{
-			{Packets: 0x3, Bytes: 0x5c4, StartMonoTimeNs: 0x17f3e9613a7f, EndMonoTimeNs: 0x17f3e979816e, Flags: 1},
-			{Packets: 0x2, Bytes: 0x8c, StartMonoTimeNs: 0x17f3e9633a7f, EndMonoTimeNs: 0x17f3e96f164e, Flags: 1},
+			{Packets: 0x3, Bytes: 0x5c4, StartMonoTimeNs: 0x17f3e9613a7f, EndMonoTimeNs: 0x17f3e979816e, Flags: 1, Syns: 1},
+			{Packets: 0x2, Bytes: 0x8c, StartMonoTimeNs: 0x17f3e9633a7f, EndMonoTimeNs: 0x17f3e96f164e, Flags: 1, Resets: 1, Syns: 2},
 			{Packets: 0x0, Bytes: 0x0, StartMonoTimeNs: 0x0, EndMonoTimeNs: 0x0, Flags: 1},
 			{Packets: 0x0, Bytes: 0x0, StartMonoTimeNs: 0x0, EndMonoTimeNs: 0x0, Flags: 1},
 		},
 		expected: ebpf.NetFlowMetrics{
 			Packets: 0x5, Bytes: 0x5c4 + 0x8c, StartMonoTimeNs: 0x17f3e9613a7f, EndMonoTimeNs: 0x17f3e979816e, Flags: 1,
+			Resets: 1, Syns: 3,
 		},
 	}}
 	ft := MapTracer{}
@@ -62,3 +63,31 @@ func TestPacketAggregation(t *testing.T) {
 		})
 	}
 }
+
+func TestJoinTCPStats(t *testing.T) {
+	stats := map[ebpf.TCPConnID]ebpf.TCPStats{}
+	tcpFlow := ebpf.NewRecord(ebpf.NetFlowId{TransportProtocol: ebpf.TransportTCP, SrcPort: 1234, DstPort: 80},
+		ebpf.NetFlowMetrics{Packets: 1})
+	tcpFlow.Id.SrcIp.In6U.U6Addr8 = [16]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 255, 1, 2, 3, 4}
+	tcpFlow.Id.DstIp.In6U.U6Addr8 = [16]uint8{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 255, 255, 4, 3, 2, 1}
+	stats[tcpFlow.Id.TCPConnID()] = ebpf.TCPStats{SrttUs: 1500, Retransmits: 3}
+
+	// flows from other protocols or connections are not joined
+	udpFlow := *tcpFlow
+	udpFlow.Id.TransportProtocol = 17
+	joinTCPStats(&udpFlow, stats)
+	assert.True(t, udpFlow.TCP.Empty())
+	otherFlow := *tcpFlow
+	otherFlow.Id.DstPort = 8080
+	joinTCPStats(&otherFlow, stats)
+	assert.True(t, otherFlow.TCP.Empty())
+
+	joinTCPStats(tcpFlow, stats)
+	assert.Equal(t, ebpf.TCPStats{SrttUs: 1500, Retransmits: 3}, tcpFlow.TCP)
+
+	// the same connection, traced from another interface, is not accounted twice
+	otherIface := ebpf.NewRecord(tcpFlow.Id, ebpf.NetFlowMetrics{Packets: 1})
+	otherIface.Id.IfIndex = 3
+	joinTCPStats(otherIface, stats)
+	assert.True(t, otherIface.TCP.Empty())
+}
@@ -0,0 +1,35 @@
+package cni
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	calicoIPIPTunnelAnnotation    = "projectcalico.org/IPv4IPIPTunnelAddr"
+	calicoVXLANTunnelAnnotation   = "projectcalico.org/IPv4VXLANTunnelAddr"
+	calicoVXLANv6TunnelAnnotation = "projectcalico.org/IPv6VXLANTunnelAddr"
+	calicoWireguardAnnotation     = "projectcalico.org/IPv4WireguardInterfaceAddr"
+	calicoWireguardV6Annotation   = "projectcalico.org/IPv6WireguardInterfaceAddr"
+	calicoNodeAddressAnnotation   = "projectcalico.org/IPv4Address"
+	calicoNodeAddressV6Annotation = "projectcalico.org/IPv6Address"
+)
+
+// calico adds the addresses of the IPIP, VXLAN and Wireguard tunnel interfaces, which are
+// the source of the traffic that is sent from the Node to Pods in other Nodes.
+type calico struct{}
+
+func (calico) Name() string {
+	return "calico"
+}
+
+func (calico) Detected(node *v1.Node) bool {
+	return hasAnyAnnotation(node.Annotations,
+		calicoNodeAddressAnnotation, calicoNodeAddressV6Annotation,
+		calicoIPIPTunnelAnnotation, calicoVXLANTunnelAnnotation, calicoVXLANv6TunnelAnnotation)
+}
+
+func (calico) NodeIPs(node *v1.Node) []string {
+	return annotatedIPs(node.Annotations,
+		calicoIPIPTunnelAnnotation, calicoVXLANTunnelAnnotation, calicoVXLANv6TunnelAnnotation,
+		calicoWireguardAnnotation, calicoWireguardV6Annotation)
+}
@@ -0,0 +1,73 @@
+package cni
+
+import (
+	"log/slog"
+	"net"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	ciliumHostIPv4Annotation       = "network.cilium.io/ipv4-cilium-host"
+	ciliumHostIPv6Annotation       = "network.cilium.io/ipv6-cilium-host"
+	ciliumHostIPv4LegacyAnnotation = "io.cilium.network.ipv4-cilium-host"
+	ciliumHostIPv6LegacyAnnotation = "io.cilium.network.ipv6-cilium-host"
+
+	ciliumInternalIPType = "CiliumInternalIP"
+)
+
+// CiliumNodes is the resource of the CiliumNode objects, which Cilium creates for each
+// Node with the same name.
+var CiliumNodes = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnodes"}
+
+// cilium adds the address of the cilium_host interface, which is reported as the
+// CiliumInternalIP address of the CiliumNode. It is the source of the traffic that
+// is sent from the Node to the Pods.
+// Cilium only copies this address into the Node annotations when the agent runs with the
+// annotate-k8s-node option, which is disabled by default in recent versions. The annotations
+// are only a fallback for when the CiliumNodes can't be listed: see CiliumInternalIPs.
+type cilium struct{}
+
+func (cilium) Name() string {
+	return "cilium"
+}
+
+func (cilium) Detected(node *v1.Node) bool {
+	return hasAnyAnnotation(node.Annotations,
+		ciliumHostIPv4Annotation, ciliumHostIPv6Annotation,
+		ciliumHostIPv4LegacyAnnotation, ciliumHostIPv6LegacyAnnotation)
+}
+
+func (cilium) NodeIPs(node *v1.Node) []string {
+	ips := annotatedIPs(node.Annotations, ciliumHostIPv4Annotation, ciliumHostIPv6Annotation)
+	if len(ips) == 0 {
+		// older Cilium versions
+		ips = annotatedIPs(node.Annotations, ciliumHostIPv4LegacyAnnotation, ciliumHostIPv6LegacyAnnotation)
+	}
+	return ips
+}
+
+// CiliumInternalIPs returns the CiliumInternalIP addresses in the spec of a CiliumNode, which
+// are the addresses of the cilium_host interface of the Node with the same name.
+func CiliumInternalIPs(ciliumNode *unstructured.Unstructured) []string {
+	addresses, _, err := unstructured.NestedSlice(ciliumNode.Object, "spec", "addresses")
+	if err != nil {
+		slog.Debug("ignoring invalid CiliumNode addresses", "component", "cni.CiliumInternalIPs",
+			"node", ciliumNode.GetName(), "error", err)
+		return nil
+	}
+	var ips []string
+	for _, address := range addresses {
+		fields, ok := address.(map[string]interface{})
+		if !ok || fields["type"] != ciliumInternalIPType {
+			continue
+		}
+		value, _ := fields["ip"].(string)
+		if ip := net.ParseIP(value); ip != nil {
+			ips = append(ips, ip.String())
+		}
+	}
+	return ips
+}
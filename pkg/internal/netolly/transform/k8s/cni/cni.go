@@ -0,0 +1,84 @@
+// Package cni provides the adapters that extract, for each supported CNI, the IPs
+// that are assigned to the Nodes apart from the addresses in the Node status (e.g. the
+// addresses of the tunnel or the host-side interfaces). The traffic from or to
+// these IPs would be otherwise reported as external.
+package cni
+
+import (
+	"log/slog"
+	"net"
+	"slices"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Plugin extracts the extra Node IPs for a given CNI
+type Plugin interface {
+	// Name of the CNI
+	Name() string
+	// Detected returns true if the Node has been set up by the CNI
+	Detected(node *v1.Node) bool
+	// NodeIPs returns the extra IPs that the CNI assigned to the Node
+	NodeIPs(node *v1.Node) []string
+}
+
+var plugins = []Plugin{ovnKubernetes{}, calico{}, cilium{}, flannel{}}
+
+// Detect the CNIs of the Node from its annotations. A Node can be set up by more than one
+// CNI (e.g. Canal combines Calico and Flannel). It returns an empty slice if the CNI is
+// not known or not supported.
+func Detect(node *v1.Node) []Plugin {
+	var detected []Plugin
+	for _, p := range plugins {
+		if p.Detected(node) {
+			detected = append(detected, p)
+		}
+	}
+	return detected
+}
+
+// AddNodeIPs appends to the provided list the extra IPs that the CNIs of the Node assigned to it.
+func AddNodeIPs(ips []string, node *v1.Node) []string {
+	for _, plugin := range Detect(node) {
+		for _, ip := range plugin.NodeIPs(node) {
+			if !slices.Contains(ips, ip) {
+				slog.Debug("adding CNI node IP", "component", "cni.AddNodeIPs",
+					"cni", plugin.Name(), "node", node.Name, "ip", ip)
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// annotatedIPs returns the IPs in the provided annotations, if they exist. The value
+// of the annotations can be an IP or a CIDR address (e.g. 10.0.0.1/24).
+func annotatedIPs(annotations map[string]string, names ...string) []string {
+	var ips []string
+	for _, name := range names {
+		value, ok := annotations[name]
+		if !ok || value == "" {
+			continue
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			var err error
+			if ip, _, err = net.ParseCIDR(value); err != nil {
+				slog.Info("ignoring invalid CNI annotation", "component", "cni.AddNodeIPs",
+					"annotation", name, "value", value)
+				continue
+			}
+		}
+		ips = append(ips, ip.String())
+	}
+	return ips
+}
+
+func hasAnyAnnotation(annotations map[string]string, names ...string) bool {
+	for _, name := range names {
+		if _, ok := annotations[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,139 @@
+package cni
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestAddNodeIPs(t *testing.T) {
+	type testCase struct {
+		name        string
+		annotations map[string]string
+		podCIDRs    []string
+		expectedCNI []string
+		expectedIPs []string
+	}
+	for _, tc := range []testCase{{
+		name:        "unknown CNI",
+		annotations: map[string]string{"foo": "bar"},
+		expectedIPs: []string{"192.168.1.10"},
+	}, {
+		name:        "ovn-kubernetes",
+		annotations: map[string]string{ovnSubnetAnnotation: `{"default":"10.129.0.0/23"}`},
+		expectedCNI: []string{"ovn-kubernetes"},
+		expectedIPs: []string{"192.168.1.10", "10.129.0.2"},
+	}, {
+		name: "calico IPIP",
+		annotations: map[string]string{
+			calicoNodeAddressAnnotation: "192.168.1.10/24",
+			calicoIPIPTunnelAnnotation:  "10.244.120.64",
+		},
+		expectedCNI: []string{"calico"},
+		expectedIPs: []string{"192.168.1.10", "10.244.120.64"},
+	}, {
+		name: "calico VXLAN and wireguard",
+		annotations: map[string]string{
+			calicoNodeAddressAnnotation:   "192.168.1.10/24",
+			calicoVXLANTunnelAnnotation:   "10.244.120.65",
+			calicoVXLANv6TunnelAnnotation: "fd00:10:244::1",
+			calicoWireguardAnnotation:     "10.244.120.66",
+		},
+		expectedCNI: []string{"calico"},
+		expectedIPs: []string{"192.168.1.10", "10.244.120.65", "fd00:10:244::1", "10.244.120.66"},
+	}, {
+		name: "cilium",
+		annotations: map[string]string{
+			ciliumHostIPv4Annotation: "10.0.1.37",
+			ciliumHostIPv6Annotation: "invalid",
+		},
+		expectedCNI: []string{"cilium"},
+		expectedIPs: []string{"192.168.1.10", "10.0.1.37"},
+	}, {
+		name:        "cilium legacy",
+		annotations: map[string]string{ciliumHostIPv4LegacyAnnotation: "10.0.1.38"},
+		expectedCNI: []string{"cilium"},
+		expectedIPs: []string{"192.168.1.10", "10.0.1.38"},
+	}, {
+		name: "flannel VXLAN",
+		annotations: map[string]string{
+			flannelPublicIPAnnotation:    "192.168.1.10",
+			flannelBackendTypeAnnotation: "vxlan",
+		},
+		podCIDRs:    []string{"10.244.1.0/24"},
+		expectedCNI: []string{"flannel"},
+		expectedIPs: []string{"192.168.1.10", "10.244.1.0", "10.244.1.1"},
+	}, {
+		name: "flannel host-gw",
+		annotations: map[string]string{
+			flannelPublicIPAnnotation:          "192.168.1.10",
+			flannelPublicIPOverwriteAnnotation: "203.0.113.10",
+			flannelBackendTypeAnnotation:       "host-gw",
+		},
+		podCIDRs:    []string{"10.244.1.0/24", "fd00:10:244:1::/64"},
+		expectedCNI: []string{"flannel"},
+		expectedIPs: []string{"192.168.1.10", "203.0.113.10", "10.244.1.1", "fd00:10:244:1::1"},
+	}, {
+		name: "canal",
+		annotations: map[string]string{
+			calicoNodeAddressAnnotation:  "192.168.1.10/24",
+			flannelPublicIPAnnotation:    "192.168.1.10",
+			flannelBackendTypeAnnotation: "vxlan",
+		},
+		podCIDRs:    []string{"10.244.1.0/24"},
+		expectedCNI: []string{"calico", "flannel"},
+		expectedIPs: []string{"192.168.1.10", "10.244.1.0", "10.244.1.1"},
+	}, {
+		name: "canal with Calico IPIP tunnel",
+		annotations: map[string]string{
+			calicoNodeAddressAnnotation:  "192.168.1.10/24",
+			calicoIPIPTunnelAnnotation:   "10.244.120.64",
+			flannelPublicIPAnnotation:    "192.168.1.10",
+			flannelBackendTypeAnnotation: "host-gw",
+		},
+		podCIDRs:    []string{"10.244.1.0/24"},
+		expectedCNI: []string{"calico", "flannel"},
+		expectedIPs: []string{"192.168.1.10", "10.244.120.64", "10.244.1.1"},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			node := &v1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: "node", Annotations: tc.annotations},
+				Spec:       v1.NodeSpec{PodCIDRs: tc.podCIDRs},
+			}
+			var detected []string
+			for _, plugin := range Detect(node) {
+				detected = append(detected, plugin.Name())
+			}
+			assert.Equal(t, tc.expectedCNI, detected)
+			assert.Equal(t, tc.expectedIPs, AddNodeIPs([]string{"192.168.1.10"}, node))
+		})
+	}
+}
+
+func TestCiliumInternalIPs(t *testing.T) {
+	ciliumNode := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNode",
+		"metadata":   map[string]interface{}{"name": "node"},
+		"spec": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "InternalIP", "ip": "192.168.1.10"},
+				map[string]interface{}{"type": "CiliumInternalIP", "ip": "10.0.1.37"},
+				map[string]interface{}{"type": "CiliumInternalIP", "ip": "fd00:10:244::1"},
+				map[string]interface{}{"type": "CiliumInternalIP", "ip": "invalid"},
+			},
+		},
+	}}
+	assert.Equal(t, []string{"10.0.1.37", "fd00:10:244::1"}, CiliumInternalIPs(ciliumNode))
+
+	assert.Empty(t, CiliumInternalIPs(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "node"},
+	}}))
+	assert.Empty(t, CiliumInternalIPs(&unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "node"},
+		"spec":     map[string]interface{}{"addresses": "invalid"},
+	}}))
+}
@@ -0,0 +1,54 @@
+package cni
+
+import (
+	"net"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	flannelPublicIPAnnotation          = "flannel.alpha.coreos.com/public-ip"
+	flannelPublicIPv6Annotation        = "flannel.alpha.coreos.com/public-ipv6"
+	flannelPublicIPOverwriteAnnotation = "flannel.alpha.coreos.com/public-ip-overwrite"
+	flannelBackendTypeAnnotation       = "flannel.alpha.coreos.com/backend-type"
+
+	flannelBackendVXLAN = "vxlan"
+)
+
+// flannel adds the public IPs of the Node, in case they differ from the Node status
+// addresses, as well as the addresses of the host-side interfaces in the Pods' subnet:
+// the cni0 bridge, which gets the first address of the subnet, and the flannel.1 VXLAN
+// device, which gets the subnet network address.
+type flannel struct{}
+
+func (flannel) Name() string {
+	return "flannel"
+}
+
+func (flannel) Detected(node *v1.Node) bool {
+	return hasAnyAnnotation(node.Annotations,
+		flannelPublicIPAnnotation, flannelPublicIPv6Annotation, flannelBackendTypeAnnotation)
+}
+
+func (flannel) NodeIPs(node *v1.Node) []string {
+	ips := annotatedIPs(node.Annotations,
+		flannelPublicIPAnnotation, flannelPublicIPv6Annotation, flannelPublicIPOverwriteAnnotation)
+	podCIDRs := node.Spec.PodCIDRs
+	if len(podCIDRs) == 0 && node.Spec.PodCIDR != "" {
+		podCIDRs = []string{node.Spec.PodCIDR}
+	}
+	for _, podCIDR := range podCIDRs {
+		_, subnet, err := net.ParseCIDR(podCIDR)
+		if err != nil {
+			continue
+		}
+		if node.Annotations[flannelBackendTypeAnnotation] == flannelBackendVXLAN {
+			ips = append(ips, subnet.IP.String())
+		}
+		gateway := make(net.IP, len(subnet.IP))
+		copy(gateway, subnet.IP)
+		gateway[len(gateway)-1]++
+		ips = append(ips, gateway.String())
+	}
+	return ips
+}
@@ -30,7 +30,17 @@ const (
 	ovnSubnetAnnotation = "k8s.ovn.org/node-subnets"
 )
 
-func AddOvnIPs(ips []string, node *v1.Node) []string {
+type ovnKubernetes struct{}
+
+func (ovnKubernetes) Name() string {
+	return "ovn-kubernetes"
+}
+
+func (ovnKubernetes) Detected(node *v1.Node) bool {
+	return hasAnyAnnotation(node.Annotations, ovnSubnetAnnotation)
+}
+
+func (ovnKubernetes) NodeIPs(node *v1.Node) []string {
 	// Add IP that is used in OVN for some traffic on mp0 interface
 	// (no IP / error returned when not using ovn-k)
 	ip, err := findOvnMp0IP(node.Annotations)
@@ -38,9 +48,9 @@ func AddOvnIPs(ips []string, node *v1.Node) []string {
 		// Log the error as Info, do not block other ips indexing
 		slog.Info("failed to index OVN mp0 IP", "error", err)
 	} else if ip != "" {
-		return append(ips, ip)
+		return []string{ip}
 	}
-	return ips
+	return nil
 }
 
 func findOvnMp0IP(annotations map[string]string) (string, error) {
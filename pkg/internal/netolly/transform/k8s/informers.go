@@ -19,6 +19,7 @@
 package k8s
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net"
@@ -28,7 +29,12 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -45,6 +51,11 @@ const (
 	typeNode              = "Node"
 	typePod               = "Pod"
 	typeService           = "Service"
+
+	labelZone         = "topology.kubernetes.io/zone"
+	labelRegion       = "topology.kubernetes.io/region"
+	labelZoneLegacy   = "failure-domain.beta.kubernetes.io/zone"
+	labelRegionLegacy = "failure-domain.beta.kubernetes.io/region"
 )
 
 // TODO: merge this data structure with the appo11y kubernetes informers
@@ -56,6 +67,9 @@ type NetworkInformers struct {
 	services cache.SharedIndexInformer
 	// replicaSets caches the ReplicaSets as partially-filled *ObjectMeta pointers
 	replicaSets cache.SharedIndexInformer
+	// ciliumNodes caches the CiliumInternalIP addresses of the CiliumNodes as *Info pointers.
+	// It is nil if the CiliumNodes can't be listed (e.g. Cilium is not installed).
+	ciliumNodes cache.SharedIndexInformer
 	stopChan    chan struct{}
 }
 
@@ -76,7 +90,46 @@ type Info struct {
 	Owner    Owner
 	HostName string
 	HostIP   string
-	ips      []string
+	// Zone and Region are taken from the topology labels of the Node. For Pods, they are
+	// taken from the Node where they run.
+	Zone   string
+	Region string
+	ips    []string
+	// portNames maps the named ports of the Pod containers or the Service
+	portNames map[portKey]string
+}
+
+type portKey struct {
+	port     uint16
+	protocol uint8
+}
+
+// PortName returns the name of the Pod container port or Service port, if defined.
+func (i *Info) PortName(port uint16, protocol uint8) (string, bool) {
+	name, ok := i.portNames[portKey{port: port, protocol: protocol}]
+	return name, ok
+}
+
+func addPortName(names map[portKey]string, name string, port int32, protocol v1.Protocol) map[portKey]string {
+	if name == "" {
+		return names
+	}
+	var number uint8
+	switch protocol {
+	case v1.ProtocolTCP, "":
+		number = 6
+	case v1.ProtocolUDP:
+		number = 17
+	case v1.ProtocolSCTP:
+		number = 132
+	default:
+		return names
+	}
+	if names == nil {
+		names = map[portKey]string{}
+	}
+	names[portKey{port: uint16(port), protocol: number}] = name
+	return names
 }
 
 var commonIndexers = map[string]cache.IndexFunc{
@@ -102,13 +155,22 @@ func (k *NetworkInformers) fetchInformers(ip string) (*Info, bool) {
 	if info, ok := infoForIP(k.pods.GetIndexer(), ip); ok {
 		// it might happen that the Host is discovered after the Pod
 		if info.HostName == "" {
-			info.HostName = k.getHostName(info.HostIP)
+			k.setHostInfo(info)
 		}
 		return info, true
 	}
 	if info, ok := infoForIP(k.nodes.GetIndexer(), ip); ok {
 		return info, true
 	}
+	if k.ciliumNodes != nil {
+		if info, ok := infoForIP(k.ciliumNodes.GetIndexer(), ip); ok {
+			// the CiliumNode has the same name as its Node, which provides the rest of the metadata
+			if node, ok, _ := k.nodes.GetIndexer().GetByKey(info.Name); ok {
+				return node.(*Info), true
+			}
+			return info, true
+		}
+	}
 	if info, ok := infoForIP(k.services.GetIndexer(), ip); ok {
 		return info, true
 	}
@@ -158,13 +220,23 @@ func (k *NetworkInformers) getOwner(info *Info) Owner {
 	}
 }
 
-func (k *NetworkInformers) getHostName(hostIP string) string {
-	if hostIP != "" {
-		if info, ok := infoForIP(k.nodes.GetIndexer(), hostIP); ok {
-			return info.Name
+func (k *NetworkInformers) setHostInfo(info *Info) {
+	if info.HostIP != "" {
+		if host, ok := infoForIP(k.nodes.GetIndexer(), info.HostIP); ok {
+			info.HostName = host.Name
+			info.Zone = host.Zone
+			info.Region = host.Region
 		}
 	}
-	return ""
+}
+
+// topologyLabel returns the value of the provided label, or the value of the
+// legacy label if the former is not defined
+func topologyLabel(labels map[string]string, label, legacyLabel string) string {
+	if value, ok := labels[label]; ok {
+		return value
+	}
+	return labels[legacyLabel]
 }
 
 func (k *NetworkInformers) initNodeInformer(informerFactory informers.SharedInformerFactory) error {
@@ -184,7 +256,7 @@ func (k *NetworkInformers) initNodeInformer(informerFactory informers.SharedInfo
 			}
 		}
 		// CNI-dependent logic (must work regardless of whether the CNI is installed)
-		ips = cni.AddOvnIPs(ips, node)
+		ips = cni.AddNodeIPs(ips, node)
 
 		return &Info{
 			ObjectMeta: metav1.ObjectMeta{
@@ -192,8 +264,10 @@ func (k *NetworkInformers) initNodeInformer(informerFactory informers.SharedInfo
 				Namespace: node.Namespace,
 				Labels:    node.Labels,
 			},
-			ips:  ips,
-			Type: typeNode,
+			ips:    ips,
+			Type:   typeNode,
+			Zone:   topologyLabel(node.Labels, labelZone, labelZoneLegacy),
+			Region: topologyLabel(node.Labels, labelRegion, labelRegionLegacy),
 		}, nil
 	}); err != nil {
 		return fmt.Errorf("can't set nodes transform: %w", err)
@@ -221,6 +295,12 @@ func (k *NetworkInformers) initPodInformer(informerFactory informers.SharedInfor
 				ips = append(ips, ip.IP)
 			}
 		}
+		var portNames map[portKey]string
+		for i := range pod.Spec.Containers {
+			for _, port := range pod.Spec.Containers[i].Ports {
+				portNames = addPortName(portNames, port.Name, port.ContainerPort, port.Protocol)
+			}
+		}
 		return &Info{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:            pod.Name,
@@ -228,9 +308,10 @@ func (k *NetworkInformers) initPodInformer(informerFactory informers.SharedInfor
 				Labels:          pod.Labels,
 				OwnerReferences: pod.OwnerReferences,
 			},
-			Type:   typePod,
-			HostIP: pod.Status.HostIP,
-			ips:    ips,
+			Type:      typePod,
+			HostIP:    pod.Status.HostIP,
+			ips:       ips,
+			portNames: portNames,
 		}, nil
 	}); err != nil {
 		return fmt.Errorf("can't set pods transform: %w", err)
@@ -256,14 +337,19 @@ func (k *NetworkInformers) initServiceInformer(informerFactory informers.SharedI
 			k.log.Warn("Service doesn't have any ClusterIP. Beyla won't decorate their flows",
 				"namespace", svc.Namespace, "name", svc.Name)
 		}
+		var portNames map[portKey]string
+		for _, port := range svc.Spec.Ports {
+			portNames = addPortName(portNames, port.Name, port.Port, port.Protocol)
+		}
 		return &Info{
 			ObjectMeta: metav1.ObjectMeta{
 				Name:      svc.Name,
 				Namespace: svc.Namespace,
 				Labels:    svc.Labels,
 			},
-			Type: typeService,
-			ips:  svc.Spec.ClusterIPs,
+			Type:      typeService,
+			ips:       svc.Spec.ClusterIPs,
+			portNames: portNames,
 		}, nil
 	}); err != nil {
 		return fmt.Errorf("can't set services transform: %w", err)
@@ -296,6 +382,64 @@ func (k *NetworkInformers) initReplicaSetInformer(informerFactory informers.Shar
 	return nil
 }
 
+// initCiliumNodeInformer caches the CiliumInternalIP addresses of the CiliumNodes, which Cilium
+// only copies into the Node annotations when its agent runs with the annotate-k8s-node option.
+// If the CiliumNodes can't be listed, the addresses are only taken from the Node annotations.
+func (k *NetworkInformers) initCiliumNodeInformer(lw cache.ListerWatcher, syncTimeout time.Duration) error {
+	if _, err := lw.List(metav1.ListOptions{Limit: 1}); err != nil {
+		if apierrors.IsNotFound(err) {
+			k.log.Debug("CiliumNodes not found. Assuming that Cilium is not installed")
+		} else {
+			k.log.Info("can't list the CiliumNodes. Their CiliumInternalIP addresses will be taken"+
+				" from the Node annotations. Make sure that Beyla can list and watch the"+
+				" ciliumnodes resource of the cilium.io API group", "error", err)
+		}
+		return nil
+	}
+	if syncTimeout <= 0 {
+		syncTimeout = defaultSyncTimeout
+	}
+	ciliumNodes := cache.NewSharedIndexInformer(lw, &unstructured.Unstructured{}, syncTimeout, cache.Indexers{})
+	// Transform any CiliumNode instance into a *Info instance that only keeps its
+	// name and its CiliumInternalIP addresses
+	if err := ciliumNodes.SetTransform(func(i interface{}) (interface{}, error) {
+		ciliumNode, ok := i.(*unstructured.Unstructured)
+		if !ok {
+			return nil, fmt.Errorf("was expecting a CiliumNode. Got: %T", i)
+		}
+		return &Info{
+			ObjectMeta: metav1.ObjectMeta{Name: ciliumNode.GetName()},
+			Type:       typeNode,
+			ips:        cni.CiliumInternalIPs(ciliumNode),
+		}, nil
+	}); err != nil {
+		return fmt.Errorf("can't set CiliumNodes transform: %w", err)
+	}
+	if err := ciliumNodes.AddIndexers(commonIndexers); err != nil {
+		return fmt.Errorf("can't add %s indexer to CiliumNodes informer: %w", IndexIP, err)
+	}
+
+	slog.Debug("starting CiliumNodes informer, waiting for syncronization")
+	go ciliumNodes.Run(k.stopChan)
+	cache.WaitForCacheSync(k.stopChan, ciliumNodes.HasSynced)
+	slog.Debug("CiliumNodes informer started")
+
+	k.ciliumNodes = ciliumNodes
+	return nil
+}
+
+func ciliumNodesListWatch(client dynamic.Interface) cache.ListerWatcher {
+	resource := client.Resource(cni.CiliumNodes)
+	return &cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return resource.List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return resource.Watch(context.Background(), options)
+		},
+	}
+}
+
 func (k *NetworkInformers) InitFromConfig(kubeConfigPath string, syncTimeout time.Duration) error {
 	k.log = slog.With("component", "kubernetes.NetworkInformers")
 	// Initialization variables
@@ -316,7 +460,12 @@ func (k *NetworkInformers) InitFromConfig(kubeConfigPath string, syncTimeout tim
 		return err
 	}
 
-	return nil
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return err
+	}
+
+	return k.initCiliumNodeInformer(ciliumNodesListWatch(dynamicClient), syncTimeout)
 }
 
 func LoadConfig(kubeConfigPath string) (*rest.Config, error) {
@@ -22,6 +22,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/hashicorp/golang-lru/v2/simplelru"
@@ -41,8 +42,17 @@ const (
 	attrSuffixOwnerType = ".owner.type"
 	attrSuffixHostIP    = ".node.ip"
 	attrSuffixHostName  = ".node.name"
+	attrSuffixZone      = ".zone"
+	attrSuffixRegion    = ".region"
+	attrSuffixService   = ".service.name"
 
 	AttrClusterName = "k8s.cluster.name"
+	// AttrCrossZone is "true" if the source and destination of the flow are in different
+	// zones, and "false" if they are in the same zone. It is not set if any of the zones is unknown.
+	AttrCrossZone = "cross.zone"
+	// AttrDstServicePort is the name of the Pod container port or Service port of the
+	// server side of the flow
+	AttrDstServicePort = "dst.service.port"
 
 	AttrDstNamespace = attrPrefixDst + attrSuffixNs
 	AttrDstName      = attrPrefixDst + attrSuffixName
@@ -51,6 +61,9 @@ const (
 	AttrDstOwnerType = attrPrefixDst + attrSuffixOwnerType
 	AttrDstHostIP    = attrPrefixDst + attrSuffixHostIP
 	AttrDstHostName  = attrPrefixDst + attrSuffixHostName
+	AttrDstZone      = attrPrefixDst + attrSuffixZone
+	AttrDstRegion    = attrPrefixDst + attrSuffixRegion
+	AttrDstService   = attrPrefixDst + attrSuffixService
 
 	AttrSrcNamespace = attrPrefixSrc + attrSuffixNs
 	AttrSrcName      = attrPrefixSrc + attrSuffixName
@@ -59,6 +72,9 @@ const (
 	AttrSrcOwnerType = attrPrefixSrc + attrSuffixOwnerType
 	AttrSrcHostIP    = attrPrefixSrc + attrSuffixHostIP
 	AttrSrcHostName  = attrPrefixSrc + attrSuffixHostName
+	AttrSrcZone      = attrPrefixSrc + attrSuffixZone
+	AttrSrcRegion    = attrPrefixSrc + attrSuffixRegion
+	AttrSrcService   = attrPrefixSrc + attrSuffixService
 )
 
 const alreadyLoggedIPsCacheLen = 256
@@ -131,6 +147,14 @@ func (n *decorator) transform(flow *ebpf.Record) bool {
 	}
 	srcOk := n.decorate(flow, attrPrefixSrc, flow.Id.SrcIP().IP().String())
 	dstOk := n.decorate(flow, attrPrefixDst, flow.Id.DstIP().IP().String())
+	if nat := flow.Attrs.NAT; nat != nil {
+		n.decorateService(flow, attrPrefixSrc, &nat.Original.SrcIP, flow.Id.SrcIP())
+		n.decorateService(flow, attrPrefixDst, &nat.Original.DstIP, flow.Id.DstIP())
+	}
+	srcZone, dstZone := flow.Attrs.Metadata[AttrSrcZone], flow.Attrs.Metadata[AttrDstZone]
+	if srcZone != "" && dstZone != "" {
+		flow.Attrs.Metadata[AttrCrossZone] = strconv.FormatBool(srcZone != dstZone)
+	}
 	return srcOk && dstOk
 }
 
@@ -158,6 +182,21 @@ func (n *decorator) decorate(flow *ebpf.Record, prefix, ip string) bool {
 			flow.Attrs.Metadata[prefix+attrSuffixHostName] = kubeInfo.HostName
 		}
 	}
+	if kubeInfo.Zone != "" {
+		flow.Attrs.Metadata[prefix+attrSuffixZone] = kubeInfo.Zone
+	}
+	if kubeInfo.Region != "" {
+		flow.Attrs.Metadata[prefix+attrSuffixRegion] = kubeInfo.Region
+	}
+	// the server side of the flow can be either the source or the destination, but the
+	// port names of the destination take precedence as it is decorated later
+	port := flow.Id.SrcPort
+	if prefix == attrPrefixDst {
+		port = flow.Id.DstPort
+	}
+	if name, ok := kubeInfo.PortName(port, flow.Id.TransportProtocol); ok {
+		flow.Attrs.Metadata[AttrDstServicePort] = name
+	}
 	// decorate other names from metadata, if required
 	if prefix == attrPrefixDst {
 		if flow.Attrs.DstName == "" {
@@ -171,6 +210,17 @@ func (n *decorator) decorate(flow *ebpf.Record, prefix, ip string) bool {
 	return true
 }
 
+// decorateService sets the name of the Service whose address was translated into
+// the actual address of the flow
+func (n *decorator) decorateService(flow *ebpf.Record, prefix string, original, actual *ebpf.IPAddr) {
+	if *original == *actual {
+		return
+	}
+	if kubeInfo, ok := n.kube.GetInfo(original.IP().String()); ok && kubeInfo.Type == typeService {
+		flow.Attrs.Metadata[prefix+attrSuffixService] = kubeInfo.Name
+	}
+}
+
 // newDecorator create a new transform
 func newDecorator(ctx context.Context, cfg *MetadataDecorator) (*decorator, error) {
 	nt := decorator{
@@ -0,0 +1,250 @@
+package k8s
+
+import (
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	fakek8sclientset "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/netolly/transform/k8s/cni"
+)
+
+func TestDecorator_Zones(t *testing.T) {
+	client := fakek8sclientset.NewSimpleClientset(
+		fakeNode("node-a1", "10.0.0.1", map[string]string{labelZone: "eu-west-1a", labelRegion: "eu-west-1"}),
+		fakeNode("node-a2", "10.0.0.2", map[string]string{labelZone: "eu-west-1a", labelRegion: "eu-west-1"}),
+		fakeNode("node-b", "10.0.0.3", map[string]string{labelZoneLegacy: "eu-west-1b", labelRegionLegacy: "eu-west-1"}),
+		fakeNode("node-unlabeled", "10.0.0.4", nil),
+		fakePod("frontend", "10.0.0.1", "10.244.1.1"),
+		fakePod("backend", "10.0.0.2", "10.244.2.1"),
+		fakePod("database", "10.0.0.3", "10.244.3.1"),
+		fakePod("cache", "10.0.0.4", "10.244.4.1"),
+	)
+	dec := decorator{log: slog.Default(), kube: NetworkInformers{log: slog.Default(), stopChan: make(chan struct{})}}
+	defer close(dec.kube.stopChan)
+	require.NoError(t, dec.kube.initInformers(client, time.Minute))
+
+	sameZone := zoneFlow("10.244.1.1", "10.244.2.1")
+	crossZone := zoneFlow("10.244.1.1", "10.244.3.1")
+	unknownZone := zoneFlow("10.244.3.1", "10.244.4.1")
+	external := zoneFlow("10.244.2.1", "140.82.121.4")
+	nodeToPod := zoneFlow("10.0.0.3", "10.244.3.1")
+	dec.decorateNoDrop([]*ebpf.Record{sameZone, crossZone, unknownZone, external, nodeToPod})
+
+	assert.Equal(t, "eu-west-1a", sameZone.Attrs.Metadata[AttrSrcZone])
+	assert.Equal(t, "eu-west-1a", sameZone.Attrs.Metadata[AttrDstZone])
+	assert.Equal(t, "eu-west-1", sameZone.Attrs.Metadata[AttrSrcRegion])
+	assert.Equal(t, "eu-west-1", sameZone.Attrs.Metadata[AttrDstRegion])
+	assert.Equal(t, "false", sameZone.Attrs.Metadata[AttrCrossZone])
+
+	assert.Equal(t, "eu-west-1a", crossZone.Attrs.Metadata[AttrSrcZone])
+	assert.Equal(t, "eu-west-1b", crossZone.Attrs.Metadata[AttrDstZone])
+	assert.Equal(t, "true", crossZone.Attrs.Metadata[AttrCrossZone])
+
+	assert.Equal(t, "eu-west-1b", unknownZone.Attrs.Metadata[AttrSrcZone])
+	assert.NotContains(t, unknownZone.Attrs.Metadata, AttrDstZone)
+	assert.NotContains(t, unknownZone.Attrs.Metadata, AttrCrossZone)
+
+	assert.Equal(t, "eu-west-1a", external.Attrs.Metadata[AttrSrcZone])
+	assert.NotContains(t, external.Attrs.Metadata, AttrCrossZone)
+
+	assert.Equal(t, "eu-west-1b", nodeToPod.Attrs.Metadata[AttrSrcZone])
+	assert.Equal(t, "false", nodeToPod.Attrs.Metadata[AttrCrossZone])
+}
+
+func TestDecorator_PortNames(t *testing.T) {
+	database := fakePod("database", "10.0.0.3", "10.244.3.1")
+	database.Spec.Containers = []v1.Container{{Ports: []v1.ContainerPort{
+		{Name: "db", ContainerPort: 5432},
+		{ContainerPort: 9187},
+		{Name: "dns", ContainerPort: 5353, Protocol: v1.ProtocolUDP},
+	}}}
+	client := fakek8sclientset.NewSimpleClientset(
+		fakePod("frontend", "10.0.0.1", "10.244.1.1"),
+		database,
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "database", Namespace: "default"},
+			Spec: v1.ServiceSpec{
+				ClusterIPs: []string{"10.96.0.10"},
+				Ports:      []v1.ServicePort{{Name: "postgres", Port: 5432, Protocol: v1.ProtocolTCP}},
+			},
+		},
+	)
+	dec := decorator{log: slog.Default(), kube: NetworkInformers{log: slog.Default(), stopChan: make(chan struct{})}}
+	defer close(dec.kube.stopChan)
+	require.NoError(t, dec.kube.initInformers(client, time.Minute))
+
+	request := zoneFlow("10.244.1.1", "10.244.3.1")
+	request.Id.SrcPort, request.Id.DstPort, request.Id.TransportProtocol = 45678, 5432, 6
+	response := zoneFlow("10.244.3.1", "10.244.1.1")
+	response.Id.SrcPort, response.Id.DstPort, response.Id.TransportProtocol = 5432, 45678, 6
+	toService := zoneFlow("10.244.1.1", "10.96.0.10")
+	toService.Id.SrcPort, toService.Id.DstPort, toService.Id.TransportProtocol = 45678, 5432, 6
+	unnamed := zoneFlow("10.244.1.1", "10.244.3.1")
+	unnamed.Id.SrcPort, unnamed.Id.DstPort, unnamed.Id.TransportProtocol = 45678, 9187, 6
+	otherProtocol := zoneFlow("10.244.1.1", "10.244.3.1")
+	otherProtocol.Id.SrcPort, otherProtocol.Id.DstPort, otherProtocol.Id.TransportProtocol = 45678, 5432, 17
+	dec.decorateNoDrop([]*ebpf.Record{request, response, toService, unnamed, otherProtocol})
+
+	assert.Equal(t, "db", request.Attrs.Metadata[AttrDstServicePort])
+	assert.Equal(t, "db", response.Attrs.Metadata[AttrDstServicePort])
+	assert.Equal(t, "postgres", toService.Attrs.Metadata[AttrDstServicePort])
+	assert.NotContains(t, unnamed.Attrs.Metadata, AttrDstServicePort)
+	assert.NotContains(t, otherProtocol.Attrs.Metadata, AttrDstServicePort)
+}
+
+func TestDecorator_NATServiceName(t *testing.T) {
+	client := fakek8sclientset.NewSimpleClientset(
+		fakePod("frontend", "10.0.0.1", "10.244.1.1"),
+		fakePod("backend", "10.0.0.2", "10.244.2.1"),
+		&v1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "backend-svc", Namespace: "default"},
+			Spec:       v1.ServiceSpec{ClusterIPs: []string{"10.96.0.10"}},
+		},
+	)
+	dec := decorator{log: slog.Default(), kube: NetworkInformers{log: slog.Default(), stopChan: make(chan struct{})}}
+	defer close(dec.kube.stopChan)
+	require.NoError(t, dec.kube.initInformers(client, time.Minute))
+
+	request := zoneFlow("10.244.1.1", "10.244.2.1")
+	request.Attrs.NAT = &ebpf.NAT{Original: natEndpoints("10.244.1.1", "10.96.0.10")}
+	response := zoneFlow("10.244.2.1", "10.244.1.1")
+	response.Attrs.NAT = &ebpf.NAT{Original: natEndpoints("10.96.0.10", "10.244.1.1")}
+	notTranslated := zoneFlow("10.244.1.1", "10.244.2.1")
+	dec.decorateNoDrop([]*ebpf.Record{request, response, notTranslated})
+
+	assert.Equal(t, "backend", request.Attrs.Metadata[AttrDstName])
+	assert.Equal(t, "backend-svc", request.Attrs.Metadata[AttrDstService])
+	assert.NotContains(t, request.Attrs.Metadata, AttrSrcService)
+
+	assert.Equal(t, "backend", response.Attrs.Metadata[AttrSrcName])
+	assert.Equal(t, "backend-svc", response.Attrs.Metadata[AttrSrcService])
+	assert.NotContains(t, response.Attrs.Metadata, AttrDstService)
+
+	assert.NotContains(t, notTranslated.Attrs.Metadata, AttrSrcService)
+	assert.NotContains(t, notTranslated.Attrs.Metadata, AttrDstService)
+}
+
+func TestDecorator_CiliumInternalIP(t *testing.T) {
+	client := fakek8sclientset.NewSimpleClientset(
+		fakeNode("node-a", "10.0.0.1", map[string]string{labelZone: "eu-west-1a"}),
+		fakePod("frontend", "10.0.0.1", "10.244.1.1"),
+	)
+	dec := decorator{log: slog.Default(), kube: NetworkInformers{log: slog.Default(), stopChan: make(chan struct{})}}
+	defer close(dec.kube.stopChan)
+	require.NoError(t, dec.kube.initInformers(client, time.Minute))
+	require.NoError(t, dec.kube.initCiliumNodeInformer(fakeCiliumNodes(nil,
+		fakeCiliumNode("node-a", "10.0.1.37"),
+		// the Node of this CiliumNode is not known yet
+		fakeCiliumNode("node-b", "10.0.2.37"),
+	), time.Minute))
+
+	// the cilium_host address is attributed to the Node of the CiliumNode
+	fromHost := zoneFlow("10.0.1.37", "10.244.1.1")
+	fromUnknownNode := zoneFlow("10.0.2.37", "10.244.1.1")
+	dec.decorateNoDrop([]*ebpf.Record{fromHost, fromUnknownNode})
+
+	assert.Equal(t, "node-a", fromHost.Attrs.Metadata[AttrSrcName])
+	assert.Equal(t, typeNode, fromHost.Attrs.Metadata[AttrSrcType])
+	assert.Equal(t, "eu-west-1a", fromHost.Attrs.Metadata[AttrSrcZone])
+	assert.Equal(t, "node-b", fromUnknownNode.Attrs.Metadata[AttrSrcName])
+	assert.Equal(t, typeNode, fromUnknownNode.Attrs.Metadata[AttrSrcType])
+}
+
+func TestDecorator_CiliumNodesNotListable(t *testing.T) {
+	client := fakek8sclientset.NewSimpleClientset(
+		fakeNode("node-a", "10.0.0.1", nil),
+	)
+	dec := decorator{log: slog.Default(), kube: NetworkInformers{log: slog.Default(), stopChan: make(chan struct{})}}
+	defer close(dec.kube.stopChan)
+	require.NoError(t, dec.kube.initInformers(client, time.Minute))
+
+	// Cilium is not installed, or Beyla can't list the CiliumNodes: the informer is not started
+	// and the flows are still decorated
+	for _, err := range []error{
+		apierrors.NewNotFound(cni.CiliumNodes.GroupResource(), ""),
+		apierrors.NewForbidden(cni.CiliumNodes.GroupResource(), "", nil),
+	} {
+		require.NoError(t, dec.kube.initCiliumNodeInformer(fakeCiliumNodes(err), time.Minute))
+		assert.Nil(t, dec.kube.ciliumNodes)
+
+		fromNode := zoneFlow("10.0.0.1", "10.0.1.37")
+		dec.decorateNoDrop([]*ebpf.Record{fromNode})
+		assert.Equal(t, "node-a", fromNode.Attrs.Metadata[AttrSrcName])
+	}
+}
+
+func fakeCiliumNodes(listErr error, ciliumNodes ...unstructured.Unstructured) cache.ListerWatcher {
+	return &cache.ListWatch{
+		ListFunc: func(_ metav1.ListOptions) (runtime.Object, error) {
+			if listErr != nil {
+				return nil, listErr
+			}
+			list := &unstructured.UnstructuredList{Items: ciliumNodes}
+			list.SetAPIVersion("cilium.io/v2")
+			list.SetKind("CiliumNodeList")
+			return list, nil
+		},
+		WatchFunc: func(_ metav1.ListOptions) (watch.Interface, error) {
+			return watch.NewFake(), nil
+		},
+	}
+}
+
+func fakeCiliumNode(name, ciliumInternalIP string) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "cilium.io/v2",
+		"kind":       "CiliumNode",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "CiliumInternalIP", "ip": ciliumInternalIP},
+			},
+		},
+	}}
+}
+
+func natEndpoints(srcIP, dstIP string) ebpf.Endpoints {
+	e := ebpf.Endpoints{}
+	copy(e.SrcIP[:], net.ParseIP(srcIP).To16())
+	copy(e.DstIP[:], net.ParseIP(dstIP).To16())
+	return e
+}
+
+func fakeNode(name, ip string, labels map[string]string) *v1.Node {
+	return &v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: v1.NodeStatus{
+			Addresses: []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: ip}},
+		},
+	}
+}
+
+func fakePod(name, hostIP, ip string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Status: v1.PodStatus{
+			HostIP: hostIP,
+			PodIPs: []v1.PodIP{{IP: ip}},
+		},
+	}
+}
+
+func zoneFlow(srcIP, dstIP string) *ebpf.Record {
+	r := &ebpf.Record{}
+	copy(r.Id.SrcIp.In6U.U6Addr8[:], net.ParseIP(srcIP).To16())
+	copy(r.Id.DstIp.In6U.U6Addr8[:], net.ParseIP(dstIP).To16())
+	return r
+}
@@ -0,0 +1,35 @@
+package nat
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+// dumpConntrack lists the IPv4 and IPv6 connections of the conntrack table of the
+// host network namespace. It requires the CAP_NET_ADMIN capability.
+func dumpConntrack() ([]connection, error) {
+	var conns []connection
+	for _, family := range []netlink.InetFamily{netlink.FAMILY_V4, netlink.FAMILY_V6} {
+		flows, err := netlink.ConntrackTableList(netlink.ConntrackTable, family)
+		if err != nil {
+			return nil, fmt.Errorf("listing conntrack table: %w", err)
+		}
+		for _, f := range flows {
+			conns = append(conns, connection{
+				protocol: f.Forward.Protocol,
+				original: ebpf.Endpoints{
+					SrcIP: ipAddr(f.Forward.SrcIP), DstIP: ipAddr(f.Forward.DstIP),
+					SrcPort: f.Forward.SrcPort, DstPort: f.Forward.DstPort,
+				},
+				reply: ebpf.Endpoints{
+					SrcIP: ipAddr(f.Reverse.SrcIP), DstIP: ipAddr(f.Reverse.DstIP),
+					SrcPort: f.Reverse.SrcPort, DstPort: f.Reverse.DstPort,
+				},
+			})
+		}
+	}
+	return conns, nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package nat
+
+import "errors"
+
+func dumpConntrack() ([]connection, error) {
+	return nil, errors.New("the conntrack table can only be accessed in Linux")
+}
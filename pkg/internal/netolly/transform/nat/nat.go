@@ -0,0 +1,191 @@
+// Package nat resolves the real endpoints of the flows that are translated by the network
+// address translation of the host (e.g. by kube-proxy in iptables or IPVS mode), by looking
+// at the entries of the conntrack table.
+package nat
+
+import (
+	"log/slog"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	protocolTCP  = 6
+	protocolUDP  = 17
+	protocolSCTP = 132
+)
+
+// refreshPeriod is the frequency of the conntrack table dumps
+const refreshPeriod = 5 * time.Second
+
+func nlog() *slog.Logger {
+	return slog.With("component", "nat.Resolver")
+}
+
+// Resolver replaces the endpoints of the flows that were captured before or after being
+// translated by the host (e.g. a Pod connecting to a Service ClusterIP, or a Pod connecting
+// to an external host through the IP of its Node) by the endpoints of the actual client
+// and server. The captured endpoints are kept in the NAT attribute of the flow.
+type Resolver struct {
+	Enable bool
+}
+
+func (r Resolver) Enabled() bool {
+	return r.Enable
+}
+
+// connection is a conntrack entry: the original tuple, as sent by the client, and the
+// reply tuple, as sent by the server
+type connection struct {
+	protocol uint8
+	original ebpf.Endpoints
+	reply    ebpf.Endpoints
+}
+
+type key struct {
+	endpoints ebpf.Endpoints
+	protocol  uint8
+}
+
+type translation struct {
+	nat ebpf.NAT
+	// real endpoints of the flow
+	real ebpf.Endpoints
+}
+
+func ResolverProvider(_ Resolver) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
+	res := &resolver{dump: dumpConntrack}
+	return func(in <-chan []*ebpf.Record, out chan<- []*ebpf.Record) {
+		log := nlog()
+		log.Debug("starting node")
+		stop := make(chan struct{})
+		go res.refreshLoop(log, refreshPeriod, stop)
+		for flows := range in {
+			res.resolve(flows)
+			out <- flows
+		}
+		close(stop)
+		log.Debug("stopping node")
+	}, nil
+}
+
+type resolver struct {
+	dump func() ([]connection, error)
+	// translations are replaced by the refresh goroutine after each conntrack dump, so the
+	// flows are resolved without waiting for a dump to finish
+	translations atomic.Pointer[map[key]*translation]
+	// dumpFailing is true while the conntrack dumps fail, so the failure is only reported
+	// as a warning once, and not each refresh period
+	dumpFailing bool
+}
+
+// refreshLoop dumps the conntrack table periodically until the stop channel is closed.
+// The table is dumped periodically instead of on each lookup miss, as most of the flows
+// are not translated and wouldn't be found anyway.
+func (r *resolver) refreshLoop(log *slog.Logger, period time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		r.refresh(log)
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *resolver) refresh(log *slog.Logger) {
+	conns, err := r.dump()
+	switch {
+	case err == nil:
+		r.dumpFailing = false
+		trs := translations(conns)
+		r.translations.Store(&trs)
+	case !r.dumpFailing:
+		r.dumpFailing = true
+		log.Warn("can't dump the conntrack table. The translated flows won't be resolved until"+
+			" the dump succeeds. Make sure that Beyla runs in the host network namespace and"+
+			" has the CAP_NET_ADMIN capability", "error", err)
+	default:
+		log.Debug("can't dump the conntrack table", "error", err)
+	}
+}
+
+func (r *resolver) resolve(flows []*ebpf.Record) {
+	trs := r.translations.Load()
+	if trs == nil || len(*trs) == 0 {
+		return
+	}
+	for _, flow := range flows {
+		switch flow.Id.TransportProtocol {
+		case protocolTCP, protocolUDP, protocolSCTP:
+		default:
+			continue
+		}
+		tr, ok := (*trs)[key{
+			endpoints: ebpf.Endpoints{
+				SrcIP:   *flow.Id.SrcIP(),
+				DstIP:   *flow.Id.DstIP(),
+				SrcPort: flow.Id.SrcPort,
+				DstPort: flow.Id.DstPort,
+			},
+			protocol: flow.Id.TransportProtocol,
+		}]
+		if !ok {
+			continue
+		}
+		nat := tr.nat
+		flow.Attrs.NAT = &nat
+		*flow.Id.SrcIP() = tr.real.SrcIP
+		*flow.Id.DstIP() = tr.real.DstIP
+		flow.Id.SrcPort = tr.real.SrcPort
+		flow.Id.DstPort = tr.real.DstPort
+	}
+}
+
+// translations indexes the translated connections by the endpoints that can be
+// observed in any direction, before or after the translation.
+func translations(conns []connection) map[key]*translation {
+	trs := map[key]*translation{}
+	for i := range conns {
+		c := &conns[i]
+		// the request, after being translated, is the inverse of the reply tuple
+		translated := inverse(&c.reply)
+		if c.original == translated {
+			continue
+		}
+		// the client is in the original tuple and the server is in the reply tuple
+		request := &translation{
+			nat: ebpf.NAT{Original: c.original, Translated: translated},
+			real: ebpf.Endpoints{
+				SrcIP: c.original.SrcIP, SrcPort: c.original.SrcPort,
+				DstIP: c.reply.SrcIP, DstPort: c.reply.SrcPort,
+			},
+		}
+		response := &translation{
+			nat:  ebpf.NAT{Original: inverse(&c.original), Translated: c.reply},
+			real: inverse(&request.real),
+		}
+		trs[key{endpoints: c.original, protocol: c.protocol}] = request
+		trs[key{endpoints: translated, protocol: c.protocol}] = request
+		trs[key{endpoints: c.reply, protocol: c.protocol}] = response
+		trs[key{endpoints: response.nat.Original, protocol: c.protocol}] = response
+	}
+	return trs
+}
+
+func inverse(e *ebpf.Endpoints) ebpf.Endpoints {
+	return ebpf.Endpoints{SrcIP: e.DstIP, DstIP: e.SrcIP, SrcPort: e.DstPort, DstPort: e.SrcPort}
+}
+
+func ipAddr(ip net.IP) ebpf.IPAddr {
+	var addr ebpf.IPAddr
+	copy(addr[:], ip.To16())
+	return addr
+}
@@ -0,0 +1,201 @@
+package nat
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	clientPod  = "10.244.1.5"
+	backendPod = "10.244.2.7"
+	clusterIP  = "10.96.0.10"
+	nodeIP     = "192.168.1.10"
+	external   = "140.82.121.4"
+)
+
+func TestResolver(t *testing.T) {
+	res := &resolver{
+		dump: func() ([]connection, error) {
+			return []connection{
+				// the client Pod connects to a Service, which is translated to a backend Pod
+				conn(protocolTCP, clientPod, 45678, clusterIP, 80, backendPod, 8080, clientPod, 45678),
+				// the client Pod connects to an external host, through the Node IP
+				conn(protocolTCP, clientPod, 45679, external, 443, external, 443, nodeIP, 50000),
+				// not translated
+				conn(protocolUDP, clientPod, 45680, backendPod, 53, backendPod, 53, clientPod, 45680),
+			}, nil
+		},
+	}
+
+	toService := flow(protocolTCP, clientPod, 45678, clusterIP, 80)
+	toBackend := flow(protocolTCP, clientPod, 45678, backendPod, 8080)
+	fromBackend := flow(protocolTCP, backendPod, 8080, clientPod, 45678)
+	fromService := flow(protocolTCP, clusterIP, 80, clientPod, 45678)
+	toExternalFromPod := flow(protocolTCP, clientPod, 45679, external, 443)
+	toExternalFromNode := flow(protocolTCP, nodeIP, 50000, external, 443)
+	fromExternalToNode := flow(protocolTCP, external, 443, nodeIP, 50000)
+	notTranslated := flow(protocolUDP, clientPod, 45680, backendPod, 53)
+	otherProtocol := flow(protocolUDP, clientPod, 45678, clusterIP, 80)
+	res.refresh(nlog())
+	res.resolve([]*ebpf.Record{
+		toService, toBackend, fromBackend, fromService,
+		toExternalFromPod, toExternalFromNode, fromExternalToNode,
+		notTranslated, otherProtocol,
+	})
+
+	// the Service flows are attributed to the actual client and backend
+	for _, f := range []*ebpf.Record{toService, toBackend} {
+		assertEndpoints(t, f, clientPod, 45678, backendPod, 8080)
+		assert.Equal(t, &ebpf.NAT{
+			Original:   endpoints(clientPod, 45678, clusterIP, 80),
+			Translated: endpoints(clientPod, 45678, backendPod, 8080),
+		}, f.Attrs.NAT)
+	}
+	for _, f := range []*ebpf.Record{fromBackend, fromService} {
+		assertEndpoints(t, f, backendPod, 8080, clientPod, 45678)
+		assert.Equal(t, &ebpf.NAT{
+			Original:   endpoints(clusterIP, 80, clientPod, 45678),
+			Translated: endpoints(backendPod, 8080, clientPod, 45678),
+		}, f.Attrs.NAT)
+	}
+	// the external flows are attributed to the client Pod instead of the Node
+	for _, f := range []*ebpf.Record{toExternalFromPod, toExternalFromNode} {
+		assertEndpoints(t, f, clientPod, 45679, external, 443)
+		assert.Equal(t, &ebpf.NAT{
+			Original:   endpoints(clientPod, 45679, external, 443),
+			Translated: endpoints(nodeIP, 50000, external, 443),
+		}, f.Attrs.NAT)
+	}
+	assertEndpoints(t, fromExternalToNode, external, 443, clientPod, 45679)
+
+	assertEndpoints(t, notTranslated, clientPod, 45680, backendPod, 53)
+	assert.Nil(t, notTranslated.Attrs.NAT)
+	assertEndpoints(t, otherProtocol, clientPod, 45678, clusterIP, 80)
+	assert.Nil(t, otherProtocol.Attrs.NAT)
+
+	// dump errors keep the previous translations
+	res.dump = func() ([]connection, error) { return nil, errors.New("permission denied") }
+	res.refresh(nlog())
+	toService = flow(protocolTCP, clientPod, 45678, clusterIP, 80)
+	res.resolve([]*ebpf.Record{toService})
+	assertEndpoints(t, toService, clientPod, 45678, backendPod, 8080)
+}
+
+func TestResolver_SlowDumpDoesNotBlock(t *testing.T) {
+	dumping := make(chan struct{}, 10)
+	release := make(chan struct{})
+	dumps := 0
+	res := &resolver{
+		dump: func() ([]connection, error) {
+			dumps++
+			if dumps > 1 {
+				// the next dumps hang until the end of the test
+				dumping <- struct{}{}
+				<-release
+			}
+			return []connection{
+				conn(protocolTCP, clientPod, 45678, clusterIP, 80, backendPod, 8080, clientPod, 45678),
+			}, nil
+		},
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	defer close(release)
+	go res.refreshLoop(nlog(), 10*time.Millisecond, stop)
+
+	// wait for the first dump to be loaded and for the second dump to be in progress
+	assert.Eventually(t, func() bool { return res.translations.Load() != nil }, 5*time.Second, 10*time.Millisecond)
+	select {
+	case <-dumping:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout while waiting for the conntrack dump")
+	}
+
+	// the flows are resolved with the previous translations while the dump is in progress
+	resolved := make(chan *ebpf.Record)
+	go func() {
+		toService := flow(protocolTCP, clientPod, 45678, clusterIP, 80)
+		res.resolve([]*ebpf.Record{toService})
+		resolved <- toService
+	}()
+	select {
+	case f := <-resolved:
+		assertEndpoints(t, f, clientPod, 45678, backendPod, 8080)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the resolution of the flows is blocked by the conntrack dump")
+	}
+}
+
+func TestResolver_DumpErrors(t *testing.T) {
+	logs := &bytes.Buffer{}
+	log := slog.New(slog.NewTextHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	fail := true
+	res := &resolver{
+		dump: func() ([]connection, error) {
+			if fail {
+				return nil, errors.New("operation not permitted")
+			}
+			return nil, nil
+		},
+	}
+	warnings := func() int { return strings.Count(logs.String(), "level=WARN") }
+
+	// the first failure is reported as a warning, and the next ones are only debug messages
+	res.refresh(log)
+	assert.Equal(t, 1, warnings())
+	res.refresh(log)
+	assert.Equal(t, 1, warnings())
+	assert.Contains(t, logs.String(), "level=DEBUG")
+
+	// after a successful dump, a new failure is reported again as a warning
+	fail = false
+	res.refresh(log)
+	fail = true
+	res.refresh(log)
+	assert.Equal(t, 2, warnings())
+}
+
+func conn(protocol uint8,
+	origSrc string, origSrcPort uint16, origDst string, origDstPort uint16,
+	replySrc string, replySrcPort uint16, replyDst string, replyDstPort uint16,
+) connection {
+	return connection{
+		protocol: protocol,
+		original: endpoints(origSrc, origSrcPort, origDst, origDstPort),
+		reply:    endpoints(replySrc, replySrcPort, replyDst, replyDstPort),
+	}
+}
+
+func endpoints(src string, srcPort uint16, dst string, dstPort uint16) ebpf.Endpoints {
+	return ebpf.Endpoints{
+		SrcIP: ipAddr(net.ParseIP(src)), SrcPort: srcPort,
+		DstIP: ipAddr(net.ParseIP(dst)), DstPort: dstPort,
+	}
+}
+
+func flow(protocol uint8, src string, srcPort uint16, dst string, dstPort uint16) *ebpf.Record {
+	r := &ebpf.Record{}
+	r.Id.TransportProtocol = protocol
+	*r.Id.SrcIP() = ipAddr(net.ParseIP(src))
+	*r.Id.DstIP() = ipAddr(net.ParseIP(dst))
+	r.Id.SrcPort = srcPort
+	r.Id.DstPort = dstPort
+	return r
+}
+
+func assertEndpoints(t *testing.T, f *ebpf.Record, src string, srcPort uint16, dst string, dstPort uint16) {
+	t.Helper()
+	assert.Equal(t, src, f.Id.SrcIP().IP().String())
+	assert.Equal(t, srcPort, f.Id.SrcPort)
+	assert.Equal(t, dst, f.Id.DstIP().IP().String())
+	assert.Equal(t, dstPort, f.Id.DstPort)
+}
@@ -0,0 +1,164 @@
+// Package process decorates the network flows with information about the local processes
+// that own their sockets.
+package process
+
+import (
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mariomac/pipes/pkg/node"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+const (
+	AttrPID         = "process.pid"
+	AttrExecName    = "process.executable.name"
+	AttrServiceName = "service.name"
+)
+
+// minRefreshPeriod limits the frequency of the socket table rebuilds, as it requires
+// visiting the file descriptors of all the processes in the host.
+const minRefreshPeriod = 5 * time.Second
+
+// unattributedTTL is the time during which the flows that couldn't be attributed after a
+// socket table rebuild do not trigger new rebuilds (e.g. flows that are forwarded by the host,
+// or whose sockets were already closed).
+const unattributedTTL = time.Minute
+
+func plog() *slog.Logger {
+	return slog.With("component", "process.Decorator")
+}
+
+// Decorator sets the process.pid, process.executable.name and service.name attributes
+// to the flows that are sent or received by a local socket. The service name is only set
+// if the process is instrumented by the application observability pipeline.
+// The attribution is done from the sockets that are open when the decorator looks for them,
+// so the short-lived connections might not be attributed.
+type Decorator struct {
+	// AllowedAttributes of the user configuration. The decorator is enabled only if
+	// any of the process attributes is selected.
+	AllowedAttributes []string
+	// Services registry of the instrumented processes. It can be nil.
+	Services *svc.PIDRegistry
+}
+
+func (d Decorator) Enabled() bool {
+	for _, attr := range d.AllowedAttributes {
+		switch strings.ReplaceAll(attr, "_", ".") {
+		case AttrPID, AttrExecName, AttrServiceName:
+			return true
+		}
+	}
+	return false
+}
+
+// nolint:gocritic
+func DecoratorProvider(d Decorator) (node.MiddleFunc[[]*ebpf.Record, []*ebpf.Record], error) {
+	dec := &decorator{
+		services:     d.Services,
+		table:        &socketTable{procRoot: "/proc", localAddrs: net.InterfaceAddrs},
+		clock:        time.Now,
+		unattributed: map[flowEndpoints]time.Time{},
+	}
+	return func(in <-chan []*ebpf.Record, out chan<- []*ebpf.Record) {
+		log := plog()
+		log.Debug("starting node")
+		for flows := range in {
+			dec.decorate(log, flows)
+			out <- flows
+		}
+		log.Debug("stopping node")
+	}, nil
+}
+
+type decorator struct {
+	services  *svc.PIDRegistry
+	table     *socketTable
+	clock     func() time.Time
+	endpoints map[endpoint]*procInfo
+	lastBuild time.Time
+	// unattributed flows after the last table rebuilds, and the time until they are ignored
+	// as a reason to rebuild the table again
+	unattributed map[flowEndpoints]time.Time
+}
+
+// flowEndpoints identifies the connection of a flow
+type flowEndpoints struct {
+	src endpoint
+	dst endpoint
+}
+
+func endpointsOf(flow *ebpf.Record) flowEndpoints {
+	proto := flow.Id.TransportProtocol
+	return flowEndpoints{
+		src: endpoint{ip: *flow.Id.SrcIP(), port: flow.Id.SrcPort, protocol: proto},
+		dst: endpoint{ip: *flow.Id.DstIP(), port: flow.Id.DstPort, protocol: proto},
+	}
+}
+
+func (d *decorator) decorate(log *slog.Logger, flows []*ebpf.Record) {
+	now := d.clock()
+	var missing []*ebpf.Record
+	rebuild := false
+	for _, flow := range flows {
+		if d.decorateFlow(flow) {
+			continue
+		}
+		missing = append(missing, flow)
+		// flows that couldn't be attributed by a recent rebuild won't be attributed by a new one
+		if until, ok := d.unattributed[endpointsOf(flow)]; !ok || now.After(until) {
+			rebuild = true
+		}
+	}
+	if !rebuild || now.Sub(d.lastBuild) < minRefreshPeriod {
+		return
+	}
+	// some flows could belong to sockets that were created after the last table build
+	endpoints, err := d.table.build()
+	d.lastBuild = now
+	if err != nil {
+		log.Debug("can't read the sockets' information", "error", err)
+		return
+	}
+	d.endpoints = endpoints
+	for conn, until := range d.unattributed {
+		if now.After(until) {
+			delete(d.unattributed, conn)
+		}
+	}
+	for _, flow := range missing {
+		if !d.decorateFlow(flow) {
+			d.unattributed[endpointsOf(flow)] = now.Add(unattributedTTL)
+		}
+	}
+}
+
+// decorateFlow returns false if the flow couldn't be attributed to any process
+func (d *decorator) decorateFlow(flow *ebpf.Record) bool {
+	proto := flow.Id.TransportProtocol
+	if proto != protocolTCP && proto != protocolUDP {
+		return true
+	}
+	conn := endpointsOf(flow)
+	info, ok := d.endpoints[conn.src]
+	if !ok {
+		info, ok = d.endpoints[conn.dst]
+	}
+	if !ok {
+		return false
+	}
+	if flow.Attrs.Metadata == nil {
+		flow.Attrs.Metadata = map[string]string{}
+	}
+	flow.Attrs.Metadata[AttrPID] = strconv.Itoa(int(info.pid))
+	flow.Attrs.Metadata[AttrExecName] = info.execName
+	if service, ok := d.services.Get(info.pid); ok {
+		flow.Attrs.Metadata[AttrServiceName] = service.Name
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package process
+
+import (
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+	"github.com/grafana/beyla/pkg/internal/svc"
+)
+
+const procNetHeader = "  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"
+
+// host network namespace: nginx listens in any address and port 80, and curl is connected to a remote host
+// container network namespace: an instrumented service listens in 10.244.0.3:8080
+func fakeProcFS(t *testing.T) string {
+	root := t.TempDir()
+	proc := func(pid, netNS, exe string, fds ...string) {
+		dir := path.Join(root, pid)
+		require.NoError(t, os.MkdirAll(path.Join(dir, "ns"), 0o755))
+		require.NoError(t, os.MkdirAll(path.Join(dir, "fd"), 0o755))
+		require.NoError(t, os.MkdirAll(path.Join(dir, "net"), 0o755))
+		require.NoError(t, os.Symlink("net:["+netNS+"]", path.Join(dir, "ns", "net")))
+		require.NoError(t, os.Symlink(exe, path.Join(dir, "exe")))
+		for i, fd := range fds {
+			require.NoError(t, os.Symlink(fd, path.Join(dir, "fd", string(rune('0'+i)))))
+		}
+	}
+	netFile := func(pid, name, content string) {
+		require.NoError(t, os.WriteFile(path.Join(root, pid, "net", name), []byte(procNetHeader+content), 0o644))
+	}
+	proc("1", "1111", "/usr/sbin/nginx", "/dev/null", "socket:[100]")
+	proc("2", "1111", "/usr/bin/curl (deleted)", "socket:[101]")
+	proc("3", "2222", "/app/server", "pipe:[5]", "socket:[200]")
+	require.NoError(t, os.Symlink("1", path.Join(root, "self")))
+	netFile("1", "tcp",
+		"   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 100 1 0000000000000000 100 0 0 10 0\n"+
+			"   1: 0100000A:D431 0401528C:01BB 01 00000000:00000000 00:00000000 00000000  1000        0 101 1 0000000000000000 20 4 30 10 -1\n")
+	netFile("3", "tcp",
+		"   0: 0300F40A:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 200 1 0000000000000000 100 0 0 10 0\n")
+	return root
+}
+
+func TestDecorator(t *testing.T) {
+	services := svc.NewPIDRegistry()
+	services.Add(3, &svc.ID{Name: "backend"})
+	now := time.Now()
+	dec := &decorator{
+		services: services,
+		table: &socketTable{procRoot: fakeProcFS(t), localAddrs: func() ([]net.Addr, error) {
+			return []net.Addr{
+				&net.IPNet{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(24, 32)},
+				&net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)},
+			}, nil
+		}},
+		clock:        func() time.Time { return now },
+		unattributed: map[flowEndpoints]time.Time{},
+	}
+
+	toNginx := flow("140.82.121.4", 33000, "10.0.0.1", 80, protocolTCP)
+	fromNginx := flow("127.0.0.1", 80, "127.0.0.1", 40000, protocolTCP)
+	fromCurl := flow("10.0.0.1", 54321, "140.82.121.4", 443, protocolTCP)
+	toBackend := flow("10.244.0.7", 41000, "10.244.0.3", 8080, protocolTCP)
+	udpToNginx := flow("140.82.121.4", 33000, "10.0.0.1", 80, protocolUDP)
+	unknown := flow("10.244.0.7", 41000, "10.244.0.8", 8080, protocolTCP)
+
+	dec.decorate(plog(), []*ebpf.Record{toNginx, fromNginx, fromCurl, toBackend, udpToNginx, unknown})
+
+	assert.Equal(t, map[string]string{AttrPID: "1", AttrExecName: "nginx"}, toNginx.Attrs.Metadata)
+	assert.Equal(t, map[string]string{AttrPID: "1", AttrExecName: "nginx"}, fromNginx.Attrs.Metadata)
+	assert.Equal(t, map[string]string{AttrPID: "2", AttrExecName: "curl"}, fromCurl.Attrs.Metadata)
+	assert.Equal(t, map[string]string{AttrPID: "3", AttrExecName: "server", AttrServiceName: "backend"},
+		toBackend.Attrs.Metadata)
+	assert.Empty(t, udpToNginx.Attrs.Metadata)
+	assert.Empty(t, unknown.Attrs.Metadata)
+
+	// the socket table isn't rebuilt until the refresh period has passed
+	dec.table.procRoot = t.TempDir()
+	unknown = flow("10.244.0.7", 41000, "10.244.0.8", 8080, protocolTCP)
+	toBackend = flow("10.244.0.7", 41000, "10.244.0.3", 8080, protocolTCP)
+	dec.decorate(plog(), []*ebpf.Record{unknown, toBackend})
+	assert.Empty(t, unknown.Attrs.Metadata)
+	assert.Equal(t, "3", toBackend.Attrs.Metadata[AttrPID])
+
+	// after the refresh period, the flows that weren't attributed in the last rebuild
+	// don't force a new rebuild
+	now = now.Add(minRefreshPeriod)
+	lastBuild := dec.lastBuild
+	dec.decorate(plog(), []*ebpf.Record{flow("10.244.0.7", 41000, "10.244.0.8", 8080, protocolTCP)})
+	assert.Equal(t, lastBuild, dec.lastBuild)
+
+	// but new unattributed flows do
+	dec.decorate(plog(), []*ebpf.Record{flow("10.244.0.7", 41001, "10.244.0.8", 8080, protocolTCP)})
+	assert.Equal(t, now, dec.lastBuild)
+
+	// as well as the previously unattributed flows, once their entries expire
+	now = now.Add(unattributedTTL + time.Second)
+	dec.decorate(plog(), []*ebpf.Record{flow("10.244.0.7", 41000, "10.244.0.8", 8080, protocolTCP)})
+	assert.Equal(t, now, dec.lastBuild)
+}
+
+func TestDecorator_Enabled(t *testing.T) {
+	assert.False(t, Decorator{}.Enabled())
+	assert.False(t, Decorator{AllowedAttributes: []string{"src.name", "k8s.src.namespace"}}.Enabled())
+	assert.True(t, Decorator{AllowedAttributes: []string{"src.name", "process.pid"}}.Enabled())
+	assert.True(t, Decorator{AllowedAttributes: []string{"process_executable_name"}}.Enabled())
+	assert.True(t, Decorator{AllowedAttributes: []string{"service_name"}}.Enabled())
+}
+
+func flow(srcIP string, srcPort uint16, dstIP string, dstPort uint16, protocol uint8) *ebpf.Record {
+	r := &ebpf.Record{}
+	r.Id.SrcIp.In6U.U6Addr8 = ipAddr(net.ParseIP(srcIP))
+	r.Id.DstIp.In6U.U6Addr8 = ipAddr(net.ParseIP(dstIP))
+	r.Id.SrcPort = srcPort
+	r.Id.DstPort = dstPort
+	r.Id.TransportProtocol = protocol
+	return r
+}
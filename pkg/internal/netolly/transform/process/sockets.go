@@ -0,0 +1,188 @@
+package process
+
+import (
+	"net"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+
+	"github.com/grafana/beyla/pkg/internal/netolly/ebpf"
+)
+
+const (
+	protocolTCP = 6
+	protocolUDP = 17
+)
+
+// endpoint of a socket
+type endpoint struct {
+	ip       ebpf.IPAddr
+	port     uint16
+	protocol uint8
+}
+
+type procInfo struct {
+	pid      int32
+	execName string
+}
+
+// socketTable maps the local endpoints of the sockets in the host to the processes that own them.
+// It is built from the procfs sockets' information of each network namespace, and the file
+// descriptors of each process.
+type socketTable struct {
+	procRoot string
+	// localAddrs returns the addresses of the host network namespace. They are used to expand
+	// the sockets that are bound to any address (e.g. listening sockets).
+	localAddrs func() ([]net.Addr, error)
+}
+
+func (st *socketTable) build() (map[endpoint]*procInfo, error) {
+	fs, err := procfs.NewFS(st.procRoot)
+	if err != nil {
+		return nil, err
+	}
+	procs, err := fs.AllProcs()
+	if err != nil {
+		return nil, err
+	}
+	// when a socket is shared by many processes (e.g. forked workers), it is attributed to the lowest PID
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].PID < procs[j].PID
+	})
+	hostNS, err := os.Readlink(path.Join(st.procRoot, "self", "ns", "net"))
+	if err != nil {
+		return nil, err
+	}
+	hostIPs, err := st.hostIPs()
+	if err != nil {
+		return nil, err
+	}
+
+	// the sockets of each network namespace are read only once, from any of its processes
+	sockets := map[uint64][]endpoint{}
+	namespaces := map[string]struct{}{}
+	for _, p := range procs {
+		ns, err := os.Readlink(path.Join(st.procRoot, strconv.Itoa(p.PID), "ns", "net"))
+		if err != nil {
+			continue
+		}
+		if _, ok := namespaces[ns]; ok {
+			continue
+		}
+		var anyIPs []ebpf.IPAddr
+		// sockets bound to any address can only be matched in the host network namespace,
+		// as the addresses of the other namespaces are unknown
+		if ns == hostNS {
+			anyIPs = hostIPs
+		}
+		if readSockets(path.Join(st.procRoot, strconv.Itoa(p.PID)), anyIPs, sockets) {
+			namespaces[ns] = struct{}{}
+		}
+	}
+
+	endpoints := map[endpoint]*procInfo{}
+	for _, p := range procs {
+		var info *procInfo
+		targets, err := p.FileDescriptorTargets()
+		if err != nil {
+			continue
+		}
+		for _, target := range targets {
+			inode, ok := socketInode(target)
+			if !ok {
+				continue
+			}
+			for _, ep := range sockets[inode] {
+				if _, ok := endpoints[ep]; ok {
+					continue
+				}
+				if info == nil {
+					info = &procInfo{pid: int32(p.PID), execName: execName(p)}
+				}
+				endpoints[ep] = info
+			}
+		}
+	}
+	return endpoints, nil
+}
+
+func (st *socketTable) hostIPs() ([]ebpf.IPAddr, error) {
+	addrs, err := st.localAddrs()
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]ebpf.IPAddr, 0, len(addrs))
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipAddr(ipNet.IP))
+		}
+	}
+	return ips, nil
+}
+
+// readSockets adds the TCP and UDP sockets of the network namespace of the process in the
+// provided procfs folder. It returns false if they couldn't be read (e.g. the process ended).
+func readSockets(procDir string, anyIPs []ebpf.IPAddr, sockets map[uint64][]endpoint) bool {
+	fs, err := procfs.NewFS(procDir)
+	if err != nil {
+		return false
+	}
+	add := func(lines procfs.NetIPSocket, protocol uint8) {
+		for _, line := range lines {
+			if line.Inode == 0 {
+				continue
+			}
+			if line.LocalAddr.IsUnspecified() {
+				for _, ip := range anyIPs {
+					sockets[line.Inode] = append(sockets[line.Inode],
+						endpoint{ip: ip, port: uint16(line.LocalPort), protocol: protocol})
+				}
+				continue
+			}
+			sockets[line.Inode] = append(sockets[line.Inode],
+				endpoint{ip: ipAddr(line.LocalAddr), port: uint16(line.LocalPort), protocol: protocol})
+		}
+	}
+	tcp, err := fs.NetTCP()
+	if err != nil {
+		return false
+	}
+	add(procfs.NetIPSocket(tcp), protocolTCP)
+	if tcp6, err := fs.NetTCP6(); err == nil {
+		add(procfs.NetIPSocket(tcp6), protocolTCP)
+	}
+	if udp, err := fs.NetUDP(); err == nil {
+		add(procfs.NetIPSocket(udp), protocolUDP)
+	}
+	if udp6, err := fs.NetUDP6(); err == nil {
+		add(procfs.NetIPSocket(udp6), protocolUDP)
+	}
+	return true
+}
+
+// socketInode parses file descriptor targets with the form socket:[inode]
+func socketInode(target string) (uint64, bool) {
+	if !strings.HasPrefix(target, "socket:[") || !strings.HasSuffix(target, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(target[len("socket:["):len(target)-1], 10, 64)
+	return inode, err == nil
+}
+
+func execName(p procfs.Proc) string {
+	if exe, err := p.Executable(); err == nil && exe != "" {
+		return path.Base(strings.TrimSuffix(exe, " (deleted)"))
+	}
+	comm, _ := p.Comm()
+	return comm
+}
+
+func ipAddr(ip net.IP) ebpf.IPAddr {
+	var addr ebpf.IPAddr
+	copy(addr[:], ip.To16())
+	return addr
+}
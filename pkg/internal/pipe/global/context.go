@@ -1,9 +1,11 @@
 package global
 
 import (
+	"github.com/grafana/beyla/pkg/internal/capture"
 	"github.com/grafana/beyla/pkg/internal/connector"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	kube2 "github.com/grafana/beyla/pkg/internal/kube"
+	"github.com/grafana/beyla/pkg/internal/svc"
 	"github.com/grafana/beyla/pkg/internal/transform/kube"
 )
 
@@ -22,4 +24,9 @@ type ContextInfo struct {
 	Metrics imetrics.Reporter
 	// Prometheus connection manager to coordinate metrics exposition from diverse nodes
 	Prometheus *connector.PrometheusManager
+	// Capture records the raw eBPF events, if the capture mode is enabled. Otherwise it is nil.
+	Capture *capture.Recorder
+	// InstrumentedServices stores the service of each process that is instrumented by the
+	// application observability pipeline
+	InstrumentedServices *svc.PIDRegistry
 }
@@ -8,9 +8,12 @@ import (
 
 	"github.com/grafana/beyla/pkg/beyla"
 	"github.com/grafana/beyla/pkg/internal/export/debug"
+	"github.com/grafana/beyla/pkg/internal/export/file"
 	agent "github.com/grafana/beyla/pkg/internal/export/grafana_agent"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/export/prom"
+	"github.com/grafana/beyla/pkg/internal/export/statsd"
+	"github.com/grafana/beyla/pkg/internal/export/zipkin"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
 	"github.com/grafana/beyla/pkg/internal/request"
@@ -27,14 +30,19 @@ type nodesMap struct {
 	Routes *transform.RoutesConfig `forwardTo:"Kubernetes"`
 
 	// Kubernetes is an optional node. If not set, data will be bypassed to the exporters.
-	Kubernetes transform.KubernetesDecorator `forwardTo:"Metrics,Traces,Prometheus,Printer,Noop,AgentTraces"`
-
-	AgentTraces beyla.TracesReceiverConfig
-	Metrics     otel.MetricsConfig
-	Traces      otel.TracesConfig
-	Prometheus  prom.PrometheusConfig
-	Printer     debug.PrintEnabled
-	Noop        debug.NoopEnabled
+	Kubernetes transform.KubernetesDecorator `forwardTo:"Metrics,Traces,Logs,Zipkin,FileExport,Prometheus,PrometheusRW,StatsD,Printer,Noop,AgentTraces"`
+
+	AgentTraces  beyla.TracesReceiverConfig
+	Metrics      otel.MetricsConfig
+	Traces       otel.TracesConfig
+	Logs         otel.LogsConfig
+	Zipkin       zipkin.Config
+	FileExport   file.TracesConfig
+	Prometheus   prom.PrometheusConfig
+	PrometheusRW prom.RemoteWriteConfig
+	StatsD       statsd.Config
+	Printer      debug.PrinterConfig
+	Noop         debug.NoopEnabled
 }
 
 func configToNodesMap(cfg *beyla.Config) *nodesMap {
@@ -44,10 +52,19 @@ func configToNodesMap(cfg *beyla.Config) *nodesMap {
 		Kubernetes:   cfg.Attributes.Kubernetes,
 		Metrics:      cfg.Metrics,
 		Traces:       cfg.Traces,
+		Logs:         cfg.Logs,
+		Zipkin:       cfg.Zipkin,
+		FileExport:   cfg.FileExport,
 		Prometheus:   cfg.Prometheus,
-		Printer:      cfg.Printer,
+		PrometheusRW: cfg.PrometheusRemoteWrite,
+		StatsD:       cfg.StatsD,
 		Noop:         cfg.Noop,
 		AgentTraces:  cfg.TracesReceiver,
+		Printer: debug.PrinterConfig{
+			PrintEnabled: cfg.Printer,
+			Format:       cfg.PrinterFormat,
+			Output:       cfg.PrinterOutput,
+		},
 	}
 }
 
@@ -94,7 +111,12 @@ func newGraphBuilder(ctx context.Context, config *beyla.Config, ctxInfo *global.
 	graph.RegisterMiddle(gnb, transform.KubeDecoratorProvider(ctxInfo))
 	graph.RegisterTerminal(gnb, gb.metricsReporterProvider)
 	graph.RegisterTerminal(gnb, gb.tracesReporterProvider)
+	graph.RegisterTerminal(gnb, gb.logsReporterProvider)
+	graph.RegisterTerminal(gnb, gb.zipkinProvider)
+	graph.RegisterTerminal(gnb, gb.fileExportProvider)
 	graph.RegisterTerminal(gnb, gb.prometheusProvider)
+	graph.RegisterTerminal(gnb, gb.prometheusRemoteWriteProvider)
+	graph.RegisterTerminal(gnb, gb.statsdProvider)
 	graph.RegisterTerminal(gnb, debug.NoopNode)
 	graph.RegisterTerminal(gnb, debug.PrinterNode)
 	graph.RegisterTerminal(gnb, gb.grafanaAgentTracesProvider)
@@ -115,6 +137,20 @@ func (gb *graphFunctions) buildGraph() (*Instrumenter, error) {
 	definedNodesMap.TracesReader.TracesInput = gb.tracesCh
 	definedNodesMap.Metrics.Grafana = &gb.config.Grafana.OTLP
 	definedNodesMap.Traces.Grafana = &gb.config.Grafana.OTLP
+	definedNodesMap.Metrics.AttributeSelection = gb.config.Attributes.Select
+	definedNodesMap.Prometheus.AttributeSelection = gb.config.Attributes.Select
+	definedNodesMap.PrometheusRW.AttributeSelection = gb.config.Attributes.Select
+	definedNodesMap.StatsD.AttributeSelection = gb.config.Attributes.Select
+	semConv := gb.config.Attributes.SemConvVersion
+	definedNodesMap.Metrics.SemConv = semConv
+	definedNodesMap.Traces.SemConv = semConv
+	definedNodesMap.Logs.SemConv = semConv
+	definedNodesMap.Zipkin.SemConv = semConv
+	definedNodesMap.FileExport.SemConv = semConv
+	definedNodesMap.Prometheus.SemConv = semConv
+	definedNodesMap.PrometheusRW.SemConv = semConv
+	definedNodesMap.StatsD.SemConv = semConv
+	definedNodesMap.AgentTraces.SemConv = semConv
 
 	grp, err := gb.builder.Build(definedNodesMap)
 	if err != nil {
@@ -149,6 +185,21 @@ func (gb *graphFunctions) tracesReporterProvider(config otel.TracesConfig) (node
 	return otel.ReportTraces(gb.ctx, &config, gb.ctxInfo)
 }
 
+//nolint:gocritic
+func (gb *graphFunctions) logsReporterProvider(config otel.LogsConfig) (node.TerminalFunc[[]request.Span], error) {
+	return otel.ReportLogs(gb.ctx, &config, gb.ctxInfo)
+}
+
+//nolint:gocritic
+func (gb *graphFunctions) zipkinProvider(config zipkin.Config) (node.TerminalFunc[[]request.Span], error) {
+	return zipkin.TracesEndpoint(gb.ctx, &config)
+}
+
+//nolint:gocritic
+func (gb *graphFunctions) fileExportProvider(config file.TracesConfig) (node.TerminalFunc[[]request.Span], error) {
+	return file.TracesFile(gb.ctx, &config)
+}
+
 //nolint:gocritic
 func (gb *graphFunctions) metricsReporterProvider(config otel.MetricsConfig) (node.TerminalFunc[[]request.Span], error) {
 	return otel.ReportMetrics(gb.ctx, &config, gb.ctxInfo)
@@ -159,6 +210,16 @@ func (gb *graphFunctions) prometheusProvider(config prom.PrometheusConfig) (node
 	return prom.PrometheusEndpoint(gb.ctx, &config, gb.ctxInfo)
 }
 
+//nolint:gocritic
+func (gb *graphFunctions) prometheusRemoteWriteProvider(config prom.RemoteWriteConfig) (node.TerminalFunc[[]request.Span], error) {
+	return prom.RemoteWriteEndpoint(gb.ctx, &config, gb.ctxInfo)
+}
+
+//nolint:gocritic
+func (gb *graphFunctions) statsdProvider(config statsd.Config) (node.TerminalFunc[[]request.Span], error) {
+	return statsd.MetricsEndpoint(gb.ctx, &config, gb.ctxInfo)
+}
+
 //nolint:gocritic
 func (gb *graphFunctions) grafanaAgentTracesProvider(config beyla.TracesReceiverConfig) (node.TerminalFunc[[]request.Span], error) {
 	return agent.TracesReceiver(gb.ctx, config)
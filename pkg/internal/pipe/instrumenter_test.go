@@ -11,11 +11,14 @@ import (
 	"github.com/mariomac/pipes/pkg/node"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/ptrace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.19.0"
+	trace2 "go.opentelemetry.io/otel/trace"
 
 	"github.com/grafana/beyla/pkg/beyla"
+	"github.com/grafana/beyla/pkg/internal/export/attributes"
 	"github.com/grafana/beyla/pkg/internal/export/otel"
 	"github.com/grafana/beyla/pkg/internal/imetrics"
 	"github.com/grafana/beyla/pkg/internal/pipe/global"
@@ -70,17 +73,131 @@ func TestBasicPipeline(t *testing.T) {
 		Name: "http.server.request.duration",
 		Unit: "s",
 		Attributes: map[string]string{
-			string(otel.HTTPRequestMethodKey):      "GET",
-			string(otel.HTTPResponseStatusCodeKey): "404",
-			string(otel.HTTPUrlPathKey):            "/foo/bar",
-			string(otel.ClientAddrKey):             "1.1.1.1",
-			string(semconv.ServiceNameKey):         "foo-svc",
+			"http.request.method":          "GET",
+			"http.response.status_code":    "404",
+			"url.path":                     "/foo/bar",
+			"client.address":               "1.1.1.1",
+			string(semconv.ServiceNameKey): "foo-svc",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, event)
 
 }
 
+func TestPipeline_AttributesSelection(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tc, err := collector.Start(ctx)
+	require.NoError(t, err)
+
+	gb := newGraphBuilder(ctx, &beyla.Config{
+		Metrics: otel.MetricsConfig{
+			Features:        []string{otel.FeatureApplication},
+			MetricsEndpoint: tc.ServerEndpoint, ReportTarget: true,
+			ReportPeerInfo: true, Interval: 10 * time.Millisecond,
+			ReportersCacheLen: 16,
+		},
+		Attributes: beyla.Attributes{Select: attributes.Selection{
+			attributes.SectionHTTPServer: {Exclude: []string{"url.path"}},
+		}},
+	}, gctx(), make(<-chan []request.Span))
+	// Override eBPF tracer to send some fake data
+	graph.RegisterStart(gb.builder, func(_ traces.ReadDecorator) (node.StartFunc[[]request.Span], error) {
+		return func(out chan<- []request.Span) {
+			out <- newRequest("foo-svc", 1, "GET", "/foo/bar", "1.1.1.1:3456", 404)
+			// closing prematurely the input node would finish the whole graph processing
+			// and OTEL exporters could be closed, so we wait.
+			time.Sleep(testTimeout)
+		}, nil
+	})
+	pipe, err := gb.buildGraph()
+	require.NoError(t, err)
+
+	go pipe.Run(ctx)
+
+	event := testutil.ReadChannel(t, tc.Records, testTimeout)
+	assert.Equal(t, collector.MetricRecord{
+		Name: "http.server.request.duration",
+		Unit: "s",
+		Attributes: map[string]string{
+			"http.request.method":          "GET",
+			"http.response.status_code":    "404",
+			"client.address":               "1.1.1.1",
+			string(semconv.ServiceNameKey): "foo-svc",
+		},
+		Type: pmetric.MetricTypeHistogram,
+	}, event)
+}
+
+func TestPipeline_SemConvVersion(t *testing.T) {
+	type testCase struct {
+		version attributes.SemConvVersion
+		metric  string
+		attrs   map[string]string
+	}
+	for _, tc := range []testCase{{
+		version: attributes.SemConv119,
+		metric:  "http.server.duration",
+		attrs: map[string]string{
+			"http.method":                  "GET",
+			"http.status_code":             "404",
+			"http.target":                  "/foo/bar",
+			"net.sock.peer.addr":           "1.1.1.1",
+			string(semconv.ServiceNameKey): "foo-svc",
+		},
+	}, {
+		version: attributes.SemConv123,
+		metric:  "http.server.request.duration",
+		attrs: map[string]string{
+			"http.request.method":          "GET",
+			"http.response.status_code":    "404",
+			"url.path":                     "/foo/bar",
+			"client.address":               "1.1.1.1",
+			string(semconv.ServiceNameKey): "foo-svc",
+		},
+	}} {
+		t.Run(string(tc.version), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			coll, err := collector.Start(ctx)
+			require.NoError(t, err)
+
+			gb := newGraphBuilder(ctx, &beyla.Config{
+				Metrics: otel.MetricsConfig{
+					Features:        []string{otel.FeatureApplication},
+					MetricsEndpoint: coll.ServerEndpoint, ReportTarget: true,
+					ReportPeerInfo: true, Interval: 10 * time.Millisecond,
+					ReportersCacheLen: 16,
+				},
+				Attributes: beyla.Attributes{SemConvVersion: tc.version},
+			}, gctx(), make(<-chan []request.Span))
+			// Override eBPF tracer to send some fake data
+			graph.RegisterStart(gb.builder, func(_ traces.ReadDecorator) (node.StartFunc[[]request.Span], error) {
+				return func(out chan<- []request.Span) {
+					out <- newRequest("foo-svc", 1, "GET", "/foo/bar", "1.1.1.1:3456", 404)
+					// closing prematurely the input node would finish the whole graph processing
+					// and OTEL exporters could be closed, so we wait.
+					time.Sleep(testTimeout)
+				}, nil
+			})
+			pipe, err := gb.buildGraph()
+			require.NoError(t, err)
+
+			go pipe.Run(ctx)
+
+			event := testutil.ReadChannel(t, coll.Records, testTimeout)
+			assert.Equal(t, collector.MetricRecord{
+				Name:       tc.metric,
+				Unit:       "s",
+				Attributes: tc.attrs,
+				Type:       pmetric.MetricTypeHistogram,
+			}, event)
+		})
+	}
+}
+
 func TestTracerPipeline(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -117,6 +234,60 @@ func TestTracerPipeline(t *testing.T) {
 	matchTraceEvent(t, "GET", event)
 }
 
+func TestLogsPipeline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tc, err := collector.Start(ctx)
+	require.NoError(t, err)
+
+	gb := newGraphBuilder(ctx, &beyla.Config{
+		Logs: otel.LogsConfig{
+			BatchTimeout: 10 * time.Millisecond,
+			LogsEndpoint: tc.ServerEndpoint,
+		},
+	}, gctx(), make(<-chan []request.Span))
+	// Override eBPF tracer to send some fake data
+	graph.RegisterStart(gb.builder, func(_ traces.ReadDecorator) (node.StartFunc[[]request.Span], error) {
+		return func(out chan<- []request.Span) {
+			spans := newRequest("bar-svc", 1, "GET", "/foo/bar", "1.1.1.1:3456", 404)
+			spans[0].TraceID = trace2.TraceID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+			spans[0].SpanID = trace2.SpanID{1, 2, 3, 4, 5, 6, 7, 8}
+			out <- spans
+			// closing prematurely the input node would finish the whole graph processing
+			// and OTEL exporters could be closed, so we wait.
+			time.Sleep(testTimeout)
+		}, nil
+	})
+	pipe, err := gb.buildGraph()
+	require.NoError(t, err)
+
+	go pipe.Run(ctx)
+
+	event := testutil.ReadChannel(t, tc.LogRecords, testTimeout)
+	assert.Equal(t, collector.LogRecord{
+		Body:     "GET /foo/bar 404 2ns",
+		Severity: plog.SeverityNumberInfo,
+		TraceID:  "0102030405060708090a0b0c0d0e0f10",
+		SpanID:   "0102030405060708",
+		Attributes: map[string]string{
+			"http.request.method":       "GET",
+			"http.response.status_code": "404",
+			"url.path":                  "/foo/bar",
+			"client.address":            "1.1.1.1",
+			"server.address":            getHostname(),
+			"server.port":               "8080",
+			"http.request.body.size":    "0",
+			string(otel.DurationKey):    "2e-9",
+		},
+		ResourceAttributes: map[string]string{
+			string(semconv.ServiceNameKey):          "bar-svc",
+			string(semconv.TelemetrySDKLanguageKey): "go",
+			string(semconv.TelemetrySDKNameKey):     "beyla",
+		},
+	}, event)
+}
+
 func TestTracerReceiverPipeline(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -227,10 +398,10 @@ func TestRouteConsolidation(t *testing.T) {
 		Name: "http.server.request.duration",
 		Unit: "s",
 		Attributes: map[string]string{
-			string(semconv.ServiceNameKey):         "svc-1",
-			string(otel.HTTPRequestMethodKey):      "GET",
-			string(otel.HTTPResponseStatusCodeKey): "200",
-			string(semconv.HTTPRouteKey):           "/user/{id}",
+			string(semconv.ServiceNameKey): "svc-1",
+			"http.request.method":          "GET",
+			"http.response.status_code":    "200",
+			string(semconv.HTTPRouteKey):   "/user/{id}",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, events["/user/{id}"])
@@ -239,10 +410,10 @@ func TestRouteConsolidation(t *testing.T) {
 		Name: "http.server.request.duration",
 		Unit: "s",
 		Attributes: map[string]string{
-			string(semconv.ServiceNameKey):         "svc-1",
-			string(otel.HTTPRequestMethodKey):      "GET",
-			string(otel.HTTPResponseStatusCodeKey): "200",
-			string(semconv.HTTPRouteKey):           "/products/{id}/push",
+			string(semconv.ServiceNameKey): "svc-1",
+			"http.request.method":          "GET",
+			"http.response.status_code":    "200",
+			string(semconv.HTTPRouteKey):   "/products/{id}/push",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, events["/products/{id}/push"])
@@ -251,10 +422,10 @@ func TestRouteConsolidation(t *testing.T) {
 		Name: "http.server.request.duration",
 		Unit: "s",
 		Attributes: map[string]string{
-			string(semconv.ServiceNameKey):         "svc-1",
-			string(otel.HTTPRequestMethodKey):      "GET",
-			string(otel.HTTPResponseStatusCodeKey): "200",
-			string(semconv.HTTPRouteKey):           "/**",
+			string(semconv.ServiceNameKey): "svc-1",
+			"http.request.method":          "GET",
+			"http.response.status_code":    "200",
+			string(semconv.HTTPRouteKey):   "/**",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, events["/**"])
@@ -297,7 +468,7 @@ func TestGRPCPipeline(t *testing.T) {
 			string(semconv.RPCSystemKey):         "grpc",
 			string(semconv.RPCGRPCStatusCodeKey): "3",
 			string(semconv.RPCMethodKey):         "/foo/bar",
-			string(otel.ClientAddrKey):           "1.1.1.1",
+			"client.address":                     "1.1.1.1",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, event)
@@ -365,11 +536,11 @@ func TestBasicPipelineInfo(t *testing.T) {
 		Name: "http.server.request.duration",
 		Unit: "s",
 		Attributes: map[string]string{
-			string(otel.HTTPRequestMethodKey):      "PATCH",
-			string(otel.HTTPResponseStatusCodeKey): "204",
-			string(otel.HTTPUrlPathKey):            "/aaa/bbb",
-			string(otel.ClientAddrKey):             "1.1.1.1",
-			string(semconv.ServiceNameKey):         "comm",
+			"http.request.method":          "PATCH",
+			"http.response.status_code":    "204",
+			"url.path":                     "/aaa/bbb",
+			"client.address":               "1.1.1.1",
+			string(semconv.ServiceNameKey): "comm",
 		},
 		Type: pmetric.MetricTypeHistogram,
 	}, event)
@@ -463,15 +634,15 @@ func matchTraceEvent(t require.TestingT, name string, event collector.TraceRecor
 	assert.Equal(t, collector.TraceRecord{
 		Name: name,
 		Attributes: map[string]string{
-			string(otel.HTTPRequestMethodKey):      "GET",
-			string(otel.HTTPResponseStatusCodeKey): "404",
-			string(otel.HTTPUrlPathKey):            "/foo/bar",
-			string(otel.ClientAddrKey):             "1.1.1.1",
-			string(otel.ServerAddrKey):             getHostname(),
-			string(otel.ServerPortKey):             "8080",
-			string(otel.HTTPRequestBodySizeKey):    "0",
-			"span_id":                              event.Attributes["span_id"],
-			"parent_span_id":                       event.Attributes["parent_span_id"],
+			"http.request.method":       "GET",
+			"http.response.status_code": "404",
+			"url.path":                  "/foo/bar",
+			"client.address":            "1.1.1.1",
+			"server.address":            getHostname(),
+			"server.port":               "8080",
+			"http.request.body.size":    "0",
+			"span_id":                   event.Attributes["span_id"],
+			"parent_span_id":            event.Attributes["parent_span_id"],
 		},
 		ResourceAttributes: map[string]string{
 			string(semconv.ServiceNameKey):          "bar-svc",
@@ -506,9 +677,9 @@ func matchGRPCTraceEvent(t *testing.T, name string, event collector.TraceRecord)
 			string(semconv.RPCSystemKey):         "grpc",
 			string(semconv.RPCGRPCStatusCodeKey): "3",
 			string(semconv.RPCMethodKey):         "foo.bar",
-			string(otel.ClientAddrKey):           "1.1.1.1",
-			string(otel.ServerAddrKey):           "127.0.0.1",
-			string(otel.ServerPortKey):           "8080",
+			"client.address":                     "1.1.1.1",
+			"server.address":                     "127.0.0.1",
+			"server.port":                        "8080",
 			"span_id":                            event.Attributes["span_id"],
 			"parent_span_id":                     event.Attributes["parent_span_id"],
 		},
@@ -539,12 +710,12 @@ func matchInnerGRPCTraceEvent(t *testing.T, name string, event collector.TraceRe
 
 func matchNestedEvent(t *testing.T, name, method, target, status string, kind ptrace.SpanKind, event collector.TraceRecord) {
 	assert.Equal(t, name, event.Name)
-	assert.Equal(t, method, event.Attributes[string(otel.HTTPRequestMethodKey)])
-	assert.Equal(t, status, event.Attributes[string(otel.HTTPResponseStatusCodeKey)])
+	assert.Equal(t, method, event.Attributes["http.request.method"])
+	assert.Equal(t, status, event.Attributes["http.response.status_code"])
 	if kind == ptrace.SpanKindClient {
-		assert.Equal(t, target, event.Attributes[string(otel.HTTPUrlFullKey)])
+		assert.Equal(t, target, event.Attributes["url.full"])
 	} else {
-		assert.Equal(t, target, event.Attributes[string(otel.HTTPUrlPathKey)])
+		assert.Equal(t, target, event.Attributes["url.path"])
 	}
 	assert.Equal(t, kind, event.Kind)
 }
@@ -569,15 +740,15 @@ func matchInfoEvent(t *testing.T, name string, event collector.TraceRecord) {
 	assert.Equal(t, collector.TraceRecord{
 		Name: name,
 		Attributes: map[string]string{
-			string(otel.HTTPRequestMethodKey):      "PATCH",
-			string(otel.HTTPResponseStatusCodeKey): "204",
-			string(otel.HTTPUrlPathKey):            "/aaa/bbb",
-			string(otel.ClientAddrKey):             "1.1.1.1",
-			string(otel.ServerAddrKey):             getHostname(),
-			string(otel.ServerPortKey):             "8080",
-			string(otel.HTTPRequestBodySizeKey):    "0",
-			"span_id":                              event.Attributes["span_id"],
-			"parent_span_id":                       "",
+			"http.request.method":       "PATCH",
+			"http.response.status_code": "204",
+			"url.path":                  "/aaa/bbb",
+			"client.address":            "1.1.1.1",
+			"server.address":            getHostname(),
+			"server.port":               "8080",
+			"http.request.body.size":    "0",
+			"span_id":                   event.Attributes["span_id"],
+			"parent_span_id":            "",
 		},
 		ResourceAttributes: map[string]string{
 			string(semconv.ServiceNameKey):          "comm",
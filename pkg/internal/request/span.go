@@ -75,6 +75,12 @@ func (s *Span) Inside(parent *Span) bool {
 	return s.RequestStart >= parent.RequestStart && s.End <= parent.End
 }
 
+// Sampled returns true if the span carries a valid trace ID and the trace flags
+// mark it as sampled.
+func (s *Span) Sampled() bool {
+	return s.TraceID.IsValid() && trace2.TraceFlags(s.Flags).IsSampled()
+}
+
 type Timings struct {
 	RequestStart time.Time
 	Start        time.Time
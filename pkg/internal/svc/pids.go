@@ -0,0 +1,45 @@
+package svc
+
+import "sync"
+
+// PIDRegistry keeps the service of each instrumented process, so other Beyla components
+// (e.g. the network flows decorator) can know which service is running behind a given PID.
+// It is safe for concurrent use. A nil registry does not store anything.
+type PIDRegistry struct {
+	mt   sync.RWMutex
+	pids map[int32]ID
+}
+
+func NewPIDRegistry() *PIDRegistry {
+	return &PIDRegistry{pids: map[int32]ID{}}
+}
+
+// Add the service of the provided PID, overriding any previous entry
+func (r *PIDRegistry) Add(pid int32, id *ID) {
+	if r == nil {
+		return
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	r.pids[pid] = *id
+}
+
+func (r *PIDRegistry) Remove(pid int32) {
+	if r == nil {
+		return
+	}
+	r.mt.Lock()
+	defer r.mt.Unlock()
+	delete(r.pids, pid)
+}
+
+// Get the service of the provided PID, if it is instrumented
+func (r *PIDRegistry) Get(pid int32) (ID, bool) {
+	if r == nil {
+		return ID{}, false
+	}
+	r.mt.RLock()
+	defer r.mt.RUnlock()
+	id, ok := r.pids[pid]
+	return id, ok
+}
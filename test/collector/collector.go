@@ -11,6 +11,7 @@ import (
 	"os"
 
 	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"go.opentelemetry.io/collector/pdata/ptrace"
@@ -25,6 +26,7 @@ type TestCollector struct {
 	// TODO: add also traces history
 	Records      chan MetricRecord
 	TraceRecords chan TraceRecord
+	LogRecords   chan LogRecord
 }
 
 var log *slog.Logger
@@ -41,6 +43,7 @@ func Start(ctx context.Context) (*TestCollector, error) {
 	tc := TestCollector{
 		Records:      make(chan MetricRecord, 100),
 		TraceRecords: make(chan TraceRecord, 100),
+		LogRecords:   make(chan LogRecord, 100),
 	}
 	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		body, err := io.ReadAll(request.Body)
@@ -57,6 +60,10 @@ func Start(ctx context.Context) (*TestCollector, error) {
 			tc.traceEvent(writer, body)
 			return
 		}
+		if request.URL.Path == "/v1/logs" {
+			tc.logEvent(writer, body)
+			return
+		}
 		slog.Info("unknown path " + request.URL.String())
 		writer.WriteHeader(http.StatusNotFound)
 	}))
@@ -119,6 +126,43 @@ func (tc *TestCollector) traceEvent(writer http.ResponseWriter, body []byte) {
 	})
 }
 
+func (tc *TestCollector) logEvent(writer http.ResponseWriter, body []byte) {
+	// the logs export request has the same wire format as the LogsData message
+	logs, err := (&plog.ProtoUnmarshaler{}).UnmarshalLogs(body)
+	if err != nil {
+		log.Error("unmarshalling protobuf event", err)
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+
+	forEach[plog.ResourceLogs](logs.ResourceLogs(), func(rl plog.ResourceLogs) {
+		forEach[plog.ScopeLogs](rl.ScopeLogs(), func(sl plog.ScopeLogs) {
+			forEach[plog.LogRecord](sl.LogRecords(), func(l plog.LogRecord) {
+				lr := LogRecord{
+					Body:               l.Body().AsString(),
+					Severity:           l.SeverityNumber(),
+					TraceID:            l.TraceID().String(),
+					SpanID:             l.SpanID().String(),
+					Attributes:         map[string]string{},
+					ResourceAttributes: map[string]string{},
+				}
+				l.Attributes().Range(func(k string, v pcommon.Value) bool {
+					lr.Attributes[k] = v.AsString()
+					return true
+				})
+				rl.Resource().Attributes().Range(func(k string, v pcommon.Value) bool {
+					lr.ResourceAttributes[k] = v.AsString()
+					return true
+				})
+				// remove ServiceInstanceIDKey to avoid flakiness
+				delete(lr.ResourceAttributes, string(semconv.ServiceInstanceIDKey))
+				tc.LogRecords <- lr
+			})
+		})
+	})
+}
+
 func (tc *TestCollector) metricEvent(writer http.ResponseWriter, body []byte) {
 	req := pmetricotlp.NewExportRequest()
 	if err := req.UnmarshalProto(body); err != nil {
@@ -171,6 +215,15 @@ type TraceRecord struct {
 	Kind               ptrace.SpanKind
 }
 
+type LogRecord struct {
+	ResourceAttributes map[string]string
+	Attributes         map[string]string
+	Body               string
+	Severity           plog.SeverityNumber
+	TraceID            string
+	SpanID             string
+}
+
 type slice[T any] interface {
 	At(int) T
 	Len() int